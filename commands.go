@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"hotbrandon/go-cron-be/internal/metrics"
+	"hotbrandon/go-cron-be/internal/notify"
+	"hotbrandon/go-cron-be/internal/scheduler"
+	"hotbrandon/go-cron-be/internal/secret"
+	"hotbrandon/go-cron-be/internal/vault"
+)
+
+// runCommand executes a CLI subcommand and returns the process exit code.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "validate-schedule":
+		return validateScheduleCommand(args[1:])
+	case "encrypt-config":
+		return encryptConfigCommand(args[1:])
+	case "healthcheck":
+		return healthcheckCommand(args[1:])
+	case "service":
+		return serviceCommand(args[1:])
+	case "pause-schedule":
+		return scheduleCommand("pause", args[1:])
+	case "resume-schedule":
+		return scheduleCommand("resume", args[1:])
+	case "simulate":
+		return simulateCommand(args[1:])
+	case "check-config":
+		return checkConfigCommand(args[1:])
+	case "run-job":
+		return runJobCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		fmt.Fprintln(os.Stderr, "usage: go-cron-be [validate-schedule <spec> [n] | encrypt-config <NAME> | healthcheck | service install|uninstall|run | pause-schedule <name> | resume-schedule <name> | simulate <from> <to> | check-config | run-job <job_name> <date>]")
+		return 2
+	}
+}
+
+// validateScheduleCommand prints the next n fire times of a cron expression.
+func validateScheduleCommand(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, `usage: go-cron-be validate-schedule "<spec>" [n]`)
+		return 2
+	}
+
+	n := 5
+	if len(args) == 2 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid n %q: %v\n", args[1], err)
+			return 2
+		}
+		n = parsed
+	}
+
+	preview, err := scheduler.PreviewSchedule(args[0], time.Now(), n, time.Local)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("%s (%s)\n", preview.Spec, preview.Timezone)
+	for _, w := range preview.Warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+	for _, t := range preview.NextRuns {
+		fmt.Println(t.Format(time.RFC3339))
+	}
+	return 0
+}
+
+// healthcheckCommand probes the local /readyz endpoint so the image needs no
+// curl for its HEALTHCHECK. It exits 0 when ready and 1 otherwise.
+func healthcheckCommand(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: go-cron-be healthcheck")
+		return 2
+	}
+
+	base, client, err := localAPI()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	target := base + "/readyz"
+
+	resp, err := client.Get(target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s: %s %s\n", target, resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+	return 0
+}
+
+// scheduleCommand pauses or resumes a schedule through the local API, e.g.
+// to stop golf job creation during an Oracle migration. The change is
+// stored in MySQL, so it applies to every instance.
+func scheduleCommand(action string, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: go-cron-be %s-schedule <name>\n", action)
+		return 2
+	}
+
+	base, client, err := localAPI()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	req, err := http.NewRequest(http.MethodPost, base+"/schedules/"+url.PathEscape(args[0])+"/"+action, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	actor := "cli"
+	if user := os.Getenv("USER"); user != "" {
+		actor = "cli:" + user
+	}
+	req.Header.Set("X-Actor", actor)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+	fmt.Printf("schedule %s %sd\n", args[0], action)
+	return 0
+}
+
+// localAPI returns the base URL of this host's API server and a client for
+// it, for commands that talk to the running service.
+func localAPI() (string, *http.Client, error) {
+	host, port, err := net.SplitHostPort(apiAddr())
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid API_ADDR: %w", err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	scheme := "http"
+	client := &http.Client{Timeout: 5 * time.Second}
+	if os.Getenv("API_TLS_CERT") != "" {
+		// The certificate is issued for the service name, not 127.0.0.1,
+		// and these commands only talk to the local process.
+		scheme = "https"
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	return scheme + "://" + net.JoinHostPort(host, port), client, nil
+}
+
+// encryptConfigCommand reads a value from stdin, so it stays out of shell
+// history, and prints the NAME=enc:v1:... line to put in the .env file.
+func encryptConfigCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: go-cron-be encrypt-config <NAME> < value")
+		return 2
+	}
+
+	vaultClient, err := vault.NewClientFromEnv(slog.Default())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	key, err := configKey(vaultClient)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	sealed, err := secret.Encrypt(key, args[0], strings.TrimRight(string(value), "\r\n"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s=%s\n", args[0], sealed)
+	return 0
+}
+
+// simulateCommand fast-forwards through the schedules of the environment
+// from one date to another, both YYYY-MM-DD and inclusive, and prints the
+// jobs they would enqueue, without touching MySQL. Duplicates that
+// unique_job absorbs are only counted, unless -v is given.
+func simulateCommand(args []string) int {
+	verbose := len(args) > 0 && args[0] == "-v"
+	if verbose {
+		args = args[1:]
+	}
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: go-cron-be simulate [-v] <from> <to>")
+		return 2
+	}
+	from, err := time.ParseInLocation("2006-01-02", args[0], time.Local)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid from %q: %v\n", args[0], err)
+		return 2
+	}
+	to, err := time.ParseInLocation("2006-01-02", args[1], time.Local)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid to %q: %v\n", args[1], err)
+		return 2
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	sim, err := scheduler.NewScheduler(nil, logger).Simulate(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("simulating %s to %s (%s)\n", args[0], args[1], sim.Timezone)
+	for _, job := range sim.Jobs {
+		if job.Outcome == scheduler.SimDeduplicated && !verbose {
+			continue
+		}
+		line := fmt.Sprintf("%s %-12s %s %s %s %s", job.At.Format(time.RFC3339), job.Outcome, job.Schedule, job.JobName, job.JobDate, job.Params)
+		if job.Blackout != "" {
+			line += " blackout=" + job.Blackout
+		}
+		if job.RunAt != nil {
+			line += " runs_at=" + job.RunAt.Format(time.RFC3339)
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+	for _, sum := range sim.Summaries {
+		fmt.Printf("%s: %d firings, %d created, %d deduplicated, %d held, %d skipped\n",
+			sum.Schedule, sum.Firings, sum.Created, sum.Deduplicated, sum.Held, sum.Skipped)
+	}
+	return 0
+}
+
+// checkConfigCommand validates the configuration the way the server does at
+// startup, without connecting to any database, so a deployment can be
+// checked before it is rolled out.
+func checkConfigCommand(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: go-cron-be check-config")
+		return 2
+	}
+
+	if err := loadSecrets(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	if errs := validateConfig(logger); len(errs) > 0 {
+		printConfigErrors(os.Stderr, errs)
+		return 1
+	}
+	fmt.Println("configuration OK")
+	return 0
+}
+
+// loadSecrets decrypts enc:v1: values and loads Vault secrets into the
+// environment once, as serve does at startup.
+func loadSecrets() error {
+	vaultClient, err := vault.NewClientFromEnv(slog.Default())
+	if err != nil {
+		return fmt.Errorf("invalid Vault configuration: %w", err)
+	}
+	if _, err := decryptConfig(vaultClient); err != nil {
+		return fmt.Errorf("failed to decrypt configuration: %w", err)
+	}
+	if vaultClient != nil && vaultClient.ReadsSecrets() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := vaultClient.Load(ctx); err != nil {
+			return fmt.Errorf("failed to load secrets from Vault: %w", err)
+		}
+	}
+	return nil
+}
+
+// runJobCommand creates and runs jobs in this process, one per date of a
+// YYYY-MM-DD date or from..to range, for ad-hoc runs and backfills that
+// should not wait for the dispatcher. Dates that already have the job are
+// skipped. With PUSHGATEWAY_URL set the run metrics are pushed afterwards,
+// since Prometheus never scrapes this process.
+func runJobCommand(args []string) int {
+	dryRun := len(args) > 0 && args[0] == "-dry-run"
+	if dryRun {
+		args = args[1:]
+	}
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprintln(os.Stderr, `usage: go-cron-be run-job [-dry-run] <job_name> <date|from..to> ['{"params": ...}']`)
+		return 2
+	}
+	jobName := args[0]
+	dates, err := jobDates(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var params json.RawMessage
+	if len(args) == 3 {
+		params = json.RawMessage(args[2])
+	}
+
+	if err := loadSecrets(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	sink, prom, err := metrics.FromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid metrics configuration:", err)
+		return 1
+	}
+	pushgateway, err := metrics.PushgatewayFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if pushgateway != nil && prom == nil {
+		prom = metrics.NewPrometheus()
+		sink = metrics.Multi{sink, prom}
+	}
+	notifier, err := notify.FromEnv(slog.Default())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid notification configuration:", err)
+		return 1
+	}
+	defer notify.Close(notifier)
+
+	db, err := openMySQL("MYSQL_DSN")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	sched := scheduler.NewScheduler(db, slog.Default(), scheduler.WithNotifier(notifier), scheduler.WithMetrics(sink))
+	var status, message string
+	sched.AddHook(scheduler.AfterRun, func(ctx context.Context, ev scheduler.RunEvent) {
+		status, message = ev.Status, ev.Message
+	})
+	// registers the cron entries too, but the cron is never started
+	if err := sched.RegisterJobs(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	code := 0
+	for _, date := range dates {
+		jobID, created, err := sched.TriggerJob(ctx, jobName, date, params, 0, dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", date, err)
+			return 1
+		}
+		if !created {
+			fmt.Printf("%s: job already exists, skipped\n", date)
+			continue
+		}
+
+		status, message = "", ""
+		if err := sched.RunJob(ctx, jobID); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: running job %d: %v\n", date, jobID, err)
+			code = 1
+			continue
+		}
+		if status == "" {
+			// claimed by a worker or held back by a concurrency limit
+			fmt.Printf("%s: job %d left to the dispatcher\n", date, jobID)
+			continue
+		}
+		fmt.Printf("%s: job %d %s: %s\n", date, jobID, status, message)
+		if status != "finished" {
+			code = 1
+		}
+	}
+
+	if pushgateway != nil {
+		hostname, _ := os.Hostname()
+		grouping := []metrics.Tag{metrics.T("instance", hostname), metrics.T("job_name", jobName)}
+		pushCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := pushgateway.Push(pushCtx, grouping, prom); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// jobDates expands a YYYY-MM-DD date or an inclusive from..to range.
+func jobDates(raw string) ([]string, error) {
+	fromRaw, toRaw, isRange := strings.Cut(raw, "..")
+	if !isRange {
+		toRaw = fromRaw
+	}
+	from, err := time.Parse("2006-01-02", fromRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %v", fromRaw, err)
+	}
+	to, err := time.Parse("2006-01-02", toRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %v", toRaw, err)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("date range %s ends before it starts", raw)
+	}
+
+	var dates []string
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+	return dates, nil
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"hotbrandon/go-cron-be/internal/scheduler"
+)
+
+// handleListAlerts returns the latest escalation alerts, newest first; with
+// ?open=true only those neither acknowledged nor resolved.
+func (s *Server) handleListAlerts(w http.ResponseWriter, r *http.Request) {
+	open, _ := strconv.ParseBool(r.URL.Query().Get("open"))
+	alerts, err := s.sched.ListAlerts(r.Context(), open)
+	if err != nil {
+		s.log(r).Error("Failed to list alerts", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("listing alerts failed"))
+		return
+	}
+	alerts = slices.DeleteFunc(alerts, func(a scheduler.Alert) bool {
+		return !allowedTenant(r, s.sched.TenantOf(a.JobName))
+	})
+	s.writeJSON(w, http.StatusOK, alerts)
+}
+
+// handleAcknowledgeAlert stops the escalation of the alert in the path,
+// recording the caller as the one who acknowledged it. Other tenants' alerts
+// are reported as missing.
+func (s *Server) handleAcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errors.New("invalid alert id"))
+		return
+	}
+
+	alert, err := s.sched.GetAlert(r.Context(), id)
+	if errors.Is(err, scheduler.ErrUnknownAlert) || (err == nil && !allowedTenant(r, s.sched.TenantOf(alert.JobName))) {
+		s.writeError(w, http.StatusNotFound, errors.New("alert not found"))
+		return
+	}
+	if err == nil {
+		alert, err = s.sched.AcknowledgeAlert(r.Context(), id, actor(r))
+	}
+	if err != nil {
+		s.log(r).Error("Failed to acknowledge alert", "alert_id", id, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("acknowledging alert failed"))
+		return
+	}
+	s.audit(r, "alert.ack", "alert:"+strconv.FormatInt(id, 10), nil)
+	s.writeJSON(w, http.StatusOK, alert)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"hotbrandon/go-cron-be/internal/envlist"
+)
+
+// AuthConfig authenticates callers of the mutating endpoints, see
+// AuthFromEnv.
+type AuthConfig struct {
+	mtls       bool
+	token      string
+	privileged []string
+}
+
+// AuthFromEnv configures who may call the API's mutating endpoints, every
+// route other than GET and HEAD: enqueueing, retrying, schedules, scripts,
+// webhooks and alert acknowledgements. Under mutual TLS (tlsConfig with
+// client CAs) every caller has a verified certificate; when
+// API_TRIGGER_TOKEN is set it must also be sent as "Authorization: Bearer
+// <token>". With neither it returns nil and those endpoints are refused.
+// Privileged job types, see scheduler.Privileged, can only be triggered or
+// scheduled when listed in API_TRIGGER_PRIVILEGED_JOBS.
+func AuthFromEnv(tlsConfig *tls.Config) (*AuthConfig, error) {
+	cfg := &AuthConfig{
+		mtls:       tlsConfig != nil && tlsConfig.ClientCAs != nil,
+		token:      os.Getenv("API_TRIGGER_TOKEN"),
+		privileged: envlist.Split(os.Getenv("API_TRIGGER_PRIVILEGED_JOBS")),
+	}
+	if !cfg.mtls && cfg.token == "" {
+		if len(cfg.privileged) > 0 {
+			return nil, errors.New("API_TRIGGER_PRIVILEGED_JOBS requires API_TLS_CLIENT_CA or API_TRIGGER_TOKEN")
+		}
+		return nil, nil
+	}
+	return cfg, nil
+}
+
+// EnableWrites serves the mutating endpoints to callers authenticated by
+// cfg; while it is not called, or cfg is nil, they answer 403. Call it
+// before Handler.
+func (s *Server) EnableWrites(cfg *AuthConfig) {
+	s.auth = cfg
+}
+
+// allowedJobType reports whether jobName may be triggered or scheduled
+// through the API: privileged job types only when listed in
+// API_TRIGGER_PRIVILEGED_JOBS.
+func (s *Server) allowedJobType(jobName string) bool {
+	return !s.sched.Privileged(jobName) || (s.auth != nil && slices.Contains(s.auth.privileged, jobName))
+}
+
+// requireAuth lets reads through and authenticates every other request
+// against s.auth.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.auth == nil {
+			s.writeError(w, http.StatusForbidden, errors.New("changes through the API are disabled, see API_TLS_CLIENT_CA and API_TRIGGER_TOKEN"))
+			return
+		}
+		if s.auth.mtls && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			s.writeError(w, http.StatusUnauthorized, errors.New("client certificate required"))
+			return
+		}
+		if s.auth.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.auth.token)) != 1 {
+				s.writeError(w, http.StatusUnauthorized, errors.New("a valid bearer token is required"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
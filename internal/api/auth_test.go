@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hotbrandon/go-cron-be/internal/scheduler"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sched := scheduler.NewScheduler(nil, logger, scheduler.WithJobStore(scheduler.NewMemoryJobStore()))
+	return NewServer(sched, logger)
+}
+
+// serve sends a request with the given bearer token, if any, to handler.
+func serve(handler http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// mutatingRoutes are requests that must not reach their handler without
+// authentication.
+var mutatingRoutes = []struct{ method, path string }{
+	{http.MethodPost, "/jobs"},
+	{http.MethodPost, "/jobs/retry"},
+	{http.MethodPost, "/schedules"},
+	{http.MethodDelete, "/schedules/nightly"},
+	{http.MethodPost, "/schedules/nightly/pause"},
+	{http.MethodPost, "/schedules/nightly/resume"},
+	{http.MethodPost, "/webhooks"},
+	{http.MethodDelete, "/webhooks/1"},
+	{http.MethodPut, "/scripts/check"},
+	{http.MethodDelete, "/scripts/check"},
+	{http.MethodPost, "/alerts/1/ack"},
+}
+
+func TestMutatingRoutesDisabledWithoutAuth(t *testing.T) {
+	t.Setenv("API_TRIGGER_TOKEN", "")
+	t.Setenv("API_TRIGGER_PRIVILEGED_JOBS", "")
+	auth, err := AuthFromEnv(nil)
+	if err != nil || auth != nil {
+		t.Fatalf("AuthFromEnv = %v, %v, want nil", auth, err)
+	}
+	s := newTestServer(t)
+	s.EnableWrites(auth)
+	for _, route := range mutatingRoutes {
+		if rec := serve(s.Handler(), route.method, route.path, "", "{}"); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s = %d, want 403", route.method, route.path, rec.Code)
+		}
+	}
+}
+
+func TestMutatingRoutesRequireToken(t *testing.T) {
+	t.Setenv("API_TRIGGER_TOKEN", "s3cret")
+	t.Setenv("API_TRIGGER_PRIVILEGED_JOBS", "")
+	auth, err := AuthFromEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	s.EnableWrites(auth)
+	for _, route := range mutatingRoutes {
+		for _, token := range []string{"", "wrong"} {
+			if rec := serve(s.Handler(), route.method, route.path, token, "{}"); rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with token %q = %d, want 401", route.method, route.path, token, rec.Code)
+			}
+		}
+	}
+
+	// With the token the request reaches the handler, which rejects the
+	// invalid id itself.
+	if rec := serve(s.Handler(), http.MethodDelete, "/webhooks/x", "s3cret", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("DELETE /webhooks/x with the token = %d, want 400 from the handler", rec.Code)
+	}
+}
+
+func TestMutatingRoutesRequireClientCertUnderMTLS(t *testing.T) {
+	t.Setenv("API_TRIGGER_TOKEN", "")
+	auth, err := AuthFromEnv(&tls.Config{ClientCAs: x509.NewCertPool()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	s.EnableWrites(auth)
+	if rec := serve(s.Handler(), http.MethodPost, "/jobs/retry", "", "{}"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /jobs/retry without a certificate = %d, want 401", rec.Code)
+	}
+}
+
+func TestAuthFromEnvPrivilegedNeedsAuth(t *testing.T) {
+	t.Setenv("API_TRIGGER_TOKEN", "")
+	t.Setenv("API_TRIGGER_PRIVILEGED_JOBS", "sql")
+	if _, err := AuthFromEnv(nil); err == nil {
+		t.Error("API_TRIGGER_PRIVILEGED_JOBS without authentication was accepted")
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"hotbrandon/go-cron-be/internal/envlist"
+)
+
+const (
+	defaultCORSMethods = "GET, POST, PUT, PATCH, DELETE"
+	defaultCORSHeaders = "Content-Type, Authorization, X-Request-ID, X-Actor"
+	defaultCORSMaxAge  = 10 * time.Minute
+)
+
+// CORSFromEnv returns a middleware that lets the admin frontend call the API
+// from another origin. API_CORS_ORIGINS lists the allowed origins ("*" for
+// any); API_CORS_METHODS, API_CORS_HEADERS, API_CORS_MAX_AGE and
+// API_CORS_CREDENTIALS tune the response. It returns nil when no origins are
+// configured.
+func CORSFromEnv() (Middleware, error) {
+	origins := envlist.Split(os.Getenv("API_CORS_ORIGINS"))
+	if len(origins) == 0 {
+		return nil, nil
+	}
+
+	c := &cors{
+		origins: origins,
+		methods: joinList(os.Getenv("API_CORS_METHODS"), defaultCORSMethods),
+		headers: joinList(os.Getenv("API_CORS_HEADERS"), defaultCORSHeaders),
+		maxAge:  strconv.Itoa(int(defaultCORSMaxAge.Seconds())),
+	}
+	if raw := os.Getenv("API_CORS_MAX_AGE"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid API_CORS_MAX_AGE %q", raw)
+		}
+		c.maxAge = strconv.Itoa(int(d.Seconds()))
+	}
+	if raw := os.Getenv("API_CORS_CREDENTIALS"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API_CORS_CREDENTIALS %q", raw)
+		}
+		c.credentials = v
+	}
+	if c.credentials && slices.Contains(origins, "*") {
+		return nil, errors.New(`API_CORS_CREDENTIALS cannot be combined with API_CORS_ORIGINS="*"`)
+	}
+	return c.wrap, nil
+}
+
+type cors struct {
+	origins     []string
+	methods     string
+	headers     string
+	maxAge      string
+	credentials bool
+}
+
+func (c *cors) allowed(origin string) bool {
+	return slices.Contains(c.origins, "*") || slices.Contains(c.origins, origin)
+}
+
+func (c *cors) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !c.allowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if c.credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, X-Request-ID")
+
+		// Answer preflights here; the mux has no OPTIONS routes.
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", c.methods)
+			w.Header().Set("Access-Control-Allow-Headers", c.headers)
+			w.Header().Set("Access-Control-Max-Age", c.maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func joinList(raw, fallback string) string {
+	if items := envlist.Split(raw); len(items) > 0 {
+		return strings.Join(items, ", ")
+	}
+	return fallback
+}
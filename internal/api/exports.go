@@ -0,0 +1,44 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// handleFuneralInvoicesXLSX returns the funeral invoice workbook for ?date=,
+// for callers with access to the export job's tenant. It is built from the
+// invoices funeral_invoice_import has already copied to MySQL, so a GET never
+// runs the ERP extract procedure; dates not imported yet are reported as
+// missing.
+func (s *Server) handleFuneralInvoicesXLSX(w http.ResponseWriter, r *http.Request) {
+	if !allowedTenant(r, s.sched.TenantOf("funeral_invoice_xlsx")) {
+		s.writeError(w, http.StatusForbidden, errTenantForbidden)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if _, err := time.ParseInLocation("2006-01-02", date, time.Local); err != nil {
+		s.writeError(w, http.StatusBadRequest, errors.New("date must be YYYY-MM-DD"))
+		return
+	}
+
+	// Render to memory first so a failure can still be reported as JSON.
+	var buf bytes.Buffer
+	count, err := s.sched.WriteImportedFuneralInvoicesXLSX(r.Context(), &buf, date)
+	if err != nil {
+		s.log(r).Error("Failed to export funeral invoices", "date", date, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("exporting funeral invoices failed"))
+		return
+	}
+	if count == 0 {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("no funeral invoices imported for %s", date))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="funeral_invoices_%s.xlsx"`, date))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// handleHealthz is the liveness probe: the process is up and the cron loop
+// is ticking. It does not touch the database.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !s.sched.Alive(time.Minute) {
+		s.writeError(w, http.StatusServiceUnavailable, errors.New("scheduler loop stalled"))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz is the readiness probe, also used by the healthcheck
+// subcommand.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	if err := s.sched.Ready(ctx); err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "leader": s.sched.IsLeader()})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hotbrandon/go-cron-be/internal/envlist"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/scheduler"
+)
+
+// jobFilter reads the job filters shared by the job list and bulk endpoints:
+// ?job_name=, ?status= (repeatable or comma-separated), ?from= and ?to= on
+// job_date, ?site=, ?param.<key>= on job_params fields (e.g.
+// ?param.db_id=TH) and ?tenant=, scoped to the caller's tenants.
+func jobFilter(r *http.Request) (scheduler.JobFilter, error) {
+	q := r.URL.Query()
+	f := scheduler.JobFilter{
+		JobName:  q.Get("job_name"),
+		DateFrom: q.Get("from"),
+		DateTo:   q.Get("to"),
+		Site:     q.Get("site"),
+		Tenants:  tenantScope(r),
+	}
+	for _, status := range q["status"] {
+		f.Statuses = append(f.Statuses, envlist.Split(status)...)
+	}
+	for name, values := range q {
+		if key, ok := strings.CutPrefix(name, "param."); ok {
+			if f.Params == nil {
+				f.Params = map[string]string{}
+			}
+			f.Params[key] = values[0]
+		}
+	}
+	if tenant := q.Get("tenant"); tenant != "" {
+		if !allowedTenant(r, tenant) {
+			return f, errTenantForbidden
+		}
+		f.Tenants = []string{tenant}
+	}
+	return f, nil
+}
+
+// handleListJobs returns a page of jobs, newest first. Pass the response's
+// next_cursor as ?cursor= for the following page; ?limit= sets its size.
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	filter, err := jobFilter(r)
+	if err != nil {
+		s.writeError(w, http.StatusForbidden, err)
+		return
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, errors.New("limit must be an integer"))
+			return
+		}
+	}
+
+	page, err := s.sched.ListJobs(r.Context(), filter, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errclass.Classify(err) == errclass.Data {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.log(r).Error("Failed to list jobs", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("listing jobs failed"))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, page)
+}
+
+// handleRetryJobs requeues all jobs matching {"job_name", "status", "from",
+// "to", "site", "params", "tenant"} in one transaction. status defaults to ["failed"].
+func (s *Server) handleRetryJobs(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		JobName string            `json:"job_name"`
+		Status  []string          `json:"status"`
+		From    string            `json:"from"`
+		To      string            `json:"to"`
+		Site    string            `json:"site"`
+		Params  map[string]string `json:"params"`
+		Tenant  string            `json:"tenant"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	filter := scheduler.JobFilter{
+		JobName:  req.JobName,
+		Statuses: req.Status,
+		DateFrom: req.From,
+		DateTo:   req.To,
+		Site:     req.Site,
+		Params:   req.Params,
+		Tenants:  tenantScope(r),
+	}
+	if req.Tenant != "" {
+		if !allowedTenant(r, req.Tenant) {
+			s.writeError(w, http.StatusForbidden, errTenantForbidden)
+			return
+		}
+		filter.Tenants = []string{req.Tenant}
+	}
+
+	requeued, err := s.sched.RetryJobs(r.Context(), filter)
+	if err != nil {
+		if errclass.Classify(err) == errclass.Data {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.log(r).Error("Failed to retry jobs", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("retrying jobs failed"))
+		return
+	}
+
+	s.log(r).Info("Jobs requeued via API", "count", requeued, "job_name", req.JobName, "from", req.From, "to", req.To)
+	s.audit(r, "job.retry", "jobs", map[string]any{"filter": req, "requeued": requeued})
+	s.writeJSON(w, http.StatusOK, map[string]int64{"requeued": requeued})
+}
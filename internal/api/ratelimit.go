@@ -0,0 +1,118 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitIdle is how long a client's bucket is kept after its last request.
+const rateLimitIdle = 10 * time.Minute
+
+// RateLimitFromEnv returns a middleware that gives each client a token
+// bucket refilled at API_RATE_LIMIT requests per second, holding up to
+// API_RATE_BURST (default 20) requests. Clients are told apart by their
+// client certificate name under mutual TLS, otherwise by IP. The health
+// probes are not limited. It returns nil when API_RATE_LIMIT is not set.
+func RateLimitFromEnv() (Middleware, error) {
+	raw := os.Getenv("API_RATE_LIMIT")
+	if raw == "" {
+		return nil, nil
+	}
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("API_RATE_LIMIT must be a positive number of requests per second, got %q", raw)
+	}
+
+	burst := 20
+	if raw := os.Getenv("API_RATE_BURST"); raw != "" {
+		burst, err = strconv.Atoi(raw)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("API_RATE_BURST must be a positive integer, got %q", raw)
+		}
+	}
+
+	l := &rateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+	return l.wrap, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// allow takes a token from client's bucket. When it is empty it returns how
+// long until the next token.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) > rateLimitIdle {
+		for key, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitIdle {
+				delete(l.buckets, key)
+			}
+		}
+		l.swept = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (l *rateLimiter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, wait := l.allow(clientKey(r), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"rate limit exceeded"}` + "\n"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey identifies the caller: the verified certificate's common name
+// under mutual TLS, otherwise the remote IP.
+func clientKey(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
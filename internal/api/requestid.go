@@ -0,0 +1,30 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"hotbrandon/go-cron-be/internal/requestid"
+)
+
+// RequestID takes the caller's X-Request-ID, or generates one, echoes it in
+// the response and stores it in the request context for handlers and the
+// jobs they trigger.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
+// log returns the server logger tagged with the request's ID.
+func (s *Server) log(r *http.Request) *slog.Logger {
+	if id := requestid.FromContext(r.Context()); id != "" {
+		return s.logger.With("request_id", id)
+	}
+	return s.logger
+}
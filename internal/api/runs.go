@@ -0,0 +1,100 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"hotbrandon/go-cron-be/internal/errclass"
+)
+
+// runLogFlushEvery is how many lines are written between flushes, so long
+// logs reach the client as they are read.
+const runLogFlushEvery = 100
+
+// handleRunLogs streams the log lines captured during one run of a job as
+// plain text, one line per record. Runs of other tenants' jobs are reported
+// as missing.
+func (s *Server) handleRunLogs(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errors.New("invalid job id"))
+		return
+	}
+	runID, err := strconv.ParseInt(r.PathValue("run"), 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errors.New("invalid run id"))
+		return
+	}
+
+	jobName, err := s.sched.RunJobName(r.Context(), jobID, runID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.writeError(w, http.StatusNotFound, errors.New("run not found"))
+			return
+		}
+		s.log(r).Error("Failed to look up run", "job_id", jobID, "run_id", runID, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("reading run logs failed"))
+		return
+	}
+	if !allowedTenant(r, s.sched.TenantOf(jobName)) {
+		s.writeError(w, http.StatusNotFound, errors.New("run not found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rc := http.NewResponseController(w)
+	written := 0
+	err = s.sched.EachRunLogLine(r.Context(), runID, func(line string) error {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+		written++
+		if written%runLogFlushEvery == 0 {
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if written == 0 {
+			s.log(r).Error("Failed to read run logs", "job_id", jobID, "run_id", runID, "error", err)
+			s.writeError(w, http.StatusInternalServerError, errors.New("reading run logs failed"))
+			return
+		}
+		// The status is already sent, so a failure part way is only logged.
+		s.log(r).Warn("Failed to stream run logs", "job_id", jobID, "run_id", runID, "error", err)
+	}
+}
+
+// handleDiffRuns compares the stored results of runs ?from= and ?to=, which
+// must be of the same job name and date, and lists the values that changed.
+func (s *Server) handleDiffRuns(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, errFrom := strconv.ParseInt(q.Get("from"), 10, 64)
+	to, errTo := strconv.ParseInt(q.Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil {
+		s.writeError(w, http.StatusBadRequest, errors.New("from and to must be run ids"))
+		return
+	}
+
+	diff, err := s.sched.DiffRuns(r.Context(), from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			s.writeError(w, http.StatusNotFound, errors.New("run not found"))
+		case errclass.Classify(err) == errclass.Data:
+			s.writeError(w, http.StatusBadRequest, err)
+		default:
+			s.log(r).Error("Failed to diff runs", "from", from, "to", to, "error", err)
+			s.writeError(w, http.StatusInternalServerError, errors.New("diffing runs failed"))
+		}
+		return
+	}
+	if !allowedTenant(r, s.sched.TenantOf(diff.JobName)) {
+		s.writeError(w, http.StatusNotFound, errors.New("run not found"))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, diff)
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/scheduler"
+)
+
+// defaultPreviewRuns is used when the caller does not pass ?n=.
+const defaultPreviewRuns = 5
+
+// handleValidateSchedule validates ?spec= and returns its next ?n= fire times.
+func (s *Server) handleValidateSchedule(w http.ResponseWriter, r *http.Request) {
+	spec := r.URL.Query().Get("spec")
+	if spec == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("spec query parameter is required"))
+		return
+	}
+
+	n, err := previewRuns(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	preview, err := s.sched.PreviewSchedule(spec, n)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, preview)
+}
+
+// previewRuns reads ?n=, the number of fire times to return.
+func previewRuns(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("n")
+	if raw == "" {
+		return defaultPreviewRuns, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, errors.New("n must be an integer")
+	}
+	return n, nil
+}
+
+// handleForecastSchedules returns the next ?n= firings of every schedule
+// after ?from= (RFC 3339, default now), marking those that fall in a
+// blackout window, so the batch calendar can be checked in advance.
+func (s *Server) handleForecastSchedules(w http.ResponseWriter, r *http.Request) {
+	n, err := previewRuns(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	from := time.Now()
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		if from, err = time.Parse(time.RFC3339, raw); err != nil {
+			s.writeError(w, http.StatusBadRequest, errors.New("from must be an RFC 3339 timestamp"))
+			return
+		}
+	}
+
+	forecast, err := s.sched.ForecastSchedules(r.Context(), from, n)
+	if err != nil {
+		if errclass.Classify(err) == errclass.Data {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.log(r).Error("Failed to forecast schedules", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("forecasting schedules failed"))
+		return
+	}
+	forecast.Schedules = slices.DeleteFunc(forecast.Schedules, func(f scheduler.ScheduleForecast) bool {
+		return !allowedTenant(r, s.sched.TenantOf(f.JobName))
+	})
+	s.writeJSON(w, http.StatusOK, forecast)
+}
+
+// handleListSchedules lists the schedules that create the caller's jobs
+// with their next and previous fire times and latest job.
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := s.sched.Schedules(r.Context())
+	if err != nil {
+		s.log(r).Error("Failed to list schedules", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("listing schedules failed"))
+		return
+	}
+	schedules = slices.DeleteFunc(schedules, func(info scheduler.ScheduleInfo) bool {
+		return !allowedTenant(r, s.sched.TenantOf(info.JobName))
+	})
+	s.writeJSON(w, http.StatusOK, schedules)
+}
+
+// handleAddSchedule registers a schedule from a scheduler.ScheduledJob
+// ({"name", "schedule", "job_name", "job_date", "params", "priority"}). It
+// fires on the running scheduler right away and is kept across restarts.
+// Privileged job types are refused unless listed in
+// API_TRIGGER_PRIVILEGED_JOBS.
+func (s *Server) handleAddSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduler.ScheduledJob
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.JobName == "" || req.Schedule == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("job_name and schedule are required"))
+		return
+	}
+
+	if !allowedTenant(r, s.sched.TenantOf(req.JobName)) {
+		s.writeError(w, http.StatusForbidden, errTenantForbidden)
+		return
+	}
+	if !s.allowedJobType(req.JobName) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("job type %q cannot be scheduled through the API, see API_TRIGGER_PRIVILEGED_JOBS", req.JobName))
+		return
+	}
+
+	job, err := s.sched.AddSchedule(r.Context(), req, actor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, scheduler.ErrScheduleExists):
+			s.writeError(w, http.StatusConflict, err)
+		case errclass.Classify(err) == errclass.Data:
+			s.writeError(w, http.StatusBadRequest, err)
+		default:
+			s.log(r).Error("Failed to add schedule", "schedule", req.Name, "error", err)
+			s.writeError(w, http.StatusInternalServerError, errors.New("adding schedule failed"))
+		}
+		return
+	}
+	s.audit(r, "schedule.add", "schedule:"+job.Name, job)
+	s.writeJSON(w, http.StatusCreated, job)
+}
+
+// handleRemoveSchedule removes a schedule added through the API. Schedules
+// configured in the environment are answered with 409.
+func (s *Server) handleRemoveSchedule(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.scheduleForCaller(w, r)
+	if !ok {
+		return
+	}
+	err := s.sched.RemoveSchedule(r.Context(), name)
+	switch {
+	case errors.Is(err, scheduler.ErrStaticSchedule):
+		s.writeError(w, http.StatusConflict, err)
+		return
+	case errors.Is(err, scheduler.ErrUnknownSchedule):
+		// removed concurrently
+		s.writeError(w, http.StatusNotFound, errors.New("schedule not found"))
+		return
+	case err != nil:
+		s.log(r).Error("Failed to remove schedule", "schedule", name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("removing schedule failed"))
+		return
+	}
+	s.audit(r, "schedule.remove", "schedule:"+name, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePauseSchedule stops the schedule named in the path from creating
+// jobs until it is resumed. Schedules of other tenants' jobs are reported as
+// missing.
+func (s *Server) handlePauseSchedule(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.scheduleForCaller(w, r)
+	if !ok {
+		return
+	}
+	if err := s.sched.PauseSchedule(r.Context(), name, actor(r)); err != nil {
+		s.log(r).Error("Failed to pause schedule", "schedule", name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("pausing schedule failed"))
+		return
+	}
+	s.audit(r, "schedule.pause", "schedule:"+name, nil)
+	s.writeJSON(w, http.StatusOK, map[string]any{"schedule": name, "paused": true})
+}
+
+// handleResumeSchedule lets a paused schedule create jobs again.
+func (s *Server) handleResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.scheduleForCaller(w, r)
+	if !ok {
+		return
+	}
+	if err := s.sched.ResumeSchedule(r.Context(), name); err != nil {
+		s.log(r).Error("Failed to resume schedule", "schedule", name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("resuming schedule failed"))
+		return
+	}
+	s.audit(r, "schedule.resume", "schedule:"+name, nil)
+	s.writeJSON(w, http.StatusOK, map[string]any{"schedule": name, "paused": false})
+}
+
+// scheduleForCaller resolves the {name} path value to a schedule the caller
+// may manage, writing a 404 otherwise.
+func (s *Server) scheduleForCaller(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.PathValue("name")
+	jobName, err := s.sched.ScheduleJobName(name)
+	if errors.Is(err, scheduler.ErrUnknownSchedule) || (err == nil && !allowedTenant(r, s.sched.TenantOf(jobName))) {
+		s.writeError(w, http.StatusNotFound, errors.New("schedule not found"))
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAddScheduleRefusesPrivilegedJobTypes(t *testing.T) {
+	const body = `{"name": "wipe", "schedule": "@daily", "job_name": "sql", "params": {"connection": "mysql", "statements": [{"sql": "DELETE FROM cron_jobs"}]}}`
+
+	t.Setenv("API_TRIGGER_TOKEN", "")
+	t.Setenv("API_TRIGGER_PRIVILEGED_JOBS", "")
+	s := newTestServer(t)
+	if rec := serve(s.Handler(), http.MethodPost, "/schedules", "", body); rec.Code != http.StatusForbidden {
+		t.Errorf("POST /schedules without authentication configured = %d, want 403", rec.Code)
+	}
+
+	t.Setenv("API_TRIGGER_TOKEN", "s3cret")
+	auth, err := AuthFromEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.EnableWrites(auth)
+	if rec := serve(s.Handler(), http.MethodPost, "/schedules", "", body); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /schedules without credentials = %d, want 401", rec.Code)
+	}
+	rec := serve(s.Handler(), http.MethodPost, "/schedules", "s3cret", body)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "API_TRIGGER_PRIVILEGED_JOBS") {
+		t.Errorf("POST /schedules of a sql job = %d %s, want 403", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"hotbrandon/go-cron-be/internal/errclass"
+)
+
+// Stored scripts are shared by every tenant's script jobs, so only callers
+// that may see all tenants can read or change them. Changes also need the
+// authentication of every mutating route, see EnableWrites, and a named
+// author, see scriptAuthor.
+
+func (s *Server) handleListScripts(w http.ResponseWriter, r *http.Request) {
+	if !allowedAllTenants(r) {
+		s.writeError(w, http.StatusForbidden, errTenantForbidden)
+		return
+	}
+	scripts, err := s.sched.Scripts(r.Context())
+	if err != nil {
+		s.log(r).Error("Failed to list scripts", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("listing scripts failed"))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, scripts)
+}
+
+func (s *Server) handleGetScript(w http.ResponseWriter, r *http.Request) {
+	if !allowedAllTenants(r) {
+		s.writeError(w, http.StatusForbidden, errTenantForbidden)
+		return
+	}
+	script, err := s.sched.Script(r.Context(), r.PathValue("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		s.writeError(w, http.StatusNotFound, errors.New("script not found"))
+		return
+	}
+	if err != nil {
+		s.log(r).Error("Failed to get script", "script", r.PathValue("name"), "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("getting script failed"))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, script)
+}
+
+// scriptAuthor returns who is changing a script: the client certificate
+// under mutual TLS, otherwise the X-Actor header, which is then required so
+// script changes are never recorded as anonymous.
+func (s *Server) scriptAuthor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	author := actor(r)
+	if author == "anonymous" {
+		s.writeError(w, http.StatusBadRequest, errors.New("an X-Actor header naming the author is required to change scripts"))
+		return "", false
+	}
+	return author, true
+}
+
+// handleSaveScript creates or replaces the script named in the path from
+// {"source"}. A script that does not compile is answered with 400.
+func (s *Server) handleSaveScript(w http.ResponseWriter, r *http.Request) {
+	if !allowedAllTenants(r) {
+		s.writeError(w, http.StatusForbidden, errTenantForbidden)
+		return
+	}
+	author, ok := s.scriptAuthor(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Source string `json:"source"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 256*1024))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	name := r.PathValue("name")
+	script, err := s.sched.SaveScript(r.Context(), name, req.Source, author)
+	if err != nil {
+		if errclass.Classify(err) == errclass.Data {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.log(r).Error("Failed to save script", "script", name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("saving script failed"))
+		return
+	}
+	s.audit(r, "script.save", "script:"+name, map[string]string{"author": author, "source": req.Source})
+	s.writeJSON(w, http.StatusOK, script)
+}
+
+func (s *Server) handleDeleteScript(w http.ResponseWriter, r *http.Request) {
+	if !allowedAllTenants(r) {
+		s.writeError(w, http.StatusForbidden, errTenantForbidden)
+		return
+	}
+	author, ok := s.scriptAuthor(w, r)
+	if !ok {
+		return
+	}
+	name := r.PathValue("name")
+	if err := s.sched.DeleteScript(r.Context(), name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.writeError(w, http.StatusNotFound, errors.New("script not found"))
+			return
+		}
+		s.log(r).Error("Failed to delete script", "script", name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("deleting script failed"))
+		return
+	}
+	s.audit(r, "script.delete", "script:"+name, map[string]string{"author": author})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestScriptChangesRequireAuthAndAuthor(t *testing.T) {
+	t.Setenv("API_TRIGGER_TOKEN", "s3cret")
+	t.Setenv("API_TRIGGER_PRIVILEGED_JOBS", "")
+	auth, err := AuthFromEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	s.EnableWrites(auth)
+
+	const body = `{"source": "return 1"}`
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		if rec := serve(s.Handler(), method, "/scripts/check", "", body); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s /scripts/check without credentials = %d, want 401", method, rec.Code)
+		}
+		if rec := serve(s.Handler(), method, "/scripts/check", "s3cret", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s /scripts/check without an author = %d, want 400", method, rec.Code)
+		}
+	}
+}
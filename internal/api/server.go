@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+
+	"hotbrandon/go-cron-be/internal/scheduler"
+)
+
+// Middleware wraps the API handler, e.g. CORSFromEnv or RateLimitFromEnv.
+type Middleware func(http.Handler) http.Handler
+
+type Server struct {
+	sched  *scheduler.Scheduler
+	logger *slog.Logger
+	mux    *http.ServeMux
+	// auth admits callers to the mutating endpoints, see EnableWrites
+	auth *AuthConfig
+}
+
+func NewServer(sched *scheduler.Scheduler, logger *slog.Logger) *Server {
+	s := &Server{
+		sched:  sched,
+		logger: logger.WithGroup("API"),
+		mux:    http.NewServeMux(),
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /healthz", s.handleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.handleReadyz)
+	s.mux.HandleFunc("GET /jobs", s.handleListJobs)
+	s.mux.HandleFunc("POST /jobs", s.handleTriggerJob)
+	s.mux.HandleFunc("POST /jobs/retry", s.handleRetryJobs)
+	s.mux.HandleFunc("GET /jobs/{id}/runs/{run}/logs", s.handleRunLogs)
+	s.mux.HandleFunc("GET /runs/diff", s.handleDiffRuns)
+	s.mux.HandleFunc("GET /schedules", s.handleListSchedules)
+	s.mux.HandleFunc("GET /schedules/validate", s.handleValidateSchedule)
+	s.mux.HandleFunc("GET /schedules/forecast", s.handleForecastSchedules)
+	s.mux.HandleFunc("POST /schedules", s.handleAddSchedule)
+	s.mux.HandleFunc("DELETE /schedules/{name}", s.handleRemoveSchedule)
+	s.mux.HandleFunc("POST /schedules/{name}/pause", s.handlePauseSchedule)
+	s.mux.HandleFunc("POST /schedules/{name}/resume", s.handleResumeSchedule)
+	s.mux.HandleFunc("GET /exports/funeral-invoices.xlsx", s.handleFuneralInvoicesXLSX)
+	s.mux.HandleFunc("GET /webhooks", s.handleListWebhooks)
+	s.mux.HandleFunc("POST /webhooks", s.handleCreateWebhook)
+	s.mux.HandleFunc("DELETE /webhooks/{id}", s.handleDeleteWebhook)
+	s.mux.HandleFunc("GET /scripts", s.handleListScripts)
+	s.mux.HandleFunc("GET /scripts/{name}", s.handleGetScript)
+	s.mux.HandleFunc("PUT /scripts/{name}", s.handleSaveScript)
+	s.mux.HandleFunc("DELETE /scripts/{name}", s.handleDeleteScript)
+	s.mux.HandleFunc("GET /audit", s.handleListAudit)
+	s.mux.HandleFunc("GET /stats", s.handleListStats)
+	s.mux.HandleFunc("GET /alerts", s.handleListAlerts)
+	s.mux.HandleFunc("POST /alerts/{id}/ack", s.handleAcknowledgeAlert)
+	s.mux.HandleFunc("GET /debug/vars", s.handleDebugVars)
+}
+
+// handleDebugVars serves expvar, whose scheduler state spans every tenant.
+func (s *Server) handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	if !allowedAllTenants(r) {
+		s.writeError(w, http.StatusForbidden, errors.New("debug variables require access to all tenants"))
+		return
+	}
+	expvar.Handler().ServeHTTP(w, r)
+}
+
+// Handle adds a route served outside the API proper, such as the metrics
+// endpoint. Call it before Handler.
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// Handler returns the root HTTP handler for the admin API. Requests other
+// than GET and HEAD are authenticated, see EnableWrites.
+func (s *Server) Handler() http.Handler {
+	return s.requireAuth(s.mux)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Warn("Failed to write response", "error", err)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, map[string]string{"error": err.Error()})
+}
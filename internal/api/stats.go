@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"hotbrandon/go-cron-be/internal/errclass"
+)
+
+// handleListStats returns the daily run statistics between ?from= and ?to=
+// (YYYY-MM-DD, default the last 7 days up to yesterday), optionally for one
+// ?job_name=. Job types of tenants the caller cannot access are left out.
+func (s *Server) handleListStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	yesterday := time.Now().AddDate(0, 0, -1)
+	from, to := q.Get("from"), q.Get("to")
+	if from == "" {
+		from = yesterday.AddDate(0, 0, -6).Format("2006-01-02")
+	}
+	if to == "" {
+		to = yesterday.Format("2006-01-02")
+	}
+
+	stats, err := s.sched.ListDailyStats(r.Context(), from, to, q.Get("job_name"))
+	if err != nil {
+		if errclass.Classify(err) == errclass.Data {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.log(r).Error("Failed to list job stats", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("listing job stats failed"))
+		return
+	}
+
+	if tenantScope(r) != nil {
+		visible := stats[:0]
+		for _, st := range stats {
+			if allowedTenant(r, s.sched.TenantOf(st.JobName)) {
+				visible = append(visible, st)
+			}
+		}
+		stats = visible
+	}
+	s.writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"hotbrandon/go-cron-be/internal/envlist"
+)
+
+// allTenants grants access to every tenant in API_TENANT_ACCESS.
+const allTenants = "*"
+
+type tenantsKey struct{}
+
+// TenantAccessFromEnv returns a middleware that limits each caller to the
+// tenants listed for it in API_TENANT_ACCESS, e.g.
+// "cert:erp-bridge=funeral,cert:golf-dashboard=golf,cert:ops=*", with several tenants
+// separated by "|". Callers are named as in the audit log, by their client
+// certificate, so it requires mutual TLS (tlsConfig with client CAs): the
+// X-Actor header any caller can set would let anyone claim "ops". Unlisted
+// callers are refused. It returns nil when API_TENANT_ACCESS is not set,
+// leaving every caller unrestricted.
+func TenantAccessFromEnv(tlsConfig *tls.Config) (Middleware, error) {
+	raw := os.Getenv("API_TENANT_ACCESS")
+	if raw == "" {
+		return nil, nil
+	}
+	if tlsConfig == nil || tlsConfig.ClientCAs == nil {
+		return nil, errors.New("API_TENANT_ACCESS requires mutual TLS, set API_TLS_CERT, API_TLS_KEY and API_TLS_CLIENT_CA")
+	}
+
+	access := make(map[string][]string)
+	for _, entry := range envlist.Split(raw) {
+		name, tenants, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.TrimSpace(tenants) == "" {
+			return nil, fmt.Errorf("invalid API_TENANT_ACCESS entry %q, expected actor=tenant|tenant", entry)
+		}
+		for _, tenant := range strings.Split(tenants, "|") {
+			if tenant = strings.TrimSpace(tenant); tenant != "" {
+				access[name] = append(access[name], tenant)
+			}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			tenants, ok := access[actor(r)]
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"error":"caller has no tenant access"}` + "\n"))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantsKey{}, tenants)))
+		})
+	}, nil
+}
+
+// allowedTenant reports whether the caller may see and act on tenant's jobs.
+func allowedTenant(r *http.Request, tenant string) bool {
+	tenants, restricted := r.Context().Value(tenantsKey{}).([]string)
+	return !restricted || slices.Contains(tenants, allTenants) || slices.Contains(tenants, tenant)
+}
+
+// allowedAllTenants reports whether the caller is unrestricted, as needed for
+// cross-tenant views such as the audit log.
+func allowedAllTenants(r *http.Request) bool {
+	tenants, restricted := r.Context().Value(tenantsKey{}).([]string)
+	return !restricted || slices.Contains(tenants, allTenants)
+}
+
+// tenantScope returns the tenants the caller may see, or nil for all.
+func tenantScope(r *http.Request) []string {
+	if allowedAllTenants(r) {
+		return nil
+	}
+	tenants, _ := r.Context().Value(tenantsKey{}).([]string)
+	return tenants
+}
+
+var errTenantForbidden = errors.New("job type belongs to a tenant this caller cannot access")
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"hotbrandon/go-cron-be/internal/errclass"
+)
+
+// handleTriggerJob enqueues a job from {"job_name", "job_date", "params",
+// "priority", "dry_run"}. The caller's X-Request-ID is stored on the job. An
+// identical existing job is reported with 200 and created=false. Privileged
+// job types are refused unless listed in API_TRIGGER_PRIVILEGED_JOBS.
+func (s *Server) handleTriggerJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		JobName  string          `json:"job_name"`
+		JobDate  string          `json:"job_date"`
+		Params   json.RawMessage `json:"params"`
+		Priority int             `json:"priority"`
+		DryRun   bool            `json:"dry_run"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.JobName == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("job_name is required"))
+		return
+	}
+
+	if !allowedTenant(r, s.sched.TenantOf(req.JobName)) {
+		s.writeError(w, http.StatusForbidden, errTenantForbidden)
+		return
+	}
+	if !s.allowedJobType(req.JobName) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("job type %q is not allowed through the API, see API_TRIGGER_PRIVILEGED_JOBS", req.JobName))
+		return
+	}
+
+	jobID, created, err := s.sched.TriggerJob(r.Context(), req.JobName, req.JobDate, req.Params, req.Priority, req.DryRun)
+	if err != nil {
+		if errclass.Classify(err) == errclass.Data {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.log(r).Error("Failed to trigger job", "job_name", req.JobName, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("triggering job failed"))
+		return
+	}
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	} else {
+		s.log(r).Info("Job triggered via API", "job_id", jobID, "job_name", req.JobName)
+		s.audit(r, "job.trigger", "job:"+strconv.FormatInt(jobID, 10), req)
+	}
+	s.writeJSON(w, status, map[string]any{"job_id": jobID, "created": created})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/scheduler"
+)
+
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := s.sched.ListWebhooks(r.Context())
+	if err != nil {
+		s.log(r).Error("Failed to list webhooks", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("listing webhooks failed"))
+		return
+	}
+	webhooks = slices.DeleteFunc(webhooks, func(wh scheduler.Webhook) bool {
+		return !s.webhookAllowed(r, wh.JobName)
+	})
+	s.writeJSON(w, http.StatusOK, webhooks)
+}
+
+// webhookAllowed reports whether the caller may manage webhooks for jobName;
+// "*" webhooks see every tenant's jobs.
+func (s *Server) webhookAllowed(r *http.Request, jobName string) bool {
+	if jobName == "*" {
+		return allowedAllTenants(r)
+	}
+	return allowedTenant(r, s.sched.TenantOf(jobName))
+}
+
+// handleCreateWebhook registers a webhook from {"job_name", "url", "secret"}.
+// The response contains the secret, generated if none was given; it cannot
+// be retrieved later.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		JobName string `json:"job_name"`
+		URL     string `json:"url"`
+		Secret  string `json:"secret"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.JobName == "" || req.URL == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("job_name and url are required"))
+		return
+	}
+
+	if !s.webhookAllowed(r, req.JobName) {
+		s.writeError(w, http.StatusForbidden, errTenantForbidden)
+		return
+	}
+
+	webhook, err := s.sched.CreateWebhook(r.Context(), req.JobName, req.URL, req.Secret)
+	if err != nil {
+		if errclass.Classify(err) == errclass.Data {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.log(r).Error("Failed to create webhook", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("creating webhook failed"))
+		return
+	}
+	// the secret stays out of the audit log
+	s.audit(r, "webhook.create", "webhook:"+strconv.FormatInt(webhook.WebhookID, 10),
+		map[string]string{"job_name": webhook.JobName, "url": webhook.URL})
+	s.writeJSON(w, http.StatusCreated, webhook)
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errors.New("invalid webhook id"))
+		return
+	}
+
+	// Other tenants' webhooks are reported as missing.
+	webhooks, err := s.sched.ListWebhooks(r.Context())
+	if err != nil {
+		s.log(r).Error("Failed to list webhooks", "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("deleting webhook failed"))
+		return
+	}
+	i := slices.IndexFunc(webhooks, func(wh scheduler.Webhook) bool { return wh.WebhookID == id })
+	if i >= 0 && !s.webhookAllowed(r, webhooks[i].JobName) {
+		s.writeError(w, http.StatusNotFound, errors.New("webhook not found"))
+		return
+	}
+
+	if err := s.sched.DeleteWebhook(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.writeError(w, http.StatusNotFound, errors.New("webhook not found"))
+			return
+		}
+		s.log(r).Error("Failed to delete webhook", "webhook_id", id, "error", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("deleting webhook failed"))
+		return
+	}
+	s.audit(r, "webhook.delete", "webhook:"+strconv.FormatInt(id, 10), nil)
+	w.WriteHeader(http.StatusNoContent)
+}
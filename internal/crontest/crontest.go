@@ -0,0 +1,215 @@
+// Package crontest runs the scheduler against throwaway database containers
+// so job types can be tested end to end:
+//
+//	func TestFuneralImport(t *testing.T) {
+//		env := crontest.New(t)
+//		jobID := env.Enqueue(t, "funeral_invoice_import", "2025-07-15", map[string]string{"invoice_date": "2025-07-15"})
+//		job := env.Run(t, jobID)
+//		if job.JobStatus != "finished" { ... }
+//	}
+//
+// Containers are started with the docker CLI instead of testcontainers-go,
+// which would pull its Docker SDK dependency tree into the module for the
+// sake of tests; tests are skipped when docker is not available and in
+// -short mode. The images can be overridden with CRONTEST_MYSQL_IMAGE and
+// CRONTEST_ORACLE_IMAGE.
+package crontest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/sijms/go-ora/v2"
+
+	"hotbrandon/go-cron-be/internal/scheduler"
+)
+
+const (
+	defaultMySQLImage  = "mysql:8.0"
+	defaultOracleImage = "gvenzl/oracle-xe:21-slim"
+	containerPassword  = "crontest"
+	// startTimeout is how long a container may take to accept connections;
+	// Oracle XE needs a few minutes on first start.
+	startTimeout = 5 * time.Minute
+)
+
+// Env is a scheduler with its tables created in a fresh MySQL database.
+type Env struct {
+	DB        *sql.DB
+	Scheduler *scheduler.Scheduler
+}
+
+// New starts MySQL, creates the scheduler's tables and loads its
+// configuration from the environment (set it with t.Setenv beforehand). Cron
+// and the workers are not started: jobs run only through Run, so tests are
+// deterministic.
+func New(t testing.TB, opts ...scheduler.Option) *Env {
+	t.Helper()
+	db := StartMySQL(t)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if testing.Verbose() {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	sched := scheduler.NewScheduler(db, logger, opts...)
+	if err := sched.RegisterJobs(); err != nil {
+		t.Fatalf("crontest: registering jobs: %v", err)
+	}
+	t.Cleanup(sched.Stop)
+	return &Env{DB: db, Scheduler: sched}
+}
+
+// Enqueue creates a pending job and returns its id; params is encoded as
+// job_params. A job identical to an existing one fails the test.
+func (e *Env) Enqueue(t testing.TB, jobName, jobDate string, params any) int64 {
+	t.Helper()
+	jobID, created, err := e.Scheduler.EnqueueJob(context.Background(), jobName, jobDate, params, 0)
+	if err != nil {
+		t.Fatalf("crontest: enqueueing %s: %v", jobName, err)
+	}
+	if !created {
+		t.Fatalf("crontest: %s job for %s already exists", jobName, jobDate)
+	}
+	return jobID
+}
+
+// Run executes the pending job jobID through the full dispatch path
+// (middleware, hooks, retries) and returns the job as stored afterwards.
+func (e *Env) Run(t testing.TB, jobID int64) scheduler.CronJob {
+	t.Helper()
+	if err := e.Scheduler.RunJob(context.Background(), jobID); err != nil {
+		t.Fatalf("crontest: running job %d: %v", jobID, err)
+	}
+	return e.Job(t, jobID)
+}
+
+// Job loads the job jobID.
+func (e *Env) Job(t testing.TB, jobID int64) scheduler.CronJob {
+	t.Helper()
+	var job scheduler.CronJob
+	var message sql.NullString
+	var result []byte
+	err := e.DB.QueryRow(`
+		SELECT job_id, job_name, job_date, job_params, job_status, attempts, message, job_result
+		FROM cron_jobs WHERE job_id = ?
+	`, jobID).Scan(&job.JobID, &job.JobName, &job.JobDate, &job.JobParams, &job.JobStatus, &job.Attempts, &message, &result)
+	if err != nil {
+		t.Fatalf("crontest: loading job %d: %v", jobID, err)
+	}
+	job.Message, job.Result = message.String, result
+	return job
+}
+
+// StartMySQL starts a MySQL container for the test and returns a connection
+// to an empty database in it, opened like the scheduler's own. The
+// container is removed when the test ends.
+func StartMySQL(t testing.TB) *sql.DB {
+	t.Helper()
+	image := imageFromEnv("CRONTEST_MYSQL_IMAGE", defaultMySQLImage)
+	addr := startContainer(t, image, "3306/tcp",
+		"-e", "MYSQL_ROOT_PASSWORD="+containerPassword, "-e", "MYSQL_DATABASE=crontest")
+
+	cfg := mysql.NewConfig()
+	cfg.User, cfg.Passwd, cfg.Net, cfg.Addr, cfg.DBName = "root", containerPassword, "tcp", addr, "crontest"
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("crontest: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	waitForDB(t, db, image)
+	return db
+}
+
+// StartOracle starts an Oracle XE container for the test and returns the DSN
+// of an empty schema in it, e.g. to set as ORACLE_DSN_TH with t.Setenv.
+func StartOracle(t testing.TB) string {
+	t.Helper()
+	image := imageFromEnv("CRONTEST_ORACLE_IMAGE", defaultOracleImage)
+	addr := startContainer(t, image, "1521/tcp",
+		"-e", "ORACLE_PASSWORD="+containerPassword, "-e", "APP_USER=crontest", "-e", "APP_USER_PASSWORD="+containerPassword)
+
+	dsn := fmt.Sprintf("oracle://crontest:%s@%s/XEPDB1", containerPassword, addr)
+	db, err := sql.Open("oracle", dsn)
+	if err != nil {
+		t.Fatalf("crontest: %v", err)
+	}
+	defer db.Close()
+	waitForDB(t, db, image)
+	return dsn
+}
+
+func imageFromEnv(key, fallback string) string {
+	if image := os.Getenv(key); image != "" {
+		return image
+	}
+	return fallback
+}
+
+// startContainer runs image with port published on a random local port and
+// returns that address. The test is skipped without docker.
+func startContainer(t testing.TB, image, port string, args ...string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("crontest: starting containers is skipped in -short mode")
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("crontest: docker is not available")
+	}
+
+	runArgs := append([]string{"run", "-d", "--rm", "-p", "127.0.0.1::" + strings.TrimSuffix(port, "/tcp")}, args...)
+	out, err := exec.Command("docker", append(runArgs, image)...).Output()
+	if err != nil {
+		t.Fatalf("crontest: starting %s: %v", image, commandError(err))
+	}
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		if err := exec.Command("docker", "rm", "-f", id).Run(); err != nil {
+			t.Logf("crontest: removing container %s: %v", id, err)
+		}
+	})
+
+	out, err = exec.Command("docker", "port", id, port).Output()
+	if err != nil {
+		t.Fatalf("crontest: reading %s port of %s: %v", port, image, commandError(err))
+	}
+	// docker prints one line per address family
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return addr
+}
+
+// waitForDB pings db until the container accepts connections.
+func waitForDB(t testing.TB, db *sql.DB, image string) {
+	t.Helper()
+	deadline := time.Now().Add(startTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("crontest: %s not ready after %s: %v", image, startTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// commandError adds the stderr of a failed docker command to err.
+func commandError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package crontest
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"hotbrandon/go-cron-be/internal/scheduler"
+)
+
+type echoParams struct {
+	Text string `json:"text"`
+}
+
+func TestEnqueueAndRun(t *testing.T) {
+	env := New(t)
+	scheduler.RegisterJobType(env.Scheduler, "crontest_echo", func(ctx context.Context, job scheduler.CronJob, p echoParams) (string, error) {
+		if err := scheduler.SetResult(ctx, map[string]string{"echo": p.Text}); err != nil {
+			return "", err
+		}
+		return "said " + p.Text, nil
+	})
+
+	jobID := env.Enqueue(t, "crontest_echo", "2025-07-15", echoParams{Text: "hi"})
+	job := env.Run(t, jobID)
+	if job.JobStatus != "finished" || job.Message != "said hi" || job.Attempts != 1 {
+		t.Errorf("job = %s %q after %d attempts, want finished \"said hi\" after 1", job.JobStatus, job.Message, job.Attempts)
+	}
+	var result map[string]string
+	if err := json.Unmarshal(job.Result, &result); err != nil || result["echo"] != "hi" {
+		t.Errorf("job_result = %s, want {\"echo\":\"hi\"}", job.Result)
+	}
+
+	var runStatus string
+	if err := env.DB.QueryRow("SELECT run_status FROM job_runs WHERE job_id = ?", jobID).Scan(&runStatus); err != nil || runStatus != "finished" {
+		t.Errorf("run_status = %q, %v, want finished", runStatus, err)
+	}
+
+	rows, err := env.DB.Query("SELECT event_type FROM job_events WHERE job_id = ? ORDER BY event_id", jobID)
+	if err != nil {
+		t.Fatalf("querying job_events: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var eventType string
+		if err := rows.Scan(&eventType); err != nil {
+			t.Fatalf("scanning job_events: %v", err)
+		}
+		got = append(got, eventType)
+	}
+	want := []string{"job_created", "job_started", "job_finished"}
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestRunFailedJob(t *testing.T) {
+	env := New(t)
+
+	// An unknown params field is a data error, which is never retried.
+	jobID := env.Enqueue(t, "golf", "2025-07-15", map[string]string{"site": "typo"})
+	job := env.Run(t, jobID)
+	if job.JobStatus != "failed" || job.Attempts != 1 {
+		t.Errorf("job = %s after %d attempts, want failed after 1", job.JobStatus, job.Attempts)
+	}
+}
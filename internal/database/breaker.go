@@ -0,0 +1,106 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of connecting to an upstream whose
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+var (
+	breakerMu        sync.Mutex
+	breakers         = map[string]*breaker{}
+	breakerThreshold = 5
+	breakerCooldown  = time.Minute
+	breakerHook      func(name string, open bool, err error)
+)
+
+// ConfigureBreaker sets after how many consecutive transient connection
+// failures an upstream's circuit opens, and how long it stays open before one
+// connection attempt is let through to probe it. threshold 0 disables the
+// breakers.
+func ConfigureBreaker(threshold int, cooldown time.Duration) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	breakerThreshold = threshold
+	breakerCooldown = cooldown
+}
+
+// OnBreakerChange registers fn to be called when an upstream's circuit opens,
+// with the failure that opened it, or closes again.
+func OnBreakerChange(fn func(name string, open bool, err error)) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	breakerHook = fn
+}
+
+// breaker tracks the connection failures of one upstream, e.g. "ERP" or a
+// golf site id. While open, connecting fails fast with ErrCircuitOpen so
+// pending jobs do not each wait out a connect timeout against a dead
+// listener.
+type breaker struct {
+	name      string
+	failures  int
+	open      bool
+	openUntil time.Time
+	probing   bool
+}
+
+func breakerFor(name string) *breaker {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	b, ok := breakers[name]
+	if !ok {
+		b = &breaker{name: name}
+		breakers[name] = b
+	}
+	return b
+}
+
+// allow reports ErrCircuitOpen while the circuit is open. After the cooldown
+// it lets a single probe through.
+func (b *breaker) allow() error {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	if breakerThreshold == 0 || !b.open {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return errclass.TransientError(fmt.Errorf("%s: %w until %s", b.name, ErrCircuitOpen, b.openUntil.Format(time.TimeOnly)))
+	}
+	b.probing = true
+	return nil
+}
+
+// record counts the outcome of a connection attempt. Only transient failures
+// count; a wrong password will not get better by waiting.
+func (b *breaker) record(err error) {
+	breakerMu.Lock()
+	hook := breakerHook
+	var changed, open bool
+	switch {
+	case err == nil:
+		changed = b.open
+		b.failures, b.open, b.probing = 0, false, false
+	case errclass.Classify(err) == errclass.Transient:
+		b.failures++
+		b.probing = false
+		if b.open || (breakerThreshold > 0 && b.failures >= breakerThreshold) {
+			changed, open = !b.open, true
+			b.open = true
+			b.openUntil = time.Now().Add(breakerCooldown)
+		}
+	default:
+		b.probing = false
+	}
+	breakerMu.Unlock()
+
+	if changed && hook != nil {
+		hook(b.name, open, err)
+	}
+}
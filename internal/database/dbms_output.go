@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+// MaxOutputLines bounds how many DBMS_OUTPUT lines ReadOutput collects.
+const MaxOutputLines = 1000
+
+// EnableOutput turns on DBMS_OUTPUT buffering for the session behind conn,
+// without a size limit. The output is only visible to that session, so the
+// procedure must run and be read on the same conn.
+func EnableOutput(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, "BEGIN DBMS_OUTPUT.ENABLE(NULL); END;"); err != nil {
+		return fmt.Errorf("enabling DBMS_OUTPUT: %w", err)
+	}
+	return nil
+}
+
+// ReadOutput drains the lines buffered with DBMS_OUTPUT on conn, up to
+// MaxOutputLines; the rest are discarded.
+func ReadOutput(ctx context.Context, conn *sql.Conn) ([]string, error) {
+	var lines []string
+	for len(lines) < MaxOutputLines {
+		var line string
+		var status int64
+		_, err := conn.ExecContext(ctx, "BEGIN DBMS_OUTPUT.GET_LINE(:1, :2); END;",
+			go_ora.Out{Dest: &line, Size: 32767}, go_ora.Out{Dest: &status})
+		if err != nil {
+			return lines, fmt.Errorf("reading DBMS_OUTPUT: %w", err)
+		}
+		// GET_LINE reports 1 once the buffer is empty.
+		if status != 0 {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+	// Clear what is left so it does not show up in the next caller's output.
+	if _, err := conn.ExecContext(ctx, "BEGIN DBMS_OUTPUT.DISABLE; END;"); err != nil {
+		return lines, fmt.Errorf("discarding DBMS_OUTPUT: %w", err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Database kinds, as passed to WithSlowLog.
+const (
+	KindOracle = "oracle"
+	KindMySQL  = "mysql"
+)
+
+type dbTimeKey struct{}
+
+// DBTime adds up how long the statements run under a context took, per
+// kind of database. Only the statement itself counts, not reading its rows.
+type DBTime struct {
+	oracle atomic.Int64
+	mysql  atomic.Int64
+}
+
+// WithDBTime returns a context whose statements are accounted in the
+// returned DBTime.
+func WithDBTime(ctx context.Context) (context.Context, *DBTime) {
+	t := &DBTime{}
+	return context.WithValue(ctx, dbTimeKey{}, t), t
+}
+
+func (t *DBTime) Oracle() time.Duration { return time.Duration(t.oracle.Load()) }
+func (t *DBTime) MySQL() time.Duration  { return time.Duration(t.mysql.Load()) }
+
+func addDBTime(ctx context.Context, kind string, d time.Duration) {
+	t, ok := ctx.Value(dbTimeKey{}).(*DBTime)
+	if !ok {
+		return
+	}
+	switch kind {
+	case KindOracle:
+		t.oracle.Add(int64(d))
+	case KindMySQL:
+		t.mysql.Add(int64(d))
+	}
+}
@@ -2,20 +2,24 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
 	"os"
-
-	_ "github.com/sijms/go-ora/v2"
 )
 
+// GetErpConnection returns the shared ERP pool. Callers must not close it.
 func GetErpConnection() (*sql.DB, error) {
 	// Use the ERP DSN from environment variables
 	erpDsn := os.Getenv("ERP_DSN")
+	if erpDsn == "" {
+		return nil, errclass.ConfigError(errors.New("ERP_DSN is not set"))
+	}
 
 	// Connect to the ERP database
-	db, err := sql.Open("oracle", erpDsn)
+	db, err := cachedOracle("ERP", erpDsn, os.Getenv("ERP_DSN_STANDBY"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to ERP database: %w", err)
+		return nil, errclass.ConfigError(fmt.Errorf("failed to connect to ERP database: %w", err))
 	}
 
 	return db, nil
@@ -3,32 +3,33 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
 	"os"
 	"strings"
-
-	_ "github.com/sijms/go-ora/v2"
 )
 
+// GetGolfConnection returns the shared pool of a golf site. Callers must not
+// close it.
 func GetGolfConnection(site_id string) (*sql.DB, error) {
 	// Use the GOLF DSN from environment variables
-	var golfDsn string
+	var golfDsn, standbyDsn string
 	switch strings.ToUpper(site_id) {
 	case "GC":
-		golfDsn = os.Getenv("ORACLE_DSN_GC")
+		golfDsn, standbyDsn = os.Getenv("ORACLE_DSN_GC"), os.Getenv("ORACLE_DSN_GC_STANDBY")
 	case "TH":
-		golfDsn = os.Getenv("ORACLE_DSN_TH")
+		golfDsn, standbyDsn = os.Getenv("ORACLE_DSN_TH"), os.Getenv("ORACLE_DSN_TH_STANDBY")
 	case "OS":
-		golfDsn = os.Getenv("ORACLE_DSN_OS")
+		golfDsn, standbyDsn = os.Getenv("ORACLE_DSN_OS"), os.Getenv("ORACLE_DSN_OS_STANDBY")
 	}
 
 	if golfDsn == "" {
-		return nil, fmt.Errorf("GOLF_DSN_XX not found for site_id: %s", strings.ToUpper(site_id))
+		return nil, errclass.ConfigError(fmt.Errorf("GOLF_DSN_XX not found for site_id: %s", strings.ToUpper(site_id)))
 	}
 
 	// Connect to the ERP database
-	db, err := sql.Open("oracle", golfDsn)
+	db, err := cachedOracle(strings.ToUpper(site_id), golfDsn, standbyDsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to GOLF database for site_id: %s: %w", strings.ToUpper(site_id), err)
+		return nil, errclass.ConfigError(fmt.Errorf("failed to connect to GOLF database for site_id: %s: %w", strings.ToUpper(site_id), err))
 	}
 
 	return db, nil
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+// HeldConn is an Oracle session checked out of its pool for a while.
+type HeldConn struct {
+	ID       int64
+	Upstream string
+	Since    time.Time
+	// Caller is the first function outside database/sql and the driver
+	// that checked the session out, e.g. a handler that never closed rows.
+	Caller string
+}
+
+var (
+	heldMu sync.Mutex
+	held   = map[*trackedConn]time.Time{}
+	nextID atomic.Int64
+)
+
+// trackedConn records when database/sql hands the session out and gets it
+// back: a new or reused session is checked out, and IsValid is called when
+// it returns to the pool.
+type trackedConn struct {
+	*go_ora.Connection
+	id       int64
+	upstream string
+	callers  [32]uintptr
+}
+
+func trackConn(conn driver.Conn, upstream string) driver.Conn {
+	oc, ok := conn.(*go_ora.Connection)
+	if !ok {
+		return conn
+	}
+	tc := &trackedConn{Connection: oc, id: nextID.Add(1), upstream: upstream}
+	tc.checkout()
+	return tc
+}
+
+func (c *trackedConn) checkout() {
+	runtime.Callers(3, c.callers[:])
+	heldMu.Lock()
+	held[c] = time.Now()
+	heldMu.Unlock()
+}
+
+func (c *trackedConn) checkin() {
+	heldMu.Lock()
+	delete(held, c)
+	heldMu.Unlock()
+}
+
+func (c *trackedConn) ResetSession(ctx context.Context) error {
+	if err := c.Connection.ResetSession(ctx); err != nil {
+		return err
+	}
+	c.checkout()
+	return nil
+}
+
+func (c *trackedConn) IsValid() bool {
+	c.checkin()
+	return true
+}
+
+func (c *trackedConn) Close() error {
+	c.checkin()
+	return c.Connection.Close()
+}
+
+// HeldLongerThan lists the Oracle sessions checked out for longer than d,
+// oldest first.
+func HeldLongerThan(d time.Duration) []HeldConn {
+	heldMu.Lock()
+	var conns []HeldConn
+	var callers [][32]uintptr
+	for c, since := range held {
+		if time.Since(since) > d {
+			conns = append(conns, HeldConn{ID: c.id, Upstream: c.upstream, Since: since})
+			callers = append(callers, c.callers)
+		}
+	}
+	heldMu.Unlock()
+
+	for i := range conns {
+		conns[i].Caller = firstCaller(callers[i][:])
+	}
+	slices.SortFunc(conns, func(a, b HeldConn) int { return a.Since.Compare(b.Since) })
+	return conns
+}
+
+func firstCaller(pcs []uintptr) string {
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fn := frame.Function
+		if fn != "" && !strings.HasPrefix(fn, "database/sql.") && !strings.Contains(fn, "/internal/database.") &&
+			!strings.Contains(fn, "sijms/go-ora") && !strings.HasPrefix(fn, "runtime.") {
+			return fn
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
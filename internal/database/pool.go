@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// poolCheckAfter is how long a pool may sit unused before it is pinged
+	// again before being handed out.
+	poolCheckAfter  = 5 * time.Minute
+	poolPingTimeout = 5 * time.Second
+)
+
+var (
+	poolsMu sync.Mutex
+	pools   = map[string]*pool{}
+)
+
+// pool is the cached *sql.DB of one upstream.
+type pool struct {
+	db       *sql.DB
+	dsn      string
+	standby  string
+	lastUsed time.Time
+}
+
+// cachedOracle returns the shared pool of upstream name, opening it on first
+// use. A pool idle for longer than poolCheckAfter is pinged first and rebuilt
+// if its sessions no longer answer, e.g. after a firewall dropped them
+// overnight. A changed DSN, such as rotated credentials, also rebuilds it.
+func cachedOracle(name, dsn, standby string) (*sql.DB, error) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	p, ok := pools[name]
+	if ok && (p.dsn != dsn || p.standby != standby) {
+		slog.Info("Oracle DSN changed, rebuilding pool", "upstream", name)
+		retirePool(p)
+		ok = false
+	}
+	if ok && time.Since(p.lastUsed) > poolCheckAfter {
+		ctx, cancel := context.WithTimeout(context.Background(), poolPingTimeout)
+		err := p.db.PingContext(ctx)
+		cancel()
+		if err != nil {
+			slog.Warn("Cached Oracle pool failed its ping, rebuilding", "upstream", name, "error", err)
+			retirePool(p)
+			ok = false
+		}
+	}
+	if !ok {
+		settings, err := oraclePoolSettings(name)
+		if err != nil {
+			return nil, err
+		}
+		db, err := openOracle(name, dsn, standby)
+		if err != nil {
+			return nil, err
+		}
+		settings.Apply(db)
+		p = &pool{db: db, dsn: dsn, standby: standby}
+		pools[name] = p
+	}
+	p.lastUsed = time.Now()
+	return p.db, nil
+}
+
+// retirePool closes p once the jobs still using it are done with it.
+func retirePool(p *pool) {
+	go p.db.Close()
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PoolSettings size a *sql.DB. Zero durations and MaxOpen mean no limit.
+type PoolSettings struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+	MaxIdleTime time.Duration
+}
+
+// DefaultMySQLPool leaves room for every worker plus the API and housekeeping.
+var DefaultMySQLPool = PoolSettings{MaxOpen: 10, MaxIdle: 10, MaxLifetime: time.Hour}
+
+// defaultOraclePool drops idle sessions before a firewall's idle timeout can
+// sever them silently.
+var defaultOraclePool = PoolSettings{MaxOpen: 5, MaxIdle: 2, MaxLifetime: time.Hour, MaxIdleTime: 10 * time.Minute}
+
+// ParsePoolSettings overrides the fields of def named in raw, a list such as
+// "max_open=10,max_idle=5,max_lifetime=1h,max_idle_time=10m".
+func ParsePoolSettings(raw string, def PoolSettings) (PoolSettings, error) {
+	p := def
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return p, fmt.Errorf("invalid entry %q, expected key=value", entry)
+		}
+
+		var err error
+		switch key {
+		case "max_open":
+			p.MaxOpen, err = strconv.Atoi(value)
+		case "max_idle":
+			p.MaxIdle, err = strconv.Atoi(value)
+		case "max_lifetime":
+			p.MaxLifetime, err = time.ParseDuration(value)
+		case "max_idle_time":
+			p.MaxIdleTime, err = time.ParseDuration(value)
+		default:
+			return p, fmt.Errorf("unknown setting %q, expected max_open, max_idle, max_lifetime or max_idle_time", key)
+		}
+		if err != nil || strings.HasPrefix(value, "-") {
+			return p, fmt.Errorf("invalid %s %q", key, value)
+		}
+	}
+	return p, nil
+}
+
+// Apply sizes db with p.
+func (p PoolSettings) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(p.MaxOpen)
+	db.SetMaxIdleConns(p.MaxIdle)
+	db.SetConnMaxLifetime(p.MaxLifetime)
+	db.SetConnMaxIdleTime(p.MaxIdleTime)
+}
+
+// oraclePoolSettings reads the settings of upstream name from
+// ORACLE_POOL_<NAME>, falling back to ORACLE_POOL for every Oracle pool.
+func oraclePoolSettings(name string) (PoolSettings, error) {
+	p, err := ParsePoolSettings(os.Getenv("ORACLE_POOL"), defaultOraclePool)
+	if err != nil {
+		return p, errclass.ConfigError(fmt.Errorf("parsing ORACLE_POOL: %w", err))
+	}
+	key := "ORACLE_POOL_" + strings.ToUpper(name)
+	p, err = ParsePoolSettings(os.Getenv(key), p)
+	if err != nil {
+		return p, errclass.ConfigError(fmt.Errorf("parsing %s: %w", key, err))
+	}
+	return p, nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	retryMu       sync.RWMutex
+	retryAttempts = 3
+	retryBackoff  = time.Second
+)
+
+// ConfigureRetry sets how often Retry repeats a statement after a transient
+// error and the delay before the first repeat, which doubles each time.
+// attempts 0 disables retrying.
+func ConfigureRetry(attempts int, backoff time.Duration) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryAttempts = attempts
+	retryBackoff = backoff
+}
+
+// Retry runs fn and repeats it with backoff while it fails with a transient
+// ORA- error, such as a lost session or a deadlock, before returning the last
+// error. fn must be safe to repeat: a read, or a procedure that rebuilds its
+// output from scratch.
+func Retry(ctx context.Context, op string, fn func() error) error {
+	retryMu.RLock()
+	attempts, backoff := retryAttempts, retryBackoff
+	retryMu.RUnlock()
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= attempts || !errclass.IsTransientOracle(err) {
+			return err
+		}
+		slog.Warn("Retrying Oracle statement after transient error", "op", op, "attempt", attempt+1,
+			"ora", errclass.OracleCode(err), "backoff", backoff, "error", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
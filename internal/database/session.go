@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"log/slog"
+	"os"
+	"strings"
+
+	go_ora "github.com/sijms/go-ora/v2"
+)
+
+// openOracle opens dsn like sql.Open("oracle", dsn), but applies the NLS
+// settings from ORACLE_NLS_DATE_FORMAT, ORACLE_NLS_NUMERIC_CHARACTERS and
+// ORACLE_NLS_LANG to every session the pool opens, so reports do not depend
+// on the instance defaults, and guards new sessions with the circuit breaker
+// of upstream name. When standby is set, sessions fall back to it while dsn
+// is unreachable.
+func openOracle(name, dsn, standby string) (*sql.DB, error) {
+	settings, err := nlsSettings()
+	if err != nil {
+		return nil, err
+	}
+	c := sessionConnector{
+		upstream:  name,
+		endpoints: []endpoint{{label: "primary", connector: go_ora.NewConnector(dsn), breaker: breakerFor(name)}},
+		init:      settings,
+	}
+	if standby != "" {
+		c.endpoints = append(c.endpoints, endpoint{
+			label:     "standby",
+			connector: go_ora.NewConnector(standby),
+			breaker:   breakerFor(name + " standby"),
+		})
+	}
+	return sql.OpenDB(c), nil
+}
+
+// nlsSettings returns the ALTER SESSION statements for the configured NLS
+// settings. NLS_LANG uses the client format LANGUAGE_TERRITORY.CHARSET; the
+// character set is ignored since the driver converts it itself.
+func nlsSettings() ([]string, error) {
+	params := [][2]string{
+		{"NLS_DATE_FORMAT", os.Getenv("ORACLE_NLS_DATE_FORMAT")},
+		{"NLS_NUMERIC_CHARACTERS", os.Getenv("ORACLE_NLS_NUMERIC_CHARACTERS")},
+	}
+	if lang := os.Getenv("ORACLE_NLS_LANG"); lang != "" {
+		lang, _, _ = strings.Cut(lang, ".")
+		i := strings.LastIndex(lang, "_")
+		if i <= 0 || i == len(lang)-1 {
+			return nil, errclass.ConfigError(fmt.Errorf("ORACLE_NLS_LANG must be LANGUAGE_TERRITORY[.CHARSET], got %q", lang))
+		}
+		params = append(params, [2]string{"NLS_LANGUAGE", lang[:i]}, [2]string{"NLS_TERRITORY", lang[i+1:]})
+	}
+
+	var settings []string
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		if p[0] == "NLS_NUMERIC_CHARACTERS" && len([]rune(p[1])) != 2 {
+			return nil, errclass.ConfigError(errors.New("ORACLE_NLS_NUMERIC_CHARACTERS must be the decimal and group separators, e.g. \".,\""))
+		}
+		settings = append(settings, fmt.Sprintf("ALTER SESSION SET %s = '%s'", p[0], strings.ReplaceAll(p[1], "'", "''")))
+	}
+	return settings, nil
+}
+
+// endpoint is one DSN of an upstream with its own circuit breaker.
+type endpoint struct {
+	label     string
+	connector driver.Connector
+	breaker   *breaker
+}
+
+// sessionConnector connects to the first reachable endpoint and runs init on
+// the new connection before the pool hands it out.
+type sessionConnector struct {
+	upstream  string
+	endpoints []endpoint
+	init      []string
+}
+
+func (c sessionConnector) Driver() driver.Driver {
+	return c.endpoints[0].connector.Driver()
+}
+
+func (c sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var err error
+	for i, ep := range c.endpoints {
+		var conn driver.Conn
+		if err = ep.breaker.allow(); err == nil {
+			conn, err = ep.connector.Connect(ctx)
+			ep.breaker.record(err)
+		}
+		if err == nil {
+			if len(c.endpoints) > 1 {
+				reportEndpoint(ctx, c.upstream, ep.label)
+			}
+			return c.initSession(ctx, conn)
+		}
+		// Only an unreachable endpoint is worth failing over from.
+		if errclass.Classify(err) != errclass.Transient {
+			return nil, err
+		}
+		if i < len(c.endpoints)-1 {
+			slog.Warn("Oracle endpoint unreachable, failing over", "upstream", c.upstream, "endpoint", ep.label, "error", err)
+		}
+	}
+	return nil, err
+}
+
+func (c sessionConnector) initSession(ctx context.Context, conn driver.Conn) (driver.Conn, error) {
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		conn.Close()
+		return nil, errors.New("oracle driver does not support ExecContext")
+	}
+	for _, stmt := range c.init {
+		if _, err := execer.ExecContext(ctx, stmt, nil); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return &slowConn{conn: trackConn(conn, c.upstream), kind: KindOracle, target: c.upstream}, nil
+}
+
+type endpointReporterKey struct{}
+
+// WithEndpointReporter returns a context under which every new session to an
+// upstream with a standby calls fn with the upstream and the endpoint,
+// "primary" or "standby", that served it.
+func WithEndpointReporter(ctx context.Context, fn func(upstream, endpoint string)) context.Context {
+	return context.WithValue(ctx, endpointReporterKey{}, fn)
+}
+
+func reportEndpoint(ctx context.Context, upstream, endpoint string) {
+	if fn, ok := ctx.Value(endpointReporterKey{}).(func(upstream, endpoint string)); ok {
+		fn(upstream, endpoint)
+	}
+}
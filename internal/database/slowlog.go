@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSlowQueryThreshold is used when SLOW_QUERY_THRESHOLD is not set.
+const DefaultSlowQueryThreshold = 5 * time.Second
+
+const (
+	maxLoggedSQL   = 1000
+	maxLoggedValue = 64
+)
+
+var slowThreshold atomic.Int64
+
+func init() {
+	slowThreshold.Store(int64(DefaultSlowQueryThreshold))
+}
+
+// SlowQueryThresholdFromEnv reads SLOW_QUERY_THRESHOLD (a Go duration such as
+// "5s"; "0" disables slow query logging) and applies it.
+func SlowQueryThresholdFromEnv() error {
+	raw := os.Getenv("SLOW_QUERY_THRESHOLD")
+	if raw == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("SLOW_QUERY_THRESHOLD must be a non-negative duration, got %q", raw)
+	}
+	slowThreshold.Store(int64(d))
+	return nil
+}
+
+// WithSlowLog wraps connector so statements on its connections that take
+// longer than SLOW_QUERY_THRESHOLD are logged at WARN with their SQL, bind
+// values and target, the database name shown in the log. Statement time is
+// also accounted to the DBTime of the statement's context under kind,
+// KindOracle or KindMySQL.
+func WithSlowLog(connector driver.Connector, kind, target string) driver.Connector {
+	return slowConnector{Connector: connector, kind: kind, target: target}
+}
+
+type slowConnector struct {
+	driver.Connector
+	kind   string
+	target string
+}
+
+func (c slowConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &slowConn{conn: conn, kind: c.kind, target: c.target}, nil
+}
+
+// slowConn times the statements run on conn and forwards every optional
+// interface database/sql uses.
+type slowConn struct {
+	conn   driver.Conn
+	kind   string
+	target string
+}
+
+func (c *slowConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *slowConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &slowStmt{Stmt: stmt, query: query, kind: c.kind, target: c.target}, nil
+}
+
+func (c *slowConn) Close() error { return c.conn.Close() }
+
+func (c *slowConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *slowConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.conn.Begin()
+}
+
+func (c *slowConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer observe(ctx, time.Now(), c.kind, c.target, query, args)
+	return e.ExecContext(ctx, query, args)
+}
+
+func (c *slowConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer observe(ctx, time.Now(), c.kind, c.target, query, args)
+	return q.QueryContext(ctx, query, args)
+}
+
+func (c *slowConn) Ping(ctx context.Context) error {
+	if p, ok := c.conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *slowConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *slowConn) IsValid() bool {
+	if v, ok := c.conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *slowConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// slowStmt times a prepared statement, which database/sql uses when the
+// driver cannot run a statement with arguments directly.
+type slowStmt struct {
+	driver.Stmt
+	query  string
+	kind   string
+	target string
+}
+
+func (s *slowStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer observe(ctx, time.Now(), s.kind, s.target, s.query, args)
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	return s.Stmt.Exec(namedToValues(args))
+}
+
+func (s *slowStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	defer observe(ctx, time.Now(), s.kind, s.target, s.query, args)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	return s.Stmt.Query(namedToValues(args))
+}
+
+func (s *slowStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func namedToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+// observe accounts a finished statement and logs it if it was slow.
+func observe(ctx context.Context, start time.Time, kind, target, query string, args []driver.NamedValue) {
+	elapsed := time.Since(start)
+	addDBTime(ctx, kind, elapsed)
+	threshold := time.Duration(slowThreshold.Load())
+	if threshold == 0 || elapsed < threshold {
+		return
+	}
+	slog.Warn("Slow query", "target", target, "duration", elapsed.Round(time.Millisecond),
+		"sql", sanitizeSQL(query), "args", formatArgs(args))
+}
+
+var (
+	sqlSpace   = regexp.MustCompile(`\s+`)
+	sqlLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+)
+
+// sanitizeSQL puts the statement on one line and replaces string literals,
+// which may hold personal data, with '?'.
+func sanitizeSQL(query string) string {
+	query = sqlLiteral.ReplaceAllString(query, "'?'")
+	query = strings.TrimSpace(sqlSpace.ReplaceAllString(query, " "))
+	if len(query) > maxLoggedSQL {
+		query = strings.ToValidUTF8(query[:maxLoggedSQL], "") + "..."
+	}
+	return query
+}
+
+// formatArgs renders bind values, shortening long strings and leaving out
+// binary data.
+func formatArgs(args []driver.NamedValue) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		var v string
+		switch value := arg.Value.(type) {
+		case []byte:
+			v = fmt.Sprintf("<%d bytes>", len(value))
+		case time.Time:
+			v = value.Format(time.DateTime)
+		default:
+			v = fmt.Sprint(value)
+		}
+		if len(v) > maxLoggedValue {
+			v = strings.ToValidUTF8(v[:maxLoggedValue], "") + "..."
+		}
+		if arg.Name != "" {
+			v = arg.Name + "=" + v
+		}
+		out[i] = v
+	}
+	return out
+}
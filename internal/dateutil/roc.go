@@ -0,0 +1,59 @@
+// Package dateutil holds date helpers for the ERP, which stores some dates
+// in ROC (Minguo) years, where ROC year 1 is 1912.
+package dateutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rocOffset is the difference between Gregorian and ROC years.
+const rocOffset = 1911
+
+// ROCYear returns the ROC year of t, e.g. 114 for 2025.
+func ROCYear(t time.Time) int {
+	return t.Year() - rocOffset
+}
+
+// FormatROC formats t as a compact ROC date, e.g. "1140715" for 2025-07-15.
+// The year is zero-padded to three digits as the ERP expects.
+func FormatROC(t time.Time) string {
+	return fmt.Sprintf("%03d%02d%02d", ROCYear(t), t.Month(), t.Day())
+}
+
+// FormatROCSep formats t with sep between the parts, e.g. "114/07/15".
+func FormatROCSep(t time.Time, sep string) string {
+	return fmt.Sprintf("%03d%s%02d%s%02d", ROCYear(t), sep, t.Month(), sep, t.Day())
+}
+
+// ParseROC parses a compact ("1140715") or separated ("114/07/15",
+// "114-07-15") ROC date in loc.
+func ParseROC(s string, loc *time.Location) (time.Time, error) {
+	var parts []string
+	if strings.ContainsAny(s, "/-.") {
+		parts = strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == '-' || r == '.' })
+	} else if len(s) >= 5 {
+		parts = []string{s[:len(s)-4], s[len(s)-4 : len(s)-2], s[len(s)-2:]}
+	}
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid ROC date %q", s)
+	}
+
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid ROC date %q", s)
+		}
+		nums[i] = n
+	}
+
+	year, month, day := nums[0]+rocOffset, time.Month(nums[1]), nums[2]
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if nums[0] < 1 || t.Month() != month || t.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid ROC date %q", s)
+	}
+	return t, nil
+}
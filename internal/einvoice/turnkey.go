@@ -0,0 +1,279 @@
+// Package einvoice hands invoices to the MOF e-invoice Turnkey client.
+//
+// Turnkey is the Ministry of Finance's file exchange program, installed next
+// to the scheduler. It uploads the MIG XML message files placed in a
+// message type's SRC folder to the e-invoice platform and moves each file to
+// BAK once sent, or to ERR when it fails validation; its own log records why.
+// This package writes C0401 (B2C invoice issue) messages and reads their
+// outcome from those folders:
+//
+//	<EINVOICE_TURNKEY_DIR>/SRC/C0401_AB12345678.xml  waiting for Turnkey
+//	<EINVOICE_TURNKEY_DIR>/BAK/C0401_AB12345678.xml  sent to the platform
+//	<EINVOICE_TURNKEY_DIR>/ERR/C0401_AB12345678.xml  rejected
+//
+// Invoice numbers come from the track the tax office assigned to the seller,
+// see NumberRange.
+package einvoice
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// StatusSubmitted means the message was written to SRC.
+	StatusSubmitted = "submitted"
+	// StatusAccepted means Turnkey sent the message to the platform.
+	StatusAccepted = "accepted"
+	// StatusRejected means Turnkey moved the message to ERR.
+	StatusRejected = "rejected"
+
+	c0401Namespace = "urn:GEINV:eInvoiceMessage:C0401:4.0"
+	// b2cBuyer is the buyer identifier of invoices issued to consumers.
+	b2cBuyer = "0000000000"
+	// defaultDescription is the product line of a funeral invoice.
+	defaultDescription = "禮儀服務"
+)
+
+type Invoice struct {
+	// Number is the invoice number, e.g. AB12345678.
+	Number      string
+	InvoiceDate string
+	// CustomerID identifies the invoice in funeral_invoices; it is not
+	// printed on a B2C invoice.
+	CustomerID string
+	// 含稅額(除以10), as stored in funeral_invoices
+	TotalAmount int
+}
+
+// Turnkey writes C0401 messages to one Turnkey message folder.
+type Turnkey struct {
+	dir         string
+	sellerID    string
+	sellerName  string
+	description string
+}
+
+// NewTurnkeyFromEnv configures Turnkey from EINVOICE_TURNKEY_DIR, the C0401
+// folder holding SRC, BAK and ERR, EINVOICE_SELLER_ID, the seller's 8-digit
+// business number, EINVOICE_SELLER_NAME and, optionally,
+// EINVOICE_ITEM_DESCRIPTION.
+func NewTurnkeyFromEnv() (*Turnkey, error) {
+	t := &Turnkey{
+		dir:         os.Getenv("EINVOICE_TURNKEY_DIR"),
+		sellerID:    os.Getenv("EINVOICE_SELLER_ID"),
+		sellerName:  os.Getenv("EINVOICE_SELLER_NAME"),
+		description: os.Getenv("EINVOICE_ITEM_DESCRIPTION"),
+	}
+	if t.dir == "" || t.sellerName == "" {
+		return nil, errclass.ConfigError(errors.New("EINVOICE_TURNKEY_DIR and EINVOICE_SELLER_NAME must be set"))
+	}
+	if !businessNumber.MatchString(t.sellerID) {
+		return nil, errclass.ConfigError(fmt.Errorf("EINVOICE_SELLER_ID must be an 8-digit business number, got %q", t.sellerID))
+	}
+	if t.description == "" {
+		t.description = defaultDescription
+	}
+	return t, nil
+}
+
+var businessNumber = regexp.MustCompile(`^[0-9]{8}$`)
+
+// Submit writes inv as a C0401 message to SRC. The file is written under a
+// temporary name and renamed, so Turnkey never picks up a partial message.
+func (t *Turnkey) Submit(inv Invoice) error {
+	body, err := t.encode(inv)
+	if err != nil {
+		return errclass.DataError(fmt.Errorf("invoice %s: %w", inv.Number, err))
+	}
+
+	src := filepath.Join(t.dir, "SRC")
+	tmp, err := os.CreateTemp(src, ".c0401-*.tmp")
+	if err != nil {
+		return errclass.TransientError(fmt.Errorf("writing invoice %s: %w", inv.Number, err))
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return errclass.TransientError(fmt.Errorf("writing invoice %s: %w", inv.Number, err))
+	}
+	if err := tmp.Close(); err != nil {
+		return errclass.TransientError(fmt.Errorf("writing invoice %s: %w", inv.Number, err))
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(src, fileName(inv.Number))); err != nil {
+		return errclass.TransientError(fmt.Errorf("writing invoice %s: %w", inv.Number, err))
+	}
+	return nil
+}
+
+// Status reports where Turnkey has put the message of invoice number: one of
+// the Status constants, or "" when there is no such message, e.g. because
+// Submit failed after the number was assigned.
+func (t *Turnkey) Status(number string) (string, error) {
+	for _, f := range []struct{ folder, status string }{
+		{"ERR", StatusRejected},
+		{"BAK", StatusAccepted},
+		{"SRC", StatusSubmitted},
+	} {
+		_, err := os.Stat(filepath.Join(t.dir, f.folder, fileName(number)))
+		if err == nil {
+			return f.status, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", errclass.TransientError(fmt.Errorf("reading status of invoice %s: %w", number, err))
+		}
+	}
+	return "", nil
+}
+
+func fileName(number string) string {
+	return "C0401_" + number + ".xml"
+}
+
+type c0401 struct {
+	XMLName xml.Name     `xml:"Invoice"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Main    c0401Main    `xml:"Main"`
+	Details c0401Details `xml:"Details"`
+	Amount  c0401Amount  `xml:"Amount"`
+}
+
+type c0401Main struct {
+	InvoiceNumber string     `xml:"InvoiceNumber"`
+	InvoiceDate   string     `xml:"InvoiceDate"`
+	InvoiceTime   string     `xml:"InvoiceTime"`
+	Seller        c0401Party `xml:"Seller"`
+	Buyer         c0401Party `xml:"Buyer"`
+	InvoiceType   string     `xml:"InvoiceType"`
+	DonateMark    string     `xml:"DonateMark"`
+	PrintMark     string     `xml:"PrintMark"`
+}
+
+type c0401Party struct {
+	Identifier string `xml:"Identifier"`
+	Name       string `xml:"Name"`
+}
+
+type c0401Details struct {
+	ProductItem []c0401Item `xml:"ProductItem"`
+}
+
+type c0401Item struct {
+	Description    string `xml:"Description"`
+	Quantity       int    `xml:"Quantity"`
+	UnitPrice      int    `xml:"UnitPrice"`
+	Amount         int    `xml:"Amount"`
+	SequenceNumber string `xml:"SequenceNumber"`
+}
+
+type c0401Amount struct {
+	SalesAmount        int    `xml:"SalesAmount"`
+	FreeTaxSalesAmount int    `xml:"FreeTaxSalesAmount"`
+	ZeroTaxSalesAmount int    `xml:"ZeroTaxSalesAmount"`
+	TaxType            string `xml:"TaxType"`
+	TaxRate            string `xml:"TaxRate"`
+	TaxAmount          int    `xml:"TaxAmount"`
+	TotalAmount        int    `xml:"TotalAmount"`
+}
+
+// encode renders inv as a C0401 message: a taxable B2C invoice with one
+// product line. B2C amounts include tax, so TaxAmount is 0.
+func (t *Turnkey) encode(inv Invoice) ([]byte, error) {
+	if !invoiceNumber.MatchString(inv.Number) {
+		return nil, fmt.Errorf("invalid invoice number %q", inv.Number)
+	}
+	date, err := time.Parse("2006-01-02", inv.InvoiceDate)
+	if err != nil {
+		return nil, fmt.Errorf("invoice_date must be YYYY-MM-DD, got %q", inv.InvoiceDate)
+	}
+	if inv.TotalAmount <= 0 {
+		return nil, fmt.Errorf("total amount must be positive, got %d", inv.TotalAmount)
+	}
+	total := inv.TotalAmount * 10
+
+	msg := c0401{
+		Xmlns: c0401Namespace,
+		Main: c0401Main{
+			InvoiceNumber: inv.Number,
+			InvoiceDate:   date.Format("20060102"),
+			InvoiceTime:   "00:00:00",
+			Seller:        c0401Party{Identifier: t.sellerID, Name: t.sellerName},
+			Buyer:         c0401Party{Identifier: b2cBuyer, Name: "0000"},
+			InvoiceType:   "07",
+			DonateMark:    "0",
+			PrintMark:     "Y",
+		},
+		Details: c0401Details{ProductItem: []c0401Item{{
+			Description:    t.description,
+			Quantity:       1,
+			UnitPrice:      total,
+			Amount:         total,
+			SequenceNumber: "1",
+		}}},
+		Amount: c0401Amount{
+			SalesAmount: total,
+			TaxType:     "1",
+			TaxRate:     "0.05",
+			TotalAmount: total,
+		},
+	}
+	body, err := xml.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+var invoiceNumber = regexp.MustCompile(`^[A-Z]{2}[0-9]{8}$`)
+
+// NumberRange is an invoice number track such as AB12345000-AB12345049.
+type NumberRange struct {
+	Prefix      string
+	First, Last int
+}
+
+// ParseNumberRange parses EINVOICE_INVOICE_NUMBERS, a range "FIRST-LAST" of
+// invoice numbers with the same two-letter track.
+func ParseNumberRange(raw string) (NumberRange, error) {
+	first, last, ok := strings.Cut(raw, "-")
+	if !ok || !invoiceNumber.MatchString(first) || !invoiceNumber.MatchString(last) || first[:2] != last[:2] {
+		return NumberRange{}, fmt.Errorf("invoice numbers must be a range like AB12345000-AB12345049, got %q", raw)
+	}
+	r := NumberRange{Prefix: first[:2]}
+	r.First, _ = strconv.Atoi(first[2:])
+	r.Last, _ = strconv.Atoi(last[2:])
+	if r.First > r.Last {
+		return NumberRange{}, fmt.Errorf("invoice number range %q is reversed", raw)
+	}
+	return r, nil
+}
+
+// Bounds returns the first and last number of the range.
+func (r NumberRange) Bounds() (first, last string) {
+	return r.format(r.First), r.format(r.Last)
+}
+
+// Next returns the number after last, which must be in the range, or the
+// first number when last is empty. ok is false when the range is used up.
+func (r NumberRange) Next(last string) (next string, ok bool) {
+	if last == "" {
+		return r.format(r.First), true
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(last, r.Prefix))
+	if err != nil || n >= r.Last {
+		return "", false
+	}
+	return r.format(n + 1), true
+}
+
+func (r NumberRange) format(n int) string {
+	return fmt.Sprintf("%s%08d", r.Prefix, n)
+}
@@ -0,0 +1,118 @@
+package einvoice
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func newTestTurnkey(t *testing.T) *Turnkey {
+	t.Helper()
+	dir := t.TempDir()
+	for _, folder := range []string{"SRC", "BAK", "ERR"} {
+		if err := os.Mkdir(filepath.Join(dir, folder), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("EINVOICE_TURNKEY_DIR", dir)
+	t.Setenv("EINVOICE_SELLER_ID", "12345678")
+	t.Setenv("EINVOICE_SELLER_NAME", "Test Funeral Co.")
+	tk, err := NewTurnkeyFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tk
+}
+
+func TestSubmitWritesC0401(t *testing.T) {
+	tk := newTestTurnkey(t)
+	inv := Invoice{Number: "AB12345678", InvoiceDate: "2025-07-15", CustomerID: "A123456789", TotalAmount: 1050}
+	if err := tk.Submit(inv); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(tk.dir, "SRC", "C0401_AB12345678.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg c0401
+	if err := xml.Unmarshal(raw, &msg); err != nil {
+		t.Fatalf("decoding message: %v", err)
+	}
+	if msg.XMLName.Space != c0401Namespace || msg.Main.InvoiceNumber != "AB12345678" || msg.Main.InvoiceDate != "20250715" {
+		t.Errorf("message header = %+v %+v", msg.XMLName, msg.Main)
+	}
+	if msg.Main.Seller.Identifier != "12345678" || msg.Main.Buyer.Identifier != b2cBuyer {
+		t.Errorf("seller = %+v, buyer = %+v", msg.Main.Seller, msg.Main.Buyer)
+	}
+	if msg.Amount.TotalAmount != 10500 || msg.Amount.SalesAmount != 10500 || msg.Amount.TaxAmount != 0 {
+		t.Errorf("amount = %+v, want a tax-inclusive total of 10500", msg.Amount)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(tk.dir, "SRC"))
+	if len(entries) != 1 {
+		t.Errorf("SRC holds %d files, want only the message", len(entries))
+	}
+}
+
+func TestStatusFollowsTurnkeyFolders(t *testing.T) {
+	tk := newTestTurnkey(t)
+	if status, err := tk.Status("AB12345678"); err != nil || status != "" {
+		t.Fatalf("Status of an unknown invoice = %q, %v", status, err)
+	}
+
+	if err := tk.Submit(Invoice{Number: "AB12345678", InvoiceDate: "2025-07-15", TotalAmount: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := tk.Status("AB12345678"); status != StatusSubmitted {
+		t.Errorf("Status in SRC = %q, want %q", status, StatusSubmitted)
+	}
+
+	// Turnkey moves a sent message to BAK; check ERR wins over BAK too.
+	from := filepath.Join(tk.dir, "SRC", fileName("AB12345678"))
+	for _, step := range []struct{ folder, want string }{{"BAK", StatusAccepted}, {"ERR", StatusRejected}} {
+		to := filepath.Join(tk.dir, step.folder, fileName("AB12345678"))
+		if err := os.Link(from, to); err != nil {
+			t.Fatal(err)
+		}
+		if status, _ := tk.Status("AB12345678"); status != step.want {
+			t.Errorf("Status in %s = %q, want %q", step.folder, status, step.want)
+		}
+	}
+}
+
+func TestSubmitRejectsBadInvoice(t *testing.T) {
+	tk := newTestTurnkey(t)
+	for _, inv := range []Invoice{
+		{Number: "AB1234", InvoiceDate: "2025-07-15", TotalAmount: 100},
+		{Number: "AB12345678", InvoiceDate: "15/07/2025", TotalAmount: 100},
+		{Number: "AB12345678", InvoiceDate: "2025-07-15", TotalAmount: 0},
+	} {
+		if err := tk.Submit(inv); err == nil {
+			t.Errorf("Submit(%+v) succeeded", inv)
+		}
+	}
+}
+
+func TestNumberRange(t *testing.T) {
+	r, err := ParseNumberRange("AB12345998-AB12346000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for n, ok := r.Next(""); ok; n, ok = r.Next(n) {
+		got = append(got, n)
+	}
+	want := []string{"AB12345998", "AB12345999", "AB12346000"}
+	if !slices.Equal(got, want) {
+		t.Errorf("numbers = %v, want %v", got, want)
+	}
+
+	for _, raw := range []string{"", "AB12345678", "AB12345678-CD12345679", "AB12345679-AB12345678"} {
+		if _, err := ParseNumberRange(raw); err == nil {
+			t.Errorf("ParseNumberRange(%q) succeeded", raw)
+		}
+	}
+}
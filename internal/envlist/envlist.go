@@ -0,0 +1,16 @@
+// Package envlist parses list-valued settings such as NOTIFY_CHANNELS.
+package envlist
+
+import "strings"
+
+// Split splits a comma-separated list, trimming entries and dropping empty
+// ones, so "a, b,," is ["a" "b"] and "" is nil.
+func Split(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
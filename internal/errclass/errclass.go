@@ -0,0 +1,126 @@
+// Package errclass classifies job and database errors so the retry engine can
+// tell a dropped Oracle connection (worth retrying) from malformed params
+// (never going to succeed).
+package errclass
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type Category string
+
+const (
+	// Transient errors such as connection resets, timeouts and deadlocks may
+	// succeed when retried.
+	Transient Category = "transient"
+	// Data errors such as malformed params or rows will fail again.
+	Data Category = "data"
+	// Config errors such as a missing DSN need an operator to fix them.
+	Config Category = "config"
+	// Unknown is used for errors that carry no classification.
+	Unknown Category = "unknown"
+)
+
+// Error attaches a Category to an underlying error.
+type Error struct {
+	Category Category
+	Err      error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap classifies err as category. It returns nil for a nil err.
+func Wrap(category Category, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: category, Err: err}
+}
+
+func TransientError(err error) error { return Wrap(Transient, err) }
+func DataError(err error) error      { return Wrap(Data, err) }
+func ConfigError(err error) error    { return Wrap(Config, err) }
+
+// transientOracleCodes are ORA- errors caused by lost sessions, unreachable
+// listeners, deadlocks and resource contention rather than by the statement.
+var transientOracleCodes = map[int]bool{
+	60:    true, // deadlock detected while waiting for resource
+	1033:  true, // ORACLE initialization or shutdown in progress
+	1034:  true, // ORACLE not available
+	1089:  true, // immediate shutdown in progress
+	3113:  true, // end-of-file on communication channel
+	3114:  true, // not connected to ORACLE
+	3135:  true, // connection lost contact
+	12170: true, // TNS:Connect timeout occurred
+	12514: true, // TNS:listener does not currently know of service
+	12516: true, // TNS:listener could not find available handler
+	12519: true, // TNS:no appropriate service handler found
+	12528: true, // TNS:listener: all appropriate instances are blocking new connections
+	12537: true, // TNS:connection closed
+	12541: true, // TNS:no listener
+	12543: true, // TNS:destination host unreachable
+	12545: true, // Connect failed because target host or object does not exist
+	12547: true, // TNS:lost contact
+	12571: true, // TNS:packet writer failure
+}
+
+var oracleCodePattern = regexp.MustCompile(`ORA-(\d{5})`)
+
+// OracleCode extracts the first ORA- error code from err, or 0 if none.
+func OracleCode(err error) int {
+	match := oracleCodePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+	code, _ := strconv.Atoi(match[1])
+	return code
+}
+
+// IsTransientOracle reports whether err carries a transient ORA- code.
+func IsTransientOracle(err error) bool {
+	return err != nil && transientOracleCodes[OracleCode(err)]
+}
+
+// Classify returns the category of err. Explicitly wrapped errors keep their
+// category; otherwise well-known transient driver and network failures are
+// recognised and everything else is Unknown.
+func Classify(err error) Category {
+	if err == nil {
+		return ""
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Category
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return Transient
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Transient
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1205, 1213: // lock wait timeout, deadlock
+			return Transient
+		}
+	}
+
+	if IsTransientOracle(err) {
+		return Transient
+	}
+	return Unknown
+}
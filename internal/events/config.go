@@ -0,0 +1,57 @@
+package events
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"hotbrandon/go-cron-be/internal/notify"
+	"io"
+	"os"
+)
+
+// FromEnv builds the publishers listed in EVENT_PUBLISHERS (comma-separated:
+// notify, kafka, nats, rabbitmq). "notify" forwards events to n and is the
+// default when the variable is empty.
+func FromEnv(n notify.Notifier) ([]Publisher, error) {
+	names := envlist.Split(os.Getenv("EVENT_PUBLISHERS"))
+	if len(names) == 0 {
+		names = []string{"notify"}
+	}
+
+	var publishers []Publisher
+	for _, name := range names {
+		switch name {
+		case "notify":
+			publishers = append(publishers, NewNotifierPublisher(n))
+		case "kafka":
+			p, err := NewKafkaPublisherFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, p)
+		case "nats":
+			p, err := NewNATSPublisherFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, p)
+		case "rabbitmq":
+			p, err := NewRabbitMQPublisherFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, p)
+		default:
+			return nil, fmt.Errorf("unknown event publisher %q in EVENT_PUBLISHERS", name)
+		}
+	}
+	return publishers, nil
+}
+
+// Close closes the publishers that hold connections.
+func Close(publishers []Publisher) {
+	for _, p := range publishers {
+		if c, ok := p.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
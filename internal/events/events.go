@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/notify"
+	"time"
+)
+
+type Type string
+
+const (
+	JobCreated  Type = "job_created"
+	JobStarted  Type = "job_started"
+	JobFinished Type = "job_finished"
+	JobFailed   Type = "job_failed"
+)
+
+// Event is a job lifecycle event as stored in the job_events outbox.
+type Event struct {
+	ID        int64           `json:"event_id"`
+	Type      Type            `json:"event_type"`
+	JobID     int64           `json:"job_id"`
+	JobName   string          `json:"job_name"`
+	JobDate   string          `json:"job_date"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Publisher delivers outbox events to a consumer. Delivery is at-least-once,
+// so consumers should de-duplicate on Event.ID.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// NotifierPublisher forwards events to a notifier as informational messages,
+// or warnings for failed jobs.
+type NotifierPublisher struct {
+	notifier notify.Notifier
+}
+
+func NewNotifierPublisher(n notify.Notifier) *NotifierPublisher {
+	return &NotifierPublisher{notifier: n}
+}
+
+func (p *NotifierPublisher) Publish(ctx context.Context, e Event) error {
+	severity := notify.SeverityInfo
+	if e.Type == JobFailed {
+		severity = notify.SeverityWarning
+	}
+	return p.notifier.Notify(ctx, notify.Message{
+		Event:    string(e.Type),
+		Subject:  fmt.Sprintf("%s: %s #%d", e.Type, e.JobName, e.JobID),
+		Body:     fmt.Sprintf("Job %d (%s, job_date %s) event %s at %s.", e.JobID, e.JobName, e.JobDate, e.Type, e.CreatedAt.Format(time.RFC3339)),
+		Severity: severity,
+		JobName:  e.JobName,
+		JobID:    e.JobID,
+	})
+}
@@ -0,0 +1,574 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kafka API keys and the versions spoken by KafkaPublisher. Metadata v4 and
+// Produce v3 are understood by brokers from Kafka 1.0 through 4.x.
+const (
+	kafkaProduceKey      = 0
+	kafkaProduceVersion  = 3
+	kafkaMetadataKey     = 3
+	kafkaMetadataVersion = 4
+	// SaslHandshake v1 and SaslAuthenticate v0 are understood by brokers
+	// from Kafka 1.0 on.
+	kafkaSaslHandshakeKey        = 17
+	kafkaSaslHandshakeVersion    = 1
+	kafkaSaslAuthenticateKey     = 36
+	kafkaSaslAuthenticateVersion = 0
+
+	kafkaDialTimeout    = 10 * time.Second
+	kafkaProduceTimeout = 30 * time.Second
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// KafkaPublisher writes each event as one record to a Kafka topic. The record
+// key is the job id, so all events of a job land on the same partition in
+// order; the value is the Event as JSON and the event type is also sent as
+// the "event_type" header.
+//
+// It implements just enough of the Kafka wire protocol to produce
+// uncompressed records without idempotence, authenticating with SASL/PLAIN
+// when a username is configured.
+type KafkaPublisher struct {
+	brokers  []string
+	topic    string
+	clientID string
+	acks     int16
+	tls      *tls.Config
+	// saslUser and saslPass are the SASL/PLAIN credentials; empty saslUser
+	// skips authentication.
+	saslUser string
+	saslPass string
+
+	mu            sync.Mutex
+	conns         map[string]*kafkaConn
+	leaders       map[int32]string // partition -> broker address
+	partitions    int32
+	correlationID int32
+}
+
+// NewKafkaPublisherFromEnv configures the publisher from KAFKA_BROKERS
+// (comma-separated host:port), KAFKA_TOPIC, KAFKA_CLIENT_ID (default
+// go-cron-be), KAFKA_ACKS (default -1, all in-sync replicas), KAFKA_TLS and
+// KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD. KAFKA_SASL_MECHANISM may only
+// be PLAIN, the default; the password is sent as is, so use it with TLS.
+func NewKafkaPublisherFromEnv() (*KafkaPublisher, error) {
+	p := &KafkaPublisher{
+		brokers:  envlist.Split(os.Getenv("KAFKA_BROKERS")),
+		topic:    os.Getenv("KAFKA_TOPIC"),
+		clientID: os.Getenv("KAFKA_CLIENT_ID"),
+		acks:     -1,
+		saslUser: os.Getenv("KAFKA_SASL_USERNAME"),
+		saslPass: os.Getenv("KAFKA_SASL_PASSWORD"),
+		conns:    make(map[string]*kafkaConn),
+	}
+	if len(p.brokers) == 0 || p.topic == "" {
+		return nil, errors.New("KAFKA_BROKERS and KAFKA_TOPIC must be set for the kafka publisher")
+	}
+	if m := os.Getenv("KAFKA_SASL_MECHANISM"); m != "" && m != "PLAIN" {
+		return nil, fmt.Errorf("unsupported KAFKA_SASL_MECHANISM %q: only PLAIN is supported", m)
+	}
+	if p.clientID == "" {
+		p.clientID = "go-cron-be"
+	}
+	if raw := os.Getenv("KAFKA_ACKS"); raw != "" {
+		acks, err := strconv.ParseInt(raw, 10, 16)
+		if err != nil || (acks != -1 && acks != 0 && acks != 1) {
+			return nil, fmt.Errorf("invalid KAFKA_ACKS %q: must be -1, 0 or 1", raw)
+		}
+		p.acks = int16(acks)
+	}
+	if raw := os.Getenv("KAFKA_TLS"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KAFKA_TLS %q: %w", raw, err)
+		}
+		if enabled {
+			p.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+	}
+	return p, nil
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, e Event) error {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("kafka: encoding event %d: %w", e.ID, err)
+	}
+	key := []byte(strconv.FormatInt(e.JobID, 10))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.produce(ctx, key, value, string(e.Type), e.CreatedAt); err != nil {
+		// Leadership may have moved or the connection dropped; start over
+		// with fresh metadata on the next attempt.
+		p.reset()
+		return fmt.Errorf("kafka: publishing event %d to %s: %w", e.ID, p.topic, err)
+	}
+	return nil
+}
+
+// Close closes all broker connections.
+func (p *KafkaPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+	return nil
+}
+
+func (p *KafkaPublisher) reset() {
+	for addr, c := range p.conns {
+		c.Close()
+		delete(p.conns, addr)
+	}
+	p.leaders = nil
+}
+
+func (p *KafkaPublisher) produce(ctx context.Context, key, value []byte, eventType string, ts time.Time) error {
+	if p.leaders == nil {
+		if err := p.refreshMetadata(ctx); err != nil {
+			return err
+		}
+	}
+
+	h := fnv.New32a()
+	h.Write(key)
+	partition := int32(h.Sum32() % uint32(p.partitions))
+	leader, ok := p.leaders[partition]
+	if !ok {
+		return fmt.Errorf("partition %d has no leader", partition)
+	}
+
+	conn, err := p.conn(ctx, leader)
+	if err != nil {
+		return err
+	}
+
+	batch := encodeRecordBatch(key, value, map[string]string{"event_type": eventType}, ts)
+
+	var body kafkaEncoder
+	body.nullableString(nil) // transactional_id
+	body.int16(p.acks)
+	body.int32(int32(kafkaProduceTimeout / time.Millisecond))
+	body.int32(1) // topics
+	body.string(p.topic)
+	body.int32(1) // partitions
+	body.int32(partition)
+	body.bytes(batch)
+
+	if p.acks == 0 {
+		return conn.send(ctx, p.nextCorrelationID(), kafkaProduceKey, kafkaProduceVersion, p.clientID, body.buf)
+	}
+	resp, err := conn.roundTrip(ctx, p.nextCorrelationID(), kafkaProduceKey, kafkaProduceVersion, p.clientID, body.buf)
+	if err != nil {
+		return err
+	}
+
+	d := kafkaDecoder{buf: resp}
+	for range d.arrayLen() {
+		d.string() // topic
+		for range d.arrayLen() {
+			d.int32() // partition
+			if code := d.int16(); code != 0 {
+				return kafkaError(code)
+			}
+			d.int64() // base_offset
+			d.int64() // log_append_time
+		}
+	}
+	return d.err
+}
+
+// refreshMetadata finds the partition leaders of the topic from the first
+// reachable bootstrap broker.
+func (p *KafkaPublisher) refreshMetadata(ctx context.Context) error {
+	var body kafkaEncoder
+	body.int32(1)
+	body.string(p.topic)
+	body.bool(false) // allow_auto_topic_creation
+
+	var errs []error
+	for _, addr := range p.brokers {
+		conn, err := p.conn(ctx, addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp, err := conn.roundTrip(ctx, p.nextCorrelationID(), kafkaMetadataKey, kafkaMetadataVersion, p.clientID, body.buf)
+		if err != nil {
+			conn.Close()
+			delete(p.conns, addr)
+			errs = append(errs, fmt.Errorf("%s: %w", addr, err))
+			continue
+		}
+		return p.parseMetadata(resp)
+	}
+	return fmt.Errorf("no broker reachable: %w", errors.Join(errs...))
+}
+
+func (p *KafkaPublisher) parseMetadata(resp []byte) error {
+	d := kafkaDecoder{buf: resp}
+	d.int32() // throttle_time_ms
+
+	brokers := make(map[int32]string)
+	for range d.arrayLen() {
+		nodeID := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.nullableString() // rack
+		brokers[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.nullableString() // cluster_id
+	d.int32()          // controller_id
+
+	leaders := make(map[int32]string)
+	var partitions int32
+	for range d.arrayLen() {
+		topicErr := d.int16()
+		name := d.string()
+		d.bool() // is_internal
+		for range d.arrayLen() {
+			d.int16() // partition error_code
+			index := d.int32()
+			leader := d.int32()
+			d.int32Array() // replica_nodes
+			d.int32Array() // isr_nodes
+			if addr, ok := brokers[leader]; ok {
+				leaders[index] = addr
+			}
+			partitions++
+		}
+		if d.err == nil && name == p.topic && topicErr != 0 {
+			return fmt.Errorf("topic %s: %w", name, kafkaError(topicErr))
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("decoding metadata: %w", d.err)
+	}
+	if partitions == 0 {
+		return fmt.Errorf("topic %s has no partitions", p.topic)
+	}
+
+	p.leaders = leaders
+	p.partitions = partitions
+	return nil
+}
+
+func (p *KafkaPublisher) conn(ctx context.Context, addr string) (*kafkaConn, error) {
+	if c, ok := p.conns[addr]; ok {
+		return c, nil
+	}
+
+	dialer := &net.Dialer{Timeout: kafkaDialTimeout}
+	var nc net.Conn
+	var err error
+	if p.tls != nil {
+		nc, err = (&tls.Dialer{NetDialer: dialer, Config: p.tls}).DialContext(ctx, "tcp", addr)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &kafkaConn{Conn: nc, r: bufio.NewReader(nc)}
+	if p.saslUser != "" {
+		if err := p.authenticate(ctx, c); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("%s: %w", addr, err)
+		}
+	}
+	p.conns[addr] = c
+	return c, nil
+}
+
+// authenticate performs a SASL/PLAIN exchange on a new connection.
+func (p *KafkaPublisher) authenticate(ctx context.Context, c *kafkaConn) error {
+	var handshake kafkaEncoder
+	handshake.string("PLAIN")
+	resp, err := c.roundTrip(ctx, p.nextCorrelationID(), kafkaSaslHandshakeKey, kafkaSaslHandshakeVersion, p.clientID, handshake.buf)
+	if err != nil {
+		return fmt.Errorf("sasl handshake: %w", err)
+	}
+	d := kafkaDecoder{buf: resp}
+	code := d.int16()
+	var mechanisms []string
+	for range d.arrayLen() {
+		mechanisms = append(mechanisms, d.string())
+	}
+	if d.err != nil {
+		return fmt.Errorf("decoding sasl handshake: %w", d.err)
+	}
+	if code != 0 {
+		return fmt.Errorf("sasl handshake: %w (broker offers %v)", kafkaError(code), mechanisms)
+	}
+
+	var auth kafkaEncoder
+	auth.bytes([]byte("\x00" + p.saslUser + "\x00" + p.saslPass))
+	resp, err = c.roundTrip(ctx, p.nextCorrelationID(), kafkaSaslAuthenticateKey, kafkaSaslAuthenticateVersion, p.clientID, auth.buf)
+	if err != nil {
+		return fmt.Errorf("sasl authenticate: %w", err)
+	}
+	d = kafkaDecoder{buf: resp}
+	code = d.int16()
+	message := d.nullableString()
+	if d.err != nil {
+		return fmt.Errorf("decoding sasl authenticate: %w", d.err)
+	}
+	if code != 0 {
+		if message != "" {
+			return fmt.Errorf("sasl authenticate: %w: %s", kafkaError(code), message)
+		}
+		return fmt.Errorf("sasl authenticate: %w", kafkaError(code))
+	}
+	return nil
+}
+
+func (p *KafkaPublisher) nextCorrelationID() int32 {
+	p.correlationID++
+	return p.correlationID
+}
+
+type kafkaConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// send writes one request framed with a v1 request header.
+func (c *kafkaConn) send(ctx context.Context, correlationID int32, apiKey, apiVersion int16, clientID string, body []byte) error {
+	var req kafkaEncoder
+	req.int32(0) // size, filled in below
+	req.int16(apiKey)
+	req.int16(apiVersion)
+	req.int32(correlationID)
+	req.string(clientID)
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(kafkaProduceTimeout + kafkaDialTimeout)
+	}
+	c.SetDeadline(deadline)
+
+	_, err := c.Write(req.buf)
+	return err
+}
+
+// roundTrip sends a request and returns the response body after the
+// correlation id.
+func (c *kafkaConn) roundTrip(ctx context.Context, correlationID int32, apiKey, apiVersion int16, clientID string, body []byte) ([]byte, error) {
+	if err := c.send(ctx, correlationID, apiKey, apiVersion, clientID, body); err != nil {
+		return nil, err
+	}
+
+	var size int32
+	if err := binary.Read(c.r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 4 {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	if got := int32(binary.BigEndian.Uint32(resp)); got != correlationID {
+		return nil, fmt.Errorf("correlation id mismatch: sent %d, got %d", correlationID, got)
+	}
+	return resp[4:], nil
+}
+
+// encodeRecordBatch builds a v2 (magic 2) record batch holding one record.
+func encodeRecordBatch(key, value []byte, headers map[string]string, ts time.Time) []byte {
+	var record []byte
+	record = append(record, 0)              // attributes
+	record = binary.AppendVarint(record, 0) // timestamp delta
+	record = binary.AppendVarint(record, 0) // offset delta
+	record = binary.AppendVarint(record, int64(len(key)))
+	record = append(record, key...)
+	record = binary.AppendVarint(record, int64(len(value)))
+	record = append(record, value...)
+	record = binary.AppendVarint(record, int64(len(headers)))
+	for k, v := range headers {
+		record = binary.AppendVarint(record, int64(len(k)))
+		record = append(record, k...)
+		record = binary.AppendVarint(record, int64(len(v)))
+		record = append(record, v...)
+	}
+
+	// Everything from attributes on is covered by the CRC.
+	var crcPart kafkaEncoder
+	crcPart.int16(0) // attributes: no compression, create time
+	crcPart.int32(0) // last offset delta
+	crcPart.int64(ts.UnixMilli())
+	crcPart.int64(ts.UnixMilli())
+	crcPart.int64(-1) // producer id
+	crcPart.int16(-1) // producer epoch
+	crcPart.int32(-1) // base sequence
+	crcPart.int32(1)  // records
+	crcPart.buf = binary.AppendVarint(crcPart.buf, int64(len(record)))
+	crcPart.buf = append(crcPart.buf, record...)
+
+	var batch kafkaEncoder
+	batch.int64(0)                                   // base offset
+	batch.int32(int32(4 + 1 + 4 + len(crcPart.buf))) // batch length after this field
+	batch.int32(-1)                                  // partition leader epoch
+	batch.buf = append(batch.buf, 2)                 // magic
+	batch.int32(int32(crc32.Checksum(crcPart.buf, crc32c)))
+	batch.buf = append(batch.buf, crcPart.buf...)
+	return batch.buf
+}
+
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *kafkaEncoder) bool(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *kafkaEncoder) nullableString(s *string) {
+	if s == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*s)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder reads big-endian protocol fields. The first short read sets
+// err; later reads return zero values so callers check err once at the end.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) bool() bool {
+	b := d.take(1)
+	return b != nil && b[0] != 0
+}
+
+func (d *kafkaDecoder) string() string {
+	return string(d.take(int(d.int16())))
+}
+
+func (d *kafkaDecoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// arrayLen returns the element count, treating null arrays as empty.
+func (d *kafkaDecoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (d *kafkaDecoder) int32Array() {
+	for range d.arrayLen() {
+		d.int32()
+	}
+}
+
+// kafkaErrorNames covers the error codes a producer typically sees.
+var kafkaErrorNames = map[int16]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	13: "NETWORK_EXCEPTION",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	34: "ILLEGAL_SASL_STATE",
+	35: "UNSUPPORTED_VERSION",
+	58: "SASL_AUTHENTICATION_FAILED",
+	87: "INVALID_RECORD",
+}
+
+func kafkaError(code int16) error {
+	if name, ok := kafkaErrorNames[code]; ok {
+		return fmt.Errorf("broker error %d (%s)", code, name)
+	}
+	return fmt.Errorf("broker error %d", code)
+}
@@ -0,0 +1,313 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKafka is a broker that answers the SASL, Metadata and Produce requests
+// KafkaPublisher sends, for a topic with two partitions led by itself.
+type fakeKafka struct {
+	t        *testing.T
+	ln       net.Listener
+	user     string
+	password string
+
+	mu       sync.Mutex
+	apiKeys  []int16
+	produced []fakeRecord
+}
+
+type fakeRecord struct {
+	partition int32
+	key       string
+	value     []byte
+	headers   map[string]string
+	timestamp int64
+}
+
+func newFakeKafka(t *testing.T) *fakeKafka {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &fakeKafka{t: t, ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go k.serve(conn)
+		}
+	}()
+	return k
+}
+
+func (k *fakeKafka) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var size int32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		req := make([]byte, size)
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := kafkaDecoder{buf: req}
+		apiKey, apiVersion, correlationID := d.int16(), d.int16(), d.int32()
+		if clientID := d.string(); clientID != "go-cron-be" {
+			k.t.Errorf("client id %q", clientID)
+		}
+		k.mu.Lock()
+		k.apiKeys = append(k.apiKeys, apiKey)
+		k.mu.Unlock()
+
+		var resp kafkaEncoder
+		resp.int32(correlationID)
+		switch apiKey {
+		case kafkaSaslHandshakeKey:
+			if mechanism := d.string(); mechanism != "PLAIN" || apiVersion != kafkaSaslHandshakeVersion {
+				k.t.Errorf("sasl handshake v%d for %q", apiVersion, mechanism)
+			}
+			resp.int16(0)
+			resp.int32(1)
+			resp.string("PLAIN")
+		case kafkaSaslAuthenticateKey:
+			auth := d.take(int(d.int32()))
+			if string(auth) == "\x00"+k.user+"\x00"+k.password {
+				resp.int16(0)
+				resp.nullableString(nil)
+			} else {
+				resp.int16(58)
+				message := "Authentication failed: Invalid username or password"
+				resp.nullableString(&message)
+			}
+			resp.bytes(nil)
+		case kafkaMetadataKey:
+			host, port, _ := net.SplitHostPort(k.ln.Addr().String())
+			portNum, _ := strconv.Atoi(port)
+			resp.int32(0) // throttle_time_ms
+			resp.int32(1) // brokers
+			resp.int32(1)
+			resp.string(host)
+			resp.int32(int32(portNum))
+			resp.nullableString(nil)
+			resp.nullableString(nil) // cluster_id
+			resp.int32(1)            // controller_id
+			resp.int32(1)            // topics
+			d.arrayLen()
+			resp.int16(0)
+			resp.string(d.string())
+			resp.bool(false)
+			resp.int32(2) // partitions
+			for i := range int32(2) {
+				resp.int16(0)
+				resp.int32(i)
+				resp.int32(1) // leader
+				resp.int32(1) // replica_nodes
+				resp.int32(1)
+				resp.int32(1) // isr_nodes
+				resp.int32(1)
+			}
+		case kafkaProduceKey:
+			d.nullableString() // transactional_id
+			d.int16()          // acks
+			d.int32()          // timeout
+			d.arrayLen()
+			topic := d.string()
+			d.arrayLen()
+			partition := d.int32()
+			batch := d.take(int(d.int32()))
+			if d.err != nil {
+				k.t.Errorf("decoding produce request: %v", d.err)
+				return
+			}
+			record := decodeRecordBatch(k.t, batch)
+			record.partition = partition
+			k.mu.Lock()
+			k.produced = append(k.produced, record)
+			k.mu.Unlock()
+
+			resp.int32(1)
+			resp.string(topic)
+			resp.int32(1)
+			resp.int32(partition)
+			resp.int16(0)
+			resp.int64(0)
+			resp.int64(-1)
+			resp.int32(0) // throttle_time_ms
+		default:
+			k.t.Errorf("unexpected api key %d", apiKey)
+			return
+		}
+
+		frame := binary.BigEndian.AppendUint32(nil, uint32(len(resp.buf)))
+		if _, err := conn.Write(append(frame, resp.buf...)); err != nil {
+			return
+		}
+	}
+}
+
+// decodeRecordBatch checks the framing and CRC of a v2 record batch holding
+// one record and returns the record.
+func decodeRecordBatch(t *testing.T, batch []byte) fakeRecord {
+	t.Helper()
+	d := kafkaDecoder{buf: batch}
+	d.int64() // base offset
+	if length := d.int32(); int(length) != len(d.buf) {
+		t.Errorf("batch length %d, %d bytes follow", length, len(d.buf))
+	}
+	d.int32() // partition leader epoch
+	if magic := d.take(1); magic == nil || magic[0] != 2 {
+		t.Errorf("magic %v", magic)
+	}
+	crc := uint32(d.int32())
+	if got := crc32.Checksum(d.buf, crc32.MakeTable(crc32.Castagnoli)); got != crc {
+		t.Errorf("crc %08x, computed %08x", crc, got)
+	}
+	d.int16() // attributes
+	d.int32() // last offset delta
+	first := d.int64()
+	d.int64() // max timestamp
+	if producerID := d.int64(); producerID != -1 {
+		t.Errorf("producer id %d", producerID)
+	}
+	d.int16()
+	d.int32()
+	if n := d.int32(); n != 1 {
+		t.Fatalf("%d records", n)
+	}
+
+	varint := func() int64 {
+		v, n := binary.Varint(d.buf)
+		if n <= 0 {
+			t.Fatal("bad varint")
+		}
+		d.take(n)
+		return v
+	}
+	length := varint()
+	if int(length) != len(d.buf) {
+		t.Errorf("record length %d, %d bytes follow", length, len(d.buf))
+	}
+	d.take(1) // attributes
+	varint()  // timestamp delta
+	varint()  // offset delta
+	record := fakeRecord{timestamp: first, headers: map[string]string{}}
+	record.key = string(d.take(int(varint())))
+	record.value = d.take(int(varint()))
+	for range varint() {
+		k := string(d.take(int(varint())))
+		record.headers[k] = string(d.take(int(varint())))
+	}
+	if d.err != nil || len(d.buf) != 0 {
+		t.Errorf("record: %v, %d trailing bytes", d.err, len(d.buf))
+	}
+	return record
+}
+
+func TestKafkaPublish(t *testing.T) {
+	broker := newFakeKafka(t)
+	broker.user, broker.password = "cron", "s3cret"
+	t.Setenv("KAFKA_BROKERS", broker.ln.Addr().String())
+	t.Setenv("KAFKA_TOPIC", "go-cron.job-events")
+	t.Setenv("KAFKA_SASL_USERNAME", "cron")
+	t.Setenv("KAFKA_SASL_PASSWORD", "s3cret")
+	p, err := NewKafkaPublisherFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	created := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+	for _, e := range []Event{
+		{ID: 1, Type: JobStarted, JobID: 42, JobName: "golf", JobDate: "2025-07-15", CreatedAt: created},
+		{ID: 2, Type: JobFinished, JobID: 42, JobName: "golf", JobDate: "2025-07-15", CreatedAt: created},
+	} {
+		if err := p.Publish(ctx, e); err != nil {
+			t.Fatalf("Publish %d: %v", e.ID, err)
+		}
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	wantKeys := []int16{kafkaSaslHandshakeKey, kafkaSaslAuthenticateKey, kafkaMetadataKey, kafkaProduceKey, kafkaProduceKey}
+	if !slices.Equal(broker.apiKeys, wantKeys) {
+		t.Errorf("requests %v, want %v", broker.apiKeys, wantKeys)
+	}
+	if len(broker.produced) != 2 {
+		t.Fatalf("%d records produced", len(broker.produced))
+	}
+	for i, r := range broker.produced {
+		var e Event
+		if err := json.Unmarshal(r.value, &e); err != nil || e.ID != int64(i+1) {
+			t.Errorf("record %d value %s: %v", i, r.value, err)
+		}
+		if r.key != "42" || r.headers["event_type"] != string(e.Type) || r.timestamp != created.UnixMilli() {
+			t.Errorf("record %d: key %q, headers %v, timestamp %d", i, r.key, r.headers, r.timestamp)
+		}
+	}
+	// Records of one job share a partition, keeping them in order.
+	if broker.produced[0].partition != broker.produced[1].partition {
+		t.Errorf("events of one job went to partitions %d and %d", broker.produced[0].partition, broker.produced[1].partition)
+	}
+}
+
+func TestKafkaAuthenticationFailure(t *testing.T) {
+	broker := newFakeKafka(t)
+	broker.user, broker.password = "cron", "s3cret"
+	t.Setenv("KAFKA_BROKERS", broker.ln.Addr().String())
+	t.Setenv("KAFKA_TOPIC", "go-cron.job-events")
+	t.Setenv("KAFKA_SASL_USERNAME", "cron")
+	t.Setenv("KAFKA_SASL_PASSWORD", "wrong")
+	p, err := NewKafkaPublisherFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = p.Publish(ctx, Event{ID: 1, Type: JobStarted, JobID: 1})
+	if err == nil || !strings.Contains(err.Error(), "SASL_AUTHENTICATION_FAILED") || !strings.Contains(err.Error(), "Invalid username or password") {
+		t.Errorf("Publish = %v, want the broker's authentication error", err)
+	}
+
+	t.Setenv("KAFKA_SASL_MECHANISM", "SCRAM-SHA-512")
+	if _, err := NewKafkaPublisherFromEnv(); err == nil {
+		t.Error("unsupported SASL mechanism accepted")
+	}
+}
+
+func TestKafkaRecordBatchCRC(t *testing.T) {
+	batch := encodeRecordBatch([]byte("7"), []byte(`{"event_id":1}`), map[string]string{"event_type": "job_created"}, time.UnixMilli(1752580800000))
+	r := decodeRecordBatch(t, batch)
+	if r.key != "7" || !bytes.Equal(r.value, []byte(`{"event_id":1}`)) || r.headers["event_type"] != "job_created" {
+		t.Errorf("decoded %+v", r)
+	}
+
+	batch[len(batch)-1] ^= 0xff
+	d := kafkaDecoder{buf: batch[21:]}
+	if crc32.Checksum(d.buf, crc32.MakeTable(crc32.Castagnoli)) == binary.BigEndian.Uint32(batch[17:21]) {
+		t.Error("corrupted batch still matches its CRC")
+	}
+}
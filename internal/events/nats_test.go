@@ -0,0 +1,130 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// natsMessage is a PUB received by fakeNATS.
+type natsMessage struct {
+	subject string
+	payload []byte
+}
+
+// fakeNATS accepts one client and speaks the core text protocol: it checks
+// the CONNECT credentials, answers PINGs, and sends its own PING before the
+// first PONG to exercise the client's handling of it.
+func fakeNATS(t *testing.T, user, pass string) (addr string, msgs <-chan natsMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan natsMessage, 10)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"version\":\"2.10.0\",\"auth_required\":true,\"max_payload\":1048576}\r\n")
+
+		pinged := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch verb, rest, _ := strings.Cut(line, " "); verb {
+			case "CONNECT":
+				var opts map[string]any
+				if err := json.Unmarshal([]byte(rest), &opts); err != nil {
+					t.Errorf("CONNECT %s: %v", rest, err)
+					return
+				}
+				if opts["verbose"] != false || opts["user"] != user || opts["pass"] != pass {
+					fmt.Fprintf(conn, "-ERR 'Authorization Violation'\r\n")
+					return
+				}
+			case "PING":
+				if !pinged {
+					pinged = true
+					fmt.Fprintf(conn, "PING\r\n")
+					if reply, _ := r.ReadString('\n'); reply != "PONG\r\n" {
+						t.Errorf("client answered PING with %q", reply)
+					}
+				}
+				fmt.Fprintf(conn, "PONG\r\n")
+			case "PUB":
+				fields := strings.Fields(rest)
+				n, err := strconv.Atoi(fields[len(fields)-1])
+				if err != nil {
+					t.Errorf("PUB %s", rest)
+					return
+				}
+				payload := make([]byte, n+2)
+				if _, err := io.ReadFull(r, payload); err != nil || string(payload[n:]) != "\r\n" {
+					t.Errorf("PUB payload %q: %v", payload, err)
+					return
+				}
+				ch <- natsMessage{subject: fields[0], payload: payload[:n]}
+			default:
+				t.Errorf("unexpected client line %q", line)
+				return
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestNATSPublish(t *testing.T) {
+	addr, msgs := fakeNATS(t, "cron", "s3cret")
+	t.Setenv("NATS_URL", "nats://cron:s3cret@"+addr)
+	t.Setenv("NATS_SUBJECT", "")
+	p, err := NewNATSPublisherFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Publish(ctx, Event{ID: 7, Type: JobFailed, JobID: 3, JobName: "golf"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	msg := <-msgs
+	var e Event
+	if msg.subject != "go-cron.job-events.job_failed" || json.Unmarshal(msg.payload, &e) != nil || e.ID != 7 {
+		t.Errorf("received %s %s", msg.subject, msg.payload)
+	}
+}
+
+func TestNATSAuthorizationError(t *testing.T) {
+	addr, _ := fakeNATS(t, "cron", "s3cret")
+	t.Setenv("NATS_URL", "nats://cron:wrong@"+addr)
+	p, err := NewNATSPublisherFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = p.Publish(ctx, Event{ID: 1, Type: JobStarted})
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("Publish = %v, want the server's authorization error", err)
+	}
+}
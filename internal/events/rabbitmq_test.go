@@ -0,0 +1,253 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// amqpMessage is a Basic.Publish received by fakeRabbitMQ.
+type amqpMessage struct {
+	exchange   string
+	routingKey string
+	mandatory  bool
+	properties uint16
+	body       []byte
+	frames     int
+}
+
+// fakeRabbitMQ accepts one client, runs the connection handshake with a small
+// frame-max so bodies are split, and confirms each publish. Messages routed
+// with the key "unroutable" are returned as NO_ROUTE before the ack, as
+// RabbitMQ does for mandatory messages.
+func fakeRabbitMQ(t *testing.T, user, pass string) (addr string, msgs <-chan amqpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	ch := make(chan amqpMessage, 10)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		write := func(frameType byte, channel uint16, payload []byte) {
+			var f amqpEncoder
+			f.buf = append(f.buf, frameType)
+			f.uint16(channel)
+			f.uint32(uint32(len(payload)))
+			f.buf = append(f.buf, payload...)
+			f.buf = append(f.buf, amqpFrameEnd)
+			conn.Write(f.buf)
+		}
+		writeMethod := func(channel uint16, m amqpMethod, args []byte) {
+			var p amqpEncoder
+			p.uint16(m.class)
+			p.uint16(m.method)
+			write(amqpFrameMethod, channel, append(p.buf, args...))
+		}
+		read := func() (frameType byte, payload []byte) {
+			var hdr [7]byte
+			if _, err := io.ReadFull(r, hdr[:]); err != nil {
+				return 0, nil
+			}
+			payload = make([]byte, binary.BigEndian.Uint32(hdr[3:])+1)
+			if _, err := io.ReadFull(r, payload); err != nil || payload[len(payload)-1] != amqpFrameEnd {
+				t.Errorf("malformed frame from client: %v", err)
+				return 0, nil
+			}
+			return hdr[0], payload[:len(payload)-1]
+		}
+		readMethod := func() (amqpMethod, *amqpDecoder) {
+			frameType, payload := read()
+			if frameType != amqpFrameMethod || len(payload) < 4 {
+				return amqpMethod{}, nil
+			}
+			return amqpMethod{binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])}, &amqpDecoder{buf: payload[4:]}
+		}
+		expect := func(want amqpMethod) *amqpDecoder {
+			m, d := readMethod()
+			if m != want {
+				t.Errorf("expected method %v, got %v", want, m)
+				return nil
+			}
+			return d
+		}
+
+		var protocol [8]byte
+		if _, err := io.ReadFull(r, protocol[:]); err != nil || string(protocol[:]) != "AMQP\x00\x00\x09\x01" {
+			t.Errorf("protocol header %q", protocol)
+			return
+		}
+		var start amqpEncoder
+		start.buf = append(start.buf, 0, 9)
+		start.table(map[string]string{"product": "fake"})
+		start.longString("PLAIN")
+		start.longString("en_US")
+		writeMethod(0, amqpConnectionStart, start.buf)
+
+		d := expect(amqpConnectionStartOk)
+		if d == nil {
+			return
+		}
+		d.buf = d.buf[d.uint32():] // client properties
+		mechanism := d.shortString()
+		response := string(d.buf[4:][:d.uint32()])
+		if mechanism != "PLAIN" || response != "\x00"+user+"\x00"+pass {
+			var closeArgs amqpEncoder
+			closeArgs.uint16(403)
+			closeArgs.shortString("ACCESS_REFUSED - Login was refused using authentication mechanism PLAIN")
+			closeArgs.uint16(10)
+			closeArgs.uint16(11)
+			writeMethod(0, amqpConnectionClose, closeArgs.buf)
+			return
+		}
+
+		var tune amqpEncoder
+		tune.uint16(2047)
+		tune.uint32(64)
+		tune.uint16(60)
+		writeMethod(0, amqpConnectionTune, tune.buf)
+		if d := expect(amqpConnectionTuneOk); d == nil || d.uint16() != 2047 || d.uint32() != 64 || d.uint16() != 0 {
+			t.Errorf("tune-ok %v", d)
+			return
+		}
+		if d := expect(amqpConnectionOpen); d == nil || d.shortString() != "jobs" {
+			t.Error("connection opened on the wrong vhost")
+			return
+		}
+		writeMethod(0, amqpConnectionOpenOk, []byte{0})
+		if expect(amqpChannelOpen) == nil {
+			return
+		}
+		writeMethod(1, amqpChannelOpenOk, []byte{0, 0, 0, 0})
+		if expect(amqpConfirmSelect) == nil {
+			return
+		}
+		writeMethod(1, amqpConfirmSelectOk, nil)
+
+		// A heartbeat between methods must be skipped by the client.
+		write(amqpFrameHeartbeat, 0, nil)
+
+		for tag := uint64(1); ; tag++ {
+			// The client closes the connection when done or after a
+			// returned message.
+			m, d := readMethod()
+			if d == nil || m == amqpConnectionClose {
+				return
+			}
+			if m != amqpBasicPublish {
+				t.Errorf("expected method %v, got %v", amqpBasicPublish, m)
+				return
+			}
+			d.uint16()
+			msg := amqpMessage{exchange: d.shortString(), routingKey: d.shortString()}
+			msg.mandatory = len(d.buf) == 1 && d.buf[0]&1 == 1
+
+			frameType, header := read()
+			hd := amqpDecoder{buf: header}
+			if frameType != amqpFrameHeader || hd.uint16() != amqpBasicClass {
+				t.Errorf("content header frame type %d", frameType)
+				return
+			}
+			hd.uint16()
+			size := uint64(hd.uint32())<<32 | uint64(hd.uint32())
+			msg.properties = hd.uint16()
+			for uint64(len(msg.body)) < size {
+				frameType, body := read()
+				if frameType != amqpFrameBody || len(body) > 64-8 {
+					t.Errorf("body frame type %d of %d bytes", frameType, len(body))
+					return
+				}
+				msg.body = append(msg.body, body...)
+				msg.frames++
+			}
+			ch <- msg
+
+			if msg.routingKey == "unroutable" {
+				var ret amqpEncoder
+				ret.uint16(312)
+				ret.shortString("NO_ROUTE")
+				ret.shortString(msg.exchange)
+				ret.shortString(msg.routingKey)
+				writeMethod(1, amqpBasicReturn, ret.buf)
+				write(amqpFrameHeader, 1, header)
+				write(amqpFrameBody, 1, msg.body)
+			}
+			var ack amqpEncoder
+			ack.uint64(tag)
+			ack.buf = append(ack.buf, 0)
+			writeMethod(1, amqpBasicAck, ack.buf)
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestRabbitMQPublish(t *testing.T) {
+	addr, msgs := fakeRabbitMQ(t, "cron", "s3cret")
+	t.Setenv("RABBITMQ_URL", "amqp://cron:s3cret@"+addr+"/jobs")
+	t.Setenv("RABBITMQ_EXCHANGE", "go-cron")
+	p, err := NewRabbitMQPublisherFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	e := Event{ID: 9, Type: JobFinished, JobID: 3, JobName: "golf", JobDate: "2025-07-15", CreatedAt: time.Unix(1752580800, 0)}
+	if err := p.Publish(ctx, e); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	msg := <-msgs
+	var got Event
+	if err := json.Unmarshal(msg.body, &got); err != nil || got.ID != 9 {
+		t.Errorf("body %s: %v", msg.body, err)
+	}
+	if msg.exchange != "go-cron" || msg.routingKey != "job_finished" || !msg.mandatory {
+		t.Errorf("published to %q/%q, mandatory %v", msg.exchange, msg.routingKey, msg.mandatory)
+	}
+	if msg.properties != 0x8000|0x1000|0x0080|0x0040|0x0020 {
+		t.Errorf("property flags %04x", msg.properties)
+	}
+	if msg.frames < 2 {
+		t.Errorf("%d-byte body sent in %d frame(s) with frame-max 64", len(msg.body), msg.frames)
+	}
+
+	e.Type = "unroutable"
+	err = p.Publish(ctx, e)
+	if err == nil || !strings.Contains(err.Error(), "312 NO_ROUTE") {
+		t.Errorf("Publish = %v, want the message returned as unroutable", err)
+	}
+}
+
+func TestRabbitMQAccessRefused(t *testing.T) {
+	addr, _ := fakeRabbitMQ(t, "cron", "s3cret")
+	t.Setenv("RABBITMQ_URL", "amqp://cron:wrong@"+addr+"/jobs")
+	t.Setenv("RABBITMQ_EXCHANGE", "go-cron")
+	p, err := NewRabbitMQPublisherFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = p.Publish(ctx, Event{ID: 1, Type: JobStarted})
+	if err == nil || !strings.Contains(err.Error(), "403 ACCESS_REFUSED") {
+		t.Errorf("Publish = %v, want the broker's access refusal", err)
+	}
+}
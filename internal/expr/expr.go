@@ -0,0 +1,340 @@
+// Package expr evaluates small expressions written in Go syntax, such as
+// "amt_d < 10 && amt_y > 0", against a set of JSON-like variables. It is the
+// language of script jobs: numbers are float64, and values may also be
+// strings, bools, nil, map[string]any and []any as decoded by encoding/json.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Expr is a parsed expression.
+type Expr struct {
+	src  string
+	node ast.Expr
+}
+
+// Parse parses src. Statements, function literals and other constructs
+// beyond plain expressions are rejected when evaluated.
+func Parse(src string) (*Expr, error) {
+	node, err := parser.ParseExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", src, err)
+	}
+	return &Expr{src: src, node: node}, nil
+}
+
+// String returns the source of e.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates e with vars as its variables.
+func (e *Expr) Eval(vars map[string]any) (any, error) {
+	v, err := eval(e.node, vars)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating %q: %w", e.src, err)
+	}
+	return v, nil
+}
+
+// EvalBool evaluates e and requires the result to be a bool.
+func (e *Expr) EvalBool(vars map[string]any) (bool, error) {
+	v, err := e.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("evaluating %q: got %s, want bool", e.src, typeName(v))
+	}
+	return b, nil
+}
+
+func eval(node ast.Expr, vars map[string]any) (any, error) {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		switch n.Kind {
+		case token.INT, token.FLOAT:
+			return strconv.ParseFloat(n.Value, 64)
+		case token.STRING:
+			return strconv.Unquote(n.Value)
+		}
+		return nil, fmt.Errorf("unsupported literal %s", n.Value)
+
+	case *ast.Ident:
+		switch n.Name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "nil":
+			return nil, nil
+		}
+		v, ok := vars[n.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %s", n.Name)
+		}
+		return normalize(v), nil
+
+	case *ast.ParenExpr:
+		return eval(n.X, vars)
+
+	case *ast.SelectorExpr:
+		x, err := eval(n.X, vars)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := x.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot select .%s from %s", n.Sel.Name, typeName(x))
+		}
+		return normalize(m[n.Sel.Name]), nil
+
+	case *ast.IndexExpr:
+		return evalIndex(n, vars)
+
+	case *ast.UnaryExpr:
+		x, err := eval(n.X, vars)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case token.NOT:
+			if b, ok := x.(bool); ok {
+				return !b, nil
+			}
+		case token.SUB:
+			if f, ok := x.(float64); ok {
+				return -f, nil
+			}
+		case token.ADD:
+			if f, ok := x.(float64); ok {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid operation %s on %s", n.Op, typeName(x))
+
+	case *ast.BinaryExpr:
+		return evalBinary(n, vars)
+
+	case *ast.CallExpr:
+		return evalCall(n, vars)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", node)
+}
+
+func evalIndex(n *ast.IndexExpr, vars map[string]any) (any, error) {
+	x, err := eval(n.X, vars)
+	if err != nil {
+		return nil, err
+	}
+	index, err := eval(n.Index, vars)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case []any:
+		f, ok := index.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, fmt.Errorf("list index must be an integer, got %s", typeName(index))
+		}
+		if f < 0 || int(f) >= len(x) {
+			return nil, fmt.Errorf("index %d out of range [0:%d]", int(f), len(x))
+		}
+		return normalize(x[int(f)]), nil
+	case map[string]any:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("object key must be a string, got %s", typeName(index))
+		}
+		return normalize(x[key]), nil
+	}
+	return nil, fmt.Errorf("cannot index %s", typeName(x))
+}
+
+func evalBinary(n *ast.BinaryExpr, vars map[string]any) (any, error) {
+	x, err := eval(n.X, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || only evaluate their right side when needed.
+	if n.Op == token.LAND || n.Op == token.LOR {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid operation %s on %s", n.Op, typeName(x))
+		}
+		if (n.Op == token.LAND && !b) || (n.Op == token.LOR && b) {
+			return b, nil
+		}
+		y, err := eval(n.Y, vars)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := y.(bool); !ok {
+			return nil, fmt.Errorf("invalid operation %s on %s", n.Op, typeName(y))
+		}
+		return y, nil
+	}
+
+	y, err := eval(n.Y, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case token.EQL:
+		return reflect.DeepEqual(x, y), nil
+	case token.NEQ:
+		return !reflect.DeepEqual(x, y), nil
+	}
+
+	if a, ok := x.(float64); ok {
+		if b, ok := y.(float64); ok {
+			return numberOp(n.Op, a, b)
+		}
+	}
+	if a, ok := x.(string); ok {
+		if b, ok := y.(string); ok {
+			switch n.Op {
+			case token.ADD:
+				return a + b, nil
+			case token.LSS:
+				return a < b, nil
+			case token.LEQ:
+				return a <= b, nil
+			case token.GTR:
+				return a > b, nil
+			case token.GEQ:
+				return a >= b, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid operation %s between %s and %s", n.Op, typeName(x), typeName(y))
+}
+
+func numberOp(op token.Token, a, b float64) (any, error) {
+	switch op {
+	case token.ADD:
+		return a + b, nil
+	case token.SUB:
+		return a - b, nil
+	case token.MUL:
+		return a * b, nil
+	case token.QUO:
+		if b == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return a / b, nil
+	case token.REM:
+		if b == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return math.Mod(a, b), nil
+	case token.LSS:
+		return a < b, nil
+	case token.LEQ:
+		return a <= b, nil
+	case token.GTR:
+		return a > b, nil
+	case token.GEQ:
+		return a >= b, nil
+	}
+	return nil, fmt.Errorf("invalid operation %s between numbers", op)
+}
+
+// evalCall runs the built-in functions: len, abs, min and max.
+func evalCall(n *ast.CallExpr, vars map[string]any) (any, error) {
+	fn, ok := n.Fun.(*ast.Ident)
+	if !ok {
+		return nil, errors.New("only built-in functions can be called")
+	}
+	args := make([]any, len(n.Args))
+	for i, arg := range n.Args {
+		v, err := eval(arg, vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch fn.Name {
+	case "len":
+		if len(args) == 1 {
+			switch v := args[0].(type) {
+			case string:
+				return float64(len(v)), nil
+			case []any:
+				return float64(len(v)), nil
+			case map[string]any:
+				return float64(len(v)), nil
+			}
+		}
+		return nil, errors.New("len takes one string, list or object")
+	case "abs":
+		if len(args) == 1 {
+			if f, ok := args[0].(float64); ok {
+				return math.Abs(f), nil
+			}
+		}
+		return nil, errors.New("abs takes one number")
+	case "min", "max":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s takes at least one number", fn.Name)
+		}
+		var result float64
+		for i, arg := range args {
+			f, ok := arg.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%s takes numbers, got %s", fn.Name, typeName(arg))
+			}
+			if i == 0 || (fn.Name == "min" && f < result) || (fn.Name == "max" && f > result) {
+				result = f
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unknown function %s", fn.Name)
+}
+
+// normalize converts Go numbers to float64 so variables set from structs
+// compare like decoded JSON.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,20 @@
+package lock
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// FromEnv returns the provider selected by LOCK_PROVIDER: "mysql" (the
+// default, using db) or "redis".
+func FromEnv(db *sql.DB) (Locker, error) {
+	switch provider := os.Getenv("LOCK_PROVIDER"); provider {
+	case "", "mysql":
+		return NewMySQLLocker(db), nil
+	case "redis":
+		return NewRedisLockerFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown LOCK_PROVIDER %q", provider)
+	}
+}
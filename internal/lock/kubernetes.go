@@ -0,0 +1,299 @@
+package lock
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	leaseNamePrefix   = "go-cron-"
+	// microTimeLayout is the wire format of metav1.MicroTime.
+	microTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var invalidLeaseChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// KubernetesLocker stores locks as coordination.k8s.io/v1 Lease objects, the
+// same mechanism client-go's leader election uses, so the current holder is
+// visible with "kubectl get lease". It talks to the API server with the
+// pod's service account, which needs get, create and update on leases.
+type KubernetesLocker struct {
+	baseURL   string
+	namespace string
+	identity  string
+	tokenFile string
+	http      *http.Client
+}
+
+// NewKubernetesLockerFromEnv configures the locker from the in-cluster
+// service account. The namespace comes from POD_NAMESPACE or the service
+// account, and the holder identity from POD_NAME or the hostname.
+func NewKubernetesLockerFromEnv() (*KubernetesLocker, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("kubernetes leader election requires running in a cluster (KUBERNETES_SERVICE_HOST is not set)")
+	}
+
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		raw, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("reading service account namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(raw))
+	}
+
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+
+	caPEM, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("reading service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("service account CA contains no certificates")
+	}
+
+	return &KubernetesLocker{
+		baseURL:   "https://" + net.JoinHostPort(host, port),
+		namespace: namespace,
+		identity:  identity,
+		tokenFile: serviceAccountDir + "/token",
+		http: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+	}, nil
+}
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions"`
+}
+
+// expired reports whether the lease is free to take at now.
+func (s leaseSpec) expired(now time.Time) bool {
+	if s.HolderIdentity == "" || s.RenewTime == "" {
+		return true
+	}
+	renewed, err := time.Parse(time.RFC3339Nano, s.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(s.LeaseDurationSeconds) * time.Second))
+}
+
+func (k *KubernetesLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (Lock, bool, error) {
+	l := &kubernetesLock{
+		locker:   k,
+		name:     leaseName(name),
+		identity: k.identity + "_" + newToken()[:8],
+	}
+
+	current, found, err := k.get(ctx, l.name)
+	if err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+
+	if !found {
+		created := lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: l.name, Namespace: k.namespace},
+			Spec:       l.spec(ttl, now, now, 0),
+		}
+		ok, err := k.write(ctx, http.MethodPost, k.leasesURL(""), created)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		return l, true, nil
+	}
+
+	if !current.Spec.expired(now) {
+		return nil, false, nil
+	}
+	current.Spec = l.spec(ttl, now, now, current.Spec.LeaseTransitions+1)
+	ok, err := k.write(ctx, http.MethodPut, k.leasesURL(l.name), current)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return l, true, nil
+}
+
+func (k *KubernetesLocker) leasesURL(name string) string {
+	u := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", k.baseURL, k.namespace)
+	if name != "" {
+		u += "/" + name
+	}
+	return u
+}
+
+func (k *KubernetesLocker) get(ctx context.Context, name string) (lease, bool, error) {
+	resp, err := k.do(ctx, http.MethodGet, k.leasesURL(name), nil)
+	if err != nil {
+		return lease{}, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return lease{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return lease{}, false, apiError(resp)
+	}
+	var l lease
+	if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return lease{}, false, fmt.Errorf("decoding lease %s: %w", name, err)
+	}
+	return l, true, nil
+}
+
+// write creates or updates a lease. ok is false when another writer got
+// there first (409 Conflict on resourceVersion or an existing name).
+func (k *KubernetesLocker) write(ctx context.Context, method, url string, l lease) (bool, error) {
+	body, err := json.Marshal(l)
+	if err != nil {
+		return false, err
+	}
+	resp, err := k.do(ctx, method, url, body)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return true, nil
+	default:
+		return false, apiError(resp)
+	}
+}
+
+func (k *KubernetesLocker) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	// The token is re-read on every request because kubelet rotates it.
+	token, err := os.ReadFile(k.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading service account token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	return resp, nil
+}
+
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var status struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &status) == nil && status.Message != "" {
+		return fmt.Errorf("kubernetes API returned %s: %s", resp.Status, status.Message)
+	}
+	return fmt.Errorf("kubernetes API returned %s", resp.Status)
+}
+
+// leaseName maps a lock name to a valid object name, e.g. "job:oracle_proc"
+// -> "go-cron-job-oracle-proc".
+func leaseName(name string) string {
+	return leaseNamePrefix + strings.Trim(invalidLeaseChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+type kubernetesLock struct {
+	locker   *KubernetesLocker
+	name     string
+	identity string
+	acquired time.Time
+}
+
+func (l *kubernetesLock) spec(ttl time.Duration, acquired, renewed time.Time, transitions int) leaseSpec {
+	l.acquired = acquired
+	return leaseSpec{
+		HolderIdentity:       l.identity,
+		LeaseDurationSeconds: max(1, int(ttl.Seconds())),
+		AcquireTime:          acquired.UTC().Format(microTimeLayout),
+		RenewTime:            renewed.UTC().Format(microTimeLayout),
+		LeaseTransitions:     transitions,
+	}
+}
+
+func (l *kubernetesLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	current, found, err := l.locker.get(ctx, l.name)
+	if err != nil {
+		return err
+	}
+	if !found || current.Spec.HolderIdentity != l.identity {
+		return ErrLost
+	}
+
+	current.Spec = l.spec(ttl, l.acquired, time.Now(), current.Spec.LeaseTransitions)
+	ok, err := l.locker.write(ctx, http.MethodPut, l.locker.leasesURL(l.name), current)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLost
+	}
+	return nil
+}
+
+// Unlock clears the holder, as client-go does on release, so the next
+// candidate does not have to wait for the lease to expire.
+func (l *kubernetesLock) Unlock(ctx context.Context) error {
+	current, found, err := l.locker.get(ctx, l.name)
+	if err != nil || !found || current.Spec.HolderIdentity != l.identity {
+		return err
+	}
+
+	current.Spec.HolderIdentity = ""
+	current.Spec.LeaseDurationSeconds = 1
+	current.Spec.RenewTime = time.Now().UTC().Format(microTimeLayout)
+	_, err = l.locker.write(ctx, http.MethodPut, l.locker.leasesURL(l.name), current)
+	return err
+}
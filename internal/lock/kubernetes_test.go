@@ -0,0 +1,235 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLeaseAPI serves the coordination.k8s.io/v1 Lease endpoints of one
+// namespace, with optimistic concurrency on resourceVersion like the real
+// API server.
+type fakeLeaseAPI struct {
+	t     *testing.T
+	token string
+
+	mu      sync.Mutex
+	leases  map[string]lease
+	version int
+}
+
+func (f *fakeLeaseAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"kind": "Status", "message": "Unauthorized"})
+		return
+	}
+	const prefix = "/apis/coordination.k8s.io/v1/namespaces/cron/leases"
+	name, ok := strings.CutPrefix(r.URL.Path, prefix)
+	if !ok {
+		f.t.Errorf("unexpected path %s", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	name = strings.TrimPrefix(name, "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := func(code int, message string) {
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{"kind": "Status", "message": message})
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		l, ok := f.leases[name]
+		if !ok {
+			status(http.StatusNotFound, `leases.coordination.k8s.io "`+name+`" not found`)
+			return
+		}
+		json.NewEncoder(w).Encode(l)
+	case http.MethodPost, http.MethodPut:
+		var l lease
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			status(http.StatusBadRequest, err.Error())
+			return
+		}
+		if r.Method == http.MethodPost {
+			name = l.Metadata.Name
+		}
+		if _, err := time.Parse(time.RFC3339Nano, l.Spec.RenewTime); l.Spec.RenewTime != "" && err != nil {
+			status(http.StatusUnprocessableEntity, "invalid renewTime: "+err.Error())
+			return
+		}
+		current, exists := f.leases[name]
+		switch {
+		case r.Method == http.MethodPost && exists:
+			status(http.StatusConflict, `leases.coordination.k8s.io "`+name+`" already exists`)
+			return
+		case r.Method == http.MethodPut && !exists:
+			status(http.StatusNotFound, `leases.coordination.k8s.io "`+name+`" not found`)
+			return
+		case r.Method == http.MethodPut && l.Metadata.ResourceVersion != current.Metadata.ResourceVersion:
+			status(http.StatusConflict, "the object has been modified; please apply your changes to the latest version and try again")
+			return
+		}
+		f.version++
+		l.Metadata.ResourceVersion = strconv.Itoa(f.version)
+		f.leases[name] = l
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(l)
+	default:
+		status(http.StatusMethodNotAllowed, r.Method)
+	}
+}
+
+func (f *fakeLeaseAPI) lease(name string) lease {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.leases[name]
+}
+
+func (f *fakeLeaseAPI) update(name string, fn func(*lease)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l := f.leases[name]
+	fn(&l)
+	f.version++
+	l.Metadata.ResourceVersion = strconv.Itoa(f.version)
+	f.leases[name] = l
+}
+
+// newTestKubernetesLocker returns a locker for identity that talks to server
+// over TLS, reading token from a service account token file.
+func newTestKubernetesLocker(t *testing.T, server *httptest.Server, identity, token string) *KubernetesLocker {
+	t.Helper()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &KubernetesLocker{
+		baseURL:   server.URL,
+		namespace: "cron",
+		identity:  identity,
+		tokenFile: tokenFile,
+		http:      server.Client(),
+	}
+}
+
+func TestKubernetesLock(t *testing.T) {
+	api := &fakeLeaseAPI{t: t, token: "sa-token", leases: map[string]lease{}}
+	server := httptest.NewTLSServer(api)
+	defer server.Close()
+	a := newTestKubernetesLocker(t, server, "pod-a", "sa-token")
+	b := newTestKubernetesLocker(t, server, "pod-b", "sa-token")
+	ctx := context.Background()
+
+	l, ok, err := a.TryLock(ctx, "job:oracle_proc", 30*time.Second)
+	if err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	stored := api.lease("go-cron-job-oracle-proc")
+	if !strings.HasPrefix(stored.Spec.HolderIdentity, "pod-a_") || stored.Spec.LeaseDurationSeconds != 30 || stored.Kind != "Lease" {
+		t.Errorf("created lease %+v", stored)
+	}
+	if _, ok, err := b.TryLock(ctx, "job:oracle_proc", 30*time.Second); ok || err != nil {
+		t.Errorf("TryLock on a held lease = %v, %v", ok, err)
+	}
+
+	if err := l.Refresh(ctx, 30*time.Second); err != nil {
+		t.Errorf("Refresh: %v", err)
+	}
+	if renewed := api.lease("go-cron-job-oracle-proc"); renewed.Spec.AcquireTime != stored.Spec.AcquireTime || renewed.Metadata.ResourceVersion == stored.Metadata.ResourceVersion {
+		t.Errorf("refreshed lease %+v", renewed)
+	}
+
+	if err := l.Unlock(ctx); err != nil {
+		t.Errorf("Unlock: %v", err)
+	}
+	if released := api.lease("go-cron-job-oracle-proc"); released.Spec.HolderIdentity != "" {
+		t.Errorf("released lease still held by %q", released.Spec.HolderIdentity)
+	}
+
+	// The released lease is taken over at once, counting a transition.
+	l2, ok, err := b.TryLock(ctx, "job:oracle_proc", 30*time.Second)
+	if err != nil || !ok {
+		t.Fatalf("TryLock after release = %v, %v", ok, err)
+	}
+	if taken := api.lease("go-cron-job-oracle-proc"); !strings.HasPrefix(taken.Spec.HolderIdentity, "pod-b_") || taken.Spec.LeaseTransitions != 1 {
+		t.Errorf("taken-over lease %+v", taken.Spec)
+	}
+	if err := l.Refresh(ctx, 30*time.Second); !errors.Is(err, ErrLost) {
+		t.Errorf("Refresh by the previous holder = %v, want ErrLost", err)
+	}
+	l.Unlock(ctx)
+	if taken := api.lease("go-cron-job-oracle-proc"); !strings.HasPrefix(taken.Spec.HolderIdentity, "pod-b_") {
+		t.Error("previous holder released the new holder's lease")
+	}
+	l2.Unlock(ctx)
+}
+
+func TestKubernetesLockExpired(t *testing.T) {
+	api := &fakeLeaseAPI{t: t, token: "sa-token", leases: map[string]lease{}}
+	server := httptest.NewTLSServer(api)
+	defer server.Close()
+	a := newTestKubernetesLocker(t, server, "pod-a", "sa-token")
+	b := newTestKubernetesLocker(t, server, "pod-b", "sa-token")
+	ctx := context.Background()
+
+	if _, ok, err := a.TryLock(ctx, "leader", 10*time.Second); err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	api.update("go-cron-leader", func(l *lease) {
+		l.Spec.RenewTime = time.Now().Add(-time.Minute).UTC().Format(microTimeLayout)
+	})
+	if _, ok, err := b.TryLock(ctx, "leader", 10*time.Second); err != nil || !ok {
+		t.Errorf("TryLock on an expired lease = %v, %v", ok, err)
+	}
+}
+
+func TestKubernetesLockConflict(t *testing.T) {
+	api := &fakeLeaseAPI{t: t, token: "sa-token", leases: map[string]lease{}}
+	server := httptest.NewTLSServer(api)
+	defer server.Close()
+	a := newTestKubernetesLocker(t, server, "pod-a", "sa-token")
+	ctx := context.Background()
+
+	l, ok, err := a.TryLock(ctx, "leader", 10*time.Second)
+	if err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	// Another writer updates the lease between a GET and the PUT based on
+	// it: the stale resourceVersion gets a 409, reported as not written.
+	kl := l.(*kubernetesLock)
+	current, _, err := a.get(ctx, kl.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.update("go-cron-leader", func(l *lease) {})
+	if ok, err := a.write(ctx, http.MethodPut, a.leasesURL(kl.name), current); ok || err != nil {
+		t.Errorf("write with a stale resourceVersion = %v, %v, want a conflict", ok, err)
+	}
+}
+
+func TestKubernetesLockUnauthorized(t *testing.T) {
+	api := &fakeLeaseAPI{t: t, token: "sa-token", leases: map[string]lease{}}
+	server := httptest.NewTLSServer(api)
+	defer server.Close()
+	a := newTestKubernetesLocker(t, server, "pod-a", "expired-token")
+
+	_, ok, err := a.TryLock(context.Background(), "leader", 10*time.Second)
+	if ok || err == nil || !strings.Contains(err.Error(), "401 Unauthorized: Unauthorized") {
+		t.Errorf("TryLock = %v, %v, want the API server's error", ok, err)
+	}
+}
@@ -0,0 +1,42 @@
+// Package lock provides named, expiring locks shared by all scheduler
+// instances. They back leader election and per-job-type mutual exclusion.
+//
+// Locks are leases: a holder must Refresh before the TTL runs out or another
+// instance may take over. Callers should treat ErrLost as "stop doing the
+// guarded work now".
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrLost is returned by Refresh when the lock expired and may have been
+// taken by someone else.
+var ErrLost = errors.New("lock lost")
+
+// Locker hands out locks.
+type Locker interface {
+	// TryLock acquires name for ttl without waiting. ok is false if another
+	// holder has it.
+	TryLock(ctx context.Context, name string, ttl time.Duration) (l Lock, ok bool, err error)
+}
+
+// Lock is a held lock.
+type Lock interface {
+	// Refresh extends the lock to ttl from now.
+	Refresh(ctx context.Context, ttl time.Duration) error
+	// Unlock releases the lock if it is still held.
+	Unlock(ctx context.Context) error
+}
+
+// newToken returns a random value identifying one acquisition, so a holder
+// whose lease expired cannot release or extend its successor's lock.
+func newToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
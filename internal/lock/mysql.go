@@ -0,0 +1,81 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MySQLLocker stores leases in the scheduler_locks table, which the
+// scheduler creates on startup. Unlike GET_LOCK it does not pin a pooled
+// connection for as long as the lock is held.
+type MySQLLocker struct {
+	db *sql.DB
+}
+
+func NewMySQLLocker(db *sql.DB) *MySQLLocker {
+	return &MySQLLocker{db: db}
+}
+
+func (m *MySQLLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (Lock, bool, error) {
+	token := newToken()
+
+	// MySQL applies the assignments left to right, so expires_at is only
+	// moved when owner was just taken over.
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO scheduler_locks (lock_name, owner, expires_at)
+		VALUES (?, ?, NOW(3) + INTERVAL ? MICROSECOND)
+		ON DUPLICATE KEY UPDATE
+			owner = IF(expires_at < NOW(3), VALUES(owner), owner),
+			expires_at = IF(owner = VALUES(owner), VALUES(expires_at), expires_at)
+	`, name, token, ttl.Microseconds())
+	if err != nil {
+		return nil, false, fmt.Errorf("acquiring lock %s: %w", name, err)
+	}
+
+	held, err := m.held(ctx, name, token)
+	if err != nil || !held {
+		return nil, false, err
+	}
+	return &mysqlLock{db: m.db, name: name, token: token}, true, nil
+}
+
+func (m *MySQLLocker) held(ctx context.Context, name, token string) (bool, error) {
+	var held bool
+	err := m.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) > 0 FROM scheduler_locks
+		WHERE lock_name = ? AND owner = ? AND expires_at > NOW(3)
+	`, name, token).Scan(&held)
+	if err != nil {
+		return false, fmt.Errorf("checking lock %s: %w", name, err)
+	}
+	return held, nil
+}
+
+type mysqlLock struct {
+	db    *sql.DB
+	name  string
+	token string
+}
+
+func (l *mysqlLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	result, err := l.db.ExecContext(ctx, `
+		UPDATE scheduler_locks SET expires_at = NOW(3) + INTERVAL ? MICROSECOND
+		WHERE lock_name = ? AND owner = ? AND expires_at > NOW(3)
+	`, ttl.Microseconds(), l.name, l.token)
+	if err != nil {
+		return fmt.Errorf("refreshing lock %s: %w", l.name, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrLost
+	}
+	return nil
+}
+
+func (l *mysqlLock) Unlock(ctx context.Context) error {
+	if _, err := l.db.ExecContext(ctx, "DELETE FROM scheduler_locks WHERE lock_name = ? AND owner = ?", l.name, l.token); err != nil {
+		return fmt.Errorf("releasing lock %s: %w", l.name, err)
+	}
+	return nil
+}
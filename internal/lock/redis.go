@@ -0,0 +1,267 @@
+package lock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redisKeyPrefix   = "go-cron:lock:"
+	redisDialTimeout = 5 * time.Second
+	// redisClockDrift is subtracted from a lease's validity to allow for
+	// clock differences between the Redis servers.
+	redisClockDrift = 0.01
+)
+
+const (
+	redisRefreshScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	redisUnlockScript  = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// RedisLocker implements the Redlock algorithm over one or more independent
+// Redis servers: a lock is held when a majority of them accepted it within
+// its TTL. With a single server it is a plain SET NX PX lock.
+type RedisLocker struct {
+	servers []*redisServer
+}
+
+// NewRedisLockerFromEnv configures the locker from REDIS_ADDRS
+// (comma-separated host:port of independent servers, not replicas of each
+// other), optional REDIS_PASSWORD and REDIS_DB.
+func NewRedisLockerFromEnv() (*RedisLocker, error) {
+	var addrs []string
+	for _, addr := range strings.Split(os.Getenv("REDIS_ADDRS"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("REDIS_ADDRS must be set for the redis lock provider")
+	}
+
+	db := 0
+	if raw := os.Getenv("REDIS_DB"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid REDIS_DB %q", raw)
+		}
+		db = n
+	}
+
+	l := &RedisLocker{}
+	for _, addr := range addrs {
+		l.servers = append(l.servers, &redisServer{addr: addr, password: os.Getenv("REDIS_PASSWORD"), db: db})
+	}
+	return l, nil
+}
+
+func (l *RedisLocker) quorum() int {
+	return len(l.servers)/2 + 1
+}
+
+func (l *RedisLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (Lock, bool, error) {
+	lock := &redisLock{locker: l, key: redisKeyPrefix + name, token: newToken()}
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+
+	start := time.Now()
+	acquired, errs := l.each(ctx, func(s *redisServer) (bool, error) {
+		reply, err := s.do(ctx, "SET", lock.key, lock.token, "NX", "PX", ms)
+		return reply == "OK", err
+	})
+
+	validity := ttl - time.Since(start) - time.Duration(float64(ttl)*redisClockDrift)
+	if acquired >= l.quorum() && validity > 0 {
+		return lock, true, nil
+	}
+
+	// Release the minority we did get so others need not wait for the TTL.
+	lock.Unlock(context.WithoutCancel(ctx))
+	if acquired == 0 && len(errs) == len(l.servers) {
+		return nil, false, fmt.Errorf("acquiring lock %s: %w", name, errors.Join(errs...))
+	}
+	return nil, false, nil
+}
+
+// each runs fn against every server in parallel and returns how many
+// returned true, plus the errors.
+func (l *RedisLocker) each(ctx context.Context, fn func(*redisServer) (bool, error)) (int, []error) {
+	type result struct {
+		ok  bool
+		err error
+	}
+	results := make(chan result, len(l.servers))
+	for _, s := range l.servers {
+		go func() {
+			ok, err := fn(s)
+			results <- result{ok, err}
+		}()
+	}
+
+	n := 0
+	var errs []error
+	for range l.servers {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+		} else if r.ok {
+			n++
+		}
+	}
+	return n, errs
+}
+
+type redisLock struct {
+	locker *RedisLocker
+	key    string
+	token  string
+}
+
+func (l *redisLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	n, errs := l.locker.each(ctx, func(s *redisServer) (bool, error) {
+		reply, err := s.do(ctx, "EVAL", redisRefreshScript, "1", l.key, l.token, ms)
+		return reply == "1", err
+	})
+	if n >= l.locker.quorum() {
+		return nil
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrLost, errors.Join(errs...))
+	}
+	return ErrLost
+}
+
+func (l *redisLock) Unlock(ctx context.Context) error {
+	_, errs := l.locker.each(ctx, func(s *redisServer) (bool, error) {
+		_, err := s.do(ctx, "EVAL", redisUnlockScript, "1", l.key, l.token)
+		return true, err
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("releasing %s: %w", l.key, errors.Join(errs...))
+	}
+	return nil
+}
+
+// redisServer is a single connection to one Redis server speaking RESP2. It
+// is re-dialed after any error.
+type redisServer struct {
+	addr     string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// do sends one command and returns a simple string, integer or bulk reply as
+// a string; a nil bulk reply is "".
+func (s *redisServer) do(ctx context.Context, args ...string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return "", fmt.Errorf("redis %s: %w", s.addr, err)
+		}
+	}
+	reply, err := s.roundTrip(ctx, args...)
+	if err != nil {
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			s.conn.Close()
+			s.conn = nil
+		}
+		return "", fmt.Errorf("redis %s: %w", s.addr, err)
+	}
+	return reply, nil
+}
+
+func (s *redisServer) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.conn, s.r = conn, bufio.NewReader(conn)
+
+	if s.password != "" {
+		if _, err := s.roundTrip(ctx, "AUTH", s.password); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	if s.db != 0 {
+		if _, err := s.roundTrip(ctx, "SELECT", strconv.Itoa(s.db)); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *redisServer) roundTrip(ctx context.Context, args ...string) (string, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisDialTimeout)
+	}
+	s.conn.SetDeadline(deadline)
+
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := s.conn.Write([]byte(cmd.String())); err != nil {
+		return "", err
+	}
+	return s.readReply()
+}
+
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func (s *redisServer) readReply() (string, error) {
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return "", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(s.r, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	default:
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,313 @@
+package lock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a RESP2 server holding string keys with expiry. It understands
+// the commands RedisLocker sends, with EVAL limited to its two scripts.
+type fakeRedis struct {
+	t        *testing.T
+	ln       net.Listener
+	password string
+
+	mu      sync.Mutex
+	db      map[string]string
+	expires map[string]time.Time
+	dbIndex int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{t: t, ln: ln, password: password, db: map[string]string{}, expires: map[string]time.Time{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) addr() string { return r.ln.Addr().String() }
+
+// get returns the live value of key.
+func (r *fakeRedis) get(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookup(key)
+}
+
+func (r *fakeRedis) set(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.db[key] = value
+	delete(r.expires, key)
+}
+
+func (r *fakeRedis) del(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.db, key)
+	delete(r.expires, key)
+}
+
+func (r *fakeRedis) lookup(key string) (string, bool) {
+	if exp, ok := r.expires[key]; ok && time.Now().After(exp) {
+		delete(r.db, key)
+		delete(r.expires, key)
+	}
+	v, ok := r.db[key]
+	return v, ok
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	authed := r.password == ""
+	for {
+		args, err := readCommand(br)
+		if err != nil {
+			if err != io.EOF {
+				r.t.Errorf("reading command: %v", err)
+			}
+			return
+		}
+		var reply string
+		if cmd := strings.ToUpper(args[0]); !authed && cmd != "AUTH" {
+			reply = "-NOAUTH Authentication required.\r\n"
+		} else {
+			reply = r.exec(args, &authed)
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads one command sent as a RESP array of bulk strings.
+func readCommand(br *bufio.Reader) ([]string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("expected array, got %q", line)
+	}
+	n, err := strconv.Atoi(line[1 : len(line)-2])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", line)
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, fmt.Errorf("bulk string of %d bytes not terminated", size)
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (r *fakeRedis) exec(args []string, authed *bool) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch cmd := strings.ToUpper(args[0]); {
+	case cmd == "AUTH" && len(args) == 2:
+		if args[1] != r.password {
+			return "-WRONGPASS invalid username-password pair or user is disabled.\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	case cmd == "SELECT" && len(args) == 2:
+		r.dbIndex, _ = strconv.Atoi(args[1])
+		return "+OK\r\n"
+	case cmd == "SET" && len(args) == 6 && args[3] == "NX" && args[4] == "PX":
+		if _, ok := r.lookup(args[1]); ok {
+			return "$-1\r\n"
+		}
+		ms, _ := strconv.Atoi(args[5])
+		r.db[args[1]] = args[2]
+		r.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return "+OK\r\n"
+	case cmd == "EVAL" && len(args) >= 5 && args[2] == "1":
+		key, token := args[3], args[4]
+		if v, ok := r.lookup(key); !ok || v != token {
+			return ":0\r\n"
+		}
+		switch args[1] {
+		case redisRefreshScript:
+			ms, _ := strconv.Atoi(args[5])
+			r.expires[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		case redisUnlockScript:
+			delete(r.db, key)
+			delete(r.expires, key)
+		default:
+			return "-NOSCRIPT unknown script\r\n"
+		}
+		return ":1\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+func TestRedisLockSingleServer(t *testing.T) {
+	server := newFakeRedis(t, "s3cret")
+	t.Setenv("REDIS_ADDRS", server.addr())
+	t.Setenv("REDIS_PASSWORD", "s3cret")
+	t.Setenv("REDIS_DB", "2")
+	locker, err := NewRedisLockerFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewRedisLockerFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	l, ok, err := locker.TryLock(ctx, "leader", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	if token, _ := server.get(redisKeyPrefix + "leader"); token != l.(*redisLock).token {
+		t.Errorf("stored token %q", token)
+	}
+	server.mu.Lock()
+	if server.dbIndex != 2 {
+		t.Errorf("SELECT %d", server.dbIndex)
+	}
+	server.mu.Unlock()
+	if _, ok, err := other.TryLock(ctx, "leader", time.Minute); ok || err != nil {
+		t.Errorf("second TryLock = %v, %v, want held", ok, err)
+	}
+	if err := l.Refresh(ctx, time.Minute); err != nil {
+		t.Errorf("Refresh: %v", err)
+	}
+	if err := l.Unlock(ctx); err != nil {
+		t.Errorf("Unlock: %v", err)
+	}
+	if _, ok := server.get(redisKeyPrefix + "leader"); ok {
+		t.Error("key still set after Unlock")
+	}
+
+	// A holder whose key was taken over must neither extend nor delete it.
+	l, _, _ = locker.TryLock(ctx, "leader", time.Minute)
+	server.set(redisKeyPrefix+"leader", "successor")
+	if err := l.Refresh(ctx, time.Minute); !errors.Is(err, ErrLost) {
+		t.Errorf("Refresh = %v, want ErrLost", err)
+	}
+	l.Unlock(ctx)
+	if token, _ := server.get(redisKeyPrefix + "leader"); token != "successor" {
+		t.Errorf("Unlock removed the successor's lock, key is %q", token)
+	}
+}
+
+func TestRedisLockWrongPassword(t *testing.T) {
+	server := newFakeRedis(t, "s3cret")
+	t.Setenv("REDIS_ADDRS", server.addr())
+	t.Setenv("REDIS_PASSWORD", "wrong")
+	t.Setenv("REDIS_DB", "")
+	locker, err := NewRedisLockerFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok, err := locker.TryLock(context.Background(), "leader", time.Minute)
+	if ok || err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("TryLock = %v, %v, want the AUTH error", ok, err)
+	}
+}
+
+func TestRedisLockQuorum(t *testing.T) {
+	servers := []*fakeRedis{newFakeRedis(t, ""), newFakeRedis(t, ""), newFakeRedis(t, "")}
+	t.Setenv("REDIS_ADDRS", servers[0].addr()+", "+servers[1].addr()+","+servers[2].addr())
+	t.Setenv("REDIS_PASSWORD", "")
+	t.Setenv("REDIS_DB", "")
+	locker, err := NewRedisLockerFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := redisKeyPrefix + "job:golf"
+
+	// Two of three is a majority.
+	servers[0].set(key, "stale")
+	l, ok, err := locker.TryLock(ctx, "job:golf", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("TryLock with 2/3 servers = %v, %v", ok, err)
+	}
+	l.Unlock(ctx)
+
+	// One of three is not, and the minority acquired is released.
+	servers[1].set(key, "stale")
+	if _, ok, err := locker.TryLock(ctx, "job:golf", time.Minute); ok || err != nil {
+		t.Errorf("TryLock with 1/3 servers = %v, %v", ok, err)
+	}
+	if _, ok := servers[2].get(key); ok {
+		t.Error("minority lock not released")
+	}
+
+	// Refresh fails once a majority no longer holds the token.
+	servers[0].del(key)
+	servers[1].del(key)
+	l, ok, _ = locker.TryLock(ctx, "job:golf", time.Minute)
+	if !ok {
+		t.Fatal("TryLock on free servers failed")
+	}
+	servers[0].set(key, "successor")
+	servers[1].set(key, "successor")
+	if err := l.Refresh(ctx, time.Minute); !errors.Is(err, ErrLost) {
+		t.Errorf("Refresh = %v, want ErrLost", err)
+	}
+}
+
+func TestRedisLockServersDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	t.Setenv("REDIS_ADDRS", addr)
+	t.Setenv("REDIS_PASSWORD", "")
+	t.Setenv("REDIS_DB", "")
+	locker, err := NewRedisLockerFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := locker.TryLock(context.Background(), "leader", time.Minute); ok || err == nil {
+		t.Errorf("TryLock with no reachable server = %v, %v, want an error", ok, err)
+	}
+}
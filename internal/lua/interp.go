@@ -0,0 +1,760 @@
+// Package lua runs small scripts written in a subset of Lua 5.1, so
+// operators can keep job logic such as "if amt_d < 10 then alert(...) end"
+// in the database. It implements the statements and expressions of the
+// language, closures, pcall and the math, string and table libraries, but
+// not coroutines, metatables, varargs, goto or Lua patterns. Numbers are
+// float64, and tables convert to and from JSON with FromJSON and ToJSON.
+//
+// Scripts only see the globals the host gives them; every run has a step
+// budget and honors its context, so a broken script cannot hang a worker.
+package lua
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultMaxSteps is the step budget of a Run when State.MaxSteps is 0.
+	DefaultMaxSteps = 1_000_000
+	// maxCallDepth bounds recursion, so a runaway script fails with a Lua
+	// error instead of exhausting the Go stack.
+	maxCallDepth = 200
+	// maxStringLen bounds the strings concatenation and string.rep build.
+	maxStringLen = 1 << 20
+)
+
+// ErrStepLimit is returned by Run when a script executes more steps
+// (statements, loop iterations and calls) than its budget. pcall cannot
+// catch it.
+var ErrStepLimit = errors.New("script exceeded its step limit")
+
+// Error is a Lua error: a syntax error, a runtime error, or a value raised
+// by error(). pcall catches it.
+type Error struct {
+	Chunk string
+	Line  int
+	Msg   string
+	// Value is the value passed to error(), nil for other errors.
+	Value Value
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Line == 0 && e.Chunk == "":
+		return e.Msg
+	case e.Line == 0:
+		return e.Chunk + ": " + e.Msg
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Chunk, e.Line, e.Msg)
+}
+
+// Errorf returns a Lua error for a Function to raise; the line of the call
+// is added to it.
+func Errorf(format string, args ...any) error {
+	return &Error{Msg: fmt.Sprintf(format, args...)}
+}
+
+// State runs compiled chunks against a set of globals: the standard library
+// subset of openLibs plus whatever the host adds with SetGlobal. Scripts
+// have no access to files, the network or the clock. A State is not safe
+// for concurrent use.
+type State struct {
+	// MaxSteps is the step budget of each Run; 0 means DefaultMaxSteps.
+	MaxSteps int
+	// Print receives the output of print; nil discards it.
+	Print func(string)
+
+	globals *Table
+	ctx     context.Context
+	chunk   string
+	steps   int
+	depth   int
+}
+
+// NewState returns a State with the standard library loaded.
+func NewState() *State {
+	s := &State{globals: NewTable(), ctx: context.Background()}
+	s.openLibs()
+	return s
+}
+
+// SetGlobal sets the global variable name.
+func (s *State) SetGlobal(name string, v Value) {
+	s.globals.Set(name, v)
+}
+
+// Global returns the global variable name.
+func (s *State) Global(name string) Value {
+	return s.globals.Get(name)
+}
+
+// Run executes c and returns the values its main chunk returns. It stops
+// with ctx's error when ctx is done and with ErrStepLimit when the step
+// budget is spent.
+func (s *State) Run(ctx context.Context, c *Chunk) ([]Value, error) {
+	s.ctx, s.chunk, s.steps, s.depth = ctx, c.name, 0, 0
+	ctl, rets, err := s.execBlock(c.body, nil)
+	if err == nil && ctl == ctlBreak {
+		err = s.errorf(0, "break outside a loop")
+	}
+	return rets, err
+}
+
+type env struct {
+	vars   map[string]*Value
+	parent *env
+}
+
+func (e *env) define(name string, v Value) {
+	if e.vars == nil {
+		e.vars = make(map[string]*Value)
+	}
+	e.vars[name] = &v
+}
+
+func (e *env) lookup(name string) *Value {
+	for ; e != nil; e = e.parent {
+		if p, ok := e.vars[name]; ok {
+			return p
+		}
+	}
+	return nil
+}
+
+type control int
+
+const (
+	ctlNone control = iota
+	ctlBreak
+	ctlReturn
+)
+
+func (s *State) errorf(line int, format string, args ...any) error {
+	return &Error{Chunk: s.chunk, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (s *State) step() error {
+	s.steps++
+	limit := s.MaxSteps
+	if limit <= 0 {
+		limit = DefaultMaxSteps
+	}
+	if s.steps > limit {
+		return ErrStepLimit
+	}
+	if s.steps%1024 == 0 {
+		return s.ctx.Err()
+	}
+	return nil
+}
+
+func (s *State) execBlock(b *block, parent *env) (control, []Value, error) {
+	return s.execStmts(b.stmts, &env{parent: parent})
+}
+
+func (s *State) execStmts(stmts []stmt, e *env) (control, []Value, error) {
+	for _, st := range stmts {
+		ctl, rets, err := s.exec(st, e)
+		if err != nil || ctl != ctlNone {
+			return ctl, rets, err
+		}
+	}
+	return ctlNone, nil, nil
+}
+
+func (s *State) exec(st stmt, e *env) (control, []Value, error) {
+	if err := s.step(); err != nil {
+		return ctlNone, nil, err
+	}
+
+	switch st := st.(type) {
+	case *localStmt:
+		vals, err := s.evalList(st.exprs, e, len(st.names))
+		if err != nil {
+			return ctlNone, nil, err
+		}
+		for i, name := range st.names {
+			e.define(name, vals[i])
+		}
+
+	case *localFuncStmt:
+		e.define(st.name, nil)
+		*e.lookup(st.name) = &closure{fn: st.fn, env: e}
+
+	case *assignStmt:
+		return ctlNone, nil, s.assign(st, e)
+
+	case *callStmt:
+		_, err := s.evalMulti(st.call, e)
+		return ctlNone, nil, err
+
+	case *ifStmt:
+		for i, cond := range st.conds {
+			v, err := s.eval(cond, e)
+			if err != nil {
+				return ctlNone, nil, err
+			}
+			if truthy(v) {
+				return s.execBlock(st.blocks[i], e)
+			}
+		}
+		if st.els != nil {
+			return s.execBlock(st.els, e)
+		}
+
+	case *whileStmt:
+		for {
+			v, err := s.eval(st.cond, e)
+			if err != nil {
+				return ctlNone, nil, err
+			}
+			if !truthy(v) {
+				break
+			}
+			ctl, rets, err := s.execBlock(st.body, e)
+			if err != nil || ctl == ctlReturn {
+				return ctl, rets, err
+			}
+			if ctl == ctlBreak {
+				break
+			}
+			if err := s.step(); err != nil {
+				return ctlNone, nil, err
+			}
+		}
+
+	case *repeatStmt:
+		for {
+			// The condition sees the body's locals.
+			body := &env{parent: e}
+			ctl, rets, err := s.execStmts(st.body.stmts, body)
+			if err != nil || ctl == ctlReturn {
+				return ctl, rets, err
+			}
+			if ctl == ctlBreak {
+				break
+			}
+			v, err := s.eval(st.cond, body)
+			if err != nil {
+				return ctlNone, nil, err
+			}
+			if truthy(v) {
+				break
+			}
+			if err := s.step(); err != nil {
+				return ctlNone, nil, err
+			}
+		}
+
+	case *numForStmt:
+		return s.numFor(st, e)
+
+	case *genForStmt:
+		return s.genFor(st, e)
+
+	case *doStmt:
+		return s.execBlock(st.body, e)
+
+	case *breakStmt:
+		return ctlBreak, nil, nil
+
+	case *returnStmt:
+		rets, err := s.evalList(st.exprs, e, -1)
+		return ctlReturn, rets, err
+	}
+	return ctlNone, nil, nil
+}
+
+func (s *State) assign(st *assignStmt, e *env) error {
+	type ref struct {
+		obj, key Value
+		name     *nameExpr
+		line     int
+	}
+	refs := make([]ref, len(st.targets))
+	for i, t := range st.targets {
+		switch t := t.(type) {
+		case *nameExpr:
+			refs[i] = ref{name: t}
+		case *indexExpr:
+			obj, err := s.eval(t.obj, e)
+			if err != nil {
+				return err
+			}
+			key, err := s.eval(t.key, e)
+			if err != nil {
+				return err
+			}
+			refs[i] = ref{obj: obj, key: key, line: t.line}
+			if _, ok := obj.(*Table); !ok {
+				return s.errorf(t.line, "attempt to index a %s value%s", TypeName(obj), describe(t.obj, e))
+			}
+		}
+	}
+
+	vals, err := s.evalList(st.exprs, e, len(st.targets))
+	if err != nil {
+		return err
+	}
+	for i, r := range refs {
+		if r.name != nil {
+			if p := e.lookup(r.name.name); p != nil {
+				*p = vals[i]
+			} else {
+				s.globals.Set(r.name.name, vals[i])
+			}
+			continue
+		}
+		if err := s.checkKey(r.key, r.line); err != nil {
+			return err
+		}
+		r.obj.(*Table).Set(r.key, vals[i])
+	}
+	return nil
+}
+
+func (s *State) checkKey(key Value, line int) error {
+	if key == nil {
+		return s.errorf(line, "table index is nil")
+	}
+	if f, ok := key.(float64); ok && math.IsNaN(f) {
+		return s.errorf(line, "table index is NaN")
+	}
+	return nil
+}
+
+func (s *State) numFor(st *numForStmt, e *env) (control, []Value, error) {
+	var bounds [3]float64
+	for i, x := range []expr{st.start, st.limit, st.step} {
+		if x == nil {
+			bounds[i] = 1
+			continue
+		}
+		v, err := s.eval(x, e)
+		if err != nil {
+			return ctlNone, nil, err
+		}
+		n, ok := toNumber(v)
+		if !ok {
+			return ctlNone, nil, s.errorf(st.line, "'for' %s must be a number", [3]string{"initial value", "limit", "step"}[i])
+		}
+		bounds[i] = n
+	}
+	start, limit, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return ctlNone, nil, s.errorf(st.line, "'for' step is zero")
+	}
+
+	for i := start; (step > 0 && i <= limit) || (step < 0 && i >= limit); i += step {
+		loop := &env{parent: e}
+		loop.define(st.name, i)
+		ctl, rets, err := s.execBlock(st.body, loop)
+		if err != nil || ctl == ctlReturn {
+			return ctl, rets, err
+		}
+		if ctl == ctlBreak {
+			break
+		}
+		if err := s.step(); err != nil {
+			return ctlNone, nil, err
+		}
+	}
+	return ctlNone, nil, nil
+}
+
+func (s *State) genFor(st *genForStmt, e *env) (control, []Value, error) {
+	init, err := s.evalList(st.exprs, e, 3)
+	if err != nil {
+		return ctlNone, nil, err
+	}
+	fn, state, ctlVar := init[0], init[1], init[2]
+	for {
+		rets, err := s.call(fn, []Value{state, ctlVar}, st.line, "for iterator")
+		if err != nil {
+			return ctlNone, nil, err
+		}
+		if len(rets) == 0 || rets[0] == nil {
+			break
+		}
+		ctlVar = rets[0]
+		loop := &env{parent: e}
+		for i, name := range st.names {
+			var v Value
+			if i < len(rets) {
+				v = rets[i]
+			}
+			loop.define(name, v)
+		}
+		ctl, rets, err := s.execBlock(st.body, loop)
+		if err != nil || ctl == ctlReturn {
+			return ctl, rets, err
+		}
+		if ctl == ctlBreak {
+			break
+		}
+	}
+	return ctlNone, nil, nil
+}
+
+// evalList evaluates exprs, expanding the results of a final call. want is
+// the number of values to return, padded with nil, or -1 for all.
+func (s *State) evalList(exprs []expr, e *env, want int) ([]Value, error) {
+	var vals []Value
+	for i, x := range exprs {
+		if i == len(exprs)-1 {
+			multi, err := s.evalMulti(x, e)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, multi...)
+			break
+		}
+		v, err := s.eval(x, e)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	if want < 0 {
+		return vals, nil
+	}
+	for len(vals) < want {
+		vals = append(vals, nil)
+	}
+	return vals[:want], nil
+}
+
+// evalMulti evaluates x, returning all results of a call.
+func (s *State) evalMulti(x expr, e *env) ([]Value, error) {
+	switch x := x.(type) {
+	case *callExpr:
+		fn, err := s.eval(x.fn, e)
+		if err != nil {
+			return nil, err
+		}
+		args, err := s.evalList(x.args, e, -1)
+		if err != nil {
+			return nil, err
+		}
+		return s.call(fn, args, x.line, describe(x.fn, e))
+
+	case *methodExpr:
+		obj, err := s.eval(x.obj, e)
+		if err != nil {
+			return nil, err
+		}
+		var fn Value
+		switch o := obj.(type) {
+		case string:
+			if lib, ok := s.globals.Get("string").(*Table); ok {
+				fn = lib.Get(x.name)
+			}
+		case *Table:
+			fn = o.Get(x.name)
+		default:
+			return nil, s.errorf(x.line, "attempt to index a %s value%s", TypeName(obj), describe(x.obj, e))
+		}
+		args, err := s.evalList(x.args, e, -1)
+		if err != nil {
+			return nil, err
+		}
+		return s.call(fn, append([]Value{obj}, args...), x.line, fmt.Sprintf(" (method '%s')", x.name))
+	}
+
+	v, err := s.eval(x, e)
+	if err != nil {
+		return nil, err
+	}
+	return []Value{v}, nil
+}
+
+// call calls fn; what describes it in errors.
+func (s *State) call(fn Value, args []Value, line int, what string) ([]Value, error) {
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+
+	switch fn := fn.(type) {
+	case *closure:
+		if s.depth >= maxCallDepth {
+			return nil, s.errorf(line, "stack overflow")
+		}
+		s.depth++
+		defer func() { s.depth-- }()
+
+		local := &env{parent: fn.env}
+		for i, name := range fn.fn.params {
+			var v Value
+			if i < len(args) {
+				v = args[i]
+			}
+			local.define(name, v)
+		}
+		ctl, rets, err := s.execStmts(fn.fn.body.stmts, local)
+		if err == nil && ctl == ctlBreak {
+			err = s.errorf(line, "break outside a loop")
+		}
+		return rets, err
+
+	case *Function:
+		rets, err := fn.Fn(s, args)
+		var luaErr *Error
+		if errors.As(err, &luaErr) && luaErr.Line == 0 {
+			luaErr.Chunk, luaErr.Line = s.chunk, line
+		}
+		return rets, err
+	}
+	return nil, s.errorf(line, "attempt to call a %s value%s", TypeName(fn), what)
+}
+
+func (s *State) eval(x expr, e *env) (Value, error) {
+	switch x := x.(type) {
+	case *constExpr:
+		return x.v, nil
+
+	case *nameExpr:
+		if p := e.lookup(x.name); p != nil {
+			return *p, nil
+		}
+		return s.globals.Get(x.name), nil
+
+	case *indexExpr:
+		obj, err := s.eval(x.obj, e)
+		if err != nil {
+			return nil, err
+		}
+		key, err := s.eval(x.key, e)
+		if err != nil {
+			return nil, err
+		}
+		t, ok := obj.(*Table)
+		if !ok {
+			return nil, s.errorf(x.line, "attempt to index a %s value%s", TypeName(obj), describe(x.obj, e))
+		}
+		return t.Get(key), nil
+
+	case *callExpr, *methodExpr:
+		vals, err := s.evalMulti(x, e)
+		if err != nil || len(vals) == 0 {
+			return nil, err
+		}
+		return vals[0], nil
+
+	case *parenExpr:
+		return s.eval(x.x, e)
+
+	case *funcExpr:
+		return &closure{fn: x, env: e}, nil
+
+	case *tableExpr:
+		return s.table(x, e)
+
+	case *unExpr:
+		v, err := s.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		return s.unary(x, v, e)
+
+	case *binExpr:
+		l, err := s.eval(x.l, e)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "and":
+			if !truthy(l) {
+				return l, nil
+			}
+			return s.eval(x.r, e)
+		case "or":
+			if truthy(l) {
+				return l, nil
+			}
+			return s.eval(x.r, e)
+		}
+		r, err := s.eval(x.r, e)
+		if err != nil {
+			return nil, err
+		}
+		return s.binary(x, l, r, e)
+	}
+	return nil, fmt.Errorf("lua: unknown expression %T", x)
+}
+
+func (s *State) table(x *tableExpr, e *env) (Value, error) {
+	t := NewTable()
+	n := 1
+	for i, f := range x.fields {
+		if f.key == nil {
+			if i == len(x.fields)-1 {
+				vals, err := s.evalMulti(f.val, e)
+				if err != nil {
+					return nil, err
+				}
+				for _, v := range vals {
+					t.Set(float64(n), v)
+					n++
+				}
+				break
+			}
+			v, err := s.eval(f.val, e)
+			if err != nil {
+				return nil, err
+			}
+			t.Set(float64(n), v)
+			n++
+			continue
+		}
+		k, err := s.eval(f.key, e)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkKey(k, x.line); err != nil {
+			return nil, err
+		}
+		v, err := s.eval(f.val, e)
+		if err != nil {
+			return nil, err
+		}
+		t.Set(k, v)
+	}
+	return t, nil
+}
+
+func (s *State) unary(x *unExpr, v Value, e *env) (Value, error) {
+	switch x.op {
+	case "not":
+		return !truthy(v), nil
+	case "-":
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, s.errorf(x.line, "attempt to perform arithmetic on a %s value%s", TypeName(v), describe(x.x, e))
+		}
+		return -n, nil
+	}
+	switch v := v.(type) {
+	case string:
+		return float64(len(v)), nil
+	case *Table:
+		return float64(v.Len()), nil
+	}
+	return nil, s.errorf(x.line, "attempt to get length of a %s value%s", TypeName(v), describe(x.x, e))
+}
+
+func (s *State) binary(x *binExpr, l, r Value, e *env) (Value, error) {
+	switch x.op {
+	case "==":
+		return l == r, nil
+	case "~=":
+		return l != r, nil
+	case "<", "<=", ">", ">=":
+		return s.compare(x, l, r)
+	case "..":
+		ls, lok := concatOperand(l)
+		rs, rok := concatOperand(r)
+		if !lok || !rok {
+			bad, side := l, x.l
+			if lok {
+				bad, side = r, x.r
+			}
+			return nil, s.errorf(x.line, "attempt to concatenate a %s value%s", TypeName(bad), describe(side, e))
+		}
+		if len(ls)+len(rs) > maxStringLen {
+			return nil, s.errorf(x.line, "string longer than %d bytes", maxStringLen)
+		}
+		return ls + rs, nil
+	}
+
+	a, aok := toNumber(l)
+	b, bok := toNumber(r)
+	if !aok || !bok {
+		bad, side := l, x.l
+		if aok {
+			bad, side = r, x.r
+		}
+		return nil, s.errorf(x.line, "attempt to perform arithmetic on a %s value%s", TypeName(bad), describe(side, e))
+	}
+	switch x.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		return a / b, nil
+	case "%":
+		return a - math.Floor(a/b)*b, nil
+	}
+	return math.Pow(a, b), nil
+}
+
+func concatOperand(v Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return formatNumber(v), true
+	}
+	return "", false
+}
+
+func (s *State) compare(x *binExpr, l, r Value) (Value, error) {
+	less := func(a, b Value) (bool, bool) {
+		switch a := a.(type) {
+		case float64:
+			if b, ok := b.(float64); ok {
+				return a < b, true
+			}
+		case string:
+			if b, ok := b.(string); ok {
+				return a < b, true
+			}
+		}
+		return false, false
+	}
+	var result, ok bool
+	switch x.op {
+	case "<":
+		result, ok = less(l, r)
+	case ">":
+		result, ok = less(r, l)
+	case "<=":
+		result, ok = less(r, l)
+		result = !result
+	case ">=":
+		result, ok = less(l, r)
+		result = !result
+	}
+	if !ok {
+		return nil, s.errorf(x.line, "attempt to compare %s with %s", TypeName(l), TypeName(r))
+	}
+	// NaN compares false both ways.
+	if f, isNum := l.(float64); isNum && (math.IsNaN(f) || math.IsNaN(r.(float64))) {
+		return false, nil
+	}
+	return result, nil
+}
+
+// describe names the variable or field x refers to for error messages, as
+// in "attempt to index a nil value (global 'cfg')".
+func describe(x expr, e *env) string {
+	switch x := x.(type) {
+	case *nameExpr:
+		if e.lookup(x.name) != nil {
+			return fmt.Sprintf(" (local '%s')", x.name)
+		}
+		return fmt.Sprintf(" (global '%s')", x.name)
+	case *indexExpr:
+		if k, ok := x.key.(*constExpr); ok {
+			if name, ok := k.v.(string); ok {
+				return fmt.Sprintf(" (field '%s')", name)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,275 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tEOF tokenKind = iota
+	tName
+	tNumber
+	tString
+	// tSymbol is an operator, punctuation or keyword, spelled in token.s.
+	tSymbol
+)
+
+type token struct {
+	kind tokenKind
+	s    string
+	n    float64
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tEOF:
+		return "<eof>"
+	case tString:
+		return fmt.Sprintf("%q", t.s)
+	}
+	return "'" + t.s + "'"
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "if": true, "in": true, "local": true,
+	"nil": true, "not": true, "or": true, "repeat": true, "return": true, "then": true,
+	"true": true, "until": true, "while": true,
+}
+
+// symbols are matched longest first.
+var symbols = []string{
+	"...", "..", "==", "~=", "<=", ">=",
+	"+", "-", "*", "/", "%", "^", "#", "<", ">", "=",
+	"(", ")", "{", "}", "[", "]", ";", ":", ",", ".",
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return &Error{Line: l.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tEOF, line: l.line}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		word := l.src[start:l.pos]
+		if keywords[word] {
+			return token{kind: tSymbol, s: word, line: l.line}, nil
+		}
+		return token{kind: tName, s: word, line: l.line}, nil
+
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		return l.number()
+
+	case c == '"' || c == '\'':
+		return l.quoted(c)
+
+	case c == '[' && l.longBracket() >= 0:
+		s, err := l.long()
+		return token{kind: tString, s: s, line: l.line}, err
+	}
+
+	for _, sym := range symbols {
+		if strings.HasPrefix(l.src[l.pos:], sym) {
+			l.pos += len(sym)
+			return token{kind: tSymbol, s: sym, line: l.line}, nil
+		}
+	}
+	return token{}, l.errorf("unexpected character %q", c)
+}
+
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "--"):
+			l.pos += 2
+			if l.pos < len(l.src) && l.src[l.pos] == '[' && l.longBracket() >= 0 {
+				if _, err := l.long(); err != nil {
+					return err
+				}
+				continue
+			}
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// longBracket returns the level of the long bracket [==[ at pos, or -1.
+func (l *lexer) longBracket() int {
+	i := l.pos + 1
+	for i < len(l.src) && l.src[i] == '=' {
+		i++
+	}
+	if i < len(l.src) && l.src[i] == '[' {
+		return i - l.pos - 1
+	}
+	return -1
+}
+
+// long reads a long string or comment starting at its opening bracket.
+func (l *lexer) long() (string, error) {
+	level := l.longBracket()
+	l.pos += level + 2
+	// A newline right after the opening bracket is skipped.
+	if strings.HasPrefix(l.src[l.pos:], "\r\n") {
+		l.pos += 2
+		l.line++
+	} else if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+		l.pos++
+		l.line++
+	}
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := strings.Index(l.src[l.pos:], closing)
+	if end < 0 {
+		return "", l.errorf("unfinished long string or comment")
+	}
+	s := l.src[l.pos : l.pos+end]
+	l.line += strings.Count(s, "\n")
+	l.pos += end + len(closing)
+	return s, nil
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") {
+		l.pos += 2
+		for l.pos < len(l.src) && isHexDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	} else {
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+			l.pos++
+			if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+				l.pos++
+			}
+			for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+				l.pos++
+			}
+		}
+	}
+	text := l.src[start:l.pos]
+	n, ok := parseNumber(text)
+	if !ok || (l.pos < len(l.src) && isLetter(l.src[l.pos])) {
+		return token{}, l.errorf("malformed number near %q", text)
+	}
+	return token{kind: tNumber, n: n, s: text, line: l.line}, nil
+}
+
+func (l *lexer) quoted(quote byte) (token, error) {
+	l.pos++
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return token{}, l.errorf("unfinished string")
+		}
+		c := l.src[l.pos]
+		l.pos++
+		if c == quote {
+			return token{kind: tString, s: b.String(), line: l.line}, nil
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if l.pos >= len(l.src) {
+			return token{}, l.errorf("unfinished string")
+		}
+		e := l.src[l.pos]
+		l.pos++
+		switch e {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '"', '\'':
+			b.WriteByte(e)
+		case '\n':
+			b.WriteByte('\n')
+			l.line++
+		case 'x':
+			if l.pos+2 > len(l.src) || !isHexDigit(l.src[l.pos]) || !isHexDigit(l.src[l.pos+1]) {
+				return token{}, l.errorf("invalid \\x escape")
+			}
+			b.WriteByte(hexValue(l.src[l.pos])<<4 | hexValue(l.src[l.pos+1]))
+			l.pos += 2
+		default:
+			if !isDigit(e) {
+				return token{}, l.errorf("invalid escape \\%c", e)
+			}
+			n := int(e - '0')
+			for i := 0; i < 2 && l.pos < len(l.src) && isDigit(l.src[l.pos]); i++ {
+				n = n*10 + int(l.src[l.pos]-'0')
+				l.pos++
+			}
+			if n > 255 {
+				return token{}, l.errorf("decimal escape too large")
+			}
+			b.WriteByte(byte(n))
+		}
+	}
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case isDigit(c):
+		return c - '0'
+	case c >= 'a':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
@@ -0,0 +1,622 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// openLibs loads the subset of the standard library scripts may use: the
+// basic functions, math, string and table. io, os and load are left out.
+func (s *State) openLibs() {
+	for name, fn := range map[string]func(*State, []Value) ([]Value, error){
+		"assert":   baseAssert,
+		"error":    baseError,
+		"ipairs":   baseIpairs,
+		"pairs":    basePairs,
+		"pcall":    basePcall,
+		"print":    basePrint,
+		"tonumber": baseTonumber,
+		"tostring": func(_ *State, args []Value) ([]Value, error) { return []Value{ToString(arg(args, 0))}, nil },
+		"type":     baseType,
+		"unpack":   tableUnpack,
+	} {
+		s.globals.Set(name, &Function{Name: name, Fn: fn})
+	}
+
+	s.globals.Set("math", library("math", map[string]func(*State, []Value) ([]Value, error){
+		"abs":   mathFunc("abs", math.Abs),
+		"ceil":  mathFunc("ceil", math.Ceil),
+		"floor": mathFunc("floor", math.Floor),
+		"sqrt":  mathFunc("sqrt", math.Sqrt),
+		"fmod":  mathFmod,
+		"max":   mathMinMax("max", func(a, b float64) bool { return a > b }),
+		"min":   mathMinMax("min", func(a, b float64) bool { return a < b }),
+	}, map[string]Value{"huge": math.Inf(1), "pi": math.Pi}))
+
+	s.globals.Set("string", library("string", map[string]func(*State, []Value) ([]Value, error){
+		"find":   stringFind,
+		"format": stringFormat,
+		"len":    stringLen,
+		"lower":  stringCase("lower", strings.ToLower),
+		"rep":    stringRep,
+		"sub":    stringSub,
+		"upper":  stringCase("upper", strings.ToUpper),
+	}, nil))
+
+	s.globals.Set("table", library("table", map[string]func(*State, []Value) ([]Value, error){
+		"concat": tableConcat,
+		"insert": tableInsert,
+		"remove": tableRemove,
+		"sort":   tableSort,
+		"unpack": tableUnpack,
+	}, nil))
+}
+
+func library(name string, fns map[string]func(*State, []Value) ([]Value, error), consts map[string]Value) *Table {
+	t := NewTable()
+	for fname, fn := range fns {
+		t.Set(fname, &Function{Name: name + "." + fname, Fn: fn})
+	}
+	for k, v := range consts {
+		t.Set(k, v)
+	}
+	return t
+}
+
+func arg(args []Value, i int) Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+func argError(i int, fname, msg string) error {
+	return Errorf("bad argument #%d to '%s' (%s)", i+1, fname, msg)
+}
+
+func checkNumber(args []Value, i int, fname string) (float64, error) {
+	n, ok := toNumber(arg(args, i))
+	if !ok {
+		return 0, argError(i, fname, "number expected, got "+typeOrNoValue(args, i))
+	}
+	return n, nil
+}
+
+func checkInt(args []Value, i int, fname string) (int, error) {
+	n, err := checkNumber(args, i, fname)
+	if err != nil {
+		return 0, err
+	}
+	if n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+		return 0, argError(i, fname, "number has no integer representation")
+	}
+	return int(n), nil
+}
+
+func optInt(args []Value, i int, fname string, def int) (int, error) {
+	if arg(args, i) == nil {
+		return def, nil
+	}
+	return checkInt(args, i, fname)
+}
+
+func checkString(args []Value, i int, fname string) (string, error) {
+	switch v := arg(args, i).(type) {
+	case string:
+		return v, nil
+	case float64:
+		return formatNumber(v), nil
+	}
+	return "", argError(i, fname, "string expected, got "+typeOrNoValue(args, i))
+}
+
+func checkTable(args []Value, i int, fname string) (*Table, error) {
+	t, ok := arg(args, i).(*Table)
+	if !ok {
+		return nil, argError(i, fname, "table expected, got "+typeOrNoValue(args, i))
+	}
+	return t, nil
+}
+
+func typeOrNoValue(args []Value, i int) string {
+	if i >= len(args) {
+		return "no value"
+	}
+	return TypeName(args[i])
+}
+
+func baseAssert(_ *State, args []Value) ([]Value, error) {
+	if truthy(arg(args, 0)) {
+		return args, nil
+	}
+	if msg := arg(args, 1); msg != nil {
+		return nil, &Error{Msg: ToString(msg), Value: msg}
+	}
+	return nil, Errorf("assertion failed!")
+}
+
+func baseError(_ *State, args []Value) ([]Value, error) {
+	v := arg(args, 0)
+	return nil, &Error{Msg: ToString(v), Value: v}
+}
+
+func baseIpairs(_ *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "ipairs")
+	if err != nil {
+		return nil, err
+	}
+	next := &Function{Name: "ipairs_iterator", Fn: func(_ *State, args []Value) ([]Value, error) {
+		i, _ := toNumber(arg(args, 1))
+		v := t.Get(i + 1)
+		if v == nil {
+			return []Value{nil}, nil
+		}
+		return []Value{i + 1, v}, nil
+	}}
+	return []Value{next, t, float64(0)}, nil
+}
+
+// basePairs iterates over the keys t had when pairs was called, in
+// insertion order, skipping those removed since.
+func basePairs(_ *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "pairs")
+	if err != nil {
+		return nil, err
+	}
+	keys := t.keys()
+	i := 0
+	next := &Function{Name: "pairs_iterator", Fn: func(_ *State, _ []Value) ([]Value, error) {
+		for i < len(keys) {
+			k := keys[i]
+			i++
+			if v := t.Get(k); v != nil {
+				return []Value{k, v}, nil
+			}
+		}
+		return []Value{nil}, nil
+	}}
+	return []Value{next, t, nil}, nil
+}
+
+// basePcall catches Lua errors; running out of steps or being cancelled
+// still stops the script.
+func basePcall(s *State, args []Value) ([]Value, error) {
+	if len(args) == 0 {
+		return nil, argError(0, "pcall", "value expected")
+	}
+	depth := s.depth
+	rets, err := s.call(args[0], args[1:], 0, "")
+	var luaErr *Error
+	if errors.As(err, &luaErr) {
+		s.depth = depth
+		msg := luaErr.Value
+		if _, isString := msg.(string); isString || msg == nil {
+			msg = luaErr.Error()
+		}
+		return []Value{false, msg}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]Value{true}, rets...), nil
+}
+
+func basePrint(s *State, args []Value) ([]Value, error) {
+	if s.Print == nil {
+		return nil, nil
+	}
+	parts := make([]string, len(args))
+	for i, v := range args {
+		parts[i] = ToString(v)
+	}
+	s.Print(strings.Join(parts, "\t"))
+	return nil, nil
+}
+
+func baseTonumber(_ *State, args []Value) ([]Value, error) {
+	v := arg(args, 0)
+	if arg(args, 1) == nil {
+		n, ok := toNumber(v)
+		if !ok {
+			return []Value{nil}, nil
+		}
+		return []Value{n}, nil
+	}
+	base, err := checkInt(args, 1, "tonumber")
+	if err != nil {
+		return nil, err
+	}
+	if base < 2 || base > 36 {
+		return nil, argError(1, "tonumber", "base out of range")
+	}
+	str, err := checkString(args, 0, "tonumber")
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseInt(strings.ToLower(strings.TrimSpace(str)), base, 64)
+	if err != nil {
+		return []Value{nil}, nil
+	}
+	return []Value{float64(n)}, nil
+}
+
+func baseType(_ *State, args []Value) ([]Value, error) {
+	if len(args) == 0 {
+		return nil, argError(0, "type", "value expected")
+	}
+	return []Value{TypeName(args[0])}, nil
+}
+
+func mathFunc(name string, fn func(float64) float64) func(*State, []Value) ([]Value, error) {
+	return func(_ *State, args []Value) ([]Value, error) {
+		n, err := checkNumber(args, 0, "math."+name)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{fn(n)}, nil
+	}
+}
+
+func mathFmod(_ *State, args []Value) ([]Value, error) {
+	a, err := checkNumber(args, 0, "math.fmod")
+	if err != nil {
+		return nil, err
+	}
+	b, err := checkNumber(args, 1, "math.fmod")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{math.Mod(a, b)}, nil
+}
+
+func mathMinMax(name string, better func(a, b float64) bool) func(*State, []Value) ([]Value, error) {
+	return func(_ *State, args []Value) ([]Value, error) {
+		best, err := checkNumber(args, 0, "math."+name)
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(args); i++ {
+			n, err := checkNumber(args, i, "math."+name)
+			if err != nil {
+				return nil, err
+			}
+			if better(n, best) {
+				best = n
+			}
+		}
+		return []Value{best}, nil
+	}
+}
+
+func stringLen(_ *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "string.len")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{float64(len(str))}, nil
+}
+
+func stringCase(name string, fn func(string) string) func(*State, []Value) ([]Value, error) {
+	return func(_ *State, args []Value) ([]Value, error) {
+		str, err := checkString(args, 0, "string."+name)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{fn(str)}, nil
+	}
+}
+
+// strIndex converts a 1-based, possibly negative string position to a
+// 0-based offset clamped to [0, n].
+func strIndex(i, n int) int {
+	if i < 0 {
+		i = n + i + 1
+	}
+	return min(max(i-1, 0), n)
+}
+
+func stringSub(_ *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "string.sub")
+	if err != nil {
+		return nil, err
+	}
+	i, err := optInt(args, 1, "string.sub", 1)
+	if err != nil {
+		return nil, err
+	}
+	j, err := optInt(args, 2, "string.sub", -1)
+	if err != nil {
+		return nil, err
+	}
+	start := strIndex(i, len(str))
+	end := len(str)
+	if j >= 0 {
+		end = min(j, len(str))
+	} else {
+		end = max(len(str)+j+1, 0)
+	}
+	if start >= end {
+		return []Value{""}, nil
+	}
+	return []Value{str[start:end]}, nil
+}
+
+func stringRep(_ *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "string.rep")
+	if err != nil {
+		return nil, err
+	}
+	n, err := checkInt(args, 1, "string.rep")
+	if err != nil {
+		return nil, err
+	}
+	sep := ""
+	if arg(args, 2) != nil {
+		if sep, err = checkString(args, 2, "string.rep"); err != nil {
+			return nil, err
+		}
+	}
+	if n <= 0 {
+		return []Value{""}, nil
+	}
+	// n may be up to 2^53, so divide rather than multiply to avoid
+	// overflowing int.
+	if unit := len(str) + len(sep); unit > 0 && n > maxStringLen/unit {
+		return nil, Errorf("string longer than %d bytes", maxStringLen)
+	}
+	return []Value{strings.Repeat(str+sep, n-1) + str}, nil
+}
+
+// stringFind supports plain searches only; Lua patterns are not
+// implemented.
+func stringFind(_ *State, args []Value) ([]Value, error) {
+	str, err := checkString(args, 0, "string.find")
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := checkString(args, 1, "string.find")
+	if err != nil {
+		return nil, err
+	}
+	init, err := optInt(args, 2, "string.find", 1)
+	if err != nil {
+		return nil, err
+	}
+	if !truthy(arg(args, 3)) && strings.ContainsAny(pattern, "^$*+?.([%-") {
+		return nil, Errorf("string.find: patterns are not supported, pass true as the fourth argument for a plain search")
+	}
+	start := strIndex(init, len(str))
+	i := strings.Index(str[start:], pattern)
+	if i < 0 {
+		return []Value{nil}, nil
+	}
+	return []Value{float64(start + i + 1), float64(start + i + len(pattern))}, nil
+}
+
+func stringFormat(_ *State, args []Value) ([]Value, error) {
+	format, err := checkString(args, 0, "string.format")
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	n := 1
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0123456789.", format[j]) >= 0 {
+			j++
+		}
+		if j >= len(format) {
+			return nil, Errorf("invalid format string to 'format'")
+		}
+		spec, verb := format[i:j], format[j]
+		i = j
+		if verb == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if n >= len(args) {
+			return nil, argError(n, "string.format", "no value")
+		}
+		switch verb {
+		case 'd', 'i':
+			v, err := checkInt(args, n, "string.format")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, spec+"d", v)
+		case 'x', 'X', 'o':
+			v, err := checkInt(args, n, "string.format")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, spec+string(verb), v)
+		case 'e', 'E', 'f', 'g', 'G':
+			v, err := checkNumber(args, n, "string.format")
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, spec+string(verb), v)
+		case 's':
+			fmt.Fprintf(&b, spec+"s", ToString(args[n]))
+		case 'q':
+			str, err := checkString(args, n, "string.format")
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(strconv.Quote(str))
+		default:
+			return nil, Errorf("invalid option '%%%c' to 'format'", verb)
+		}
+		if b.Len() > maxStringLen {
+			return nil, Errorf("string longer than %d bytes", maxStringLen)
+		}
+		n++
+	}
+	return []Value{b.String()}, nil
+}
+
+func tableConcat(_ *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "table.concat")
+	if err != nil {
+		return nil, err
+	}
+	sep := ""
+	if arg(args, 1) != nil {
+		if sep, err = checkString(args, 1, "table.concat"); err != nil {
+			return nil, err
+		}
+	}
+	i, err := optInt(args, 2, "table.concat", 1)
+	if err != nil {
+		return nil, err
+	}
+	j, err := optInt(args, 3, "table.concat", t.Len())
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for k := i; k <= j; k++ {
+		part, ok := concatOperand(t.Get(float64(k)))
+		if !ok {
+			return nil, Errorf("invalid value (at index %d) in table for 'concat'", k)
+		}
+		if k > i {
+			b.WriteString(sep)
+		}
+		b.WriteString(part)
+		if b.Len() > maxStringLen {
+			return nil, Errorf("string longer than %d bytes", maxStringLen)
+		}
+	}
+	return []Value{b.String()}, nil
+}
+
+func tableInsert(_ *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "table.insert")
+	if err != nil {
+		return nil, err
+	}
+	n := t.Len()
+	switch len(args) {
+	case 2:
+		t.Set(float64(n+1), args[1])
+	case 3:
+		pos, err := checkInt(args, 1, "table.insert")
+		if err != nil {
+			return nil, err
+		}
+		if pos < 1 || pos > n+1 {
+			return nil, argError(1, "table.insert", "position out of bounds")
+		}
+		for k := n; k >= pos; k-- {
+			t.Set(float64(k+1), t.Get(float64(k)))
+		}
+		t.Set(float64(pos), args[2])
+	default:
+		return nil, Errorf("wrong number of arguments to 'insert'")
+	}
+	return nil, nil
+}
+
+func tableRemove(_ *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "table.remove")
+	if err != nil {
+		return nil, err
+	}
+	n := t.Len()
+	pos, err := optInt(args, 1, "table.remove", n)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return []Value{nil}, nil
+	}
+	if pos < 1 || pos > n {
+		return nil, argError(1, "table.remove", "position out of bounds")
+	}
+	removed := t.Get(float64(pos))
+	for k := pos; k < n; k++ {
+		t.Set(float64(k), t.Get(float64(k+1)))
+	}
+	t.Set(float64(n), nil)
+	return []Value{removed}, nil
+}
+
+// tableSort sorts t[1..#t] with comp, or < when comp is nil.
+func tableSort(s *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "table.sort")
+	if err != nil {
+		return nil, err
+	}
+	comp := arg(args, 1)
+	vals := make([]Value, t.Len())
+	for i := range vals {
+		vals[i] = t.Get(float64(i + 1))
+	}
+
+	var sortErr error
+	sort.SliceStable(vals, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		if comp != nil {
+			rets, err := s.call(comp, []Value{vals[i], vals[j]}, 0, " (sort comparator)")
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			return len(rets) > 0 && truthy(rets[0])
+		}
+		switch a := vals[i].(type) {
+		case float64:
+			if b, ok := vals[j].(float64); ok {
+				return a < b
+			}
+		case string:
+			if b, ok := vals[j].(string); ok {
+				return a < b
+			}
+		}
+		sortErr = Errorf("attempt to compare %s with %s", TypeName(vals[i]), TypeName(vals[j]))
+		return false
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	for i, v := range vals {
+		t.Set(float64(i+1), v)
+	}
+	return nil, nil
+}
+
+func tableUnpack(_ *State, args []Value) ([]Value, error) {
+	t, err := checkTable(args, 0, "unpack")
+	if err != nil {
+		return nil, err
+	}
+	i, err := optInt(args, 1, "unpack", 1)
+	if err != nil {
+		return nil, err
+	}
+	j, err := optInt(args, 2, "unpack", t.Len())
+	if err != nil {
+		return nil, err
+	}
+	if j-i >= 10_000 {
+		return nil, Errorf("too many results to unpack")
+	}
+	var vals []Value
+	for k := i; k <= j; k++ {
+		vals = append(vals, t.Get(float64(k)))
+	}
+	return vals, nil
+}
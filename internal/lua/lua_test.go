@@ -0,0 +1,184 @@
+package lua
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, src string) ([]Value, error) {
+	t.Helper()
+	chunk, err := Compile("test", src)
+	if err != nil {
+		return nil, err
+	}
+	return NewState().Run(context.Background(), chunk)
+}
+
+func TestScripts(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"arithmetic", "return 1 + 2 * 3 ^ 2 / 2 - -1, 7 % 3, -7 % 3, 2 ^ 3 ^ 2", "11 1 2 512"},
+		{"concat and coercion", `return "a" .. 1 .. "b", "10" + 5, 1 .. ""`, "a1b 15 1"},
+		{"comparison", `return 1 < 2, "a" < "b", 1 == "1", nil == false, 2 >= 2`, "true true false false true"},
+		{"logic", `return nil or "x", false and 1, 1 and 2, not nil`, "x false 2 true"},
+		{"length", `return #"abc", #{1, 2, 3, nil, 5}`, "3 3"},
+		{"locals and scope", `local x = 1 do local x = 2 end local y = x return x, y`, "1 1"},
+		{"if elseif else", `local v = 5 if v < 3 then return "low" elseif v < 10 then return "mid" else return "high" end`, "mid"},
+		{"numeric for", `local s = 0 for i = 10, 1, -2 do s = s + i end return s`, "30"},
+		{"while and break", `local i = 0 while true do i = i + 1 if i == 4 then break end end return i`, "4"},
+		{"repeat sees body locals", `local n = 0 repeat local done = n >= 2 n = n + 1 until done return n`, "3"},
+		{"ipairs", `local s = "" for i, v in ipairs({"a", "b", "c"}) do s = s .. i .. v end return s`, "1a2b3c"},
+		{"pairs keeps insertion order", `local t = {z = 1, a = 2} t.m = 3 local s = "" for k, v in pairs(t) do s = s .. k .. v end return s`, "z1a2m3"},
+		{"closures", `local function counter() local n = 0 return function() n = n + 1 return n end end local c = counter() c() return c()`, "2"},
+		{"recursion", `local function fib(n) if n < 2 then return n end return fib(n - 1) + fib(n - 2) end return fib(15)`, "610"},
+		{"multiple results", `local function two() return 1, 2 end local a, b, c = two() local t = {two(), two()} return a, b, c, t[3]`, "1 2 nil 2"},
+		{"methods", `local acc = {total = 0} function acc:add(n) self.total = self.total + n end acc:add(3) acc:add(4) return acc.total`, "7"},
+		{"string library", `return ("abc"):upper(), string.sub("hello", 2, -2), string.rep("ab", 3, "-"), string.find("a.b", ".", 1, true), string.len("xy")`, "ABC ell ab-ab-ab 2 2"},
+		{"string.format", `return string.format("%d|%5.2f|%s|%x|%%", 42, 3.14159, "hi", 255)`, "42| 3.14|hi|ff|%"},
+		{"table library", `local t = {3, 1, 2} table.sort(t) table.insert(t, 4) table.insert(t, 1, 0) local r = table.remove(t, 2) return table.concat(t, ","), r`, "0,2,3,4 1"},
+		{"sort with comparator", `local t = {1, 3, 2} table.sort(t, function(a, b) return a > b end) return table.concat(t, " ")`, "3 2 1"},
+		{"math", `return math.floor(2.7), math.ceil(2.1), math.max(1, 5, 3), math.min(4, 2), math.abs(-3)`, "2 3 5 2 3"},
+		{"tonumber and tostring", `return tonumber("0x1F"), tonumber("12.5"), tonumber("abc"), tonumber("ff", 16), tostring(1.5)`, "31 12.5 nil 255 1.5"},
+		{"pcall catches errors", `local ok, err = pcall(function() error("boom") end) return ok, err`, "false test:1: boom"},
+		{"pcall returns error values", `local ok, err = pcall(error, {code = 7}) return ok, err.code`, "false 7"},
+		{"long strings and comments", "--[[ a\ncomment ]] return [[x\ny]] -- trailing", "x\ny"},
+		{"escapes", `return "a\tb\65\x42\""`, "a\tbAB\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vals, err := run(t, tt.src)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			parts := make([]string, len(vals))
+			for i, v := range vals {
+				parts[i] = ToString(v)
+			}
+			if got := strings.Join(parts, " "); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"syntax", "if x then", "test:1: 'end' expected near <eof>"},
+		{"unclosed block", "while true do\nx = 1\n", "test:3: 'end' expected (to close 'while' at line 1) near <eof>"},
+		{"index nil global", "return cfg.limit", "test:1: attempt to index a nil value (global 'cfg')"},
+		{"call nil field", "local t = {} t.run()", "test:1: attempt to call a nil value (field 'run')"},
+		{"arithmetic on nil", "local a\nreturn a + 1", "test:2: attempt to perform arithmetic on a nil value (local 'a')"},
+		{"compare", `return 1 < "2"`, "test:1: attempt to compare number with string"},
+		{"error with line", "\n\nerror('stop')", "test:3: stop"},
+		{"bad argument", "string.rep()", "test:1: bad argument #1 to 'string.rep' (string expected, got no value)"},
+		{"rep too long", `return string.rep("ab", 600000)`, "test:1: string longer than 1048576 bytes"},
+		{"rep overflow", `return string.rep(string.rep("a", 1048576), 2^53)`, "test:1: string longer than 1048576 bytes"},
+		{"patterns", `string.find("abc", "b.")`, "test:1: string.find: patterns are not supported, pass true as the fourth argument for a plain search"},
+		{"nil key", "local t = {} t[nil] = 1", "test:1: table index is nil"},
+		{"stack overflow", "local function f() return f() + 1 end f()", "stack overflow"},
+		{"varargs", "local function f(...) end", "variable arguments are not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := run(t, tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+			var luaErr *Error
+			if !errors.As(err, &luaErr) {
+				t.Errorf("error %T is not a *Error", err)
+			}
+		})
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	chunk, err := Compile("loop", "while true do end")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewState()
+	s.MaxSteps = 10_000
+	if _, err := s.Run(context.Background(), chunk); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("endless loop: %v, want ErrStepLimit", err)
+	}
+
+	// pcall must not swallow the limit.
+	chunk, _ = Compile("loop", "pcall(function() while true do end end) return 'escaped'")
+	if _, err := s.Run(context.Background(), chunk); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("endless loop in pcall: %v, want ErrStepLimit", err)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	chunk, _ := Compile("loop", "while true do end")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewState()
+	s.MaxSteps = 1 << 30
+	if _, err := s.Run(ctx, chunk); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled run: %v, want context.Canceled", err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var in any
+	if err := json.Unmarshal([]byte(`{"amt_d": 8, "sites": ["TH", "KS"], "meta": {"ok": true, "note": null}}`), &in); err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := Compile("transform", `
+		local out = {low = input.amt_d < 10, count = #input.sites, sites = {}}
+		for i, s in ipairs(input.sites) do out.sites[i] = s:lower() end
+		return out
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewState()
+	s.SetGlobal("input", FromJSON(in))
+	vals, err := s.Run(context.Background(), chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ToJSON(vals[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(out)
+	if want := `{"count":2,"low":true,"sites":["th","ks"]}`; string(got) != want {
+		t.Errorf("result = %s, want %s", got, want)
+	}
+
+	self := NewTable()
+	self.Set("self", self)
+	if _, err := ToJSON(self); err == nil {
+		t.Error("ToJSON of a table containing itself succeeded")
+	}
+}
+
+func TestHostFunction(t *testing.T) {
+	var got []string
+	s := NewState()
+	s.SetGlobal("alert", &Function{Name: "alert", Fn: func(_ *State, args []Value) ([]Value, error) {
+		msg, ok := args[0].(string)
+		if !ok {
+			return nil, Errorf("alert expects a message")
+		}
+		got = append(got, msg)
+		return nil, nil
+	}})
+	chunk, _ := Compile("host", "alert('low: ' .. 3)\nalert(nil)")
+	_, err := s.Run(context.Background(), chunk)
+	if len(got) != 1 || got[0] != "low: 3" {
+		t.Errorf("alerts = %q", got)
+	}
+	if err == nil || err.Error() != "host:2: alert expects a message" {
+		t.Errorf("error = %v, want it at line 2", err)
+	}
+}
@@ -0,0 +1,603 @@
+package lua
+
+import "fmt"
+
+// Expressions.
+type (
+	expr interface{}
+
+	constExpr struct{ v Value }
+
+	nameExpr struct {
+		name string
+		line int
+	}
+
+	indexExpr struct {
+		obj, key expr
+		line     int
+	}
+
+	callExpr struct {
+		fn   expr
+		args []expr
+		line int
+	}
+
+	methodExpr struct {
+		obj  expr
+		name string
+		args []expr
+		line int
+	}
+
+	funcExpr struct {
+		name   string
+		params []string
+		body   *block
+	}
+
+	binExpr struct {
+		op   string
+		l, r expr
+		line int
+	}
+
+	unExpr struct {
+		op   string
+		x    expr
+		line int
+	}
+
+	tableExpr struct {
+		fields []tableField
+		line   int
+	}
+
+	// parenExpr truncates a call to its first result.
+	parenExpr struct{ x expr }
+)
+
+// tableField is key = val, or a positional val when key is nil.
+type tableField struct {
+	key, val expr
+}
+
+// Statements.
+type (
+	stmt interface{}
+
+	block struct {
+		stmts []stmt
+	}
+
+	localStmt struct {
+		names []string
+		exprs []expr
+		line  int
+	}
+
+	assignStmt struct {
+		targets []expr
+		exprs   []expr
+		line    int
+	}
+
+	callStmt struct{ call expr }
+
+	ifStmt struct {
+		conds  []expr
+		blocks []*block
+		els    *block
+	}
+
+	whileStmt struct {
+		cond expr
+		body *block
+	}
+
+	repeatStmt struct {
+		body *block
+		cond expr
+	}
+
+	numForStmt struct {
+		name               string
+		start, limit, step expr
+		body               *block
+		line               int
+	}
+
+	genForStmt struct {
+		names []string
+		exprs []expr
+		body  *block
+		line  int
+	}
+
+	doStmt struct{ body *block }
+
+	breakStmt struct{}
+
+	returnStmt struct {
+		exprs []expr
+	}
+
+	localFuncStmt struct {
+		name string
+		fn   *funcExpr
+	}
+)
+
+// maxNesting bounds how deeply blocks and expressions nest, so a hostile
+// script cannot exhaust the Go stack while parsing or running.
+const maxNesting = 200
+
+type parser struct {
+	lex   lexer
+	tok   token
+	ahead *token
+	depth int
+}
+
+// Chunk is a compiled script.
+type Chunk struct {
+	name string
+	body *block
+}
+
+// Compile parses src; name identifies the script in error messages.
+func Compile(name, src string) (*Chunk, error) {
+	p := &parser{lex: lexer{src: src, line: 1}}
+	body, err := p.parse()
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			e.Chunk = name
+		}
+		return nil, err
+	}
+	return &Chunk{name: name, body: body}, nil
+}
+
+// parseError aborts parsing; parse recovers it.
+type parseError struct{ err error }
+
+func (p *parser) parse() (b *block, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pe, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			err = pe.err
+		}
+	}()
+	p.advance()
+	b = p.block()
+	if p.tok.kind != tEOF {
+		p.errorf("%s expected near %s", "<eof>", p.tok)
+	}
+	return b, nil
+}
+
+func (p *parser) errorf(format string, args ...any) {
+	panic(parseError{&Error{Line: p.tok.line, Msg: fmt.Sprintf(format, args...)}})
+}
+
+func (p *parser) advance() {
+	if p.ahead != nil {
+		p.tok, p.ahead = *p.ahead, nil
+		return
+	}
+	tok, err := p.lex.next()
+	if err != nil {
+		panic(parseError{err})
+	}
+	p.tok = tok
+}
+
+func (p *parser) peek() token {
+	if p.ahead == nil {
+		tok, err := p.lex.next()
+		if err != nil {
+			panic(parseError{err})
+		}
+		p.ahead = &tok
+	}
+	return *p.ahead
+}
+
+func (p *parser) is(sym string) bool {
+	return p.tok.kind == tSymbol && p.tok.s == sym
+}
+
+func (p *parser) accept(sym string) bool {
+	if p.is(sym) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(sym string) {
+	if !p.accept(sym) {
+		p.errorf("'%s' expected near %s", sym, p.tok)
+	}
+}
+
+// expectMatch expects the closing sym of what opened on line.
+func (p *parser) expectMatch(sym, opener string, line int) {
+	if p.accept(sym) {
+		return
+	}
+	if line == p.tok.line {
+		p.errorf("'%s' expected near %s", sym, p.tok)
+	}
+	p.errorf("'%s' expected (to close '%s' at line %d) near %s", sym, opener, line, p.tok)
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tName {
+		p.errorf("name expected near %s", p.tok)
+	}
+	name := p.tok.s
+	p.advance()
+	return name
+}
+
+func (p *parser) enter() {
+	p.depth++
+	if p.depth > maxNesting {
+		p.errorf("script nested too deeply")
+	}
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func blockEnd(tok token) bool {
+	if tok.kind == tEOF {
+		return true
+	}
+	if tok.kind != tSymbol {
+		return false
+	}
+	switch tok.s {
+	case "end", "else", "elseif", "until":
+		return true
+	}
+	return false
+}
+
+func (p *parser) block() *block {
+	p.enter()
+	defer p.leave()
+	b := &block{}
+	for !blockEnd(p.tok) {
+		if p.is("return") {
+			p.advance()
+			ret := &returnStmt{}
+			if !blockEnd(p.tok) && !p.is(";") {
+				ret.exprs = p.exprList()
+			}
+			p.accept(";")
+			b.stmts = append(b.stmts, ret)
+			if !blockEnd(p.tok) {
+				p.errorf("'end' expected near %s", p.tok)
+			}
+			break
+		}
+		if s := p.statement(); s != nil {
+			b.stmts = append(b.stmts, s)
+		}
+	}
+	return b
+}
+
+func (p *parser) statement() stmt {
+	line := p.tok.line
+	switch {
+	case p.accept(";"):
+		return nil
+
+	case p.accept("if"):
+		s := &ifStmt{}
+		for {
+			s.conds = append(s.conds, p.expr())
+			p.expect("then")
+			s.blocks = append(s.blocks, p.block())
+			if !p.accept("elseif") {
+				break
+			}
+		}
+		if p.accept("else") {
+			s.els = p.block()
+		}
+		p.expectMatch("end", "if", line)
+		return s
+
+	case p.accept("while"):
+		s := &whileStmt{cond: p.expr()}
+		p.expect("do")
+		s.body = p.block()
+		p.expectMatch("end", "while", line)
+		return s
+
+	case p.accept("repeat"):
+		s := &repeatStmt{body: p.block()}
+		p.expectMatch("until", "repeat", line)
+		s.cond = p.expr()
+		return s
+
+	case p.accept("do"):
+		s := &doStmt{body: p.block()}
+		p.expectMatch("end", "do", line)
+		return s
+
+	case p.accept("for"):
+		first := p.name()
+		if p.accept("=") {
+			s := &numForStmt{name: first, line: line, start: p.expr()}
+			p.expect(",")
+			s.limit = p.expr()
+			if p.accept(",") {
+				s.step = p.expr()
+			}
+			p.expect("do")
+			s.body = p.block()
+			p.expectMatch("end", "for", line)
+			return s
+		}
+		s := &genForStmt{names: []string{first}, line: line}
+		for p.accept(",") {
+			s.names = append(s.names, p.name())
+		}
+		p.expect("in")
+		s.exprs = p.exprList()
+		p.expect("do")
+		s.body = p.block()
+		p.expectMatch("end", "for", line)
+		return s
+
+	case p.accept("function"):
+		name := p.name()
+		var target expr = &nameExpr{name: name, line: line}
+		fullName := name
+		method := false
+		for p.is(".") || p.is(":") {
+			method = p.is(":")
+			p.advance()
+			key := p.name()
+			fullName += "." + key
+			target = &indexExpr{obj: target, key: &constExpr{key}, line: line}
+			if method {
+				break
+			}
+		}
+		fn := p.funcBody(fullName, method, line)
+		return &assignStmt{targets: []expr{target}, exprs: []expr{fn}, line: line}
+
+	case p.accept("local"):
+		if p.accept("function") {
+			name := p.name()
+			return &localFuncStmt{name: name, fn: p.funcBody(name, false, line)}
+		}
+		s := &localStmt{names: []string{p.name()}, line: line}
+		for p.accept(",") {
+			s.names = append(s.names, p.name())
+		}
+		if p.accept("=") {
+			s.exprs = p.exprList()
+		}
+		return s
+
+	case p.accept("break"):
+		return &breakStmt{}
+	}
+
+	e := p.suffixedExpr()
+	if p.is("=") || p.is(",") {
+		s := &assignStmt{targets: []expr{e}, line: line}
+		for p.accept(",") {
+			s.targets = append(s.targets, p.suffixedExpr())
+		}
+		p.expect("=")
+		s.exprs = p.exprList()
+		for _, t := range s.targets {
+			switch t.(type) {
+			case *nameExpr, *indexExpr:
+			default:
+				p.errorf("cannot assign to this expression")
+			}
+		}
+		return s
+	}
+	switch e.(type) {
+	case *callExpr, *methodExpr:
+		return &callStmt{call: e}
+	}
+	p.errorf("syntax error near %s", p.tok)
+	return nil
+}
+
+func (p *parser) funcBody(name string, method bool, line int) *funcExpr {
+	fn := &funcExpr{name: name}
+	if method {
+		fn.params = append(fn.params, "self")
+	}
+	p.expect("(")
+	if !p.is(")") {
+		for {
+			if p.is("...") {
+				p.errorf("variable arguments are not supported")
+			}
+			fn.params = append(fn.params, p.name())
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	p.expect(")")
+	fn.body = p.block()
+	p.expectMatch("end", "function", line)
+	return fn
+}
+
+func (p *parser) exprList() []expr {
+	list := []expr{p.expr()}
+	for p.accept(",") {
+		list = append(list, p.expr())
+	}
+	return list
+}
+
+// binaryPriority holds the left and right priority of each binary operator;
+// right below left makes an operator right associative.
+var binaryPriority = map[string][2]int{
+	"or": {1, 1}, "and": {2, 2},
+	"<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+	"..": {5, 4},
+	"+":  {6, 6}, "-": {6, 6},
+	"*": {7, 7}, "/": {7, 7}, "%": {7, 7},
+	"^": {10, 9},
+}
+
+const unaryPriority = 8
+
+func (p *parser) expr() expr {
+	return p.subExpr(0)
+}
+
+func (p *parser) subExpr(limit int) expr {
+	p.enter()
+	defer p.leave()
+
+	var e expr
+	if p.is("not") || p.is("-") || p.is("#") {
+		op, line := p.tok.s, p.tok.line
+		p.advance()
+		e = &unExpr{op: op, x: p.subExpr(unaryPriority), line: line}
+	} else {
+		e = p.simpleExpr()
+	}
+	for p.tok.kind == tSymbol {
+		prio, ok := binaryPriority[p.tok.s]
+		if !ok || prio[0] <= limit {
+			break
+		}
+		op, line := p.tok.s, p.tok.line
+		p.advance()
+		e = &binExpr{op: op, l: e, r: p.subExpr(prio[1]), line: line}
+	}
+	return e
+}
+
+func (p *parser) simpleExpr() expr {
+	tok := p.tok
+	switch {
+	case tok.kind == tNumber:
+		p.advance()
+		return &constExpr{tok.n}
+	case tok.kind == tString:
+		p.advance()
+		return &constExpr{tok.s}
+	case p.accept("nil"):
+		return &constExpr{nil}
+	case p.accept("true"):
+		return &constExpr{true}
+	case p.accept("false"):
+		return &constExpr{false}
+	case p.is("..."):
+		p.errorf("variable arguments are not supported")
+	case p.accept("function"):
+		return p.funcBody("anonymous", false, tok.line)
+	case p.is("{"):
+		return p.table()
+	}
+	return p.suffixedExpr()
+}
+
+func (p *parser) primaryExpr() expr {
+	switch {
+	case p.tok.kind == tName:
+		line := p.tok.line
+		return &nameExpr{name: p.name(), line: line}
+	case p.is("("):
+		line := p.tok.line
+		p.advance()
+		e := p.expr()
+		p.expectMatch(")", "(", line)
+		return &parenExpr{e}
+	}
+	p.errorf("unexpected symbol near %s", p.tok)
+	return nil
+}
+
+func (p *parser) suffixedExpr() expr {
+	e := p.primaryExpr()
+	for {
+		line := p.tok.line
+		switch {
+		case p.accept("."):
+			e = &indexExpr{obj: e, key: &constExpr{p.name()}, line: line}
+		case p.accept("["):
+			key := p.expr()
+			p.expect("]")
+			e = &indexExpr{obj: e, key: key, line: line}
+		case p.accept(":"):
+			name := p.name()
+			e = &methodExpr{obj: e, name: name, args: p.callArgs(), line: line}
+		case p.is("(") || p.is("{") || p.tok.kind == tString:
+			e = &callExpr{fn: e, args: p.callArgs(), line: line}
+		default:
+			return e
+		}
+	}
+}
+
+func (p *parser) callArgs() []expr {
+	switch {
+	case p.tok.kind == tString:
+		s := p.tok.s
+		p.advance()
+		return []expr{&constExpr{s}}
+	case p.is("{"):
+		return []expr{p.table()}
+	}
+	line := p.tok.line
+	p.expect("(")
+	if p.accept(")") {
+		return nil
+	}
+	args := p.exprList()
+	p.expectMatch(")", "(", line)
+	return args
+}
+
+func (p *parser) table() expr {
+	line := p.tok.line
+	p.expect("{")
+	t := &tableExpr{line: line}
+	for !p.is("}") {
+		switch {
+		case p.is("["):
+			p.advance()
+			key := p.expr()
+			p.expect("]")
+			p.expect("=")
+			t.fields = append(t.fields, tableField{key: key, val: p.expr()})
+		case p.tok.kind == tName && p.peek().kind == tSymbol && p.peek().s == "=":
+			key := p.name()
+			p.advance()
+			t.fields = append(t.fields, tableField{key: &constExpr{key}, val: p.expr()})
+		default:
+			t.fields = append(t.fields, tableField{val: p.expr()})
+		}
+		if !p.accept(",") && !p.accept(";") {
+			break
+		}
+	}
+	p.expectMatch("}", "{", line)
+	return t
+}
@@ -0,0 +1,326 @@
+package lua
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Value is a Lua value: nil, bool, float64, string, *Table, *Function or a
+// Lua function defined by the script.
+type Value = any
+
+// Function is a Go function callable from scripts. It receives the call's
+// arguments and returns its results; an error raised with Errorf becomes a
+// Lua error that pcall can catch.
+type Function struct {
+	Name string
+	Fn   func(s *State, args []Value) ([]Value, error)
+}
+
+// closure is a function defined by the script.
+type closure struct {
+	fn  *funcExpr
+	env *env
+}
+
+// Table is a Lua table. Iteration with pairs follows insertion order, so
+// scripts behave the same on every run.
+type Table struct {
+	idx  map[Value]int
+	ents []tableEntry
+	live int
+}
+
+type tableEntry struct {
+	key, val Value
+}
+
+// NewTable returns an empty table.
+func NewTable() *Table {
+	return &Table{idx: make(map[Value]int)}
+}
+
+// Get returns t[k], nil when absent.
+func (t *Table) Get(k Value) Value {
+	if i, ok := t.idx[normKey(k)]; ok {
+		return t.ents[i].val
+	}
+	return nil
+}
+
+// Set assigns t[k] = v; a nil v removes k.
+func (t *Table) Set(k, v Value) {
+	k = normKey(k)
+	if i, ok := t.idx[k]; ok {
+		if v != nil {
+			t.ents[i].val = v
+			return
+		}
+		t.ents[i] = tableEntry{}
+		delete(t.idx, k)
+		t.live--
+		if len(t.ents) > 32 && t.live < len(t.ents)/2 {
+			t.compact()
+		}
+		return
+	}
+	if v == nil {
+		return
+	}
+	t.idx[k] = len(t.ents)
+	t.ents = append(t.ents, tableEntry{key: k, val: v})
+	t.live++
+}
+
+func (t *Table) compact() {
+	ents := make([]tableEntry, 0, t.live)
+	for _, e := range t.ents {
+		if e.key != nil {
+			t.idx[e.key] = len(ents)
+			ents = append(ents, e)
+		}
+	}
+	t.ents = ents
+}
+
+// Len returns the length # reports: the last n such that t[1]..t[n] are
+// all non-nil.
+func (t *Table) Len() int {
+	n := 0
+	for {
+		if _, ok := t.idx[float64(n+1)]; !ok {
+			return n
+		}
+		n++
+	}
+}
+
+// keys returns the table's keys in insertion order.
+func (t *Table) keys() []Value {
+	keys := make([]Value, 0, t.live)
+	for _, e := range t.ents {
+		if e.key != nil {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// normKey makes -0 and 0 the same key.
+func normKey(k Value) Value {
+	if f, ok := k.(float64); ok && f == 0 {
+		return float64(0)
+	}
+	return k
+}
+
+// TypeName returns the Lua type of v, as type() reports it.
+func TypeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *Table:
+		return "table"
+	case *Function, *closure:
+		return "function"
+	}
+	return "userdata"
+}
+
+// ToString converts v like tostring.
+func ToString(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return formatNumber(v)
+	case string:
+		return v
+	case *Table:
+		return fmt.Sprintf("table: %p", v)
+	case *Function:
+		return "builtin: " + v.Name
+	case *closure:
+		return fmt.Sprintf("function: %p", v)
+	}
+	return fmt.Sprint(v)
+}
+
+func formatNumber(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', 14, 64)
+}
+
+// toNumber converts numbers and numeric strings, as arithmetic does.
+func toNumber(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		return parseNumber(strings.TrimSpace(v))
+	}
+	return 0, false
+}
+
+// parseNumber parses a decimal or hexadecimal numeral.
+func parseNumber(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	neg := false
+	body := s
+	if body[0] == '-' {
+		neg, body = true, body[1:]
+	}
+	if len(body) > 2 && body[0] == '0' && (body[1] == 'x' || body[1] == 'X') {
+		n, err := strconv.ParseUint(body[2:], 16, 64)
+		if err != nil {
+			return 0, false
+		}
+		f := float64(n)
+		if neg {
+			f = -f
+		}
+		return f, true
+	}
+	// ParseFloat also accepts "inf", "nan" and underscores, which Lua does not.
+	for _, c := range body {
+		if !strings.ContainsRune("0123456789.eE+-", c) {
+			return 0, false
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+func truthy(v Value) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+// FromJSON converts a value decoded by encoding/json into a Lua value:
+// objects become tables with their keys in sorted order, arrays tables
+// indexed from 1.
+func FromJSON(v any) Value {
+	switch v := v.(type) {
+	case nil, bool, float64, string:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []any:
+		t := NewTable()
+		for i, e := range v {
+			t.Set(float64(i+1), FromJSON(e))
+		}
+		return t
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		t := NewTable()
+		for _, k := range keys {
+			t.Set(k, FromJSON(v[k]))
+		}
+		return t
+	case []string:
+		t := NewTable()
+		for i, e := range v {
+			t.Set(float64(i+1), e)
+		}
+		return t
+	case map[string]string:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = e
+		}
+		return FromJSON(m)
+	}
+	return nil
+}
+
+// ToJSON converts v into a value encoding/json can marshal. A table whose
+// keys are exactly 1..n becomes an array, any other table an object;
+// functions and non-finite numbers are errors.
+func ToJSON(v Value) (any, error) {
+	return toJSON(v, 0)
+}
+
+const maxJSONDepth = 100
+
+func toJSON(v Value, depth int) (any, error) {
+	if depth > maxJSONDepth {
+		return nil, errors.New("table nested too deeply, or it contains itself")
+	}
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("cannot encode %s", formatNumber(v))
+		}
+		return v, nil
+	case *Table:
+		if n := v.Len(); n > 0 && n == v.live {
+			arr := make([]any, n)
+			for i := range arr {
+				e, err := toJSON(v.Get(float64(i+1)), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				arr[i] = e
+			}
+			return arr, nil
+		}
+		obj := make(map[string]any, v.live)
+		for _, k := range v.keys() {
+			var key string
+			switch k := k.(type) {
+			case string:
+				key = k
+			case float64:
+				key = formatNumber(k)
+			default:
+				return nil, fmt.Errorf("cannot encode a %s key", TypeName(k))
+			}
+			e, err := toJSON(v.Get(k), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = e
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("cannot encode a %s", TypeName(v))
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"os"
+)
+
+// FromEnv builds the sinks named in METRICS_SINKS (comma-separated
+// "prometheus", "statsd" and "dogstatsd"; default "prometheus"), or Nop for
+// "none". prom is the Prometheus registry to serve at /metrics, or nil when
+// it is not enabled.
+func FromEnv() (sink Sink, prom *Prometheus, err error) {
+	names := envlist.Split(os.Getenv("METRICS_SINKS"))
+	if len(names) == 0 {
+		names = []string{"prometheus"}
+	}
+
+	var sinks Multi
+	for _, name := range names {
+		switch name {
+		case "none":
+		case "prometheus":
+			if prom == nil {
+				prom = NewPrometheus()
+				sinks = append(sinks, prom)
+			}
+		case "statsd", "dogstatsd":
+			s, err := NewStatsDFromEnv(name == "dogstatsd")
+			if err != nil {
+				return nil, nil, err
+			}
+			sinks = append(sinks, s)
+		default:
+			return nil, nil, fmt.Errorf("unknown metrics sink %q in METRICS_SINKS", name)
+		}
+	}
+
+	switch len(sinks) {
+	case 0:
+		return Nop{}, nil, nil
+	case 1:
+		return sinks[0], prom, nil
+	}
+	return sinks, prom, nil
+}
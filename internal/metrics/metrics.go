@@ -0,0 +1,73 @@
+// Package metrics records counters, gauges and timings and hands them to one
+// or more sinks: a Prometheus registry served at /metrics, and StatsD or
+// DogStatsD for the parts of the infrastructure that only ingest that.
+//
+// Names are plain snake_case such as "job_runs"; each sink adds its own
+// prefix and suffixes.
+package metrics
+
+import (
+	"strings"
+	"time"
+)
+
+// Tag is a dimension of a metric, a Prometheus label or DogStatsD tag.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// T is shorthand for a Tag.
+func T(key, value string) Tag {
+	return Tag{Key: key, Value: value}
+}
+
+// Sink receives metrics. Implementations must be safe for concurrent use and
+// must not block the caller on the network.
+type Sink interface {
+	// Count adds n to a counter.
+	Count(name string, n int64, tags ...Tag)
+	// Gauge sets a gauge to v.
+	Gauge(name string, v float64, tags ...Tag)
+	// Timing records one duration, e.g. of a job run.
+	Timing(name string, d time.Duration, tags ...Tag)
+}
+
+// Multi sends every metric to each sink.
+type Multi []Sink
+
+func (m Multi) Count(name string, n int64, tags ...Tag) {
+	for _, s := range m {
+		s.Count(name, n, tags...)
+	}
+}
+
+func (m Multi) Gauge(name string, v float64, tags ...Tag) {
+	for _, s := range m {
+		s.Gauge(name, v, tags...)
+	}
+}
+
+func (m Multi) Timing(name string, d time.Duration, tags ...Tag) {
+	for _, s := range m {
+		s.Timing(name, d, tags...)
+	}
+}
+
+// Nop discards every metric. It is the default when none are configured.
+type Nop struct{}
+
+func (Nop) Count(string, int64, ...Tag)          {}
+func (Nop) Gauge(string, float64, ...Tag)        {}
+func (Nop) Timing(string, time.Duration, ...Tag) {}
+
+// sanitize replaces the characters neither Prometheus nor StatsD accept in a
+// name with underscores.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prometheusNamespace prefixes every Prometheus metric name.
+const prometheusNamespace = "go_cron_be_"
+
+// Prometheus keeps metrics in memory and writes them in the Prometheus text
+// exposition format. Counters get a _total suffix, and timings become
+// summaries without quantiles, name_seconds_sum and name_seconds_count.
+type Prometheus struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	kind   string
+	series map[string]*series
+}
+
+type series struct {
+	labels string
+	value  float64
+	count  uint64
+}
+
+func NewPrometheus() *Prometheus {
+	return &Prometheus{families: map[string]*family{}}
+}
+
+func (p *Prometheus) Count(name string, n int64, tags ...Tag) {
+	p.update(sanitize(name)+"_total", "counter", tags, func(s *series) {
+		s.value += float64(n)
+	})
+}
+
+func (p *Prometheus) Gauge(name string, v float64, tags ...Tag) {
+	p.update(sanitize(name), "gauge", tags, func(s *series) {
+		s.value = v
+	})
+}
+
+func (p *Prometheus) Timing(name string, d time.Duration, tags ...Tag) {
+	p.update(sanitize(name)+"_seconds", "summary", tags, func(s *series) {
+		s.value += d.Seconds()
+		s.count++
+	})
+}
+
+// update applies fn to the series of name with tags. A name already used
+// with another kind is ignored rather than producing invalid output.
+func (p *Prometheus) update(name, kind string, tags []Tag, fn func(*series)) {
+	labels := formatLabels(tags)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, ok := p.families[name]
+	if !ok {
+		f = &family{kind: kind, series: map[string]*series{}}
+		p.families[name] = f
+	}
+	if f.kind != kind {
+		return
+	}
+	s, ok := f.series[labels]
+	if !ok {
+		s = &series{labels: labels}
+		f.series[labels] = s
+	}
+	fn(s)
+}
+
+// WriteTo writes every metric to w in the text exposition format, sorted by
+// name and labels.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		f := p.families[name]
+		full := prometheusNamespace + name
+		io.WriteString(cw, "# TYPE "+full+" "+f.kind+"\n")
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind == "summary" {
+				io.WriteString(cw, full+"_sum"+s.labels+" "+formatFloat(s.value)+"\n")
+				io.WriteString(cw, full+"_count"+s.labels+" "+strconv.FormatUint(s.count, 10)+"\n")
+				continue
+			}
+			io.WriteString(cw, full+s.labels+" "+formatFloat(s.value)+"\n")
+		}
+	}
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics for Prometheus to scrape.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// formatLabels renders tags as {key="value",...}, sorted by key so the same
+// tags in another order are the same series.
+func formatLabels(tags []Tag) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	sorted := slices.Clone(tags)
+	slices.SortFunc(sorted, func(a, b Tag) int { return strings.Compare(a.Key, b.Key) })
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, t := range sorted {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(sanitize(t.Key))
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(t.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter remembers the bytes written and the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultPushgatewayJob is the job label of pushed metrics.
+const defaultPushgatewayJob = "go_cron_be_cli"
+
+// Pushgateway pushes the metrics of short-lived processes, such as CLI job
+// runs, which Prometheus would never get to scrape.
+type Pushgateway struct {
+	url    string
+	job    string
+	client *http.Client
+}
+
+// PushgatewayFromEnv configures the Pushgateway from PUSHGATEWAY_URL, e.g.
+// http://pushgateway:9091, and PUSHGATEWAY_JOB (default go_cron_be_cli).
+// It returns nil when PUSHGATEWAY_URL is not set.
+func PushgatewayFromEnv() (*Pushgateway, error) {
+	raw := os.Getenv("PUSHGATEWAY_URL")
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid PUSHGATEWAY_URL %q, expected http(s)://host:port", raw)
+	}
+	job := os.Getenv("PUSHGATEWAY_JOB")
+	if job == "" {
+		job = defaultPushgatewayJob
+	}
+	return &Pushgateway{
+		url:    strings.TrimSuffix(raw, "/"),
+		job:    job,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Push replaces the metrics of the group identified by the job and grouping
+// with those in p. Group per kind of run, e.g. by job type, so runs of one
+// kind replace each other and not those of another.
+func (g *Pushgateway) Push(ctx context.Context, grouping []Tag, p *Prometheus) error {
+	var body bytes.Buffer
+	if _, err := p.WriteTo(&body); err != nil {
+		return err
+	}
+
+	path := "/metrics/job/" + url.PathEscape(g.job)
+	for _, t := range grouping {
+		key, value := sanitize(t.Key), t.Value
+		if value == "" || strings.Contains(value, "/") {
+			key += "@base64"
+		}
+		path += "/" + key + "/" + groupingValue(value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, g.url+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushing metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("pushing metrics: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// groupingValue encodes a path segment of the grouping key. Values the path
+// cannot carry as is go base64-encoded, with "@base64" added to the key.
+func groupingValue(value string) string {
+	if value == "" {
+		return "="
+	}
+	if strings.Contains(value, "/") {
+		return base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return url.PathEscape(value)
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultStatsDPrefix namespaces every StatsD metric.
+const defaultStatsDPrefix = "go_cron_be."
+
+// StatsD sends each metric as one UDP datagram. With DogStatsD tags are
+// appended in its "|#key:value" extension; plain StatsD has no tags, so they
+// are dropped. Send errors are ignored, as usual for StatsD.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	// tags is nil for plain StatsD; otherwise the DogStatsD constant tags
+	tags []Tag
+	dog  bool
+}
+
+// NewStatsD sends to addr, a host:port.
+func NewStatsD(addr, prefix string, dogStatsD bool, tags []Tag) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{conn: conn, prefix: prefix, tags: tags, dog: dogStatsD}, nil
+}
+
+// NewStatsDFromEnv configures the sink from STATSD_ADDR (default
+// 127.0.0.1:8125), STATSD_PREFIX (default "go_cron_be.") and, for
+// DogStatsD, STATSD_TAGS, comma-separated key:value tags added to every
+// metric such as "env:prod".
+func NewStatsDFromEnv(dogStatsD bool) (*StatsD, error) {
+	addr := os.Getenv("STATSD_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:8125"
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid STATSD_ADDR %q: %w", addr, err)
+	}
+	prefix, ok := os.LookupEnv("STATSD_PREFIX")
+	if !ok {
+		prefix = defaultStatsDPrefix
+	}
+
+	var tags []Tag
+	for _, raw := range envlist.Split(os.Getenv("STATSD_TAGS")) {
+		key, value, ok := strings.Cut(raw, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid STATSD_TAGS entry %q, expected key:value", raw)
+		}
+		tags = append(tags, T(key, value))
+	}
+
+	s, err := NewStatsD(addr, prefix, dogStatsD, tags)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STATSD_ADDR %q: %w", addr, err)
+	}
+	return s, nil
+}
+
+func (s *StatsD) Count(name string, n int64, tags ...Tag) {
+	s.send(name, strconv.FormatInt(n, 10), "c", tags)
+}
+
+func (s *StatsD) Gauge(name string, v float64, tags ...Tag) {
+	s.send(name, strconv.FormatFloat(v, 'f', -1, 64), "g", tags)
+}
+
+func (s *StatsD) Timing(name string, d time.Duration, tags ...Tag) {
+	s.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Close releases the socket.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) send(name, value, kind string, tags []Tag) {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(sanitize(name))
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(kind)
+	if s.dog && len(s.tags)+len(tags) > 0 {
+		b.WriteString("|#")
+		for i, t := range append(s.tags[:len(s.tags):len(s.tags)], tags...) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(dogTag(t.Key))
+			b.WriteByte(':')
+			b.WriteString(dogTag(t.Value))
+		}
+	}
+	s.conn.Write([]byte(b.String()))
+}
+
+// dogTag strips the characters that delimit DogStatsD tags.
+func dogTag(s string) string {
+	return strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_").Replace(s)
+}
@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// defaultChatEvents are what the chat channels send unless configured
+	// otherwise: job failures and SLA breaches, which someone has to act on.
+	defaultChatEvents = []string{"job_failed", "deadline_missed", "max_runtime_exceeded", "upstream_down"}
+	// defaultTeamsEvents add accounting's invoice alerts.
+	defaultTeamsEvents = append([]string{"invoices_quarantined", "invoice_amounts_changed"}, defaultChatEvents...)
+)
+
+// FromEnv builds the notifier for the channels listed in NOTIFY_CHANNELS
+// (comma-separated "email", "line", "telegram" and "teams"). The log channel
+// is always included. Each channel delivers only the events listed in its
+// <CHANNEL>_EVENTS, e.g. LINE_EVENTS; the chat channels default to job
+// failures and SLA breaches, email to every event.
+//
+// The channels other than the log are throttled, see Throttle, with the
+// NOTIFY_DEDUP_WINDOW (default 10m) and NOTIFY_BATCH_WINDOW (default 30s)
+// durations; 0 disables either.
+func FromEnv(logger *slog.Logger) (Notifier, error) {
+	var channels Multi
+	for _, name := range envlist.Split(os.Getenv("NOTIFY_CHANNELS")) {
+		if name == "log" {
+			continue // always enabled
+		}
+		n, err := Channel(name)
+		if err != nil {
+			return nil, fmt.Errorf("NOTIFY_CHANNELS: %w", err)
+		}
+		channels = append(channels, Only(n, channelEvents(name)...))
+	}
+
+	dedupWindow, err := durationEnv("NOTIFY_DEDUP_WINDOW", defaultDedupWindow)
+	if err != nil {
+		return nil, err
+	}
+	batchWindow, err := durationEnv("NOTIFY_BATCH_WINDOW", defaultBatchWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers := Multi{NewLogNotifier(logger)}
+	if len(channels) > 0 {
+		notifiers = append(notifiers, NewThrottle(channels, dedupWindow, batchWindow, logger))
+	}
+	return notifiers, nil
+}
+
+// durationEnv reads a non-negative duration from key, or def when unset.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a duration such as 10m", key, raw)
+	}
+	return d, nil
+}
+
+// Channel builds the named channel from its settings. Unlike through FromEnv
+// it delivers every message, so callers such as escalation policies can
+// address a channel directly.
+func Channel(name string) (Notifier, error) {
+	var (
+		n   Notifier
+		err error
+	)
+	switch name {
+	case "email":
+		n, err = NewEmailNotifierFromEnv()
+	case "line":
+		n, err = NewLineNotifierFromEnv()
+	case "telegram":
+		n, err = NewTelegramNotifierFromEnv()
+	case "teams":
+		n, err = NewTeamsNotifierFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown notification channel %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// channelEvents returns the events the named channel delivers, nil for all.
+func channelEvents(name string) []string {
+	if events := envlist.Split(os.Getenv(strings.ToUpper(name) + "_EVENTS")); len(events) > 0 {
+		return events
+	}
+	switch name {
+	case "line", "telegram":
+		return defaultChatEvents
+	case "teams":
+		return defaultTeamsEvents
+	}
+	return nil
+}
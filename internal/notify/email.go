@@ -0,0 +1,355 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	smtpDialTimeout     = 10 * time.Second
+	defaultSMTPAttempts = 3
+	defaultSMTPBackoff  = 2 * time.Second
+)
+
+// EmailNotifier sends messages through an SMTP relay.
+type EmailNotifier struct {
+	host string
+	addr string
+	from string
+	to   []string
+	// security is SMTP_TLS: starttls, tls or none
+	security string
+	auth     smtp.Auth
+	// templates is nil without EMAIL_TEMPLATE_DIR
+	templates *emailTemplates
+	attempts  int
+	backoff   time.Duration
+}
+
+// NewEmailNotifierFromEnv configures the email channel from SMTP_HOST,
+// SMTP_PORT (default 25), SMTP_FROM, NOTIFY_EMAIL_TO, the comma-separated
+// default recipients, and:
+//
+//   - SMTP_TLS: starttls (default) upgrades when the relay offers it, tls
+//     connects with implicit TLS as on port 465, none sends in the clear.
+//   - SMTP_USERNAME, SMTP_PASSWORD and SMTP_AUTH: plain (default), login or
+//     cram-md5.
+//   - SMTP_RETRY_ATTEMPTS (default 3) and SMTP_RETRY_BACKOFF (default 2s,
+//     doubled after each attempt) for transient failures: connection errors
+//     and 4xx replies.
+//   - EMAIL_TEMPLATE_DIR, see loadEmailTemplates.
+func NewEmailNotifierFromEnv() (*EmailNotifier, error) {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return nil, errors.New("SMTP_HOST and SMTP_FROM must be set for the email channel")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return nil, fmt.Errorf("SMTP_PORT must be a port number, got %q", port)
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM %q: %w", from, err)
+	}
+	for _, to := range envlist.Split(os.Getenv("NOTIFY_EMAIL_TO")) {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("invalid NOTIFY_EMAIL_TO address %q: %w", to, err)
+		}
+	}
+
+	n := &EmailNotifier{
+		host:     host,
+		addr:     net.JoinHostPort(host, port),
+		from:     from,
+		to:       envlist.Split(os.Getenv("NOTIFY_EMAIL_TO")),
+		security: os.Getenv("SMTP_TLS"),
+		attempts: defaultSMTPAttempts,
+		backoff:  defaultSMTPBackoff,
+	}
+	switch n.security {
+	case "":
+		n.security = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("SMTP_TLS must be starttls, tls or none, got %q", n.security)
+	}
+
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		password := os.Getenv("SMTP_PASSWORD")
+		switch mechanism := os.Getenv("SMTP_AUTH"); mechanism {
+		case "", "plain":
+			n.auth = smtp.PlainAuth("", user, password, host)
+		case "login":
+			n.auth = &loginAuth{username: user, password: password, host: host}
+		case "cram-md5":
+			n.auth = smtp.CRAMMD5Auth(user, password)
+		default:
+			return nil, fmt.Errorf("SMTP_AUTH must be plain, login or cram-md5, got %q", mechanism)
+		}
+	}
+
+	if raw := os.Getenv("SMTP_RETRY_ATTEMPTS"); raw != "" {
+		attempts, err := strconv.Atoi(raw)
+		if err != nil || attempts < 1 {
+			return nil, fmt.Errorf("SMTP_RETRY_ATTEMPTS must be a positive integer, got %q", raw)
+		}
+		n.attempts = attempts
+	}
+	if raw := os.Getenv("SMTP_RETRY_BACKOFF"); raw != "" {
+		backoff, err := time.ParseDuration(raw)
+		if err != nil || backoff <= 0 {
+			return nil, fmt.Errorf("SMTP_RETRY_BACKOFF must be a positive duration, got %q", raw)
+		}
+		n.backoff = backoff
+	}
+
+	if dir := os.Getenv("EMAIL_TEMPLATE_DIR"); dir != "" {
+		templates, err := loadEmailTemplates(dir)
+		if err != nil {
+			return nil, err
+		}
+		n.templates = templates
+	}
+	return n, nil
+}
+
+// Notify sends msg, retrying transient failures with backoff until ctx is
+// done. A template that fails to render is reported, but the message still
+// goes out as the caller wrote it.
+func (n *EmailNotifier) Notify(ctx context.Context, msg Message) error {
+	to := msg.Recipients
+	if len(to) == 0 {
+		to = n.to
+	}
+	if len(to) == 0 {
+		return errors.New("email: no recipients")
+	}
+
+	var renderErr error
+	if n.templates != nil {
+		rendered, err := n.templates.render(msg)
+		if err != nil {
+			renderErr = fmt.Errorf("email: %w", err)
+		} else {
+			msg = rendered
+		}
+	}
+	data, err := n.render(msg, to)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		err = n.sendContext(ctx, to, data)
+		if err == nil || attempt == n.attempts || !transientSMTPError(err) {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return errors.Join(fmt.Errorf("email: %w after %d attempts, last: %v", ctx.Err(), attempt, err), renderErr)
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("email: sending to %s: %w", strings.Join(to, ", "), err)
+	}
+	return errors.Join(err, renderErr)
+}
+
+// sendContext is send, abandoned when ctx is done since net/smtp has no
+// context support.
+func (n *EmailNotifier) sendContext(ctx context.Context, to []string, data []byte) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- n.send(to, data)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// send delivers one message, like smtp.SendMail but honoring SMTP_TLS.
+func (n *EmailNotifier) send(to []string, data []byte) error {
+	tlsConfig := &tls.Config{ServerName: n.host}
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+
+	var conn net.Conn
+	var err error
+	if n.security == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", n.addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", n.addr)
+	}
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if n.security == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if n.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(envelopeAddress(n.from)); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(envelopeAddress(rcpt)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// transientSMTPError reports whether err may go away on retry: a network
+// failure or a 4xx reply such as a greylisting deferral.
+func transientSMTPError(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// envelopeAddress returns the bare address of "Name <addr>" for the SMTP
+// envelope, or addr as is if it does not parse.
+func envelopeAddress(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		return parsed.Address
+	}
+	return addr
+}
+
+func (n *EmailNotifier) render(msg Message, to []string) ([]byte, error) {
+	contentType, body := "text/plain", msg.Body
+	if msg.HTML != "" {
+		contentType, body = "text/html", msg.HTML
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	if len(msg.Attachments) == 0 {
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n", contentType)
+		fmt.Fprintf(&buf, "\r\n%s\r\n", body)
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType + "; charset=UTF-8"}})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(part, "%s\r\n", body)
+	for _, a := range msg.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": a.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// loginAuth implements the LOGIN mechanism, which Exchange and Office 365
+// relays often require instead of PLAIN.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// same rule as smtp.PlainAuth: never send credentials in the clear,
+	// except to localhost
+	if !server.TLS && a.host != "localhost" && a.host != "127.0.0.1" && a.host != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch prompt := strings.ToLower(strings.TrimSpace(string(fromServer))); {
+	case strings.HasPrefix(prompt, "user"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "pass"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN prompt %q", fromServer)
+	}
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// defaultTemplate applies to events without templates of their own.
+const defaultTemplate = "default"
+
+// emailTemplates format messages per Message.Event.
+type emailTemplates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// loadEmailTemplates parses the templates in dir, named after the event
+// they format, e.g. job_failed.tmpl, or default for every other event:
+//
+//   - <event>.tmpl is a text/template that may define "subject" and "body".
+//   - <event>.html is an html/template for the HTML body.
+//
+// Templates execute on the Message, so they can use .Subject, .Body,
+// .JobName, .JobID, .Severity and .Event. Parts without a template keep what
+// the caller wrote.
+func loadEmailTemplates(dir string) (*emailTemplates, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading EMAIL_TEMPLATE_DIR: %w", err)
+	}
+
+	t := &emailTemplates{text: map[string]*texttemplate.Template{}, html: map[string]*htmltemplate.Template{}}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		event := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		switch filepath.Ext(entry.Name()) {
+		case ".tmpl":
+			tmpl, err := texttemplate.New(entry.Name()).Option("missingkey=error").ParseFiles(path)
+			if err != nil {
+				return nil, fmt.Errorf("parsing email template: %w", err)
+			}
+			t.text[event] = tmpl
+		case ".html":
+			tmpl, err := htmltemplate.New(entry.Name()).Option("missingkey=error").ParseFiles(path)
+			if err != nil {
+				return nil, fmt.Errorf("parsing email template: %w", err)
+			}
+			t.html[event] = tmpl
+		}
+	}
+	return t, nil
+}
+
+// render returns msg with the subject and bodies its event's templates
+// produce.
+func (t *emailTemplates) render(msg Message) (Message, error) {
+	out := msg
+	if tmpl := pick(t.text, msg.Event); tmpl != nil {
+		for name, field := range map[string]*string{"subject": &out.Subject, "body": &out.Body} {
+			if tmpl.Lookup(name) == nil {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&buf, name, msg); err != nil {
+				return msg, fmt.Errorf("rendering %s of %s: %w", name, tmpl.Name(), err)
+			}
+			*field = strings.TrimSpace(buf.String())
+		}
+	}
+	if tmpl := pick(t.html, msg.Event); tmpl != nil {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, msg); err != nil {
+			return msg, fmt.Errorf("rendering %s: %w", tmpl.Name(), err)
+		}
+		out.HTML = buf.String()
+	}
+	return out, nil
+}
+
+// pick returns the template of event, or the default one.
+func pick[T any](templates map[string]*T, event string) *T {
+	if tmpl, ok := templates[event]; ok && event != "" {
+		return tmpl
+	}
+	return templates[defaultTemplate]
+}
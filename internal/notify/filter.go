@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"context"
+	"slices"
+)
+
+// filtered passes on only the messages of some events, so a chat group is
+// not flooded with every job_created.
+type filtered struct {
+	Notifier
+	events []string
+}
+
+// Only wraps n to deliver only messages whose Event is one of events. With
+// no events every message is delivered.
+func Only(n Notifier, events ...string) Notifier {
+	if len(events) == 0 {
+		return n
+	}
+	return filtered{Notifier: n, events: events}
+}
+
+func (f filtered) Notify(ctx context.Context, msg Message) error {
+	if !slices.Contains(f.events, msg.Event) {
+		return nil
+	}
+	return f.Notifier.Notify(ctx, msg)
+}
+
+func (f filtered) delivers(event string) bool {
+	return slices.Contains(f.events, event) && Delivers(f.Notifier, event)
+}
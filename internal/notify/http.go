@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// httpTimeout bounds one request of the HTTP-based channels.
+const httpTimeout = 10 * time.Second
+
+// postJSON posts payload as JSON to url with headers. Any non-2xx status is
+// an error that includes the start of the response body, where chat APIs
+// explain what they rejected.
+func postJSON(ctx context.Context, url string, headers map[string]string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if msg = bytes.TrimSpace(msg); len(msg) == 0 {
+			return errors.New(resp.Status)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+// redact removes url, which carries a secret, from the error of a request
+// to it.
+func redact(err error, url string) error {
+	var uerr *neturl.Error
+	if errors.As(err, &uerr) && uerr.URL == url {
+		uerr.URL = "<redacted>"
+	}
+	return err
+}
+
+// chatText formats msg as plain text for chat channels, led by its severity.
+func chatText(msg Message) string {
+	text := msg.Subject
+	if msg.Body != "" {
+		text += "\n\n" + msg.Body
+	}
+	if msg.Severity != "" {
+		text = "[" + strings.ToUpper(string(msg.Severity)) + "] " + text
+	}
+	return text
+}
+
+// truncate cuts s to at most max runes, marking the cut.
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
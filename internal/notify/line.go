@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"os"
+)
+
+const (
+	linePushURL = "https://api.line.me/v2/bot/message/push"
+	// lineMaxText is the Messaging API's limit for one text message.
+	lineMaxText = 5000
+)
+
+// LineNotifier pushes messages to LINE groups through the Messaging API.
+type LineNotifier struct {
+	token string
+	to    []string
+	url   string
+}
+
+// NewLineNotifierFromEnv configures the LINE channel from
+// LINE_CHANNEL_ACCESS_TOKEN, the bot's long-lived channel access token, and
+// LINE_TO, the comma-separated group, room or user IDs to push to.
+func NewLineNotifierFromEnv() (*LineNotifier, error) {
+	token := os.Getenv("LINE_CHANNEL_ACCESS_TOKEN")
+	to := envlist.Split(os.Getenv("LINE_TO"))
+	if token == "" || len(to) == 0 {
+		return nil, errors.New("LINE_CHANNEL_ACCESS_TOKEN and LINE_TO must be set for the line channel")
+	}
+	return &LineNotifier{token: token, to: to, url: linePushURL}, nil
+}
+
+func (n *LineNotifier) Notify(ctx context.Context, msg Message) error {
+	payload := map[string]any{
+		"messages": []map[string]string{{"type": "text", "text": truncate(chatText(msg), lineMaxText)}},
+	}
+
+	var errs []error
+	for _, to := range n.to {
+		payload["to"] = to
+		if err := postJSON(ctx, n.url, map[string]string{"Authorization": "Bearer " + n.token}, payload); err != nil {
+			errs = append(errs, fmt.Errorf("line: pushing to %s: %w", to, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Message is a channel-agnostic notification. JobName and JobID are set when
+// the message concerns a specific job.
+type Message struct {
+	// Event names what happened, e.g. "job_failed" or "deadline_missed", so
+	// channels can format each kind differently; see EmailNotifier.
+	Event   string
+	Subject string
+	// Body is plain text; channels that support it prefer HTML when set.
+	Body     string
+	HTML     string
+	Severity Severity
+	JobName  string
+	JobID    int64
+	// Recipients overrides a channel's default addressees where that makes
+	// sense, e.g. email addresses for the email channel.
+	Recipients []string
+	// Attachments are files such as reports. Channels that cannot carry
+	// files leave them out.
+	Attachments []Attachment
+}
+
+// Attachment is a file sent along with a Message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Notifier delivers messages to one channel, e.g. email or a chat group.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Multi delivers each message to every notifier and joins their errors.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, msg Message) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m Multi) delivers(event string) bool {
+	for _, n := range m {
+		if Delivers(n, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivers reports whether n sends messages of event anywhere beyond the
+// log, so callers whose message must reach people, such as a daily report,
+// can fail when no channel is configured for it.
+func Delivers(n Notifier, event string) bool {
+	if d, ok := n.(interface{ delivers(event string) bool }); ok {
+		return d.delivers(event)
+	}
+	return true
+}
+
+// Close closes the notifiers that hold messages back, such as Throttle.
+func (m Multi) Close() error {
+	var errs []error
+	for _, n := range m {
+		if c, ok := n.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close delivers what n holds back, see Throttle; call it on shutdown.
+func Close(n Notifier) {
+	if c, ok := n.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// LogNotifier writes messages to the application log. It is always enabled so
+// notifications are visible even when no external channel is configured.
+type LogNotifier struct {
+	logger *slog.Logger
+}
+
+func NewLogNotifier(logger *slog.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger.WithGroup("NOTIFY")}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, msg Message) error {
+	level := slog.LevelInfo
+	switch msg.Severity {
+	case SeverityWarning:
+		level = slog.LevelWarn
+	case SeverityCritical:
+		level = slog.LevelError
+	}
+	n.logger.Log(ctx, level, msg.Subject, "body", msg.Body, "job_name", msg.JobName, "job_id", msg.JobID)
+	return nil
+}
+
+// delivers is false: the log reaches no one by itself.
+func (n *LogNotifier) delivers(string) bool { return false }
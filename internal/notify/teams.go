@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// teamsMaxText keeps a card under Teams' 28 KB message limit.
+const teamsMaxText = 20000
+
+// TeamsNotifier posts messages as Adaptive Cards to Microsoft Teams
+// channels through incoming webhooks.
+type TeamsNotifier struct {
+	urls []string
+}
+
+// NewTeamsNotifierFromEnv configures the Teams channel from
+// TEAMS_WEBHOOK_URLS, the comma-separated incoming webhook (or Workflows
+// "post to a channel when a webhook request is received") URLs.
+func NewTeamsNotifierFromEnv() (*TeamsNotifier, error) {
+	urls := envlist.Split(os.Getenv("TEAMS_WEBHOOK_URLS"))
+	if len(urls) == 0 {
+		return nil, errors.New("TEAMS_WEBHOOK_URLS must be set for the teams channel")
+	}
+	for _, u := range urls {
+		if !strings.HasPrefix(u, "https://") {
+			return nil, fmt.Errorf("invalid TEAMS_WEBHOOK_URLS entry %q, expected an https URL", u)
+		}
+	}
+	return &TeamsNotifier{urls: urls}, nil
+}
+
+func (n *TeamsNotifier) Notify(ctx context.Context, msg Message) error {
+	payload := map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     adaptiveCard(msg),
+		}},
+	}
+
+	var errs []error
+	for i, url := range n.urls {
+		// The URL carries the webhook's secret, so errors name it by position.
+		if err := postJSON(ctx, url, nil, payload); err != nil {
+			errs = append(errs, fmt.Errorf("teams: posting to webhook %d: %w", i+1, redact(err, url)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// adaptiveCard lays msg out as a title coloured by severity, the body, and
+// facts about the job.
+func adaptiveCard(msg Message) map[string]any {
+	color := "default"
+	switch msg.Severity {
+	case SeverityWarning:
+		color = "warning"
+	case SeverityCritical:
+		color = "attention"
+	}
+
+	body := []map[string]any{{
+		"type":   "TextBlock",
+		"text":   msg.Subject,
+		"size":   "medium",
+		"weight": "bolder",
+		"color":  color,
+		"wrap":   true,
+	}}
+	if msg.Body != "" {
+		// Card markdown needs blank lines to break lines.
+		text := strings.ReplaceAll(truncate(strings.TrimSpace(msg.Body), teamsMaxText), "\n", "\n\n")
+		body = append(body, map[string]any{"type": "TextBlock", "text": text, "wrap": true})
+	}
+
+	var facts []map[string]string
+	for _, f := range [][2]string{
+		{"Job", msg.JobName},
+		{"Job ID", jobID(msg.JobID)},
+		{"Event", msg.Event},
+		{"Severity", string(msg.Severity)},
+	} {
+		if f[1] != "" {
+			facts = append(facts, map[string]string{"title": f[0], "value": f[1]})
+		}
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]any{"type": "FactSet", "facts": facts, "separator": true})
+	}
+
+	return map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+		"msteams": map[string]string{"width": "Full"},
+	}
+}
+
+func jobID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"os"
+)
+
+const (
+	telegramAPIURL = "https://api.telegram.org"
+	// telegramMaxText is the Bot API's limit for one message.
+	telegramMaxText = 4096
+)
+
+// TelegramNotifier sends messages to Telegram chats through a bot.
+type TelegramNotifier struct {
+	token   string
+	chatIDs []string
+	url     string
+}
+
+// NewTelegramNotifierFromEnv configures the Telegram channel from
+// TELEGRAM_BOT_TOKEN, the token BotFather issued, and TELEGRAM_CHAT_IDS,
+// the comma-separated chats to send to; group IDs are negative.
+func NewTelegramNotifierFromEnv() (*TelegramNotifier, error) {
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	chatIDs := envlist.Split(os.Getenv("TELEGRAM_CHAT_IDS"))
+	if token == "" || len(chatIDs) == 0 {
+		return nil, errors.New("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_IDS must be set for the telegram channel")
+	}
+	return &TelegramNotifier{token: token, chatIDs: chatIDs, url: telegramAPIURL}, nil
+}
+
+func (n *TelegramNotifier) Notify(ctx context.Context, msg Message) error {
+	// Plain text, as Markdown would need every job name and error escaped.
+	payload := map[string]any{
+		"text":                     truncate(chatText(msg), telegramMaxText),
+		"disable_web_page_preview": true,
+	}
+
+	var errs []error
+	for _, chatID := range n.chatIDs {
+		payload["chat_id"] = chatID
+		// The token is part of the URL; keep it out of the error.
+		url := n.url + "/bot" + n.token + "/sendMessage"
+		if err := postJSON(ctx, url, nil, payload); err != nil {
+			errs = append(errs, fmt.Errorf("telegram: sending to %s: %w", chatID, redact(err, url)))
+		}
+	}
+	return errors.Join(errs...)
+}
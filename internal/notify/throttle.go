@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDedupWindow = 10 * time.Minute
+	defaultBatchWindow = 30 * time.Second
+	// flushTimeout bounds delivering one batch, retries included.
+	flushTimeout = 2 * time.Minute
+	// summarySubjects is how many subjects a batch summary's subject lists.
+	summarySubjects = 3
+)
+
+// Throttle holds back repeated and bursty alerts to spare their readers
+// alert fatigue:
+//
+//   - An alert identical to one delivered within the dedup window, that is
+//     with the same event, subject, job and recipients, is dropped.
+//   - Alerts of one event arriving within the batch window after the first
+//     are delivered together as one summary, e.g. a single message when all
+//     golf sites go down at once. Their delivery errors are logged, as Notify
+//     has returned by then; Close delivers pending batches right away.
+//
+// Messages with HTML or attachments, such as the daily summary and exports,
+// are documents whose senders act on the outcome: they are always delivered
+// as they are and their errors returned.
+type Throttle struct {
+	next        Notifier
+	dedupWindow time.Duration
+	batchWindow time.Duration
+	logger      *slog.Logger
+
+	mu      sync.Mutex
+	sent    map[string]time.Time
+	batches map[string]*batch
+	closed  bool
+}
+
+type batch struct {
+	msgs  []Message
+	keys  []string
+	timer *time.Timer
+}
+
+// NewThrottle wraps next. A zero window disables deduplication or batching.
+func NewThrottle(next Notifier, dedupWindow, batchWindow time.Duration, logger *slog.Logger) *Throttle {
+	return &Throttle{
+		next:        next,
+		dedupWindow: dedupWindow,
+		batchWindow: batchWindow,
+		logger:      logger,
+		sent:        make(map[string]time.Time),
+		batches:     make(map[string]*batch),
+	}
+}
+
+func (t *Throttle) Notify(ctx context.Context, msg Message) error {
+	if msg.HTML != "" || len(msg.Attachments) > 0 {
+		return t.next.Notify(ctx, msg)
+	}
+
+	key := strings.Join([]string{msg.Event, msg.Subject, msg.JobName, strings.Join(msg.Recipients, ",")}, "\x00")
+	batchKey := msg.Event + "\x00" + strings.Join(msg.Recipients, ",")
+
+	t.mu.Lock()
+	if t.recentlySent(key) {
+		t.mu.Unlock()
+		t.logger.Info("Suppressed repeated notification", "event", msg.Event, "subject", msg.Subject)
+		return nil
+	}
+	if t.batchWindow <= 0 || msg.Event == "" || t.closed {
+		t.mu.Unlock()
+		if err := t.next.Notify(ctx, msg); err != nil {
+			return err
+		}
+		t.markSent(key)
+		return nil
+	}
+
+	if b, ok := t.batches[batchKey]; ok {
+		if !slices.Contains(b.keys, key) {
+			b.msgs = append(b.msgs, msg)
+			b.keys = append(b.keys, key)
+		}
+	} else {
+		t.batches[batchKey] = &batch{
+			msgs:  []Message{msg},
+			keys:  []string{key},
+			timer: time.AfterFunc(t.batchWindow, func() { t.flush(batchKey) }),
+		}
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Throttle) delivers(event string) bool { return Delivers(t.next, event) }
+
+// recentlySent reports whether the alert with key was delivered within the
+// dedup window, forgetting older deliveries. t.mu must be held.
+func (t *Throttle) recentlySent(key string) bool {
+	if t.dedupWindow <= 0 {
+		return false
+	}
+	now := time.Now()
+	for k, at := range t.sent {
+		if now.Sub(at) >= t.dedupWindow {
+			delete(t.sent, k)
+		}
+	}
+	_, ok := t.sent[key]
+	return ok
+}
+
+// markSent starts the dedup window of the delivered alerts with keys.
+func (t *Throttle) markSent(keys ...string) {
+	if t.dedupWindow <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for _, key := range keys {
+		t.sent[key] = now
+	}
+}
+
+// flush delivers the batch under key, if it is still pending.
+func (t *Throttle) flush(key string) error {
+	t.mu.Lock()
+	b, ok := t.batches[key]
+	delete(t.batches, key)
+	t.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	b.timer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	msg := summarize(b.msgs)
+	if err := t.next.Notify(ctx, msg); err != nil {
+		t.logger.Error("Failed to send notification", "subject", msg.Subject, "error", err)
+		return err
+	}
+	t.markSent(b.keys...)
+	return nil
+}
+
+// Close delivers the pending batches. Alerts arriving later are delivered
+// right away.
+func (t *Throttle) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	keys := make([]string, 0, len(t.batches))
+	for key := range t.batches {
+		keys = append(keys, key)
+	}
+	t.mu.Unlock()
+
+	var errs []error
+	for _, key := range keys {
+		errs = append(errs, t.flush(key))
+	}
+	return errors.Join(errs...)
+}
+
+// summarize folds a batch of alerts of one event into one, with the highest
+// severity of them. Batches are plain text: messages with HTML are never
+// batched, see Throttle.
+func summarize(msgs []Message) Message {
+	if len(msgs) == 1 {
+		return msgs[0]
+	}
+
+	first := msgs[0]
+	sum := Message{
+		Event:      first.Event,
+		Severity:   first.Severity,
+		JobName:    first.JobName,
+		JobID:      first.JobID,
+		Recipients: first.Recipients,
+	}
+	subjects := make([]string, len(msgs))
+	bodies := make([]string, len(msgs))
+	for i, msg := range msgs {
+		if severityRank(msg.Severity) > severityRank(sum.Severity) {
+			sum.Severity = msg.Severity
+		}
+		if msg.JobName != sum.JobName {
+			sum.JobName = ""
+		}
+		if msg.JobID != sum.JobID {
+			sum.JobID = 0
+		}
+		subjects[i] = msg.Subject
+		bodies[i] = msg.Subject
+		if msg.Body != "" {
+			bodies[i] += "\n" + msg.Body
+		}
+	}
+
+	listed := slices.Compact(slices.Clone(subjects))
+	more := ""
+	if len(listed) > summarySubjects {
+		more = fmt.Sprintf(" and %d more", len(listed)-summarySubjects)
+		listed = listed[:summarySubjects]
+	}
+	sum.Subject = fmt.Sprintf("%d %s alerts: %s%s", len(msgs), first.Event, strings.Join(listed, "; "), more)
+	sum.Body = strings.Join(bodies, "\n\n")
+	return sum
+}
+
+func severityRank(s Severity) int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+// Package pidfile keeps a second copy of the scheduler from starting on the
+// same host. The PID file is held with an OS lock for the life of the
+// process, so a file left behind by a crash does not block the next start.
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is returned by Acquire when another process holds the file.
+var ErrLocked = errors.New("another instance is already running")
+
+// File is a held PID file.
+type File struct {
+	f    *os.File
+	path string
+}
+
+// Acquire locks path, creating it if needed, and writes the current PID to
+// it. It fails with ErrLocked without waiting if another process holds it.
+func Acquire(path string) (*File, error) {
+	f, err := lockFile(path)
+	if errors.Is(err, ErrLocked) {
+		if raw, readErr := os.ReadFile(path); readErr == nil && strings.TrimSpace(string(raw)) != "" {
+			return nil, fmt.Errorf("%w (pid %s, %s)", ErrLocked, strings.TrimSpace(string(raw)), path)
+		}
+		return nil, fmt.Errorf("%w (%s)", ErrLocked, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locking PID file %s: %w", path, err)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing PID file %s: %w", path, err)
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing PID file %s: %w", path, err)
+	}
+	return &File{f: f, path: path}, nil
+}
+
+// Release removes the file and drops the lock. The file is removed while
+// still locked so a starting instance never sees it half-deleted.
+func (p *File) Release() error {
+	removeErr := os.Remove(p.path)
+	closeErr := p.f.Close()
+	return errors.Join(removeErr, closeErr)
+}
@@ -0,0 +1,42 @@
+// Package requestid carries the X-Request-ID of an API call through
+// context, so jobs it triggers and the log lines about them can be traced
+// back to the call.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// Header is the HTTP header the ID is read from and echoed in.
+const Header = "X-Request-ID"
+
+// valid bounds what a caller may supply; anything else is replaced.
+var valid = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+type ctxKey struct{}
+
+// New returns a random 32-character hex ID.
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether a caller-supplied ID can be used as is.
+func Valid(id string) bool {
+	return valid.MatchString(id)
+}
+
+// NewContext returns ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request ID in ctx, or "".
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
@@ -0,0 +1,220 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultArchiveOlderThanDays = 90
+	defaultArchiveBatchSize     = 1000
+)
+
+// ArchiveParams are the job_params of an "archive_jobs" job. Finished jobs
+// older than OlderThanDays are written with their runs (including the run
+// logs) to the bucket and then deleted from MySQL.
+type ArchiveParams struct {
+	OlderThanDays int `json:"older_than_days"`
+	BatchSize     int `json:"batch_size"`
+}
+
+func (p ArchiveParams) Validate() error {
+	if p.OlderThanDays < 0 {
+		return errors.New("older_than_days must not be negative")
+	}
+	if p.BatchSize < 0 {
+		return errors.New("batch_size must not be negative")
+	}
+	return nil
+}
+
+// archivedRun is one job_runs row in an archive file.
+type archivedRun struct {
+	RunID           int64           `json:"run_id"`
+	RunStatus       string          `json:"run_status"`
+	ErrorCategory   *string         `json:"error_category"`
+	Message         *string         `json:"message"`
+	JobResult       json.RawMessage `json:"job_result"`
+	ExecutionTimeMs *int64          `json:"execution_time_ms"`
+	OracleMs        *int64          `json:"oracle_ms"`
+	MySQLMs         *int64          `json:"mysql_ms"`
+	AppMs           *int64          `json:"app_ms"`
+	StartedAt       *time.Time      `json:"started_at"`
+	FinishedAt      *time.Time      `json:"finished_at"`
+}
+
+// archivedJob is one line of an archive file.
+type archivedJob struct {
+	CronJob
+	Runs []archivedRun `json:"runs"`
+}
+
+// runArchiveJob moves one batch of old jobs to object storage per run and
+// then prunes archived objects past STORAGE_RETENTION. Schedule it often
+// enough for the batch size to keep up.
+func (s *Scheduler) runArchiveJob(ctx context.Context, job CronJob, params ArchiveParams) (string, error) {
+	if s.storage == nil {
+		return "", errclass.ConfigError(errors.New("object storage is not configured (STORAGE_BUCKET)"))
+	}
+
+	olderThan := defaultArchiveOlderThanDays
+	if params.OlderThanDays > 0 {
+		olderThan = params.OlderThanDays
+	}
+	batchSize := defaultArchiveBatchSize
+	if params.BatchSize > 0 {
+		batchSize = params.BatchSize
+	}
+
+	jobs, err := s.jobsToArchive(ctx, olderThan, batchSize)
+	if err != nil {
+		return "", err
+	}
+
+	message := "no jobs to archive"
+	if len(jobs) > 0 {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, j := range jobs {
+			if err := enc.Encode(j); err != nil {
+				return "", fmt.Errorf("encoding job %d: %w", j.JobID, err)
+			}
+		}
+
+		now := time.Now()
+		key := s.storage.Key("jobs", now.Format(dateLayout), fmt.Sprintf("cron_jobs_%d-%d_%s.jsonl", jobs[0].JobID, jobs[len(jobs)-1].JobID, now.Format("150405")))
+		if err := s.storage.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "application/x-ndjson"); err != nil {
+			return "", err
+		}
+
+		// Only delete once the archive is safely stored.
+		if err := s.deleteArchivedJobs(ctx, jobs); err != nil {
+			return "", fmt.Errorf("archived to %s but deleting rows failed: %w", s.storage.URL(key), err)
+		}
+		message = fmt.Sprintf("archived %d jobs to %s", len(jobs), s.storage.URL(key))
+	}
+
+	var pruned int
+	for _, area := range []string{"jobs", "exports", "outputs"} {
+		n, err := s.storage.Prune(ctx, s.storage.Key(area)+"/", time.Now())
+		pruned += n
+		if err != nil {
+			return message, fmt.Errorf("pruning %s archive: %w", area, err)
+		}
+	}
+	return fmt.Sprintf("%s; pruned %d expired objects", message, pruned), nil
+}
+
+// jobsToArchive loads up to limit finished jobs older than olderThanDays,
+// with their runs.
+func (s *Scheduler) jobsToArchive(ctx context.Context, olderThanDays, limit int) ([]archivedJob, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT job_id, job_name, job_date, job_params, job_status, priority, attempts,
+			message, execution_time_ms, created_at, updated_at, finished_at
+		FROM cron_jobs
+		WHERE job_status IN ('finished', 'failed', 'skipped')
+			AND finished_at < NOW() - INTERVAL ? DAY
+		ORDER BY job_id
+		LIMIT ?
+	`, olderThanDays, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying jobs to archive: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []archivedJob
+	index := make(map[int64]int)
+	for rows.Next() {
+		var j archivedJob
+		var message sql.NullString
+		var executionTime sql.NullInt64
+		if err := rows.Scan(&j.JobID, &j.JobName, &j.JobDate, &j.JobParams, &j.JobStatus, &j.Priority, &j.Attempts,
+			&message, &executionTime, &j.CreatedAt, &j.UpdatedAt, &j.FinishedAt); err != nil {
+			return nil, fmt.Errorf("scanning job: %w", err)
+		}
+		j.Message = message.String
+		j.ExecutionTimeMs = executionTime.Int64
+		index[j.JobID] = len(jobs)
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	ids, args := jobIDPlaceholders(jobs)
+	runRows, err := s.db.QueryContext(ctx, `
+		SELECT job_id, run_id, run_status, error_category, message, job_result, execution_time_ms, oracle_ms, mysql_ms, app_ms,
+			started_at, finished_at
+		FROM job_runs WHERE job_id IN (`+ids+`) ORDER BY run_id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying runs to archive: %w", err)
+	}
+	defer runRows.Close()
+
+	for runRows.Next() {
+		var jobID int64
+		var r archivedRun
+		var result []byte
+		if err := runRows.Scan(&jobID, &r.RunID, &r.RunStatus, &r.ErrorCategory, &r.Message, &result, &r.ExecutionTimeMs, &r.OracleMs, &r.MySQLMs, &r.AppMs,
+			&r.StartedAt, &r.FinishedAt); err != nil {
+			return nil, fmt.Errorf("scanning run: %w", err)
+		}
+		r.JobResult = result
+		if i, ok := index[jobID]; ok {
+			jobs[i].Runs = append(jobs[i].Runs, r)
+		}
+	}
+	return jobs, runRows.Err()
+}
+
+func (s *Scheduler) deleteArchivedJobs(ctx context.Context, jobs []archivedJob) error {
+	ids, args := jobIDPlaceholders(jobs)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"job_run_logs", "job_runs", "job_events", "cron_jobs"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE job_id IN ("+ids+")", args...); err != nil {
+			return fmt.Errorf("deleting from %s: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func jobIDPlaceholders(jobs []archivedJob) (string, []any) {
+	args := make([]any, len(jobs))
+	for i, j := range jobs {
+		args[i] = j.JobID
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(jobs)), ","), args
+}
+
+// archiveExport copies a finished export file to the bucket under exports/.
+// Archival is best effort: a failure is logged and reported in the job
+// message but does not fail the export.
+func (s *Scheduler) archiveExport(ctx context.Context, path, contentType string) string {
+	if s.storage == nil {
+		return ""
+	}
+	key := s.storage.Key("exports", time.Now().Format(dateLayout), filepath.Base(path))
+	if err := s.storage.PutFile(ctx, key, path, contentType); err != nil {
+		s.logger.Warn("Failed to archive export", "path", path, "error", err)
+		return fmt.Sprintf(" (archiving failed: %v)", err)
+	}
+	return ", archived to " + s.storage.URL(key)
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/requestid"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditEntry records one mutating administrative action.
+type AuditEntry struct {
+	AuditID    int64           `json:"audit_id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	Target     string          `json:"target"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	RemoteAddr string          `json:"remote_addr,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows ListAudit. Zero fields match everything; BeforeID pages
+// backwards from the previous page's last audit_id.
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	Since    time.Time
+	BeforeID int64
+	Limit    int
+}
+
+// RecordAudit appends e to the audit log. The payload is stored as given, so
+// callers must leave secrets out of it. The request ID defaults to ctx's.
+func (s *Scheduler) RecordAudit(ctx context.Context, e AuditEntry) error {
+	if e.RequestID == "" {
+		e.RequestID = requestid.FromContext(ctx)
+	}
+	var payload any
+	if len(e.Payload) > 0 {
+		payload = string(e.Payload)
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (actor, action, target, payload, request_id, remote_addr)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))
+	`, e.Actor, e.Action, e.Target, payload, e.RequestID, e.RemoteAddr)
+	if err != nil {
+		return fmt.Errorf("inserting audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAudit returns audit entries matching f, newest first.
+func (s *Scheduler) ListAudit(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	var where []string
+	var args []any
+	if f.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since)
+	}
+	if f.BeforeID > 0 {
+		where = append(where, "audit_id < ?")
+		args = append(args, f.BeforeID)
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	limit = min(limit, maxAuditLimit)
+
+	query := `
+		SELECT audit_id, actor, action, target, COALESCE(payload, ''), COALESCE(request_id, ''),
+			COALESCE(remote_addr, ''), created_at
+		FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY audit_id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying audit_log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var payload string
+		if err := rows.Scan(&e.AuditID, &e.Actor, &e.Action, &e.Target, &payload, &e.RequestID, &e.RemoteAddr, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		if payload != "" {
+			e.Payload = json.RawMessage(payload)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
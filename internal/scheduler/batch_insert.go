@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultInsertBatchSize = 500
+	// maxInsertBatchSize keeps a batch of the widest rows under MySQL's
+	// limit of 65535 placeholders per statement.
+	maxInsertBatchSize = 5000
+)
+
+// loadInsertBatchSize reads INSERT_BATCH_SIZE, the rows per multi-row INSERT.
+func (s *Scheduler) loadInsertBatchSize() error {
+	s.insertBatchSize = defaultInsertBatchSize
+	raw := os.Getenv("INSERT_BATCH_SIZE")
+	if raw == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxInsertBatchSize {
+		return fmt.Errorf("INSERT_BATCH_SIZE must be an integer between 1 and %d, got %q", maxInsertBatchSize, raw)
+	}
+	s.insertBatchSize = n
+	return nil
+}
+
+// insertBatched writes rows with multi-row INSERT statements of at most
+// s.insertBatchSize rows each. insert is the statement up to VALUES, e.g.
+// "INSERT INTO t (a, b)", and suffix follows the values, e.g. an
+// "ON DUPLICATE KEY UPDATE" clause. Every row must have the same length.
+func (s *Scheduler) insertBatched(ctx context.Context, ex execer, insert, suffix string, rows [][]any) error {
+	batchSize := s.insertBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultInsertBatchSize
+	}
+
+	for start := 0; start < len(rows); start += batchSize {
+		batch := rows[start:min(start+batchSize, len(rows))]
+		tuple := "(" + placeholders(len(batch[0])) + ")"
+		values := strings.TrimSuffix(strings.Repeat(tuple+",", len(batch)), ",")
+
+		args := make([]any, 0, len(batch)*len(batch[0]))
+		for _, row := range batch {
+			args = append(args, row...)
+		}
+		if _, err := ex.ExecContext(ctx, insert+" VALUES "+values+" "+suffix, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// BlackoutWindow is a recurring period during which some job types are not
+// dispatched, e.g. the ERP monthly close.
+type BlackoutWindow struct {
+	Name string `json:"name"`
+	// Start is a cron expression or SCHEDULE_ALIASES name for when the window
+	// opens, e.g. "0 1 1 * *".
+	Start string `json:"start"`
+	// Duration is how long the window stays open, e.g. "2h".
+	Duration string `json:"duration"`
+	// JobNames lists the affected job types; empty means all of them.
+	JobNames []string `json:"job_names"`
+	// CatchUp keeps held jobs pending so they run once the window closes;
+	// otherwise they are marked skipped.
+	CatchUp bool `json:"catch_up"`
+
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// loadBlackoutWindows reads BLACKOUT_WINDOWS, a JSON array of windows, e.g.
+// [{"name": "erp-close", "start": "0 1 1 * *", "duration": "2h", "job_names": ["oracle_proc"], "catch_up": true}].
+func (s *Scheduler) loadBlackoutWindows() error {
+	s.blackouts = nil
+	raw := os.Getenv("BLACKOUT_WINDOWS")
+	if raw == "" {
+		return nil
+	}
+
+	var windows []BlackoutWindow
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&windows); err != nil {
+		return fmt.Errorf("parsing BLACKOUT_WINDOWS: %w", err)
+	}
+
+	for i := range windows {
+		w := &windows[i]
+		if w.Name == "" {
+			return fmt.Errorf("parsing BLACKOUT_WINDOWS: window %d: name is required", i+1)
+		}
+		schedule, err := cron.ParseStandard(s.resolveSchedule(w.Start))
+		if err != nil {
+			return fmt.Errorf("parsing BLACKOUT_WINDOWS: %s: start: %w", w.Name, err)
+		}
+		d, err := time.ParseDuration(w.Duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("parsing BLACKOUT_WINDOWS: %s: %w", w.Name, errors.New("duration must be a positive Go duration"))
+		}
+		w.schedule, w.duration = schedule, d
+	}
+	s.blackouts = windows
+	return nil
+}
+
+// Active reports whether the window is open at t, i.e. it opened less than
+// Duration ago.
+func (w *BlackoutWindow) Active(t time.Time) bool {
+	opened := w.schedule.Next(t.Add(-w.duration))
+	return !opened.IsZero() && !opened.After(t)
+}
+
+func (w *BlackoutWindow) applies(jobName string) bool {
+	return len(w.JobNames) == 0 || slices.Contains(w.JobNames, jobName)
+}
+
+// activeBlackout returns the first window that holds jobName at t, or nil.
+func (s *Scheduler) activeBlackout(jobName string, t time.Time) *BlackoutWindow {
+	for i := range s.blackouts {
+		if w := &s.blackouts[i]; w.applies(jobName) && w.Active(t.In(s.c.Location())) {
+			return w
+		}
+	}
+	return nil
+}
+
+// holdForBlackout records that job was not dispatched because of window.
+// Catch-up windows leave the job pending with a note; others skip it.
+func (s *Scheduler) holdForBlackout(job CronJob, w *BlackoutWindow) {
+	if w.CatchUp {
+		note := fmt.Sprintf("deferred by blackout window %s", w.Name)
+		if err := s.store.DeferJob(s.ctx, job.JobID, note); err != nil {
+			s.logger.Error("Failed to record deferred job", "job_id", job.JobID, "error", err)
+		}
+		s.logger.Debug("Job deferred by blackout window", "job_id", job.JobID, "job_name", job.JobName, "window", w.Name)
+		return
+	}
+
+	if err := s.store.SkipJob(s.ctx, job.JobID, fmt.Sprintf("skipped by blackout window %s", w.Name)); err != nil {
+		s.logger.Error("Failed to record skipped job", "job_id", job.JobID, "error", err)
+		return
+	}
+	s.logger.Info("Job skipped by blackout window", "job_id", job.JobID, "job_name", job.JobName, "window", w.Name)
+}
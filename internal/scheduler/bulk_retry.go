@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"slices"
+)
+
+// retryableStatuses are the terminal states a bulk retry may requeue.
+var retryableStatuses = []string{"failed", "skipped", "finished"}
+
+// RetryJobs requeues every job matching f as pending with its attempts
+// reset, in one transaction, and returns how many were requeued. f.Statuses
+// defaults to failed; only terminal statuses may be retried so running jobs
+// are never touched.
+func (s *Scheduler) RetryJobs(ctx context.Context, f JobFilter) (int64, error) {
+	if len(f.Statuses) == 0 {
+		f.Statuses = []string{"failed"}
+	}
+	for _, status := range f.Statuses {
+		if !slices.Contains(retryableStatuses, status) {
+			return 0, errclass.DataError(fmt.Errorf("cannot retry %s jobs, status must be one of %v", status, retryableStatuses))
+		}
+	}
+	if err := f.Validate(); err != nil {
+		return 0, err
+	}
+	if f.JobName == "" && f.DateFrom == "" && f.DateTo == "" && f.Site == "" && len(f.Params) == 0 {
+		return 0, errclass.DataError(errors.New("bulk retry needs at least one of job_name, from, to, site or params"))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	where, args := f.where()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE cron_jobs
+		SET job_status = 'pending', attempts = 0, next_run_at = NULL, finished_at = NULL,
+			job_result = NULL, message = 'requeued by bulk retry'
+		WHERE `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("requeueing jobs: %w", err)
+	}
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("counting requeued jobs: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing bulk retry: %w", err)
+	}
+	return requeued, nil
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Checkpoints let a long job resume where a failed attempt stopped. A job
+// saves its progress in the same transaction as the work it covers and
+// clears it once done, so a retry sees exactly what was committed.
+
+// loadCheckpoint decodes the job's saved checkpoint into v. It reports false
+// when the job has none.
+func (s *Scheduler) loadCheckpoint(ctx context.Context, jobID int64, v any) (bool, error) {
+	var raw []byte
+	err := s.db.QueryRowContext(ctx, "SELECT checkpoint FROM job_checkpoints WHERE job_id = ?", jobID).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("loading checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false, fmt.Errorf("decoding checkpoint: %w", err)
+	}
+	return true, nil
+}
+
+func saveCheckpoint(ctx context.Context, ex execer, jobID int64, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+	_, err = ex.ExecContext(ctx, `
+		INSERT INTO job_checkpoints (job_id, checkpoint) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE checkpoint = VALUES(checkpoint)
+	`, jobID, raw)
+	if err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+	return nil
+}
+
+func clearCheckpoint(ctx context.Context, ex execer, jobID int64) error {
+	if _, err := ex.ExecContext(ctx, "DELETE FROM job_checkpoints WHERE job_id = ?", jobID); err != nil {
+		return fmt.Errorf("clearing checkpoint: %w", err)
+	}
+	return nil
+}
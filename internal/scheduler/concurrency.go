@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// parseConcurrencyLimits parses JOB_CONCURRENCY, e.g. "oracle_proc=1,golf=3".
+// Job types without an entry are not limited.
+func parseConcurrencyLimits(raw string) (map[string]int, error) {
+	values, err := parseKeyValues(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := make(map[string]int, len(values))
+	for jobName, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid limit for %q, must be a positive integer", jobName)
+		}
+		limits[jobName] = n
+	}
+	return limits, nil
+}
+
+// loadConcurrencyLimits reads JOB_CONCURRENCY and creates one semaphore per
+// limited job type.
+func (s *Scheduler) loadConcurrencyLimits() error {
+	limits, err := parseConcurrencyLimits(os.Getenv("JOB_CONCURRENCY"))
+	if err != nil {
+		return fmt.Errorf("parsing JOB_CONCURRENCY: %w", err)
+	}
+
+	s.slots = make(map[string]chan struct{}, len(limits))
+	for jobName, n := range limits {
+		s.slots[jobName] = make(chan struct{}, n)
+	}
+	return nil
+}
+
+// acquireSlot reserves an execution slot for jobName without blocking. It
+// returns false when the job type is already at its limit; release must be
+// called once the job is done.
+func (s *Scheduler) acquireSlot(jobName string) (release func(), ok bool) {
+	slot, limited := s.slots[jobName]
+	if !limited {
+		return func() {}, true
+	}
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, true
+	default:
+		return nil, false
+	}
+}
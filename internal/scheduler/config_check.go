@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ValidateConfig reads the whole scheduler configuration from the
+// environment and returns every problem it finds rather than stopping at the
+// first: the settings RegisterJobs loads, the golf schedule, and the Oracle
+// DSNs of the ERP and each golf site, which are otherwise only opened when a
+// job first needs them. It touches no database, so it must be called on a
+// Scheduler that has not been started, e.g. one from NewScheduler(nil, ...).
+func (s *Scheduler) ValidateConfig() []error {
+	var errs []error
+	for _, load := range s.configLoaders() {
+		if err := load(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if _, err := cron.ParseStandard(s.resolveSchedule(s.golfSchedule())); err != nil {
+		errs = append(errs, fmt.Errorf("parsing GOLF_SCHEDULE: %w", err))
+	}
+	return append(errs, database.CheckOracleConfig(golfSites)...)
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+	"hotbrandon/go-cron-be/internal/notify"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	connLeakSpec         = "@every 1m"
+	defaultConnHeldAlert = time.Hour
+)
+
+// loadConnLeakThreshold reads ORACLE_CONN_HELD_ALERT, how long an Oracle
+// session may stay checked out of its pool before it is reported as a likely
+// leak (a Go duration such as "1h"; "0" disables the check).
+func (s *Scheduler) loadConnLeakThreshold() error {
+	s.connHeldAlert = defaultConnHeldAlert
+	raw := os.Getenv("ORACLE_CONN_HELD_ALERT")
+	if raw == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("ORACLE_CONN_HELD_ALERT must be a non-negative duration, got %q", raw)
+	}
+	s.connHeldAlert = d
+	return nil
+}
+
+// CheckConnLeaks alerts once for every Oracle session held longer than
+// ORACLE_CONN_HELD_ALERT, naming the code that checked it out. Sessions are
+// tracked per process, so every instance checks its own.
+func (s *Scheduler) CheckConnLeaks() {
+	conns := database.HeldLongerThan(s.connHeldAlert)
+	current := make(map[int64]bool, len(conns))
+	var fresh []database.HeldConn
+	for _, c := range conns {
+		current[c.ID] = true
+		if !s.connLeakAlerted[c.ID] {
+			fresh = append(fresh, c)
+		}
+	}
+	s.connLeakAlerted = current
+	if len(fresh) == 0 {
+		return
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "These Oracle sessions have been checked out for more than %s, usually because rows or a pinned connection were never closed:\n", s.connHeldAlert)
+	for _, c := range fresh {
+		held := time.Since(c.Since).Round(time.Second)
+		s.logger.Warn("Oracle connection held too long", "upstream", c.Upstream, "held", held, "caller", c.Caller)
+		fmt.Fprintf(&body, "- %s session %d, held %s, checked out by %s\n", c.Upstream, c.ID, held, c.Caller)
+	}
+	s.notify(notify.Message{
+		Event:    "conn_leak",
+		Subject:  fmt.Sprintf("%d Oracle connections held too long", len(fresh)),
+		Body:     body.String(),
+		Severity: notify.SeverityWarning,
+	})
+}
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/export"
+	"hotbrandon/go-cron-be/internal/sftp"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CSVExportParams are the job_params of a "csv_export" job. Query must be a
+// SELECT on Connection ("mysql", "erp" or a golf site id); string Args and
+// Filename may use templates such as "invoices_{{roc_yesterday}}.csv". The
+// file is written to EXPORT_DIR and uploaded to RemoteDir on the SFTP drop
+// (SFTP_REMOTE_DIR if empty).
+type CSVExportParams struct {
+	Connection     string `json:"connection"`
+	Query          string `json:"query"`
+	Args           []any  `json:"args"`
+	Filename       string `json:"filename"`
+	RemoteDir      string `json:"remote_dir"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+func (p CSVExportParams) Validate() error {
+	if p.Connection == "" {
+		return errors.New("connection is required")
+	}
+	if !isQuery(p.Query) {
+		return errors.New("query must be a SELECT statement")
+	}
+	if p.Filename == "" {
+		return errors.New("filename is required")
+	}
+	if p.TimeoutSeconds < 0 {
+		return errors.New("timeout_seconds must not be negative")
+	}
+	return nil
+}
+
+func (s *Scheduler) runCSVExportJob(ctx context.Context, job CronJob, params CSVExportParams) (string, error) {
+	filename, err := expandTemplate(params.Filename, job)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("filename: %w", err))
+	}
+	if filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+		return "", errclass.DataError(fmt.Errorf("filename %q must be a plain file name", filename))
+	}
+	args, err := expandSQLArgs(params.Args, job)
+	if err != nil {
+		return "", errclass.DataError(err)
+	}
+
+	// Fail on missing SFTP settings before running a potentially slow query.
+	client, err := sftp.NewClientFromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	timeout := defaultSQLTimeout
+	if params.TimeoutSeconds > 0 {
+		timeout = time.Duration(params.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	table, err := s.queryTable(ctx, params.Connection, params.Query, args)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := writeExportFile(exportDir(), filename, func(w io.Writer) error {
+		return export.WriteCSV(w, table)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	archived := s.archiveExport(ctx, path, "text/csv")
+	if err := client.Upload(ctx, path, params.RemoteDir, filename); err != nil {
+		return fmt.Sprintf("wrote %d rows to %s%s", len(table.Rows), path, archived), err
+	}
+
+	return fmt.Sprintf("wrote %d rows to %s%s, uploaded to %s", len(table.Rows), path, archived, client.Destination(params.RemoteDir, filename)), nil
+}
+
+// queryTable runs query on the named connection and returns every row, with
+// the column names as header.
+func (s *Scheduler) queryTable(ctx context.Context, connection, query string, args []any) (export.Table, error) {
+	db, err := s.connection(connection)
+	if err != nil {
+		return export.Table{}, err
+	}
+
+	var rows *sql.Rows
+	err = database.Retry(ctx, "export query on "+connection, func() error {
+		rows, err = db.QueryContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return export.Table{}, fmt.Errorf("querying %s: %w", connection, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return export.Table{}, err
+	}
+
+	table := export.Table{Header: columns}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return export.Table{}, fmt.Errorf("scanning row: %w", err)
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		table.Rows = append(table.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return export.Table{}, fmt.Errorf("rows error: %w", err)
+	}
+
+	return table, nil
+}
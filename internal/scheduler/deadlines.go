@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/notify"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const deadlineSpec = "@every 1m"
+
+// loadDeadlines reads JOB_DEADLINES, the local time of day by which each job
+// type's jobs for the day must be finished, e.g. "golf=13:00,einvoice_upload=18:30".
+func (s *Scheduler) loadDeadlines() error {
+	values, err := parseKeyValues(os.Getenv("JOB_DEADLINES"))
+	if err != nil {
+		return fmt.Errorf("parsing JOB_DEADLINES: %w", err)
+	}
+
+	s.deadlines = make(map[string]time.Duration, len(values))
+	for jobName, value := range values {
+		offset, err := parseTimeOfDay(value)
+		if err != nil {
+			return fmt.Errorf("parsing JOB_DEADLINES: invalid time of day for %q: %q", jobName, value)
+		}
+		s.deadlines[jobName] = offset
+	}
+	s.deadlineAlerted = make(map[string]string)
+	return nil
+}
+
+// CheckDeadlines alerts once a day for each job type whose deadline has
+// passed while the day's jobs are missing or not finished yet.
+func (s *Scheduler) CheckDeadlines() {
+	now := s.now()
+	today := now.Format(dateLayout)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	for jobName, offset := range s.deadlines {
+		deadline := midnight.Add(offset)
+		if now.Before(deadline) || s.deadlineAlerted[jobName] == today {
+			continue
+		}
+
+		counts, err := s.jobStatusCounts(jobName, today)
+		if err != nil {
+			s.logger.Error("Failed to check job deadline", "job_name", jobName, "error", err)
+			continue
+		}
+		s.deadlineAlerted[jobName] = today
+
+		var total, unfinished int
+		var pending []string
+		for status, n := range counts {
+			total += n
+			if status == "pending" || status == "retrying" || status == "running" {
+				unfinished += n
+				pending = append(pending, fmt.Sprintf("%s=%d", status, n))
+			}
+		}
+
+		var problem string
+		switch {
+		case total == 0:
+			problem = "no jobs were created"
+		case unfinished > 0:
+			sort.Strings(pending)
+			problem = fmt.Sprintf("%d of %d jobs are not finished (%s)", unfinished, total, strings.Join(pending, ", "))
+		default:
+			continue
+		}
+
+		s.logger.Warn("Job deadline missed", "job_name", jobName, "job_date", today, "deadline", deadline.Format("15:04"), "problem", problem)
+		s.notify(notify.Message{
+			Event:    "deadline_missed",
+			Subject:  fmt.Sprintf("Job %s missed its %s deadline", jobName, deadline.Format("15:04")),
+			Body:     fmt.Sprintf("Job type %s must be finished by %s for job_date %s, but %s.", jobName, deadline.Format("15:04"), today, problem),
+			Severity: notify.SeverityCritical,
+			JobName:  jobName,
+		})
+	}
+}
+
+// jobStatusCounts counts the jobs of one type and job_date by status.
+func (s *Scheduler) jobStatusCounts(jobName, jobDate string) (map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT job_status, COUNT(*) FROM cron_jobs
+		WHERE job_name = ? AND job_date = ?
+		GROUP BY job_status
+	`, jobName, jobDate)
+	if err != nil {
+		return nil, fmt.Errorf("counting jobs: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// DebugVars is a snapshot of the scheduler's internal state, published
+// through expvar for a quick look at a running instance.
+type DebugVars struct {
+	Leader bool `json:"leader"`
+	// EntriesRegistered counts every cron entry, ScheduleEntries only the
+	// ones that create jobs.
+	EntriesRegistered int   `json:"entries_registered"`
+	ScheduleEntries   int   `json:"schedule_entries"`
+	Workers           int   `json:"workers"`
+	WorkersBusy       int64 `json:"workers_busy"`
+	// LastTick is when the cron loop last ran, see Alive.
+	LastTick *time.Time `json:"last_tick"`
+	// Backlog counts pending and retrying jobs, including ones not due yet.
+	// BacklogError is set instead when MySQL could not be asked.
+	Backlog      int64  `json:"backlog"`
+	BacklogError string `json:"backlog_error,omitempty"`
+}
+
+// Vars returns the current DebugVars. It is meant for expvar.Func, so it
+// reports a failed backlog query in the snapshot instead of returning it.
+func (s *Scheduler) Vars() any {
+	s.scheduleMu.Lock()
+	scheduleEntries := len(s.scheduleEntries)
+	s.scheduleMu.Unlock()
+
+	vars := DebugVars{
+		Leader:            s.IsLeader(),
+		EntriesRegistered: len(s.c.Entries()),
+		ScheduleEntries:   scheduleEntries,
+		Workers:           s.workerCount,
+		WorkersBusy:       s.busy.Load(),
+	}
+	if last := s.lastTick.Load(); last != 0 {
+		t := time.Unix(0, last)
+		vars.LastTick = &t
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 2*time.Second)
+	defer cancel()
+	err := s.readDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM cron_jobs WHERE job_status IN ('pending', 'retrying')").Scan(&vars.Backlog)
+	if err != nil {
+		vars.BacklogError = err.Error()
+	}
+	return vars
+}
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// parseDependencies parses JOB_DEPENDENCIES, e.g. "sql=golf;http=golf,sql",
+// meaning a "sql" job only runs once every "golf" job of the same job_date
+// has finished.
+func parseDependencies(raw string) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		jobName, upstream, ok := strings.Cut(entry, "=")
+		jobName = strings.TrimSpace(jobName)
+		if !ok || jobName == "" {
+			return nil, fmt.Errorf("invalid dependency %q, expected job=dep1,dep2", entry)
+		}
+		for _, dep := range strings.Split(upstream, ",") {
+			dep = strings.TrimSpace(dep)
+			if dep == "" {
+				continue
+			}
+			if dep == jobName {
+				return nil, fmt.Errorf("job %q cannot depend on itself", jobName)
+			}
+			deps[jobName] = append(deps[jobName], dep)
+		}
+	}
+	if cycle := dependencyCycle(deps); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle %s: these jobs would wait on each other forever", strings.Join(cycle, " -> "))
+	}
+	return deps, nil
+}
+
+// dependencyCycle returns a cycle in deps, e.g. [a b a], or nil. It is a
+// depth-first search that finds a back edge to a job still on the path.
+func dependencyCycle(deps map[string][]string) []string {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(job string) []string
+	visit = func(job string) []string {
+		state[job] = onPath
+		path = append(path, job)
+		for _, dep := range deps[job] {
+			switch state[dep] {
+			case onPath:
+				start := slices.Index(path, dep)
+				return append(slices.Clone(path[start:]), dep)
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[job] = done
+		return nil
+	}
+
+	// Visit in sorted order so the reported cycle is deterministic.
+	for _, job := range slices.Sorted(maps.Keys(deps)) {
+		if state[job] == unvisited {
+			if cycle := visit(job); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// loadDependencies reads JOB_DEPENDENCIES into the scheduler.
+func (s *Scheduler) loadDependencies() error {
+	deps, err := parseDependencies(os.Getenv("JOB_DEPENDENCIES"))
+	if err != nil {
+		return fmt.Errorf("parsing JOB_DEPENDENCIES: %w", err)
+	}
+	s.dependencies = deps
+	return nil
+}
+
+// UpstreamJobs counts the jobs of one upstream job type for a job_date.
+type UpstreamJobs struct {
+	Total int
+	// Finished have a finished run.
+	Finished int
+	// Failed failed for good without ever finishing.
+	Failed int
+}
+
+// dependenciesMet reports whether every upstream job type of job has at
+// least one job for the same job_date and all of them have a finished run.
+// failed names an upstream job type with a job that failed for good: job
+// would wait on it forever.
+func (s *Scheduler) dependenciesMet(job CronJob) (ready bool, failed string, err error) {
+	for _, upstream := range s.dependencies[job.JobName] {
+		u, err := s.store.UpstreamJobs(s.ctx, upstream, job.JobDate)
+		if err != nil {
+			return false, "", fmt.Errorf("checking dependency %q: %w", upstream, err)
+		}
+		if u.Failed > 0 {
+			return false, upstream, nil
+		}
+		if u.Total == 0 || u.Finished < u.Total {
+			return false, "", nil
+		}
+	}
+	return true, "", nil
+}
+
+// failDependent fails job because a job of its upstream job type failed for
+// good, firing the failure hooks so it is alerted on like any failed run.
+// It is a data error: the upstream's output for the job_date is missing
+// until someone reruns it and then job.
+func (s *Scheduler) failDependent(job CronJob, upstream string) {
+	claimed, err := s.store.ClaimJob(s.ctx, job.JobID)
+	if err != nil {
+		s.logger.Error("Failed to claim job", "job_id", job.JobID, "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	job.Attempts++
+
+	err = errclass.DataError(fmt.Errorf("upstream job type %q failed for job_date %s", upstream, job.JobDate))
+	message := fmt.Sprintf("[%s] %s", errclass.Data, err)
+	s.logger.Warn("Job failed: upstream job failed", "job_id", job.JobID, "job_name", job.JobName, "upstream", upstream)
+	s.finishJob(job, 0, "failed", errclass.Data, message, 0)
+
+	ev := RunEvent{Job: job, Status: "failed", Category: errclass.Data, Message: message, Err: err}
+	s.fireHooks(s.ctx, OnFailure, ev)
+	s.fireHooks(s.ctx, AfterRun, ev)
+}
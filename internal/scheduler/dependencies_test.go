@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"strings"
+	"testing"
+)
+
+func TestParseDependencies(t *testing.T) {
+	tests := []struct {
+		raw, err string
+	}{
+		{"sql=golf;http=golf,sql", ""},
+		{"c=b;b=a;d=a,c", ""},
+		{"a=a", `job "a" cannot depend on itself`},
+		{"a=b;b=a", "dependency cycle a -> b -> a"},
+		{"x=golf;a=c;b=a;c=b", "dependency cycle a -> c -> b -> a"},
+		{"a", "expected job=dep1,dep2"},
+	}
+	for _, tt := range tests {
+		_, err := parseDependencies(tt.raw)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("parseDependencies(%q): %v", tt.raw, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseDependencies(%q) = %v, want error %q", tt.raw, err, tt.err)
+		}
+	}
+}
+
+func TestRunPendingJobsFailsDependentOfFailedUpstream(t *testing.T) {
+	s, store := newMemoryScheduler(t)
+	RegisterJobType(s, "import", func(ctx context.Context, job CronJob, p echoParams) (string, error) {
+		return "", errclass.DataError(errors.New("ERP file missing"))
+	})
+	RegisterJobType(s, "report", func(ctx context.Context, job CronJob, p echoParams) (string, error) {
+		t.Error("dependent of a failed job ran")
+		return "", nil
+	})
+	s.dependencies = map[string][]string{"report": {"import"}}
+	var failures []RunEvent
+	s.AddHook(OnFailure, func(ctx context.Context, ev RunEvent) {
+		failures = append(failures, ev)
+	})
+
+	ctx := context.Background()
+	upstreamID, _, _ := s.EnqueueJob(ctx, "import", "2025-07-15", echoParams{}, 0)
+	reportID, _, _ := s.EnqueueJob(ctx, "report", "2025-07-15", echoParams{}, 0)
+	if err := s.RunJob(ctx, upstreamID); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RunPendingJobs()
+
+	job, _ := store.Job(reportID)
+	if job.JobStatus != "failed" || !strings.Contains(job.Message, `[data] upstream job type "import" failed for job_date 2025-07-15`) {
+		t.Errorf("dependent job %s: %q", job.JobStatus, job.Message)
+	}
+	if len(failures) != 2 || failures[1].Job.JobID != reportID || failures[1].Category != errclass.Data {
+		t.Errorf("OnFailure events %+v, want the upstream's and the dependent's", failures)
+	}
+}
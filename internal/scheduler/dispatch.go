@@ -0,0 +1,249 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"time"
+)
+
+// RunPendingJobs hands every pending job whose job_name has a registered
+// handler to the worker pool, highest priority first. It returns once all
+// jobs have been picked up by a worker or the scheduler is stopping.
+func (s *Scheduler) RunPendingJobs() {
+	jobs, err := s.pendingJobs()
+	if err != nil {
+		s.logger.Error("Failed to load pending jobs", "error", err)
+		return
+	}
+
+	now := s.now()
+	for _, job := range jobs {
+		if !s.jobEnabled(job) {
+			s.logger.Debug("Job switched off, leaving pending", "job_id", job.JobID, "job_name", job.JobName)
+			continue
+		}
+
+		if w := s.activeBlackout(job.JobName, now); w != nil {
+			s.holdForBlackout(job, w)
+			continue
+		}
+
+		ready, failed, err := s.dependenciesMet(job)
+		if err != nil {
+			s.logger.Error("Failed to resolve job dependencies", "job_id", job.JobID, "error", err)
+			continue
+		}
+		if failed != "" {
+			s.failDependent(job, failed)
+			continue
+		}
+		if !ready {
+			s.logger.Debug("Job waiting on dependencies", "job_id", job.JobID, "job_name", job.JobName, "depends_on", s.dependencies[job.JobName])
+			continue
+		}
+		if !s.enqueue(job) {
+			return
+		}
+	}
+}
+
+func (s *Scheduler) pendingJobs() ([]CronJob, error) {
+	names := s.JobTypes()
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return s.store.PendingJobs(s.ctx, names)
+}
+
+// RunJob runs the pending or retrying job jobID in the calling goroutine as
+// a worker would, without waiting for its next_run_at. It returns
+// sql.ErrNoRows if there is no such job. The dispatcher runs jobs on its
+// own; this is for test harnesses and tools.
+func (s *Scheduler) RunJob(ctx context.Context, jobID int64) error {
+	job, err := s.store.PendingJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	s.runJob(ctx, job)
+	return nil
+}
+
+// runJob claims a pending job, executes its handler and records the outcome.
+// Jobs whose type is at its concurrency limit stay pending for a later tick.
+func (s *Scheduler) runJob(ctx context.Context, job CronJob) {
+	logger := s.logger
+	if job.RequestID != "" {
+		logger = logger.With("request_id", job.RequestID)
+	}
+
+	release, ok := s.acquireSlot(job.JobName)
+	if !ok {
+		logger.Debug("Job type at concurrency limit", "job_id", job.JobID, "job_name", job.JobName)
+		return
+	}
+	defer release()
+
+	releaseTenant, ok := s.acquireTenantSlot(job.Tenant)
+	if !ok {
+		logger.Debug("Tenant at concurrency limit", "job_id", job.JobID, "job_name", job.JobName, "tenant", job.Tenant)
+		return
+	}
+	defer releaseTenant()
+
+	// lockCtx is cancelled if the lock of an exclusive job type is lost.
+	lockCtx, loseLock := context.WithCancelCause(ctx)
+	defer loseLock(nil)
+	releaseExclusive, ok := s.acquireExclusive(job.JobName, loseLock)
+	if !ok {
+		logger.Debug("Exclusive job type running on another instance", "job_id", job.JobID, "job_name", job.JobName)
+		return
+	}
+	defer releaseExclusive()
+
+	claimed, err := s.store.ClaimJob(ctx, job.JobID)
+	if err != nil {
+		logger.Error("Failed to claim job", "job_id", job.JobID, "error", err)
+		return
+	}
+	if !claimed {
+		// Another dispatcher picked it up first.
+		return
+	}
+	job.Attempts++
+
+	runID, err := s.store.StartRun(ctx, job)
+	if err != nil {
+		logger.Error("Failed to record job run", "job_id", job.JobID, "error", err)
+	}
+
+	jt, ok := s.jobTypes[job.JobName]
+	if !ok {
+		s.finishJob(job, runID, "failed", errclass.Config, fmt.Sprintf("no handler registered for job_name %q", job.JobName), 0)
+		return
+	}
+	job, job.DryRun = splitDryRun(job)
+	job.DryRun = job.DryRun || s.dryRun
+	if job.DryRun && !jt.dryRun {
+		s.finishJob(job, runID, "failed", errclass.Config, fmt.Sprintf("job type %q does not support dry runs", job.JobName), 0)
+		return
+	}
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	go s.heartbeat(heartbeatCtx, job.JobID)
+
+	runCtx, cancelRun := context.WithCancel(lockCtx)
+	stopWatchdog := s.watchRuntime(job, cancelRun)
+	runCtx, result := withJobResult(runCtx)
+	runCtx, runLog := withRunLog(runCtx)
+	runCtx = database.WithEndpointReporter(runCtx, runLog.endpointReporter())
+	runCtx, dbTime := database.WithDBTime(runCtx)
+	jobLog, captured := captureRunLog(newJobLogger(logger, job))
+	defer s.saveRunLogs(job, runID, captured)
+	runCtx = withJobLogger(runCtx, jobLog)
+	if job.DryRun {
+		runCtx = withDryRun(runCtx)
+	}
+
+	s.fireHooks(runCtx, BeforeRun, RunEvent{Job: job, RunID: runID})
+
+	start := time.Now()
+	message, err := s.wrap(jt.run)(runCtx, job)
+	elapsed := time.Since(start)
+	s.recordRunTimes(job, runID, elapsed, dbTime)
+	message = runLog.appendTo(message)
+	if job.DryRun {
+		message = "dry run: " + message
+	}
+	job.Result = result.get()
+	overran := stopWatchdog()
+	cancelRun()
+	stopHeartbeat()
+	if overran && s.cancelOverruns && err != nil {
+		err = fmt.Errorf("cancelled by watchdog after exceeding max runtime: %w", err)
+	}
+	if cause := context.Cause(lockCtx); err != nil && errors.Is(cause, errExclusiveLost) {
+		// Nothing is wrong with the job; run it again once the lock is free.
+		err = errclass.TransientError(fmt.Errorf("%w: %w", cause, err))
+	}
+	hookCtx := context.WithoutCancel(runCtx)
+	ev := RunEvent{Job: job, RunID: runID, Elapsed: elapsed, Err: err}
+	if err != nil {
+		category := errclass.Classify(err)
+		jobLog.Error("Job failed", "category", category, "error", err)
+		errMessage := fmt.Sprintf("[%s] %s", category, err)
+		if message != "" {
+			// Keep whatever output the handler captured before failing.
+			message = errMessage + "\n" + message
+		} else {
+			message = errMessage
+		}
+
+		ev.Category, ev.Message = category, message
+		if policy := s.retryPolicy(job.JobName); policy.ShouldRetry(category, job.Attempts) {
+			s.retryJob(job, runID, category, message, elapsed, policy.Delay(job.Attempts))
+			ev.Status = "retrying"
+		} else {
+			s.finishJob(job, runID, "failed", category, message, elapsed)
+			ev.Status = "failed"
+		}
+		s.fireHooks(hookCtx, OnFailure, ev)
+		s.fireHooks(hookCtx, AfterRun, ev)
+		return
+	}
+
+	jobLog.Info("Job finished", "duration", elapsed, "oracle", dbTime.Oracle(), "mysql", dbTime.MySQL())
+	s.finishJob(job, runID, "finished", "", message, elapsed)
+	ev.Status, ev.Message = "finished", message
+	s.fireHooks(hookCtx, AfterRun, ev)
+}
+
+// finishJob records the final outcome on both the job and its run record.
+func (s *Scheduler) finishJob(job CronJob, runID int64, status string, category errclass.Category, message string, elapsed time.Duration) {
+	message, job.Result = s.capOutput(job, runID, message, job.Result)
+	outcome := RunOutcome{Status: status, Category: category, Message: message, Elapsed: elapsed}
+	if err := s.store.FinishJob(context.Background(), job, runID, outcome); err != nil {
+		s.logger.Error("Failed to record job result", "job_id", job.JobID, "status", status, "error", err)
+	}
+
+	s.finishRun(job, runID, outcome)
+}
+
+// retryJob puts a failed job back in the queue to run again after delay.
+func (s *Scheduler) retryJob(job CronJob, runID int64, category errclass.Category, message string, elapsed time.Duration, delay time.Duration) {
+	s.logger.Warn("Retrying job", "job_id", job.JobID, "job_name", job.JobName, "attempt", job.Attempts, "delay", delay)
+	message, job.Result = s.capOutput(job, runID, message, job.Result)
+	outcome := RunOutcome{Status: "failed", Category: category, Message: message, Elapsed: elapsed}
+	if err := s.store.RetryJob(context.Background(), job, outcome, delay); err != nil {
+		s.logger.Error("Failed to schedule job retry", "job_id", job.JobID, "error", err)
+	}
+
+	s.finishRun(job, runID, outcome)
+}
+
+// finishRun records the outcome of one execution. A zero runID means the run
+// record could not be created.
+func (s *Scheduler) finishRun(job CronJob, runID int64, outcome RunOutcome) {
+	if runID == 0 {
+		return
+	}
+	if err := s.store.FinishRun(context.Background(), job, runID, outcome); err != nil {
+		s.logger.Error("Failed to record run result", "job_id", job.JobID, "run_id", runID, "error", err)
+	}
+}
+
+// recordRunTimes splits the run's duration into the time its statements
+// spent in Oracle and in MySQL, and the rest, spent in application code or
+// reading rows.
+func (s *Scheduler) recordRunTimes(job CronJob, runID int64, elapsed time.Duration, dbTime *database.DBTime) {
+	if runID == 0 {
+		return
+	}
+	oracle, mysql := dbTime.Oracle(), dbTime.MySQL()
+	app := max(elapsed-oracle-mysql, 0)
+	if err := s.store.RecordRunTimes(context.Background(), runID, oracle, mysql, app); err != nil {
+		s.logger.Error("Failed to record run times", "job_id", job.JobID, "run_id", runID, "error", err)
+	}
+}
@@ -0,0 +1,250 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/lock"
+	"hotbrandon/go-cron-be/internal/metrics"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type echoParams struct {
+	Text string `json:"text"`
+}
+
+func newMemoryScheduler(t *testing.T) (*Scheduler, *MemoryJobStore) {
+	t.Helper()
+	store := NewMemoryJobStore()
+	s := NewScheduler(nil, slog.New(slog.NewTextHandler(io.Discard, nil)), WithJobStore(store))
+	return s, store
+}
+
+func TestRunJobFinishes(t *testing.T) {
+	s, store := newMemoryScheduler(t)
+	RegisterJobType(s, "echo", func(ctx context.Context, job CronJob, p echoParams) (string, error) {
+		if err := SetResult(ctx, map[string]string{"echo": p.Text}); err != nil {
+			return "", err
+		}
+		return "said " + p.Text, nil
+	})
+
+	ctx := context.Background()
+	jobID, created, err := s.EnqueueJob(ctx, "echo", "2025-07-15", echoParams{Text: "hi"}, 0)
+	if err != nil || !created {
+		t.Fatalf("EnqueueJob = %d, %v, %v", jobID, created, err)
+	}
+	if _, created, _ := s.EnqueueJob(ctx, "echo", "2025-07-15", echoParams{Text: "hi"}, 0); created {
+		t.Fatal("identical job was enqueued twice")
+	}
+
+	if err := s.RunJob(ctx, jobID); err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+
+	job, _ := store.Job(jobID)
+	if job.JobStatus != "finished" || job.Message != "said hi" || job.Attempts != 1 {
+		t.Errorf("job = %s %q after %d attempts, want finished \"said hi\" after 1", job.JobStatus, job.Message, job.Attempts)
+	}
+	var result map[string]string
+	if err := json.Unmarshal(job.Result, &result); err != nil || result["echo"] != "hi" {
+		t.Errorf("job_result = %s, want {\"echo\":\"hi\"}", job.Result)
+	}
+	runs := store.Runs(jobID)
+	if len(runs) != 1 || runs[0].RunStatus != "finished" || len(runs[0].Logs) == 0 {
+		t.Errorf("runs = %+v, want one finished run with logs", runs)
+	}
+
+	if err := s.RunJob(ctx, jobID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RunJob of a finished job = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestRunJobRetriesTransientFailure(t *testing.T) {
+	s, store := newMemoryScheduler(t)
+	calls := 0
+	RegisterJobType(s, "flaky", func(ctx context.Context, job CronJob, p struct{}) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", errclass.TransientError(errors.New("connection reset"))
+		}
+		return "ok", nil
+	})
+	store.SetRetryPolicy("flaky", RetryPolicy{MaxAttempts: 2, RetryOn: []errclass.Category{errclass.Transient}})
+
+	ctx := context.Background()
+	jobID, _, err := s.EnqueueJob(ctx, "flaky", "2025-07-15", struct{}{}, 0)
+	if err != nil {
+		t.Fatalf("EnqueueJob: %v", err)
+	}
+
+	if err := s.RunJob(ctx, jobID); err != nil {
+		t.Fatalf("first RunJob: %v", err)
+	}
+	if job, _ := store.Job(jobID); job.JobStatus != "retrying" {
+		t.Fatalf("after a transient failure job_status = %s, want retrying", job.JobStatus)
+	}
+
+	if err := s.RunJob(ctx, jobID); err != nil {
+		t.Fatalf("second RunJob: %v", err)
+	}
+	job, _ := store.Job(jobID)
+	if job.JobStatus != "finished" || job.Attempts != 2 {
+		t.Errorf("job = %s after %d attempts, want finished after 2", job.JobStatus, job.Attempts)
+	}
+	runs := store.Runs(jobID)
+	if len(runs) != 2 || runs[0].RunStatus != "failed" || runs[0].ErrorCategory != errclass.Transient || runs[1].RunStatus != "finished" {
+		t.Errorf("runs = %+v, want a transient failure then a finished run", runs)
+	}
+}
+
+func TestRunJobFailsDataErrorWithoutRetry(t *testing.T) {
+	s, store := newMemoryScheduler(t)
+	RegisterJobType(s, "echo", func(ctx context.Context, job CronJob, p echoParams) (string, error) {
+		return p.Text, nil
+	})
+
+	ctx := context.Background()
+	// Unknown params fields fail decoding, a data error.
+	jobID, _, err := s.EnqueueJob(ctx, "echo", "2025-07-15", map[string]string{"txt": "typo"}, 0)
+	if err != nil {
+		t.Fatalf("EnqueueJob: %v", err)
+	}
+	if err := s.RunJob(ctx, jobID); err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+
+	job, _ := store.Job(jobID)
+	if job.JobStatus != "failed" {
+		t.Errorf("job_status = %s, want failed", job.JobStatus)
+	}
+	if runs := store.Runs(jobID); len(runs) != 1 || runs[0].ErrorCategory != errclass.Data {
+		t.Errorf("runs = %+v, want one data failure", runs)
+	}
+}
+
+func TestRunPendingJobs(t *testing.T) {
+	s, store := newMemoryScheduler(t)
+	for _, name := range []string{"echo", "paused", "erp", "report"} {
+		RegisterJobType(s, name, func(ctx context.Context, job CronJob, p echoParams) (string, error) {
+			return "said " + p.Text, nil
+		})
+	}
+	store.SetEnabled("job_type", "paused", false)
+	if err := s.refreshFlags(context.Background()); err != nil {
+		t.Fatalf("refreshFlags: %v", err)
+	}
+	t.Setenv("BLACKOUT_WINDOWS", `[
+		{"name": "erp-close", "start": "* * * * *", "duration": "1h", "job_names": ["erp"]},
+		{"name": "month-end", "start": "* * * * *", "duration": "1h", "job_names": ["report"], "catch_up": true}
+	]`)
+	if err := s.loadBlackoutWindows(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ids := map[string]int64{}
+	for _, name := range []string{"echo", "paused", "erp", "report"} {
+		jobID, _, err := s.EnqueueJob(ctx, name, "2025-07-15", echoParams{Text: "hi"}, 0)
+		if err != nil {
+			t.Fatalf("EnqueueJob(%s): %v", name, err)
+		}
+		ids[name] = jobID
+	}
+
+	s.workerCount = 1
+	s.startWorkers()
+	s.RunPendingJobs()
+	s.stopWorkers()
+
+	want := map[string]struct{ status, message string }{
+		"echo":   {"finished", "said hi"},
+		"paused": {"pending", ""},
+		"erp":    {"skipped", "skipped by blackout window erp-close"},
+		"report": {"pending", "deferred by blackout window month-end"},
+	}
+	for name, w := range want {
+		job, _ := store.Job(ids[name])
+		if job.JobStatus != w.status || job.Message != w.message {
+			t.Errorf("%s job = %s %q, want %s %q", name, job.JobStatus, job.Message, w.status, w.message)
+		}
+	}
+}
+
+// heldLocker reports the locks in held as taken by another instance and
+// hands out every other one.
+type heldLocker struct {
+	held map[string]bool
+}
+
+func (l heldLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (lock.Lock, bool, error) {
+	if l.held[name] {
+		return nil, false, nil
+	}
+	return nopLock{}, true, nil
+}
+
+type nopLock struct{}
+
+func (nopLock) Refresh(ctx context.Context, ttl time.Duration) error { return nil }
+func (nopLock) Unlock(ctx context.Context) error                     { return nil }
+
+// timings records the tags of each Timing by metric name.
+type timings struct {
+	metrics.Nop
+	mu   sync.Mutex
+	tags map[string][][]metrics.Tag
+}
+
+func (m *timings) Timing(name string, d time.Duration, tags ...metrics.Tag) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tags[name] = append(m.tags[name], tags)
+}
+
+func TestLockAndMetricsMiddleware(t *testing.T) {
+	t.Setenv("JOB_MIDDLEWARE", "recover,metrics,lock")
+	store := NewMemoryJobStore()
+	sink := &timings{tags: map[string][][]metrics.Tag{}}
+	s := NewScheduler(nil, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithJobStore(store), WithMetrics(sink), WithLocker(heldLocker{held: map[string]bool{"job:busy": true}}))
+	if err := s.loadMiddleware(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"echo", "busy"} {
+		RegisterJobType(s, name, func(ctx context.Context, job CronJob, p echoParams) (string, error) {
+			return "said " + p.Text, nil
+		})
+	}
+
+	ctx := context.Background()
+	echoID, _, _ := s.EnqueueJob(ctx, "echo", "2025-07-15", echoParams{Text: "hi"}, 0)
+	busyID, _, _ := s.EnqueueJob(ctx, "busy", "2025-07-15", echoParams{Text: "hi"}, 0)
+	for _, jobID := range []int64{echoID, busyID} {
+		if err := s.RunJob(ctx, jobID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if job, _ := store.Job(echoID); job.JobStatus != "finished" {
+		t.Errorf("echo job = %s, want finished", job.JobStatus)
+	}
+	busy, _ := store.Job(busyID)
+	if runs := store.Runs(busyID); busy.JobStatus != "retrying" || len(runs) != 1 || runs[0].ErrorCategory != errclass.Transient {
+		t.Errorf("job of a type locked elsewhere = %s, runs %+v, want a transient failure to retry", busy.JobStatus, runs)
+	}
+	want := [][]metrics.Tag{
+		{metrics.T("job_name", "echo"), metrics.T("outcome", "ok"), metrics.T("tenant", "default")},
+		{metrics.T("job_name", "busy"), metrics.T("outcome", string(errclass.Transient)), metrics.T("tenant", "default")},
+	}
+	if got := sink.tags["job_handler_duration"]; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("job_handler_duration tags = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// dryRunParam is the job_params key that marks a job triggered as a dry
+// run. It lives in job_params rather than a column of its own so that the
+// dry run does not collide with the real job under unique_job, and the real
+// job can still be triggered afterwards.
+const dryRunParam = "dry_run"
+
+type dryRunKey struct{}
+
+// loadDryRun reads DRY_RUN. When true every job runs as a dry run. Such jobs
+// are still recorded as finished, so it is meant for a staging copy of the
+// database, not to rehearse production; there, trigger single jobs with
+// dry_run instead.
+func (s *Scheduler) loadDryRun() error {
+	s.dryRun = false
+	if raw := os.Getenv("DRY_RUN"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("DRY_RUN must be true or false, got %q", raw)
+		}
+		s.dryRun = v
+	}
+	return nil
+}
+
+// supportDryRun marks job types whose handlers honor DryRun. Dry runs of
+// other job types are refused, since their handlers would write anyway.
+func (s *Scheduler) supportDryRun(jobNames ...string) {
+	for _, name := range jobNames {
+		jt := s.jobTypes[name]
+		jt.dryRun = true
+		s.jobTypes[name] = jt
+	}
+}
+
+func withDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// DryRun reports whether the job running under ctx is a dry run. Handlers
+// then read and validate as usual but skip their writes, procedure calls and
+// notifications, logging what they would have done instead.
+func DryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// markDryRun adds the dry-run marker to params, a JSON object.
+func markDryRun(params json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(params, &fields); err != nil {
+		return nil, fmt.Errorf("decoding job_params: %w", err)
+	}
+	fields[dryRunParam] = json.RawMessage("true")
+	return json.Marshal(fields)
+}
+
+// splitDryRun removes the dry-run marker from job's params and reports
+// whether it was set.
+func splitDryRun(job CronJob) (CronJob, bool) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal([]byte(job.JobParams), &fields) != nil {
+		return job, false
+	}
+	marker, ok := fields[dryRunParam]
+	if !ok {
+		return job, false
+	}
+	delete(fields, dryRunParam)
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return job, false
+	}
+	job.JobParams = string(raw)
+	return job, string(marker) == "true"
+}
@@ -0,0 +1,233 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/einvoice"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"os"
+)
+
+const defaultEInvoiceBatchSize = 100
+
+// EInvoiceParams are the job_params of an "einvoice_upload" job. InvoiceDate
+// may use templates such as "{{yesterday}}".
+type EInvoiceParams struct {
+	InvoiceDate string `json:"invoice_date"`
+	BatchSize   int    `json:"batch_size"`
+}
+
+func (p EInvoiceParams) Validate() error {
+	if p.InvoiceDate == "" {
+		return errors.New("invoice_date is required")
+	}
+	if p.BatchSize < 0 {
+		return errors.New("batch_size must not be negative")
+	}
+	return nil
+}
+
+// runEInvoiceJob hands the day's funeral_invoices rows to the MOF Turnkey
+// client and tracks each invoice in einvoice_uploads. New invoices get the
+// next number of EINVOICE_INVOICE_NUMBERS and are written to Turnkey's SRC
+// folder; invoices submitted earlier are checked for the outcome Turnkey
+// recorded, see einvoice.Turnkey.Status.
+//
+// While Turnkey has not sent every invoice the job fails as transient, so the
+// retry policy comes back to collect the outcome. Rejected invoices fail it
+// as a data error: they stay rejected until an operator fixes them and
+// deletes their einvoice_uploads row and ERR file, and the next run submits
+// them under a new number. A dry run only reports what it would submit.
+func (s *Scheduler) runEInvoiceJob(ctx context.Context, job CronJob, params EInvoiceParams) (string, error) {
+	invoiceDate, err := expandTemplate(params.InvoiceDate, job)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("invoice_date: %w", err))
+	}
+
+	turnkey, err := einvoice.NewTurnkeyFromEnv()
+	if err != nil {
+		return "", err
+	}
+	numbers, err := einvoice.ParseNumberRange(os.Getenv("EINVOICE_INVOICE_NUMBERS"))
+	if err != nil {
+		return "", errclass.ConfigError(fmt.Errorf("EINVOICE_INVOICE_NUMBERS: %w", err))
+	}
+
+	uploads, err := s.einvoiceUploads(ctx, invoiceDate)
+	if err != nil {
+		return "", err
+	}
+
+	var toSubmit []einvoice.Invoice
+	var submitted, waiting, accepted, rejected int
+	defer func() {
+		_ = SetResult(ctx, map[string]any{
+			"invoice_date": invoiceDate,
+			"invoices":     len(uploads),
+			"submitted":    submitted,
+			"waiting":      waiting,
+			"accepted":     accepted,
+			"rejected":     rejected,
+		})
+	}()
+
+	for _, u := range uploads {
+		status := u.status
+		if status == einvoice.StatusSubmitted {
+			if status, err = turnkey.Status(u.Number); err != nil {
+				return "", err
+			}
+			if status == einvoice.StatusAccepted || status == einvoice.StatusRejected {
+				if err := recordUpload(ctx, s.db, u.Invoice, status); err != nil {
+					return "", err
+				}
+			}
+		}
+		switch status {
+		case einvoice.StatusAccepted:
+			accepted++
+		case einvoice.StatusRejected:
+			rejected++
+		case einvoice.StatusSubmitted:
+			waiting++
+		default:
+			// Never submitted, or the number was assigned but the message
+			// was not written.
+			toSubmit = append(toSubmit, u.Invoice)
+		}
+	}
+
+	if DryRun(ctx) {
+		Logger(ctx).Info("Dry run: not submitting invoices", "invoice_date", invoiceDate, "invoices", len(toSubmit))
+		return fmt.Sprintf("invoice_date=%s would submit %d invoices, %d waiting for Turnkey", invoiceDate, len(toSubmit), waiting), nil
+	}
+
+	if err := s.assignInvoiceNumbers(ctx, numbers, toSubmit); err != nil {
+		return "", err
+	}
+	for _, inv := range toSubmit {
+		if err := turnkey.Submit(inv); err != nil {
+			return fmt.Sprintf("submitted=%d before failure", submitted), err
+		}
+		submitted++
+		waiting++
+	}
+
+	message := fmt.Sprintf("invoice_date=%s invoices=%d submitted=%d waiting=%d accepted=%d rejected=%d", invoiceDate, len(uploads), submitted, waiting, accepted, rejected)
+	if rejected > 0 {
+		return message, errclass.DataError(fmt.Errorf("%d invoices rejected by Turnkey, see its log and ERR folder", rejected))
+	}
+	if waiting > 0 {
+		return message, errclass.TransientError(fmt.Errorf("%d invoices waiting for Turnkey to send them", waiting))
+	}
+	return message, nil
+}
+
+// einvoiceUpload is a funeral invoice with its einvoice_uploads status, ""
+// when it has none.
+type einvoiceUpload struct {
+	einvoice.Invoice
+	status string
+}
+
+// einvoiceUploads returns the date's invoices with their upload status.
+func (s *Scheduler) einvoiceUploads(ctx context.Context, invoiceDate string) ([]einvoiceUpload, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT f.invoice_date, f.c_idno2, f.total_amount_dividint10,
+			COALESCE(u.invoice_number, ''), COALESCE(u.upload_status, '')
+		FROM funeral_invoices f
+		LEFT JOIN einvoice_uploads u
+			ON u.invoice_date = f.invoice_date AND u.c_idno2 = f.c_idno2
+		WHERE f.invoice_date = ?
+		ORDER BY f.id
+	`, invoiceDate)
+	if err != nil {
+		return nil, fmt.Errorf("querying funeral_invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []einvoiceUpload
+	for rows.Next() {
+		var u einvoiceUpload
+		if err := rows.Scan(&u.InvoiceDate, &u.CustomerID, &u.TotalAmount, &u.Number, &u.status); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		if u.Number == "" {
+			// Tracked before invoice numbers were assigned here.
+			u.status = ""
+		}
+		uploads = append(uploads, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return uploads, nil
+}
+
+// assignInvoiceNumbers gives each invoice without a number the next unused
+// number of the range and records it as submitted before any message is
+// written, so a number is never issued twice. Locking the range serializes
+// concurrent runs.
+func (s *Scheduler) assignInvoiceNumbers(ctx context.Context, numbers einvoice.NumberRange, invoices []einvoice.Invoice) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	first, last := numbers.Bounds()
+	var used string
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(invoice_number), '') FROM einvoice_uploads
+		WHERE invoice_number BETWEEN ? AND ? FOR UPDATE
+	`, first, last).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("reading last invoice number: %w", err)
+	}
+
+	for i := range invoices {
+		if invoices[i].Number != "" {
+			continue
+		}
+		next, ok := numbers.Next(used)
+		if !ok {
+			return errclass.ConfigError(fmt.Errorf("EINVOICE_INVOICE_NUMBERS %s-%s is used up", first, last))
+		}
+		invoices[i].Number, used = next, next
+		if err := recordUpload(ctx, tx, invoices[i], einvoice.StatusSubmitted); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing invoice numbers: %w", err)
+	}
+	return nil
+}
+
+// recordUpload stores the invoice's number and status; attempts counts the
+// messages written for it.
+func recordUpload(ctx context.Context, ex execer, inv einvoice.Invoice, status string) error {
+	var message any
+	if status == einvoice.StatusRejected {
+		message = "moved to ERR by Turnkey"
+	}
+	submitted := 0
+	if status == einvoice.StatusSubmitted {
+		submitted = 1
+	}
+	_, err := ex.ExecContext(ctx, `
+		INSERT INTO einvoice_uploads (invoice_date, c_idno2, invoice_number, upload_status, message, attempts)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			invoice_number = VALUES(invoice_number),
+			upload_status = VALUES(upload_status),
+			message = VALUES(message),
+			attempts = attempts + VALUES(attempts)
+	`, inv.InvoiceDate, inv.CustomerID, inv.Number, status, message, submitted)
+	if err != nil {
+		return fmt.Errorf("recording upload status: %w", err)
+	}
+	return nil
+}
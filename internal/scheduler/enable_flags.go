@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const flagRefreshSpec = "@every 30s"
+
+// Kinds of enable_flags rows.
+const (
+	flagJobType  = "job_type"
+	flagSite     = "site"
+	flagSchedule = "schedule"
+)
+
+// enableFlags caches the disabled rows of enable_flags. Anything without a
+// row is enabled.
+type enableFlags struct {
+	mu       sync.RWMutex
+	disabled map[string]bool
+}
+
+func flagKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+// enabled reports whether the job type, site or schedule called name is
+// switched on.
+func (s *Scheduler) enabled(kind, name string) bool {
+	s.flags.mu.RLock()
+	defer s.flags.mu.RUnlock()
+	return !s.flags.disabled[flagKey(kind, name)]
+}
+
+// jobEnabled reports whether job's type and, for golf-style jobs, its site
+// are switched on.
+func (s *Scheduler) jobEnabled(job CronJob) bool {
+	if !s.enabled(flagJobType, job.JobName) {
+		return false
+	}
+	site := jobSite(job)
+	return site == "" || s.enabled(flagSite, site)
+}
+
+// RefreshFlags reloads enable_flags, so operators can switch off a job type
+// (e.g. funeral_invoice_import while the ERP is in maintenance), a golf site
+// or a schedule with an UPDATE and no restart. Disabled job types and sites
+// keep their pending jobs queued, and disabled schedules create none, until
+// they are switched back on. On error the previous flags stay in effect.
+func (s *Scheduler) RefreshFlags() {
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+	if err := s.refreshFlags(ctx); err != nil {
+		s.logger.Error("Failed to refresh enable flags", "error", err)
+	}
+}
+
+func (s *Scheduler) refreshFlags(ctx context.Context) error {
+	disabled, err := s.store.DisabledFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.flags.mu.Lock()
+	defer s.flags.mu.Unlock()
+	for key := range disabled {
+		if !s.flags.disabled[key] {
+			s.logger.Info("Switched off", "flag", key)
+		}
+	}
+	for key := range s.flags.disabled {
+		if !disabled[key] {
+			s.logger.Info("Switched on", "flag", key)
+		}
+	}
+	s.flags.disabled = disabled
+	return nil
+}
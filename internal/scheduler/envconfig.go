@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseKeyValues parses comma-separated "key=value" pairs such as
+// "golf=3,oracle_proc=1". Empty entries are ignored.
+func parseKeyValues(raw string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(entry, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected key=value", entry)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
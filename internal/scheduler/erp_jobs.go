@@ -1,8 +1,11 @@
 package scheduler
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"hotbrandon/go-cron-be/internal/database"
+	"hotbrandon/go-cron-be/internal/errclass"
 	"time"
 )
 
@@ -15,44 +18,169 @@ type FuneralInvoiceRow struct {
 	TotalAmount int `json:"total_amount_dividint10"`
 }
 
-func GetFuneralInvoiceByDate(invoiceDate time.Time) ([]FuneralInvoiceRow, error) {
+// funeralInvoiceQuery reads the view ARGOERP.GOBO_P_UIBF062_V fills.
+const funeralInvoiceQuery = `
+		SELECT 
+			invoice_date,
+			c_idno2,
+			total_amount_dividint10
+		FROM GOBO_UIBF062_V2
+	`
+
+// openFuneralExtract runs the ERP procedure that prepares the extract for
+// invoiceDate and returns the session it ran in, so the view is read from the
+// same session. What the procedure prints with DBMS_OUTPUT goes to the run
+// log. The caller must call close.
+func openFuneralExtract(ctx context.Context, invoiceDate time.Time) (conn *sql.Conn, close func(), err error) {
 	// Get the ERP database connection
 	db, err := database.GetErpConnection()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	conn, err = db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to ERP: %w", err)
 	}
-	defer db.Close()
+	close = func() { conn.Close() }
 
 	// Pass the time.Time object directly. The driver will handle the conversion to Oracle's DATE type.
-	_, err = db.Exec("BEGIN ARGOERP.GOBO_P_UIBF062_V(:1); END;", invoiceDate)
+	if err := callProcedureLogged(ctx, conn, "ARGOERP.GOBO_P_UIBF062_V", invoiceDate); err != nil {
+		close()
+		return nil, nil, err
+	}
+	return conn, close, nil
+}
+
+// queryFuneralExtract prepares the extract and runs query on it, retrying
+// both on a fresh session after a transient error. Once rows have been read
+// the caller owns them and a failure is no longer retried.
+func queryFuneralExtract(ctx context.Context, invoiceDate time.Time, query string, args ...any) (rows *sql.Rows, close func(), err error) {
+	err = database.Retry(ctx, "ERP funeral invoice extract", func() error {
+		conn, closeConn, err := openFuneralExtract(ctx, invoiceDate)
+		if err != nil {
+			return err
+		}
+		rows, err = conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			closeConn()
+			return fmt.Errorf("querying GOBO_UIBF062_V2: %w", err)
+		}
+		close = func() {
+			rows.Close()
+			closeConn()
+		}
+		return nil
+	})
+	return rows, close, err
+}
+
+// EachFuneralInvoice streams the funeral invoices for invoiceDate from the
+// ERP to fn one row at a time, so a month-end extract is never held in
+// memory. An error from fn stops the scan and is returned as is.
+func EachFuneralInvoice(ctx context.Context, invoiceDate time.Time, fn func(FuneralInvoiceRow) error) error {
+	rows, close, err := queryFuneralExtract(ctx, invoiceDate, funeralInvoiceQuery)
 	if err != nil {
-		return nil, fmt.Errorf("calling ARGOERP.GOBO_P_UIBF062_V: %w", err)
+		return err
+	}
+	defer close()
+
+	for rows.Next() {
+		invoice, err := scanFuneralInvoice(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(invoice); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
 	}
 
+	return nil
+}
+
+// EachFuneralInvoicePage reads the extract for invoiceDate in pages of
+// pageSize rows in (invoice_date, c_idno2) order, skipping the first skip
+// rows, and passes each page to fn with the number of rows read so far. That
+// count is the checkpoint to resume from after a failure. Each page is a
+// separate ROWNUM-bounded query, so no cursor stays open between pages, and a
+// page that fails with a transient error is read again from a fresh extract.
+func EachFuneralInvoicePage(ctx context.Context, invoiceDate time.Time, skip, pageSize int, fn func(page []FuneralInvoiceRow, offset int) error) error {
 	query := `
-		SELECT 
-			invoice_date,
-			c_idno2,
-			total_amount_dividint10
-		FROM GOBO_UIBF062_V2
+		SELECT invoice_date, c_idno2, total_amount_dividint10 FROM (
+			SELECT v.*, ROWNUM rn FROM (
+				SELECT invoice_date, c_idno2, total_amount_dividint10
+				FROM GOBO_UIBF062_V2
+				ORDER BY invoice_date, c_idno2, total_amount_dividint10
+			) v WHERE ROWNUM <= :1
+		) WHERE rn > :2
 	`
-	rows, err := db.Query(query)
+	var conn *sql.Conn
+	close := func() {}
+	defer func() { close() }()
+
+	offset := skip
+	page := make([]FuneralInvoiceRow, 0, pageSize)
+	for {
+		err := database.Retry(ctx, "ERP funeral invoice page", func() error {
+			if conn == nil {
+				var err error
+				if conn, close, err = openFuneralExtract(ctx, invoiceDate); err != nil {
+					conn, close = nil, func() {}
+					return err
+				}
+			}
+			var err error
+			page, err = readFuneralInvoicePage(ctx, conn, query, offset, pageSize, page[:0])
+			if err != nil && errclass.IsTransientOracle(err) {
+				// The session may be gone; start over with a new extract.
+				close()
+				conn, close = nil, func() {}
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(page) == 0 {
+			return nil
+		}
+		offset += len(page)
+		if err := fn(page, offset); err != nil {
+			return err
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+	}
+}
+
+func readFuneralInvoicePage(ctx context.Context, conn *sql.Conn, query string, offset, pageSize int, page []FuneralInvoiceRow) ([]FuneralInvoiceRow, error) {
+	rows, err := conn.QueryContext(ctx, query, offset+pageSize, offset)
 	if err != nil {
-		return nil, fmt.Errorf("querying GOBO_UIBF062_V2: %w", err)
+		return page, fmt.Errorf("querying GOBO_UIBF062_V2 after row %d: %w", offset, err)
 	}
 	defer rows.Close()
 
-	var invoices []FuneralInvoiceRow
 	for rows.Next() {
-		var invoice FuneralInvoiceRow
-		if err := rows.Scan(&invoice.InvoiceDate, &invoice.CustomerID, &invoice.TotalAmount); err != nil {
-			return nil, fmt.Errorf("scanning row: %w", err)
+		invoice, err := scanFuneralInvoice(rows)
+		if err != nil {
+			return page, err
 		}
-		invoices = append(invoices, invoice)
+		page = append(page, invoice)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+		return page, fmt.Errorf("rows error: %w", err)
 	}
+	return page, nil
+}
 
-	return invoices, nil
+func scanFuneralInvoice(rows *sql.Rows) (FuneralInvoiceRow, error) {
+	var invoice FuneralInvoiceRow
+	if err := rows.Scan(&invoice.InvoiceDate, &invoice.CustomerID, &invoice.TotalAmount); err != nil {
+		return FuneralInvoiceRow{}, fmt.Errorf("scanning row: %w", err)
+	}
+	return invoice, nil
 }
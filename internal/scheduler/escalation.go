@@ -0,0 +1,308 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/notify"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	escalationSpec      = "@every 30s"
+	escalationBatchSize = 50
+	alertListLimit      = 100
+)
+
+// ErrUnknownAlert is returned for alert IDs that do not exist.
+var ErrUnknownAlert = errors.New("unknown alert")
+
+// EscalationStep notifies Channel After the previous step, unless the alert
+// has been acknowledged by then. The first step notifies right away.
+type EscalationStep struct {
+	Channel string
+	After   time.Duration
+	// notifier delivers to Channel without its event filter.
+	notifier notify.Notifier
+}
+
+// Alert is a failure of a job with an escalation policy, escalated step by
+// step until someone acknowledges it or the job is rerun successfully.
+type Alert struct {
+	AlertID int64  `json:"alert_id"`
+	JobID   int64  `json:"job_id"`
+	JobName string `json:"job_name"`
+	Subject string `json:"subject"`
+	// Step is the index of the last policy step notified.
+	Step             int        `json:"step"`
+	NextEscalationAt *time.Time `json:"next_escalation_at"`
+	AcknowledgedAt   *time.Time `json:"acknowledged_at"`
+	AcknowledgedBy   string     `json:"acknowledged_by,omitempty"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type escalationStepConfig struct {
+	Channel string `json:"channel"`
+	After   string `json:"after"`
+}
+
+// loadEscalationPolicies reads ESCALATION_POLICIES, a JSON object keyed by
+// job_name listing the channels to notify in turn when a job fails for good,
+// e.g. {"einvoice_upload": [{"channel": "line"}, {"channel": "teams",
+// "after": "15m"}, {"channel": "email", "after": "30m"}]}. Channels are
+// configured as for NOTIFY_CHANNELS but deliver regardless of their
+// <CHANNEL>_EVENTS.
+func (s *Scheduler) loadEscalationPolicies() error {
+	s.escalations = make(map[string][]EscalationStep)
+	raw := os.Getenv("ESCALATION_POLICIES")
+	if raw == "" {
+		return nil
+	}
+
+	var configs map[string][]escalationStepConfig
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&configs); err != nil {
+		return fmt.Errorf("parsing ESCALATION_POLICIES: %w", err)
+	}
+
+	channels := make(map[string]notify.Notifier)
+	for jobName, configs := range configs {
+		if len(configs) == 0 {
+			return fmt.Errorf("parsing ESCALATION_POLICIES: %s: no steps", jobName)
+		}
+		steps := make([]EscalationStep, len(configs))
+		for i, c := range configs {
+			step := EscalationStep{Channel: c.Channel}
+			if c.After != "" {
+				d, err := time.ParseDuration(c.After)
+				if err != nil || d < 0 {
+					return fmt.Errorf("parsing ESCALATION_POLICIES: %s: invalid after %q", jobName, c.After)
+				}
+				step.After = d
+			} else if i > 0 {
+				return fmt.Errorf("parsing ESCALATION_POLICIES: %s: step %d needs an after duration", jobName, i+1)
+			}
+
+			n, ok := channels[c.Channel]
+			if !ok {
+				var err error
+				if n, err = notify.Channel(c.Channel); err != nil {
+					return fmt.Errorf("parsing ESCALATION_POLICIES: %s: %w", jobName, err)
+				}
+				channels[c.Channel] = n
+			}
+			step.notifier = n
+			steps[i] = step
+		}
+		s.escalations[jobName] = steps
+	}
+	return nil
+}
+
+// escalationHook opens an alert when a job with an escalation policy fails
+// for good, and resolves its open alerts when a rerun finishes.
+func (s *Scheduler) escalationHook(ctx context.Context, ev RunEvent) {
+	steps, ok := s.escalations[ev.Job.JobName]
+	if !ok {
+		return
+	}
+	switch ev.Status {
+	case "failed":
+		s.openAlert(ctx, ev, steps)
+	case "finished":
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE alerts SET resolved_at = NOW(), next_escalation_at = NULL
+			WHERE job_id = ? AND resolved_at IS NULL AND acknowledged_at IS NULL
+		`, ev.Job.JobID)
+		if err != nil {
+			Logger(ctx).Error("Failed to resolve alerts", "error", err)
+		}
+	}
+}
+
+// openAlert records an alert for the failed run and notifies the first step.
+func (s *Scheduler) openAlert(ctx context.Context, ev RunEvent, steps []EscalationStep) {
+	subject := fmt.Sprintf("Job %s #%d failed", ev.Job.JobName, ev.Job.JobID)
+	body := fmt.Sprintf("Job %d (%s, job_date %s) failed after %s: %s", ev.Job.JobID, ev.Job.JobName, ev.Job.JobDate, ev.Elapsed.Round(time.Millisecond), ev.Message)
+
+	var next any // NULL for single-step policies
+	if len(steps) > 1 {
+		next = int64(steps[1].After.Seconds())
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO alerts (job_id, job_name, subject, body, next_escalation_at)
+		VALUES (?, ?, ?, ?, NOW() + INTERVAL ? SECOND)
+	`, ev.Job.JobID, ev.Job.JobName, subject, body, next)
+	if err != nil {
+		Logger(ctx).Error("Failed to open alert", "error", err)
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		Logger(ctx).Error("Failed to read alert id", "error", err)
+		return
+	}
+	Logger(ctx).Warn("Alert opened", "alert_id", id, "channel", steps[0].Channel)
+	s.sendAlert(id, ev.Job, subject, body, steps, 0)
+}
+
+// EscalateAlerts notifies the next step of unacknowledged alerts whose wait
+// is over. Each step is claimed with a conditional UPDATE, so it is sent
+// once even if several instances run this.
+func (s *Scheduler) EscalateAlerts() {
+	ctx := s.ctx
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT alert_id, job_id, job_name, subject, body, step
+		FROM alerts
+		WHERE next_escalation_at <= NOW() AND acknowledged_at IS NULL AND resolved_at IS NULL
+		ORDER BY alert_id
+		LIMIT ?
+	`, escalationBatchSize)
+	if err != nil {
+		s.logger.Error("Failed to query alerts to escalate", "error", err)
+		return
+	}
+	type due struct {
+		id            int64
+		job           CronJob
+		subject, body string
+		step          int
+	}
+	var alerts []due
+	for rows.Next() {
+		var a due
+		if err := rows.Scan(&a.id, &a.job.JobID, &a.job.JobName, &a.subject, &a.body, &a.step); err != nil {
+			rows.Close()
+			s.logger.Error("Failed to scan alert", "error", err)
+			return
+		}
+		alerts = append(alerts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		s.logger.Error("Failed to query alerts to escalate", "error", err)
+		return
+	}
+
+	for _, a := range alerts {
+		steps := s.escalations[a.job.JobName]
+		step := a.step + 1
+		if step >= len(steps) {
+			// The policy was shortened or removed since the alert opened.
+			if _, err := s.db.ExecContext(ctx, "UPDATE alerts SET next_escalation_at = NULL WHERE alert_id = ?", a.id); err != nil {
+				s.logger.Error("Failed to end alert escalation", "alert_id", a.id, "error", err)
+			}
+			continue
+		}
+		var next any // NULL after the last step
+		if step+1 < len(steps) {
+			next = int64(steps[step+1].After.Seconds())
+		}
+
+		result, err := s.db.ExecContext(ctx, `
+			UPDATE alerts SET step = ?, next_escalation_at = NOW() + INTERVAL ? SECOND
+			WHERE alert_id = ? AND step = ? AND acknowledged_at IS NULL AND resolved_at IS NULL
+		`, step, next, a.id, a.step)
+		if err != nil {
+			s.logger.Error("Failed to escalate alert", "alert_id", a.id, "error", err)
+			continue
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue // acknowledged or escalated meanwhile
+		}
+		s.logger.Warn("Alert escalated", "alert_id", a.id, "job_name", a.job.JobName, "step", step+1, "channel", steps[step].Channel)
+		s.sendAlert(a.id, a.job, a.subject, a.body, steps, step)
+	}
+}
+
+// sendAlert notifies step of an alert's policy.
+func (s *Scheduler) sendAlert(id int64, job CronJob, subject, body string, steps []EscalationStep, step int) {
+	if step > 0 {
+		subject = fmt.Sprintf("Unacknowledged (escalation %d of %d): %s", step+1, len(steps), subject)
+	}
+	body += fmt.Sprintf("\n\nAcknowledge alert %d to stop the escalation: POST /alerts/%d/ack", id, id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	err := steps[step].notifier.Notify(ctx, notify.Message{
+		Event:    "escalation",
+		Subject:  subject,
+		Body:     body,
+		Severity: notify.SeverityCritical,
+		JobName:  job.JobName,
+		JobID:    job.JobID,
+	})
+	if err != nil {
+		s.logger.Error("Failed to send alert", "alert_id", id, "channel", steps[step].Channel, "error", err)
+	}
+}
+
+// ListAlerts returns the latest alerts, newest first; with open only those
+// neither acknowledged nor resolved.
+func (s *Scheduler) ListAlerts(ctx context.Context, open bool) ([]Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts`
+	if open {
+		query += ` WHERE acknowledged_at IS NULL AND resolved_at IS NULL`
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY alert_id DESC LIMIT ?`, alertListLimit)
+	if err != nil {
+		return nil, fmt.Errorf("querying alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// GetAlert returns the alert with id, or ErrUnknownAlert.
+func (s *Scheduler) GetAlert(ctx context.Context, id int64) (Alert, error) {
+	a, err := scanAlert(s.db.QueryRowContext(ctx, `SELECT `+alertColumns+` FROM alerts WHERE alert_id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Alert{}, ErrUnknownAlert
+	}
+	return a, err
+}
+
+// AcknowledgeAlert stops the escalation of alert id on behalf of by.
+// Acknowledging an alert again keeps the first acknowledgment.
+func (s *Scheduler) AcknowledgeAlert(ctx context.Context, id int64, by string) (Alert, error) {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE alerts SET acknowledged_at = NOW(), acknowledged_by = ?, next_escalation_at = NULL
+		WHERE alert_id = ? AND acknowledged_at IS NULL
+	`, by, id)
+	if err != nil {
+		return Alert{}, fmt.Errorf("acknowledging alert: %w", err)
+	}
+	return s.GetAlert(ctx, id)
+}
+
+const alertColumns = `alert_id, job_id, job_name, subject, step, next_escalation_at,
+	acknowledged_at, acknowledged_by, resolved_at, created_at`
+
+func scanAlert(row interface{ Scan(...any) error }) (Alert, error) {
+	var a Alert
+	var by sql.NullString
+	err := row.Scan(&a.AlertID, &a.JobID, &a.JobName, &a.Subject, &a.Step, &a.NextEscalationAt,
+		&a.AcknowledgedAt, &by, &a.ResolvedAt, &a.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Alert{}, err
+		}
+		return Alert{}, fmt.Errorf("scanning alert: %w", err)
+	}
+	a.AcknowledgedBy = by.String
+	return a, nil
+}
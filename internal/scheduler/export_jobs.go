@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/export"
+	"hotbrandon/go-cron-be/internal/notify"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// funeralInvoiceHeader follows the column layout of accounting's template.
+var funeralInvoiceHeader = []string{"發票日期", "主事者ID", "含稅額(除以10)"}
+
+// FuneralXLSXParams are the job_params of a "funeral_invoice_xlsx" job.
+// InvoiceDate may use templates such as "{{yesterday}}"; OutputDir defaults
+// to EXPORT_DIR. When Recipients are given the workbook is also mailed to
+// them as an attachment.
+type FuneralXLSXParams struct {
+	InvoiceDate string   `json:"invoice_date"`
+	OutputDir   string   `json:"output_dir"`
+	Recipients  []string `json:"recipients,omitempty"`
+}
+
+func (p FuneralXLSXParams) Validate() error {
+	if p.InvoiceDate == "" {
+		return errors.New("invoice_date is required")
+	}
+	return nil
+}
+
+// WriteFuneralInvoicesXLSX streams the funeral invoices for invoiceDate from
+// the ERP into w as an .xlsx workbook. It returns the number of invoice rows
+// written.
+func (s *Scheduler) WriteFuneralInvoicesXLSX(ctx context.Context, w io.Writer, invoiceDate time.Time) (int, error) {
+	var count int
+	table := export.Table{
+		Header: funeralInvoiceHeader,
+		Stream: func(emit func(row []any) error) error {
+			return s.erp.EachFuneralInvoice(ctx, invoiceDate, func(inv FuneralInvoiceRow) error {
+				count++
+				return emit([]any{inv.InvoiceDate, inv.CustomerID, inv.TotalAmount})
+			})
+		},
+	}
+
+	if err := export.WriteXLSX(w, "發票明細", table); err != nil {
+		return 0, fmt.Errorf("writing xlsx: %w", err)
+	}
+	return count, nil
+}
+
+// WriteImportedFuneralInvoicesXLSX writes the funeral invoices for
+// invoiceDate (YYYY-MM-DD) already imported into funeral_invoices to w as an
+// .xlsx workbook. Unlike WriteFuneralInvoicesXLSX it only reads MySQL, so
+// serving it on demand does not run the ERP extract procedure. It returns the
+// number of invoice rows written.
+func (s *Scheduler) WriteImportedFuneralInvoicesXLSX(ctx context.Context, w io.Writer, invoiceDate string) (int, error) {
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT invoice_date, c_idno2, total_amount_dividint10
+		FROM funeral_invoices
+		WHERE invoice_date = ?
+		ORDER BY id
+	`, invoiceDate)
+	if err != nil {
+		return 0, fmt.Errorf("querying funeral_invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var count int
+	table := export.Table{
+		Header: funeralInvoiceHeader,
+		Stream: func(emit func(row []any) error) error {
+			for rows.Next() {
+				var inv FuneralInvoiceRow
+				if err := rows.Scan(&inv.InvoiceDate, &inv.CustomerID, &inv.TotalAmount); err != nil {
+					return fmt.Errorf("scanning row: %w", err)
+				}
+				count++
+				if err := emit([]any{inv.InvoiceDate, inv.CustomerID, inv.TotalAmount}); err != nil {
+					return err
+				}
+			}
+			return rows.Err()
+		},
+	}
+
+	if err := export.WriteXLSX(w, "發票明細", table); err != nil {
+		return 0, fmt.Errorf("writing xlsx: %w", err)
+	}
+	return count, nil
+}
+
+// runFuneralXLSXJob writes the export to <output_dir>/funeral_invoices_<date>.xlsx.
+func (s *Scheduler) runFuneralXLSXJob(ctx context.Context, job CronJob, params FuneralXLSXParams) (string, error) {
+	dateStr, err := expandTemplate(params.InvoiceDate, job)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("invoice_date: %w", err))
+	}
+	invoiceDate, err := time.ParseInLocation(dateLayout, dateStr, time.Local)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("invoice_date must be YYYY-MM-DD: %w", err))
+	}
+
+	dir := params.OutputDir
+	if dir == "" {
+		dir = exportDir()
+	}
+
+	var count int
+	path, err := writeExportFile(dir, fmt.Sprintf("funeral_invoices_%s.xlsx", dateStr), func(w io.Writer) error {
+		count, err = s.WriteFuneralInvoicesXLSX(ctx, w, invoiceDate)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := SetResult(ctx, map[string]any{"invoice_date": dateStr, "rows": count, "path": path}); err != nil {
+		return "", err
+	}
+	archived := s.archiveExport(ctx, path, xlsxContentType)
+	if len(params.Recipients) > 0 {
+		if err := s.mailExport(ctx, job, path, params.Recipients, dateStr, count); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("wrote %d invoices to %s%s", count, path, archived), nil
+}
+
+// mailExport sends the workbook at path to recipients.
+func (s *Scheduler) mailExport(ctx context.Context, job CronJob, path string, recipients []string, invoiceDate string, count int) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading export: %w", err)
+	}
+	err = s.notifier.Notify(ctx, notify.Message{
+		Event:       "funeral_invoice_xlsx",
+		Subject:     fmt.Sprintf("發票明細 %s", invoiceDate),
+		Body:        fmt.Sprintf("Funeral invoices for %s: %d rows, attached.", invoiceDate, count),
+		Severity:    notify.SeverityInfo,
+		JobName:     job.JobName,
+		JobID:       job.JobID,
+		Recipients:  recipients,
+		Attachments: []notify.Attachment{{Filename: filepath.Base(path), ContentType: xlsxContentType, Data: data}},
+	})
+	if err != nil {
+		return errclass.TransientError(fmt.Errorf("mailing export: %w", err))
+	}
+	return nil
+}
+
+// exportDir is where export jobs write files unless told otherwise.
+func exportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "exports"
+}
+
+// writeExportFile creates dir/name with the output of write. The file is
+// written under a temporary name first so downstream pickups never see a
+// partial file.
+func writeExportFile(dir, name string, write func(w io.Writer) error) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", errclass.ConfigError(fmt.Errorf("creating export dir: %w", err))
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return "", fmt.Errorf("creating export file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("publishing export file: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/notify"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAnomalyWeeks = 4
+	// minAnomalySamples is how many earlier weeks must have a result before
+	// a deviation is reported.
+	minAnomalySamples = 2
+)
+
+// loadAnomalyDetection reads GOLF_ANOMALY_THRESHOLD, the deviation in percent
+// from the trailing average that raises an alert (empty disables; GOLF_SITES
+// may set it per site), and
+// GOLF_ANOMALY_WEEKS, how many earlier same weekdays the average covers.
+func (s *Scheduler) loadAnomalyDetection() error {
+	s.anomalyThreshold = 0
+	s.anomalyWeeks = defaultAnomalyWeeks
+
+	if raw := os.Getenv("GOLF_ANOMALY_THRESHOLD"); raw != "" {
+		pct, err := strconv.ParseFloat(raw, 64)
+		if err != nil || pct <= 0 {
+			return fmt.Errorf("GOLF_ANOMALY_THRESHOLD must be a positive percentage, got %q", raw)
+		}
+		s.anomalyThreshold = pct
+	}
+	if raw := os.Getenv("GOLF_ANOMALY_WEEKS"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < minAnomalySamples {
+			return fmt.Errorf("GOLF_ANOMALY_WEEKS must be an integer of at least %d, got %q", minAnomalySamples, raw)
+		}
+		s.anomalyWeeks = n
+	}
+	return nil
+}
+
+// checkReservationAnomaly compares a site's summary with the average of the
+// same weekday in earlier weeks and alerts when AmtD, AmtM or AmtY deviate by
+// more than the site's threshold. A sudden zero usually means the Oracle view
+// broke.
+func (s *Scheduler) checkReservationAnomaly(ctx context.Context, job CronJob, params GolfParams, summary ReservationSummary) {
+	threshold := s.anomalyThresholdFor(params.DbID)
+	if threshold == 0 {
+		return
+	}
+
+	date, err := time.Parse(dateLayout, params.JobDate)
+	if err != nil {
+		return
+	}
+	history, err := s.reservationHistory(ctx, params.DbID, date)
+	if err != nil {
+		Logger(ctx).Warn("Failed to load reservation history", "error", err)
+		return
+	}
+	if len(history) < minAnomalySamples {
+		return
+	}
+
+	var avgD, avgM, avgY float64
+	for _, h := range history {
+		avgD += float64(h.AmtD)
+		avgM += float64(h.AmtM)
+		avgY += float64(h.AmtY)
+	}
+	n := float64(len(history))
+	avgD, avgM, avgY = avgD/n, avgM/n, avgY/n
+
+	var deviations []string
+	for _, c := range []struct {
+		name  string
+		value int
+		avg   float64
+	}{
+		{"AmtD", summary.AmtD, avgD},
+		{"AmtM", summary.AmtM, avgM},
+		{"AmtY", summary.AmtY, avgY},
+	} {
+		if c.avg == 0 {
+			continue
+		}
+		pct := math.Abs(float64(c.value)-c.avg) / c.avg * 100
+		if pct > threshold {
+			deviations = append(deviations, fmt.Sprintf("%s=%d is %.0f%% off the average %.1f", c.name, c.value, pct, c.avg))
+		}
+	}
+	if len(deviations) == 0 {
+		return
+	}
+
+	Logger(ctx).Warn("Reservation summary anomaly", "job_date", params.JobDate, "deviations", deviations)
+	if s.golfSites[params.DbID].quiet(s.now()) {
+		Logger(ctx).Info("Anomaly alert suppressed during quiet hours")
+		return
+	}
+	s.notify(notify.Message{
+		Event:   "golf_anomaly",
+		Subject: fmt.Sprintf("Unusual golf reservations for %s on %s", params.DbID, params.JobDate),
+		Body: fmt.Sprintf("Compared with the last %d %ss at %s (threshold %.0f%%): %s.",
+			len(history), date.Weekday(), params.DbID, threshold, strings.Join(deviations, "; ")),
+		Severity: notify.SeverityWarning,
+		JobName:  job.JobName,
+		JobID:    job.JobID,
+	})
+}
+
+// reservationHistory loads the stored summaries of site for the same weekday
+// in the weeks before date.
+func (s *Scheduler) reservationHistory(ctx context.Context, site string, date time.Time) ([]ReservationSummary, error) {
+	dates := make([]any, s.anomalyWeeks)
+	for i := range dates {
+		dates[i] = date.AddDate(0, 0, -7*(i+1)).Format(dateLayout)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT job_result FROM cron_jobs
+		WHERE job_name = 'golf' AND job_status = 'finished' AND job_result IS NOT NULL
+			AND JSON_UNQUOTE(JSON_EXTRACT(job_params, '$.db_id')) = ?
+			AND JSON_UNQUOTE(JSON_EXTRACT(job_params, '$.job_date')) IN (`+placeholders(len(dates))+`)
+	`, append([]any{site}, dates...)...)
+	if err != nil {
+		return nil, fmt.Errorf("querying golf results: %w", err)
+	}
+	defer rows.Close()
+
+	var history []ReservationSummary
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		var summary ReservationSummary
+		if err := json.Unmarshal(raw, &summary); err != nil {
+			continue
+		}
+		history = append(history, summary)
+	}
+	return history, rows.Err()
+}
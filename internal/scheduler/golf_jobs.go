@@ -1,24 +1,42 @@
 package scheduler
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"hotbrandon/go-cron-be/internal/database"
 	"time"
 )
 
+// GolfParams are the job_params of a "golf" job.
+type GolfParams struct {
+	DbID    string `json:"db_id"`
+	JobDate string `json:"job_date"`
+}
+
+func (p GolfParams) Validate() error {
+	if p.DbID == "" {
+		return errors.New("db_id is required")
+	}
+	if _, err := time.Parse("2006-01-02", p.JobDate); err != nil {
+		return fmt.Errorf("job_date must be YYYY-MM-DD: %w", err)
+	}
+	return nil
+}
+
 type ReservationSummary struct {
-	DataName string
-	AmtD     int
-	AmtM     int
-	AmtY     int
+	DataName string `json:"data_name"`
+	AmtD     int    `json:"amt_d"`
+	AmtM     int    `json:"amt_m"`
+	AmtY     int    `json:"amt_y"`
 }
 
-func GetReservationSummary(site_id string, resvDate time.Time) (ReservationSummary, error) {
+func GetReservationSummary(ctx context.Context, site_id string, resvDate time.Time) (ReservationSummary, error) {
 	db, err := database.GetGolfConnection(site_id)
 	if err != nil {
 		return ReservationSummary{}, err
 	}
-	defer db.Close()
 
 	// Calculate date ranges based on the input resvDate
 	year, month, _ := resvDate.Date()
@@ -59,13 +77,15 @@ func GetReservationSummary(site_id string, resvDate time.Time) (ReservationSumma
 	var summary ReservationSummary
 	// Use sql.Named to pass parameters by name, which is supported by the Oracle driver.
 	// The driver will handle the time.Time to Oracle DATE conversion.
-	err = db.QueryRow(query,
-		sql.Named("resv_date", resvDate),
-		sql.Named("resv_date_mb", firstOfMonth),
-		sql.Named("resv_date_me", lastOfMonth),
-		sql.Named("resv_date_yb", firstOfYear),
-		sql.Named("resv_date_ye", lastOfYear),
-	).Scan(&summary.DataName, &summary.AmtD, &summary.AmtM, &summary.AmtY)
+	err = database.Retry(ctx, "golf reservation summary", func() error {
+		return db.QueryRowContext(ctx, query,
+			sql.Named("resv_date", resvDate),
+			sql.Named("resv_date_mb", firstOfMonth),
+			sql.Named("resv_date_me", lastOfMonth),
+			sql.Named("resv_date_yb", firstOfYear),
+			sql.Named("resv_date_ye", lastOfYear),
+		).Scan(&summary.DataName, &summary.AmtD, &summary.AmtM, &summary.AmtY)
+	})
 
 	if err != nil {
 		return ReservationSummary{}, err
@@ -73,3 +93,20 @@ func GetReservationSummary(site_id string, resvDate time.Time) (ReservationSumma
 
 	return summary, nil
 }
+
+// runGolfJob fetches the reservation summary for one site and date.
+func (s *Scheduler) runGolfJob(ctx context.Context, job CronJob, params GolfParams) (string, error) {
+	// job_date was already checked by GolfParams.Validate.
+	jobDate, _ := time.Parse("2006-01-02", params.JobDate)
+
+	summary, err := s.golf.ReservationSummary(ctx, params.DbID, jobDate)
+	if err != nil {
+		return "", fmt.Errorf("getting reservation summary for %s: %w", params.DbID, err)
+	}
+	Logger(ctx).Info("Successfully ran golf job", "summary", summary)
+	if err := SetResult(ctx, summary); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s: day=%d month=%d year=%d", summary.DataName, summary.AmtD, summary.AmtM, summary.AmtY), nil
+}
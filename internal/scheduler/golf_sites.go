@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// GolfSite holds the alerting settings of one golf course. The courses have
+// very different booking volumes, so one threshold does not fit all.
+type GolfSite struct {
+	// AnomalyThreshold overrides GOLF_ANOMALY_THRESHOLD for the site, in
+	// percent.
+	AnomalyThreshold float64 `json:"anomaly_threshold"`
+	// QuietHours is a local time range such as "22:00-07:00" during which the
+	// site's warnings are logged but not sent.
+	QuietHours string `json:"quiet_hours"`
+
+	quietFrom, quietTo time.Duration
+}
+
+// loadGolfSites reads GOLF_SITES, a JSON object keyed by site, e.g.
+// {"GC": {"anomaly_threshold": 30}, "OS": {"anomaly_threshold": 80, "quiet_hours": "22:00-07:00"}}.
+func (s *Scheduler) loadGolfSites() error {
+	s.golfSites = nil
+	raw := os.Getenv("GOLF_SITES")
+	if raw == "" {
+		return nil
+	}
+
+	var sites map[string]GolfSite
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sites); err != nil {
+		return fmt.Errorf("parsing GOLF_SITES: %w", err)
+	}
+
+	for id, site := range sites {
+		if !slices.Contains(golfSites, id) {
+			return fmt.Errorf("parsing GOLF_SITES: unknown site %q, expected one of %v", id, golfSites)
+		}
+		if site.AnomalyThreshold < 0 {
+			return fmt.Errorf("parsing GOLF_SITES: %s: anomaly_threshold must not be negative", id)
+		}
+		if site.QuietHours != "" {
+			from, to, ok := strings.Cut(site.QuietHours, "-")
+			var err error
+			if ok {
+				if site.quietFrom, err = parseTimeOfDay(from); err == nil {
+					site.quietTo, err = parseTimeOfDay(to)
+				}
+			}
+			if !ok || err != nil {
+				return fmt.Errorf("parsing GOLF_SITES: %s: quiet_hours must look like 22:00-07:00, got %q", id, site.QuietHours)
+			}
+		}
+		sites[id] = site
+	}
+	s.golfSites = sites
+	return nil
+}
+
+// parseTimeOfDay parses "15:04" as an offset from midnight.
+func parseTimeOfDay(raw string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(raw))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// quiet reports whether t falls in the site's quiet hours. A range whose end
+// is before its start spans midnight.
+func (g GolfSite) quiet(t time.Time) bool {
+	if g.QuietHours == "" {
+		return false
+	}
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if g.quietFrom <= g.quietTo {
+		return offset >= g.quietFrom && offset < g.quietTo
+	}
+	return offset >= g.quietFrom || offset < g.quietTo
+}
+
+// anomalyThresholdFor returns the site's anomaly threshold, falling back to
+// GOLF_ANOMALY_THRESHOLD. Zero means anomalies are not checked.
+func (s *Scheduler) anomalyThresholdFor(site string) float64 {
+	if g, ok := s.golfSites[site]; ok && g.AnomalyThreshold > 0 {
+		return g.AnomalyThreshold
+	}
+	return s.anomalyThreshold
+}
@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// handoffWatchSpec is how often standbys look for a leader handing off.
+	handoffWatchSpec = "@every 2s"
+	// defaultCatchUpWindow covers a leader change that takes up to leaderTTL
+	// with room to spare.
+	defaultCatchUpWindow = 5 * time.Minute
+)
+
+// loadCatchUpWindow reads SCHEDULE_CATCHUP_WINDOW (a Go duration such as
+// "5m"): how long after its fire time a schedule that no leader ran is
+// still run by the next one. 0 disables catching up.
+func (s *Scheduler) loadCatchUpWindow() error {
+	s.catchUpWindow = defaultCatchUpWindow
+	raw := os.Getenv("SCHEDULE_CATCHUP_WINDOW")
+	if raw == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("SCHEDULE_CATCHUP_WINDOW must be a non-negative duration, got %q", raw)
+	}
+	s.catchUpWindow = d
+	return nil
+}
+
+// handOff gives up leadership on shutdown, after the cron has stopped and
+// before running jobs are drained, and records the handoff so standbys
+// campaign within handoffWatchSpec instead of waiting out leaderSpec. The
+// successor cannot double-fire a schedule since this instance creates no
+// more jobs, and catches up any firing that fell in between.
+func (s *Scheduler) handOff() {
+	if !s.IsLeader() {
+		return
+	}
+	s.resign()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO leader_handoffs (lock_name, handed_off_at) VALUES (?, NOW(3))
+		ON DUPLICATE KEY UPDATE handed_off_at = VALUES(handed_off_at)
+	`, leaderLockName)
+	if err != nil {
+		s.logger.Warn("Failed to signal leader handoff", "error", err)
+		return
+	}
+	s.logger.Info("Handed off scheduler leadership")
+}
+
+// WatchHandoff campaigns at once when the leader has handed off since the
+// last check. Leaders skip it.
+func (s *Scheduler) WatchHandoff() {
+	if s.IsLeader() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+	var at time.Time
+	err := s.db.QueryRowContext(ctx, "SELECT handed_off_at FROM leader_handoffs WHERE lock_name = ?", leaderLockName).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		s.logger.Warn("Failed to check for leader handoff", "error", err)
+		return
+	}
+	if !at.After(s.handoffSeen) {
+		return
+	}
+	// The first check also sees handoffs from before this instance started;
+	// an extra campaign is harmless.
+	s.handoffSeen = at
+	s.logger.Debug("Leader handed off, campaigning", "handed_off_at", at)
+	s.campaign()
+}
+
+// recordFire notes that schedule name fired, for catchUpSchedules.
+func (s *Scheduler) recordFire(name string) {
+	_, err := s.db.Exec(`
+		INSERT INTO schedule_fires (name, fired_at) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE fired_at = VALUES(fired_at)
+	`, name, s.now())
+	if err != nil {
+		s.logger.Warn("Failed to record schedule fire", "schedule", name, "error", err)
+	}
+}
+
+// catchUpSchedules runs, on becoming leader, every schedule whose next fire
+// time after it last fired on any instance has passed within catchUpWindow,
+// e.g. noon falling between the old leader stopping and this one taking
+// over. Schedules that never fired are left alone. Creating jobs is
+// idempotent, so a firing the old leader did make only finds its jobs.
+func (s *Scheduler) catchUpSchedules() {
+	if s.catchUpWindow == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	fired, err := s.scheduleFires(ctx)
+	cancel()
+	if err != nil {
+		s.logger.Error("Failed to read schedule fires", "error", err)
+		return
+	}
+
+	s.scheduleMu.Lock()
+	entries := slices.Clone(s.scheduleEntries)
+	s.scheduleMu.Unlock()
+
+	now := s.now()
+	for _, e := range entries {
+		last, ok := fired[e.Name]
+		if !ok {
+			continue
+		}
+		schedule, err := cron.ParseStandard(s.resolveSchedule(e.Spec))
+		if err != nil {
+			continue
+		}
+		due := schedule.Next(last.In(s.c.Location()))
+		if due.IsZero() || due.After(now) || now.Sub(due) > s.catchUpWindow {
+			continue
+		}
+		entry := s.c.Entry(e.ID)
+		if entry.ID == 0 {
+			// removed since the copy was taken
+			continue
+		}
+		s.logger.Warn("Catching up missed schedule", "schedule", e.Name, "due", due, "last_fired", last)
+		entry.WrappedJob.Run()
+	}
+}
+
+// scheduleFires reads when each schedule last fired.
+func (s *Scheduler) scheduleFires(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, fired_at FROM schedule_fires")
+	if err != nil {
+		return nil, fmt.Errorf("querying schedule_fires: %w", err)
+	}
+	defer rows.Close()
+
+	fired := map[string]time.Time{}
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		fired[name] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return fired, nil
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	heartbeatInterval = 30 * time.Second
+	// defaultStaleAfter must comfortably exceed heartbeatInterval.
+	defaultStaleAfter = 5 * time.Minute
+	reaperSpec        = "@every 1m"
+)
+
+// loadStaleAfter reads STALE_JOB_TIMEOUT (a Go duration such as "5m").
+func (s *Scheduler) loadStaleAfter() error {
+	s.staleAfter = defaultStaleAfter
+	raw := os.Getenv("STALE_JOB_TIMEOUT")
+	if raw == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 2*heartbeatInterval {
+		return fmt.Errorf("STALE_JOB_TIMEOUT must be a duration of at least %s, got %q", 2*heartbeatInterval, raw)
+	}
+	s.staleAfter = d
+	return nil
+}
+
+// heartbeat refreshes heartbeat_at for a running job until ctx is done.
+func (s *Scheduler) heartbeat(ctx context.Context, jobID int64) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.store.Heartbeat(ctx, jobID); err != nil {
+				s.logger.Warn("Failed to update job heartbeat", "job_id", jobID, "error", err)
+			}
+		}
+	}
+}
+
+// ReapStaleJobs resets running jobs whose heartbeat is older than the stale
+// timeout back to pending, e.g. after a worker crashed mid-run. Their open
+// run records are marked abandoned.
+func (s *Scheduler) ReapStaleJobs() {
+	staleSeconds := int64(s.staleAfter.Seconds())
+
+	_, err := s.db.Exec(`
+		UPDATE job_runs r
+		JOIN cron_jobs c ON c.job_id = r.job_id
+		SET r.run_status = 'abandoned', r.finished_at = NOW()
+		WHERE r.run_status = 'running'
+			AND c.job_status = 'running'
+			AND COALESCE(c.heartbeat_at, c.updated_at) < NOW() - INTERVAL ? SECOND
+	`, staleSeconds)
+	if err != nil {
+		s.logger.Error("Failed to mark stale runs abandoned", "error", err)
+		return
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE cron_jobs
+		SET job_status = 'pending', message = 'reset by reaper: heartbeat stale'
+		WHERE job_status = 'running'
+			AND COALESCE(heartbeat_at, updated_at) < NOW() - INTERVAL ? SECOND
+	`, staleSeconds)
+	if err != nil {
+		s.logger.Error("Failed to reset stale jobs", "error", err)
+		return
+	}
+
+	if reset, _ := result.RowsAffected(); reset > 0 {
+		s.logger.Warn("Reset stale running jobs to pending", "count", reset, "stale_after", s.staleAfter)
+	}
+}
+
+// livenessSpec is how often the cron loop records that it is still running.
+const livenessSpec = "@every 10s"
+
+// tick records that the cron loop is alive.
+func (s *Scheduler) tick() {
+	s.lastTick.Store(time.Now().UnixNano())
+}
+
+// Alive reports whether the cron loop has ticked within maxAge. It backs
+// the systemd watchdog, so a wedged scheduler gets restarted.
+func (s *Scheduler) Alive(maxAge time.Duration) bool {
+	last := s.lastTick.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < maxAge
+}
+
+// Ready reports why the scheduler cannot take work, or nil if it can: the
+// cron loop must be ticking and MySQL must answer.
+func (s *Scheduler) Ready(ctx context.Context) error {
+	if !s.Alive(time.Minute) {
+		return errors.New("scheduler loop has not ticked in the last minute")
+	}
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("mysql: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"time"
+)
+
+// HookPoint is a point in a run's lifecycle that hooks can subscribe to.
+type HookPoint string
+
+const (
+	// BeforeRun fires after a job is claimed and its run recorded, just
+	// before the handler is called.
+	BeforeRun HookPoint = "before_run"
+	// AfterRun fires once the outcome of every run is recorded, whether it
+	// finished, failed or will be retried.
+	AfterRun HookPoint = "after_run"
+	// OnFailure fires before AfterRun for runs that returned an error,
+	// including those that will be retried.
+	OnFailure HookPoint = "on_failure"
+)
+
+// RunEvent describes the run a hook fires for. Before the run only Job and
+// RunID are set.
+type RunEvent struct {
+	Job   CronJob
+	RunID int64
+	// Status is finished, failed or retrying.
+	Status   string
+	Category errclass.Category
+	Message  string
+	Elapsed  time.Duration
+	Err      error
+}
+
+// Hook handles a lifecycle event. ctx carries the run's Logger; after the run
+// it is no longer cancelled with the run.
+type Hook func(ctx context.Context, ev RunEvent)
+
+// AddHook subscribes hook to point, for cross-cutting behavior such as
+// metrics, alerts or cache invalidation that should not live in each
+// handler. Hooks run in the order they were added, on the worker running the
+// job, so slow ones should hand off their work. Add hooks before Start.
+func (s *Scheduler) AddHook(point HookPoint, hook Hook) {
+	if s.hooks == nil {
+		s.hooks = map[HookPoint][]Hook{}
+	}
+	s.hooks[point] = append(s.hooks[point], hook)
+}
+
+// fireHooks calls the hooks for point. A panicking hook is logged and does
+// not stop the others or the worker.
+func (s *Scheduler) fireHooks(ctx context.Context, point HookPoint, ev RunEvent) {
+	for _, hook := range s.hooks[point] {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					Logger(ctx).Error("Hook panicked", "hook_point", point, "panic", r)
+				}
+			}()
+			hook(ctx, ev)
+		}()
+	}
+}
+
+// registerHooks subscribes the built-in hooks.
+func (s *Scheduler) registerHooks() {
+	s.AddHook(AfterRun, s.golfAnomalyHook)
+	s.AddHook(AfterRun, s.metricsHook)
+	s.AddHook(AfterRun, s.escalationHook)
+}
+
+// golfAnomalyHook checks each finished golf run's summary against earlier
+// weeks, see checkReservationAnomaly.
+func (s *Scheduler) golfAnomalyHook(ctx context.Context, ev RunEvent) {
+	if ev.Job.JobName != "golf" || ev.Status != "finished" || len(ev.Job.Result) == 0 {
+		return
+	}
+	var params GolfParams
+	if err := DecodeParams(ev.Job.JobParams, &params); err != nil {
+		return
+	}
+	var summary ReservationSummary
+	if err := json.Unmarshal(ev.Job.Result, &summary); err != nil {
+		return
+	}
+	s.checkReservationAnomaly(ctx, ev.Job, params, summary)
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHTTPTimeout = 30 * time.Second
+	// maxHTTPResponseBody caps how much of the response body is recorded.
+	maxHTTPResponseBody = 16 * 1024
+)
+
+// HTTPParams are the job_params of an "http" job. URL, header values and Body
+// may use Go templates such as {{.JobDate}}.
+type HTTPParams struct {
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body"`
+	TimeoutSeconds int               `json:"timeout_seconds"`
+}
+
+func (p HTTPParams) Validate() error {
+	if p.URL == "" {
+		return errors.New("url is required")
+	}
+	if p.TimeoutSeconds < 0 {
+		return errors.New("timeout_seconds must not be negative")
+	}
+	return nil
+}
+
+// runHTTPJob performs the configured request and records the response. Any
+// non-2xx status fails the job.
+func (s *Scheduler) runHTTPJob(ctx context.Context, job CronJob, params HTTPParams) (string, error) {
+	rawURL, err := expandTemplate(params.URL, job)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("url: %w", err))
+	}
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", errclass.DataError(fmt.Errorf("url %q must be an absolute http(s) URL", rawURL))
+	}
+
+	body, err := expandTemplate(params.Body, job)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("body: %w", err))
+	}
+
+	method := strings.ToUpper(params.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	timeout := defaultHTTPTimeout
+	if params.TimeoutSeconds > 0 {
+		timeout = time.Duration(params.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, strings.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("building request: %w", err)
+	}
+	for name, value := range params.Headers {
+		expanded, err := expandTemplate(value, job)
+		if err != nil {
+			return "", errclass.DataError(fmt.Errorf("header %s: %w", name, err))
+		}
+		req.Header.Set(name, expanded)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", method, rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseBody))
+	if err != nil {
+		return "", fmt.Errorf("reading response body: %w", err)
+	}
+
+	output := fmt.Sprintf("%s %s -> %s\n%s", method, rawURL, resp.Status, respBody)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return output, errclass.TransientError(fmt.Errorf("%s %s returned %s", method, rawURL, resp.Status))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return output, fmt.Errorf("%s %s returned %s", method, rawURL, resp.Status)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,395 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/notify"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FuneralImportParams are the job_params of a "funeral_invoice_import" job.
+// InvoiceDate may use templates such as "{{yesterday}}".
+// A ChunkSize above zero reads the extract in pages of that many rows and
+// commits each page with a checkpoint, for extracts too large for one
+// transaction.
+type FuneralImportParams struct {
+	InvoiceDate string `json:"invoice_date"`
+	ChunkSize   int    `json:"chunk_size"`
+}
+
+func (p FuneralImportParams) Validate() error {
+	if p.InvoiceDate == "" {
+		return errors.New("invoice_date is required")
+	}
+	if p.ChunkSize < 0 {
+		return errors.New("chunk_size must not be negative")
+	}
+	return nil
+}
+
+// runFuneralImportJob copies the date's funeral invoices from the ERP into
+// funeral_invoices. Rows failing INVOICE_RULES go to
+// funeral_invoice_quarantine instead, and accounting is notified. An amount
+// that differs from the stored one is recorded in
+// funeral_invoice_discrepancies and alerted; INVOICE_CONFLICT_ACTION decides
+// whether the stored amount is kept (hold, the default) or replaced. A dry
+// run does all of this in transactions that are rolled back, so its counts
+// are what a real import would do, and sends no alerts.
+func (s *Scheduler) runFuneralImportJob(ctx context.Context, job CronJob, params FuneralImportParams) (string, error) {
+	dateStr, err := expandTemplate(params.InvoiceDate, job)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("invoice_date: %w", err))
+	}
+	invoiceDate, err := time.ParseInLocation(dateLayout, dateStr, time.Local)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("invoice_date must be YYYY-MM-DD: %w", err))
+	}
+
+	imp := &invoiceImport{s: s, job: job, counts: invoiceImportCounts{InvoiceDate: dateStr}}
+	if params.ChunkSize > 0 {
+		err = imp.runPaged(ctx, invoiceDate, params.ChunkSize)
+	} else {
+		err = imp.run(ctx, invoiceDate)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	counts := imp.counts
+	if DryRun(ctx) {
+		Logger(ctx).Info("Dry run: not sending alerts", "quarantined", counts.Quarantined, "conflicts", counts.Held+counts.Updated)
+	} else {
+		if counts.Quarantined > 0 {
+			s.notifyQuarantine(ctx, job, dateStr, counts.Quarantined, imp.invalid)
+		}
+		if conflicts := counts.Held + counts.Updated; conflicts > 0 {
+			s.notifyDiscrepancies(ctx, job, dateStr, conflicts, imp.conflicts)
+		}
+	}
+
+	if err := SetResult(ctx, counts); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("invoice_date=%s fetched=%d inserted=%d updated=%d unchanged=%d held=%d quarantined=%d",
+		dateStr, counts.Fetched, counts.Inserted, counts.Updated, counts.Unchanged, counts.Held, counts.Quarantined), nil
+}
+
+// maxListedInvoices is how many rows quarantine and discrepancy alerts list.
+const maxListedInvoices = 50
+
+// run loads the whole day in one transaction, so a failure part way through
+// never leaves a half-loaded day behind. Rows are streamed from the ERP and
+// persisted in chunks, so memory stays bounded.
+func (imp *invoiceImport) run(ctx context.Context, invoiceDate time.Time) error {
+	tx, err := imp.s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	imp.tx = tx
+	err = imp.s.erp.EachFuneralInvoice(ctx, invoiceDate, func(row FuneralInvoiceRow) error {
+		imp.chunk = append(imp.chunk, row)
+		if len(imp.chunk) >= imp.s.insertBatchSize {
+			return imp.flush(ctx)
+		}
+		return nil
+	})
+	if err == nil {
+		err = imp.flush(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if DryRun(ctx) {
+		Logger(ctx).Info("Dry run: rolling back invoices", "counts", imp.counts)
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing invoices: %w", err)
+	}
+	return nil
+}
+
+// invoiceCheckpoint is the progress of a paged import: the ERP rows read so
+// far and the counts up to them.
+type invoiceCheckpoint struct {
+	Offset int                 `json:"offset"`
+	Counts invoiceImportCounts `json:"counts"`
+}
+
+// runPaged reads the extract in pages of pageSize rows and commits each page
+// in its own transaction together with a checkpoint, so a retry resumes after
+// the last committed page instead of starting over. Unlike run, a failure
+// leaves the day partly loaded until the retry completes it. Resuming relies
+// on the ERP returning the same rows in the same order; rows re-read after a
+// shift are harmless since the write is an upsert.
+func (imp *invoiceImport) runPaged(ctx context.Context, invoiceDate time.Time, pageSize int) error {
+	s := imp.s
+	var cp invoiceCheckpoint
+	resumed, err := s.loadCheckpoint(ctx, imp.job.JobID, &cp)
+	if err != nil {
+		return err
+	}
+	if resumed {
+		Logger(ctx).Info("Resuming funeral invoice import", "offset", cp.Offset)
+		imp.counts = cp.Counts
+	}
+
+	err = s.erp.EachFuneralInvoicePage(ctx, invoiceDate, cp.Offset, pageSize, func(page []FuneralInvoiceRow, offset int) error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("beginning transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		imp.tx = tx
+		for chunk := range slices.Chunk(page, s.insertBatchSize) {
+			imp.chunk = append(imp.chunk[:0], chunk...)
+			if err := imp.flush(ctx); err != nil {
+				return err
+			}
+		}
+		if DryRun(ctx) {
+			Logger(ctx).Info("Dry run: rolling back invoices", "offset", offset, "counts", imp.counts)
+			return nil
+		}
+		if err := saveCheckpoint(ctx, tx, imp.job.JobID, invoiceCheckpoint{Offset: offset, Counts: imp.counts}); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("committing invoices up to row %d: %w", offset, err)
+		}
+		return nil
+	})
+	if err != nil || DryRun(ctx) {
+		return err
+	}
+	return clearCheckpoint(ctx, s.db, imp.job.JobID)
+}
+
+// invoiceImport persists one extract chunk by chunk within tx.
+type invoiceImport struct {
+	s      *Scheduler
+	tx     *sql.Tx
+	job    CronJob
+	counts invoiceImportCounts
+	chunk  []FuneralInvoiceRow
+	// invalid and conflicts keep the first rows of each for the alerts
+	invalid   []invoiceViolation
+	conflicts []invoiceDiscrepancy
+}
+
+// flush validates and writes the buffered chunk.
+func (imp *invoiceImport) flush(ctx context.Context) error {
+	if len(imp.chunk) == 0 {
+		return nil
+	}
+	s := imp.s
+	imp.counts.Fetched += len(imp.chunk)
+
+	valid, invalid := s.validateInvoices(imp.chunk)
+	imp.chunk = imp.chunk[:0]
+	imp.counts.Quarantined += len(invalid)
+	imp.invalid = appendListed(imp.invalid, invalid...)
+	if err := s.quarantineInvoices(ctx, imp.tx, imp.job, invalid); err != nil {
+		return err
+	}
+
+	existing, err := storedInvoiceAmounts(ctx, imp.tx, valid)
+	if err != nil {
+		return err
+	}
+
+	var writes [][]any
+	var conflicts []invoiceDiscrepancy
+	for _, row := range valid {
+		key := invoiceKey{row.InvoiceDate, row.CustomerID}
+		old, ok := existing[key]
+		switch {
+		case !ok:
+			imp.counts.Inserted++
+		case old == row.TotalAmount:
+			imp.counts.Unchanged++
+			continue
+		default:
+			conflicts = append(conflicts, invoiceDiscrepancy{Row: row, OldAmount: old})
+			if !s.overwriteConflicts {
+				imp.counts.Held++
+				continue
+			}
+			imp.counts.Updated++
+		}
+		writes = append(writes, []any{row.InvoiceDate, row.CustomerID, row.TotalAmount})
+		existing[key] = row.TotalAmount
+	}
+	imp.conflicts = appendListed(imp.conflicts, conflicts...)
+
+	if err := s.recordDiscrepancies(ctx, imp.tx, imp.job, conflicts); err != nil {
+		return err
+	}
+	// Rows repeated within the extract resolve through the upsert.
+	err = s.insertBatched(ctx, imp.tx,
+		"INSERT INTO funeral_invoices (invoice_date, c_idno2, total_amount_dividint10)",
+		"ON DUPLICATE KEY UPDATE total_amount_dividint10 = VALUES(total_amount_dividint10)",
+		writes)
+	if err != nil {
+		return fmt.Errorf("saving invoices: %w", err)
+	}
+	return nil
+}
+
+// appendListed appends items to list up to maxListedInvoices entries.
+func appendListed[T any](list []T, items ...T) []T {
+	n := min(len(items), maxListedInvoices-len(list))
+	if n <= 0 {
+		return list
+	}
+	return append(list, items[:n]...)
+}
+
+// invoiceImportCounts is the outcome of an import, stored as its job result.
+// Held rows changed amount in the ERP but kept the stored amount.
+type invoiceImportCounts struct {
+	InvoiceDate string `json:"invoice_date"`
+	Fetched     int    `json:"fetched"`
+	Inserted    int    `json:"inserted"`
+	Updated     int    `json:"updated"`
+	Unchanged   int    `json:"unchanged"`
+	Held        int    `json:"held"`
+	Quarantined int    `json:"quarantined"`
+}
+
+type invoiceKey struct {
+	invoiceDate string
+	customerID  string
+}
+
+// storedInvoiceAmounts loads the amounts already in funeral_invoices for
+// rows, locking them until tx ends.
+func storedInvoiceAmounts(ctx context.Context, tx *sql.Tx, rows []FuneralInvoiceRow) (map[invoiceKey]int, error) {
+	amounts := make(map[invoiceKey]int)
+	if len(rows) == 0 {
+		return amounts, nil
+	}
+
+	args := make([]any, 0, 2*len(rows))
+	for _, row := range rows {
+		args = append(args, row.InvoiceDate, row.CustomerID)
+	}
+	result, err := tx.QueryContext(ctx, `
+		SELECT invoice_date, c_idno2, total_amount_dividint10
+		FROM funeral_invoices
+		WHERE (invoice_date, c_idno2) IN (`+strings.TrimSuffix(strings.Repeat("(?,?),", len(rows)), ",")+`)
+		FOR UPDATE
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying funeral_invoices: %w", err)
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var key invoiceKey
+		var amount int
+		if err := result.Scan(&key.invoiceDate, &key.customerID, &amount); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		amounts[key] = amount
+	}
+	return amounts, result.Err()
+}
+
+// invoiceDiscrepancy is an imported row whose amount differs from the one
+// already stored.
+type invoiceDiscrepancy struct {
+	Row       FuneralInvoiceRow
+	OldAmount int
+}
+
+func (s *Scheduler) recordDiscrepancies(ctx context.Context, tx *sql.Tx, job CronJob, conflicts []invoiceDiscrepancy) error {
+	action := "held"
+	if s.overwriteConflicts {
+		action = "overwritten"
+	}
+	rows := make([][]any, len(conflicts))
+	for i, c := range conflicts {
+		rows[i] = []any{job.JobID, c.Row.InvoiceDate, c.Row.CustomerID, c.OldAmount, c.Row.TotalAmount, action}
+	}
+	err := s.insertBatched(ctx, tx,
+		"INSERT INTO funeral_invoice_discrepancies (job_id, invoice_date, c_idno2, old_amount, new_amount, action)", "", rows)
+	if err != nil {
+		return fmt.Errorf("recording discrepancies: %w", err)
+	}
+	return nil
+}
+
+// notifyDiscrepancies alerts accounting about total changed amounts, listing
+// the first of them.
+func (s *Scheduler) notifyDiscrepancies(ctx context.Context, job CronJob, invoiceDate string, total int, conflicts []invoiceDiscrepancy) {
+	outcome := "The stored amounts were kept; correct them in MySQL or the ERP."
+	if s.overwriteConflicts {
+		outcome = "The stored amounts were replaced with the ERP amounts."
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d funeral invoices for %s changed amount in the ERP after they were imported. %s\n", total, invoiceDate, outcome)
+	for _, c := range conflicts {
+		fmt.Fprintf(&body, "- %s %s: %d -> %d\n", c.Row.InvoiceDate, c.Row.CustomerID, c.OldAmount, c.Row.TotalAmount)
+	}
+	if total > len(conflicts) {
+		fmt.Fprintf(&body, "... and %d more in funeral_invoice_discrepancies\n", total-len(conflicts))
+	}
+	Logger(ctx).Warn("Funeral invoice amounts changed", "invoice_date", invoiceDate, "count", total)
+	s.notify(notify.Message{
+		Event:      "invoice_amounts_changed",
+		Subject:    fmt.Sprintf("%d funeral invoice amounts changed for %s", total, invoiceDate),
+		Body:       body.String(),
+		Severity:   notify.SeverityWarning,
+		JobName:    job.JobName,
+		JobID:      job.JobID,
+		Recipients: s.invoiceRecipients,
+	})
+}
+
+// quarantineInvoices keeps rows that failed validation, with the reasons,
+// for accounting to correct in the ERP.
+func (s *Scheduler) quarantineInvoices(ctx context.Context, tx *sql.Tx, job CronJob, invalid []invoiceViolation) error {
+	rows := make([][]any, len(invalid))
+	for i, v := range invalid {
+		rows[i] = []any{job.JobID, v.Row.InvoiceDate, v.Row.CustomerID, v.Row.TotalAmount, strings.Join(v.Violations, "; ")}
+	}
+	err := s.insertBatched(ctx, tx,
+		"INSERT INTO funeral_invoice_quarantine (job_id, invoice_date, c_idno2, total_amount_dividint10, violations)", "", rows)
+	if err != nil {
+		return fmt.Errorf("quarantining invoices: %w", err)
+	}
+	return nil
+}
+
+// notifyQuarantine alerts accounting about total quarantined rows, listing
+// the first of them.
+func (s *Scheduler) notifyQuarantine(ctx context.Context, job CronJob, invoiceDate string, total int, invalid []invoiceViolation) {
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d funeral invoices for %s failed validation and were quarantined instead of imported:\n", total, invoiceDate)
+	for _, v := range invalid {
+		fmt.Fprintf(&body, "- %s %q %d: %s\n", v.Row.InvoiceDate, v.Row.CustomerID, v.Row.TotalAmount, strings.Join(v.Violations, "; "))
+	}
+	if total > len(invalid) {
+		fmt.Fprintf(&body, "... and %d more in funeral_invoice_quarantine\n", total-len(invalid))
+	}
+	Logger(ctx).Warn("Funeral invoices quarantined", "invoice_date", invoiceDate, "count", total)
+	s.notify(notify.Message{
+		Event:      "invoices_quarantined",
+		Subject:    fmt.Sprintf("%d funeral invoices quarantined for %s", total, invoiceDate),
+		Body:       body.String(),
+		Severity:   notify.SeverityWarning,
+		JobName:    job.JobName,
+		JobID:      job.JobID,
+		Recipients: s.invoiceRecipients,
+	})
+}
@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultInvoiceRules are the checks applied when INVOICE_RULES is not set.
+const defaultInvoiceRules = "c_idno2_required,amount_positive,date_format"
+
+// invoiceRule checks one funeral invoice row and describes the violation, or
+// returns "" when the row passes.
+type invoiceRule struct {
+	name  string
+	check func(row FuneralInvoiceRow) string
+}
+
+// invoiceRuleBuilders are the rules INVOICE_RULES can enable. arg is the
+// text after "=" for rules that take a value, such as amount_max=500000.
+var invoiceRuleBuilders = map[string]func(arg string) (func(FuneralInvoiceRow) string, error){
+	"c_idno2_required": func(string) (func(FuneralInvoiceRow) string, error) {
+		return func(row FuneralInvoiceRow) string {
+			if strings.TrimSpace(row.CustomerID) == "" {
+				return "c_idno2 is empty"
+			}
+			return ""
+		}, nil
+	},
+	"amount_positive": func(string) (func(FuneralInvoiceRow) string, error) {
+		return func(row FuneralInvoiceRow) string {
+			if row.TotalAmount <= 0 {
+				return fmt.Sprintf("total_amount_dividint10 %d is not positive", row.TotalAmount)
+			}
+			return ""
+		}, nil
+	},
+	"date_format": func(string) (func(FuneralInvoiceRow) string, error) {
+		return func(row FuneralInvoiceRow) string {
+			if _, err := time.Parse(dateLayout, row.InvoiceDate); err != nil {
+				return fmt.Sprintf("invoice_date %q is not YYYY-MM-DD", row.InvoiceDate)
+			}
+			return ""
+		}, nil
+	},
+	"amount_max": func(arg string) (func(FuneralInvoiceRow) string, error) {
+		limit, err := strconv.Atoi(arg)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("amount_max needs a positive integer, e.g. amount_max=500000")
+		}
+		return func(row FuneralInvoiceRow) string {
+			if row.TotalAmount > limit {
+				return fmt.Sprintf("total_amount_dividint10 %d exceeds %d", row.TotalAmount, limit)
+			}
+			return ""
+		}, nil
+	},
+}
+
+// loadInvoiceRules reads INVOICE_RULES, the comma-separated validation rules
+// funeral invoice rows must pass before they are persisted, e.g.
+// "c_idno2_required,amount_positive,date_format,amount_max=500000", plus
+// INVOICE_CONFLICT_ACTION and INVOICE_ALERT_RECIPIENTS.
+func (s *Scheduler) loadInvoiceRules() error {
+	raw := os.Getenv("INVOICE_RULES")
+	if raw == "" {
+		raw = defaultInvoiceRules
+	}
+
+	s.invoiceRules = nil
+	for _, entry := range envlist.Split(raw) {
+		name, arg, _ := strings.Cut(entry, "=")
+		build, ok := invoiceRuleBuilders[name]
+		if !ok {
+			return fmt.Errorf("parsing INVOICE_RULES: unknown rule %q", name)
+		}
+		check, err := build(arg)
+		if err != nil {
+			return fmt.Errorf("parsing INVOICE_RULES: %w", err)
+		}
+		s.invoiceRules = append(s.invoiceRules, invoiceRule{name: name, check: check})
+	}
+
+	switch action := os.Getenv("INVOICE_CONFLICT_ACTION"); action {
+	case "", "hold":
+		s.overwriteConflicts = false
+	case "overwrite":
+		s.overwriteConflicts = true
+	default:
+		return fmt.Errorf("INVOICE_CONFLICT_ACTION must be hold or overwrite, got %q", action)
+	}
+	s.invoiceRecipients = envlist.Split(os.Getenv("INVOICE_ALERT_RECIPIENTS"))
+	return nil
+}
+
+// invoiceViolation is a row that failed at least one rule.
+type invoiceViolation struct {
+	Row        FuneralInvoiceRow
+	Violations []string
+}
+
+// validateInvoices splits rows into those passing every rule and the rest.
+func (s *Scheduler) validateInvoices(rows []FuneralInvoiceRow) ([]FuneralInvoiceRow, []invoiceViolation) {
+	var valid []FuneralInvoiceRow
+	var invalid []invoiceViolation
+	for _, row := range rows {
+		var violations []string
+		for _, rule := range s.invoiceRules {
+			if v := rule.check(row); v != "" {
+				violations = append(violations, rule.name+": "+v)
+			}
+		}
+		if len(violations) > 0 {
+			invalid = append(invalid, invoiceViolation{Row: row, Violations: violations})
+		} else {
+			valid = append(valid, row)
+		}
+	}
+	return valid, invalid
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// loadJitter reads SCHEDULE_JITTER (a Go duration such as "90s"), the
+// maximum random delay added to each scheduled fire time.
+func (s *Scheduler) loadJitter() error {
+	raw := os.Getenv("SCHEDULE_JITTER")
+	if raw == "" {
+		s.jitter = 0
+		return nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return fmt.Errorf("SCHEDULE_JITTER must be a non-negative duration, got %q", raw)
+	}
+	s.jitter = d
+	return nil
+}
+
+// withJitter delays each run of j by a random duration in [0, jitter), so
+// deployments sharing the ERP do not all fire at exactly the same second.
+// The delay is abandoned if the scheduler stops meanwhile.
+func (s *Scheduler) withJitter(j cron.Job) cron.Job {
+	return cron.FuncJob(func() {
+		if s.jitter > 0 {
+			delay := rand.N(s.jitter)
+			s.logger.Debug("Delaying scheduled run", "jitter", delay)
+			select {
+			case <-time.After(delay):
+			case <-s.stopping:
+				return
+			}
+		}
+		j.Run()
+	})
+}
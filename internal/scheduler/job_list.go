@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultJobListLimit = 50
+	maxJobListLimit     = 500
+)
+
+// jobStatuses are the values job_status can take.
+var jobStatuses = []string{"pending", "retrying", "running", "finished", "failed", "skipped"}
+
+// paramKey is a dotted path into job_params, e.g. db_id or export.format.
+var paramKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// JobFilter selects cron_jobs rows. Zero fields match everything.
+type JobFilter struct {
+	JobName  string
+	Statuses []string
+	// DateFrom and DateTo bound job_date inclusively, as YYYY-MM-DD.
+	DateFrom string
+	DateTo   string
+	// Site matches the db_id job param of golf-style jobs.
+	Site string
+	// Params matches job_params fields by value, keyed by dotted path.
+	Params map[string]string
+	// Tenants limits the result to these tenants; nil means all.
+	Tenants []string
+}
+
+// Validate rejects malformed dates, unknown statuses and param keys that are
+// not plain dotted paths as data errors.
+func (f JobFilter) Validate() error {
+	for _, status := range f.Statuses {
+		if !slices.Contains(jobStatuses, status) {
+			return errclass.DataError(fmt.Errorf("unknown status %q", status))
+		}
+	}
+	for _, date := range []string{f.DateFrom, f.DateTo} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return errclass.DataError(fmt.Errorf("dates must be YYYY-MM-DD, got %q", date))
+		}
+	}
+	for key := range f.Params {
+		if !paramKey.MatchString(key) {
+			return errclass.DataError(fmt.Errorf("invalid param key %q", key))
+		}
+	}
+	return nil
+}
+
+// where renders the filter as SQL conditions joined with AND, and their args.
+func (f JobFilter) where() (string, []any) {
+	conds := []string{"1 = 1"}
+	var args []any
+	if f.JobName != "" {
+		conds = append(conds, "job_name = ?")
+		args = append(args, f.JobName)
+	}
+	if len(f.Statuses) > 0 {
+		conds = append(conds, "job_status IN ("+placeholders(len(f.Statuses))+")")
+		for _, status := range f.Statuses {
+			args = append(args, status)
+		}
+	}
+	if f.DateFrom != "" {
+		conds = append(conds, "job_date >= ?")
+		args = append(args, f.DateFrom)
+	}
+	if f.DateTo != "" {
+		conds = append(conds, "job_date <= ?")
+		args = append(args, f.DateTo)
+	}
+	if f.Site != "" {
+		conds = append(conds, "JSON_UNQUOTE(JSON_EXTRACT(job_params, '$.db_id')) = ?")
+		args = append(args, f.Site)
+	}
+	// The path is bound like any other value; Validate keeps it to plain
+	// member names so it is always a valid JSON path.
+	for _, key := range slices.Sorted(maps.Keys(f.Params)) {
+		conds = append(conds, "JSON_UNQUOTE(JSON_EXTRACT(job_params, ?)) = ?")
+		args = append(args, "$."+key, f.Params[key])
+	}
+	if f.Tenants != nil {
+		if len(f.Tenants) == 0 {
+			conds = append(conds, "1 = 0")
+		} else {
+			conds = append(conds, "tenant IN ("+placeholders(len(f.Tenants))+")")
+			for _, tenant := range f.Tenants {
+				args = append(args, tenant)
+			}
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// JobPage is one page of ListJobs. NextCursor is empty on the last page.
+type JobPage struct {
+	Jobs       []CronJob `json:"jobs"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// ListJobs returns jobs matching f, newest first. Pages are keyed on job_id
+// rather than offsets, so they stay stable while new jobs are created.
+// cursor is the previous page's NextCursor, or "" for the first page.
+func (s *Scheduler) ListJobs(ctx context.Context, f JobFilter, cursor string, limit int) (JobPage, error) {
+	if err := f.Validate(); err != nil {
+		return JobPage{}, err
+	}
+	if limit <= 0 {
+		limit = defaultJobListLimit
+	}
+	limit = min(limit, maxJobListLimit)
+
+	where, args := f.where()
+	if cursor != "" {
+		beforeID, err := decodeJobCursor(cursor)
+		if err != nil {
+			return JobPage{}, err
+		}
+		where += " AND job_id < ?"
+		args = append(args, beforeID)
+	}
+	args = append(args, limit+1)
+
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT job_id, job_name, tenant, job_date, COALESCE(job_params, ''), job_status, priority, attempts,
+			COALESCE(message, ''), COALESCE(execution_time_ms, 0), created_at, updated_at, finished_at,
+			COALESCE(request_id, ''), job_result
+		FROM cron_jobs
+		WHERE `+where+`
+		ORDER BY job_id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return JobPage{}, fmt.Errorf("querying cron_jobs: %w", err)
+	}
+	defer rows.Close()
+
+	page := JobPage{Jobs: []CronJob{}}
+	for rows.Next() {
+		var job CronJob
+		var result []byte
+		if err := rows.Scan(&job.JobID, &job.JobName, &job.Tenant, &job.JobDate, &job.JobParams, &job.JobStatus,
+			&job.Priority, &job.Attempts, &job.Message, &job.ExecutionTimeMs, &job.CreatedAt, &job.UpdatedAt,
+			&job.FinishedAt, &job.RequestID, &result); err != nil {
+			return JobPage{}, fmt.Errorf("scanning row: %w", err)
+		}
+		job.Result = result
+		page.Jobs = append(page.Jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return JobPage{}, fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(page.Jobs) > limit {
+		page.Jobs = page.Jobs[:limit]
+		page.NextCursor = encodeJobCursor(page.Jobs[limit-1].JobID)
+	}
+	return page, nil
+}
+
+// Cursors are opaque to clients so the paging key can change later.
+func encodeJobCursor(jobID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("job:" + strconv.FormatInt(jobID, 10)))
+}
+
+func decodeJobCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if id, ok := strings.CutPrefix(string(raw), "job:"); ok {
+			if jobID, err := strconv.ParseInt(id, 10, 64); err == nil {
+				return jobID, nil
+			}
+		}
+	}
+	return 0, errclass.DataError(errors.New("invalid cursor"))
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+)
+
+type loggerKey struct{}
+
+// newJobLogger adds the fields identifying a run to logger: job_id,
+// job_name, attempt and, for jobs against one golf site, site; dry runs
+// are tagged dry_run.
+func newJobLogger(logger *slog.Logger, job CronJob) *slog.Logger {
+	logger = logger.With("job_id", job.JobID, "job_name", job.JobName, "attempt", job.Attempts)
+	if site := jobSite(job); site != "" {
+		logger = logger.With("site", site)
+	}
+	if job.DryRun {
+		logger = logger.With("dry_run", true)
+	}
+	return logger
+}
+
+// jobSite returns the golf site (the db_id param) job runs against, if any.
+func jobSite(job CronJob) string {
+	var params struct {
+		DbID string `json:"db_id"`
+	}
+	if json.Unmarshal([]byte(job.JobParams), &params) != nil {
+		return ""
+	}
+	return params.DbID
+}
+
+func withJobLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger of the job running under ctx, which tags every
+// line with the job's identifying fields, so handlers only log what is
+// specific to the line. Outside a job it returns slog.Default().
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
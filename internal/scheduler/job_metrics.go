@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"context"
+	"hotbrandon/go-cron-be/internal/metrics"
+)
+
+// metricsSpec is how often the scheduler's gauges are reported.
+const metricsSpec = "@every 15s"
+
+// metricsHook counts each run and records its duration by job type and
+// outcome.
+func (s *Scheduler) metricsHook(ctx context.Context, ev RunEvent) {
+	tags := []metrics.Tag{metrics.T("job_name", ev.Job.JobName), metrics.T("status", ev.Status), metrics.T("tenant", ev.Job.Tenant)}
+	s.metrics.Count("job_runs", 1, tags...)
+	s.metrics.Timing("job_run_duration", ev.Elapsed, tags...)
+}
+
+// ReportGauges reports the state Vars shows as gauges: busy workers, the
+// backlog, cron entries and leadership.
+func (s *Scheduler) ReportGauges() {
+	vars := s.Vars().(DebugVars)
+	s.metrics.Gauge("workers", float64(vars.Workers))
+	s.metrics.Gauge("workers_busy", float64(vars.WorkersBusy))
+	s.metrics.Gauge("cron_entries", float64(vars.EntriesRegistered))
+	s.metrics.Gauge("schedule_entries", float64(vars.ScheduleEntries))
+	leader := 0.0
+	if vars.Leader {
+		leader = 1
+	}
+	s.metrics.Gauge("leader", leader)
+	if vars.BacklogError == "" {
+		s.metrics.Gauge("backlog", float64(vars.Backlog))
+	}
+}
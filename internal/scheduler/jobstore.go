@@ -0,0 +1,377 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/events"
+	"strings"
+	"time"
+)
+
+// JobStore holds the job queue the dispatcher works on: it enqueues, claims
+// and finishes jobs and records their runs. The default keeps them in the
+// MySQL cron_jobs and job_runs tables, writing outbox events and webhook
+// deliveries in the same transactions; tests provide their own with
+// WithJobStore, see MemoryJobStore.
+type JobStore interface {
+	// PendingJobs returns the due pending and retrying jobs of the given
+	// job types, highest priority first.
+	PendingJobs(ctx context.Context, jobNames []string) ([]CronJob, error)
+	// PendingJob returns jobID if it is pending or retrying, due or not,
+	// and sql.ErrNoRows otherwise.
+	PendingJob(ctx context.Context, jobID int64) (CronJob, error)
+	// EnqueueJob adds job as pending. created is false when a job with the
+	// same job_name, job_date and job_params already exists.
+	EnqueueJob(ctx context.Context, job CronJob) (jobID int64, created bool, err error)
+	// ClaimJob moves a pending or retrying job to running and counts the
+	// attempt. It reports false when another worker claimed it first.
+	ClaimJob(ctx context.Context, jobID int64) (bool, error)
+	// Heartbeat marks a running job as alive, see ReapStaleJobs.
+	Heartbeat(ctx context.Context, jobID int64) error
+	// StartRun records the start of one execution of job.
+	StartRun(ctx context.Context, job CronJob) (runID int64, err error)
+	// FinishJob records the final outcome and job.Result on the job.
+	FinishJob(ctx context.Context, job CronJob, runID int64, outcome RunOutcome) error
+	// RetryJob records a failed attempt on the job and makes it due again
+	// after delay.
+	RetryJob(ctx context.Context, job CronJob, outcome RunOutcome, delay time.Duration) error
+	// FinishRun records the outcome and job.Result on run runID.
+	FinishRun(ctx context.Context, job CronJob, runID int64, outcome RunOutcome) error
+	// RecordRunTimes stores how a run's duration splits into Oracle, MySQL
+	// and application time.
+	RecordRunTimes(ctx context.Context, runID int64, oracle, mysql, app time.Duration) error
+	// SaveRunLogs stores the log lines captured during run runID.
+	SaveRunLogs(ctx context.Context, job CronJob, runID int64, lines []string) error
+	// StoredRetryPolicy returns the retry policy operators stored for
+	// jobName; ok is false when there is none.
+	StoredRetryPolicy(ctx context.Context, jobName string) (p RetryPolicy, ok bool, err error)
+	// UpstreamJobs counts the jobs of jobName for jobDate that a dependent
+	// job waits on, see JOB_DEPENDENCIES.
+	UpstreamJobs(ctx context.Context, jobName, jobDate string) (UpstreamJobs, error)
+	// DeferJob records note as the message of a job left pending, see
+	// BLACKOUT_WINDOWS.
+	DeferJob(ctx context.Context, jobID int64, note string) error
+	// SkipJob marks a pending or retrying job skipped with message.
+	SkipJob(ctx context.Context, jobID int64, message string) error
+	// DisabledFlags returns the switched-off rows of enable_flags, keyed
+	// by flagKey.
+	DisabledFlags(ctx context.Context) (map[string]bool, error)
+}
+
+// RunOutcome is how one execution of a job ended.
+type RunOutcome struct {
+	// Status is finished or failed.
+	Status   string
+	Category errclass.Category
+	Message  string
+	Elapsed  time.Duration
+}
+
+// mysqlJobStore is the JobStore over the scheduler's MySQL database. It
+// goes through the scheduler for the insert batch size, which is loaded
+// after the store is created.
+type mysqlJobStore struct {
+	s *Scheduler
+}
+
+const pendingJobColumns = `job_id, job_name, tenant, job_date, job_params, priority, attempts, COALESCE(request_id, '')`
+
+func scanPendingJob(scan func(dest ...any) error) (CronJob, error) {
+	var job CronJob
+	err := scan(&job.JobID, &job.JobName, &job.Tenant, &job.JobDate, &job.JobParams, &job.Priority, &job.Attempts, &job.RequestID)
+	return job, err
+}
+
+func (m mysqlJobStore) PendingJobs(ctx context.Context, jobNames []string) ([]CronJob, error) {
+	args := make([]any, len(jobNames))
+	for i, name := range jobNames {
+		args[i] = name
+	}
+
+	query := `
+		SELECT ` + pendingJobColumns + `
+		FROM cron_jobs
+		WHERE job_status IN ('pending', 'retrying')
+			AND (next_run_at IS NULL OR next_run_at <= NOW())
+			AND job_name IN (` + placeholders(len(jobNames)) + `)
+		ORDER BY priority DESC, job_id
+	`
+	rows, err := m.s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying cron_jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []CronJob
+	for rows.Next() {
+		job, err := scanPendingJob(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return jobs, nil
+}
+
+func (m mysqlJobStore) PendingJob(ctx context.Context, jobID int64) (CronJob, error) {
+	return scanPendingJob(m.s.db.QueryRowContext(ctx, `
+		SELECT `+pendingJobColumns+`
+		FROM cron_jobs
+		WHERE job_id = ? AND job_status IN ('pending', 'retrying')
+	`, jobID).Scan)
+}
+
+// EnqueueJob inserts the job and its job_created outbox event in one
+// transaction.
+func (m mysqlJobStore) EnqueueJob(ctx context.Context, job CronJob) (int64, bool, error) {
+	tx, err := m.s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The no-op update leaves existing rows untouched and reports 0 rows
+	// affected, unlike INSERT IGNORE which would also hide other errors.
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO cron_jobs (job_name, tenant, job_date, job_params, priority, request_id)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))
+		ON DUPLICATE KEY UPDATE job_id = job_id
+	`, job.JobName, job.Tenant, job.JobDate, job.JobParams, job.Priority, job.RequestID)
+	if err != nil {
+		return 0, false, fmt.Errorf("inserting job: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, false, nil
+	}
+
+	jobID, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, fmt.Errorf("reading job id: %w", err)
+	}
+	if err := insertEvent(ctx, tx, events.JobCreated, jobID, job.JobName, job.JobDate, []byte(job.JobParams)); err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("committing job: %w", err)
+	}
+	return jobID, true, nil
+}
+
+func (m mysqlJobStore) ClaimJob(ctx context.Context, jobID int64) (bool, error) {
+	result, err := m.s.db.ExecContext(ctx, `
+		UPDATE cron_jobs SET job_status = 'running', attempts = attempts + 1, heartbeat_at = NOW()
+		WHERE job_id = ? AND job_status IN ('pending', 'retrying')
+	`, jobID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (m mysqlJobStore) Heartbeat(ctx context.Context, jobID int64) error {
+	_, err := m.s.db.ExecContext(ctx, "UPDATE cron_jobs SET heartbeat_at = NOW() WHERE job_id = ?", jobID)
+	return err
+}
+
+// StartRun inserts the job_runs record together with its job_started event.
+func (m mysqlJobStore) StartRun(ctx context.Context, job CronJob) (int64, error) {
+	tx, err := m.s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO job_runs (job_id) VALUES (?)", job.JobID)
+	if err != nil {
+		return 0, err
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	payload, _ := json.Marshal(map[string]any{"run_id": runID, "attempt": job.Attempts})
+	if err := insertEvent(ctx, tx, events.JobStarted, job.JobID, job.JobName, job.JobDate, payload); err != nil {
+		return 0, err
+	}
+	return runID, tx.Commit()
+}
+
+// FinishJob updates the job, and emits a job_finished or job_failed event
+// and the webhook deliveries for it in the same transaction.
+func (m mysqlJobStore) FinishJob(ctx context.Context, job CronJob, runID int64, o RunOutcome) error {
+	tx, err := m.s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE cron_jobs
+		SET job_status = ?, message = ?, job_result = ?, execution_time_ms = ?, finished_at = NOW()
+		WHERE job_id = ?
+	`, o.Status, o.Message, resultArg(job.Result), o.Elapsed.Milliseconds(), job.JobID)
+	if err != nil {
+		return err
+	}
+
+	eventType := events.JobFinished
+	if o.Status == "failed" {
+		eventType = events.JobFailed
+	}
+	fields := map[string]any{
+		"run_id":            runID,
+		"attempt":           job.Attempts,
+		"execution_time_ms": o.Elapsed.Milliseconds(),
+	}
+	if o.Category != "" {
+		fields["error_category"] = o.Category
+	}
+	payload, _ := json.Marshal(fields)
+	if err := insertEvent(ctx, tx, eventType, job.JobID, job.JobName, job.JobDate, payload); err != nil {
+		return err
+	}
+	if err := enqueueWebhooks(tx, job, runID, o.Status, o.Category, o.Message, o.Elapsed); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m mysqlJobStore) RetryJob(ctx context.Context, job CronJob, o RunOutcome, delay time.Duration) error {
+	_, err := m.s.db.ExecContext(ctx, `
+		UPDATE cron_jobs
+		SET job_status = 'retrying', message = ?, job_result = ?, execution_time_ms = ?,
+			next_run_at = NOW() + INTERVAL ? SECOND
+		WHERE job_id = ?
+	`, o.Message, resultArg(job.Result), o.Elapsed.Milliseconds(), int64(delay.Seconds()), job.JobID)
+	return err
+}
+
+func (m mysqlJobStore) FinishRun(ctx context.Context, job CronJob, runID int64, o RunOutcome) error {
+	var categoryArg any
+	if o.Category != "" {
+		categoryArg = string(o.Category)
+	}
+	_, err := m.s.db.ExecContext(ctx, `
+		UPDATE job_runs
+		SET run_status = ?, error_category = ?, message = ?, job_result = ?, execution_time_ms = ?, finished_at = NOW()
+		WHERE run_id = ?
+	`, o.Status, categoryArg, o.Message, resultArg(job.Result), o.Elapsed.Milliseconds(), runID)
+	return err
+}
+
+func (m mysqlJobStore) RecordRunTimes(ctx context.Context, runID int64, oracle, mysql, app time.Duration) error {
+	_, err := m.s.db.ExecContext(ctx, "UPDATE job_runs SET oracle_ms = ?, mysql_ms = ?, app_ms = ? WHERE run_id = ?",
+		oracle.Milliseconds(), mysql.Milliseconds(), app.Milliseconds(), runID)
+	return err
+}
+
+func (m mysqlJobStore) SaveRunLogs(ctx context.Context, job CronJob, runID int64, lines []string) error {
+	rows := make([][]any, len(lines))
+	for i, line := range lines {
+		rows[i] = []any{runID, i + 1, job.JobID, line}
+	}
+	return m.s.insertBatched(ctx, m.s.db, "INSERT INTO job_run_logs (run_id, seq, job_id, line)", "", rows)
+}
+
+// StoredRetryPolicy reads the job type's row in retry_policies.
+func (m mysqlJobStore) StoredRetryPolicy(ctx context.Context, jobName string) (RetryPolicy, bool, error) {
+	var (
+		maxAttempts, baseDelayMs, maxDelayMs int64
+		jitter                               float64
+		retryOn                              string
+	)
+	err := m.s.db.QueryRowContext(ctx, `
+		SELECT max_attempts, base_delay_ms, max_delay_ms, jitter, retry_on
+		FROM retry_policies
+		WHERE job_name = ?
+	`, jobName).Scan(&maxAttempts, &baseDelayMs, &maxDelayMs, &jitter, &retryOn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RetryPolicy{}, false, nil
+	}
+	if err != nil {
+		return RetryPolicy{}, false, err
+	}
+
+	p := RetryPolicy{
+		MaxAttempts: int(maxAttempts),
+		BaseDelay:   time.Duration(baseDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(maxDelayMs) * time.Millisecond,
+		Jitter:      jitter,
+	}
+	for _, c := range strings.Split(retryOn, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			p.RetryOn = append(p.RetryOn, errclass.Category(c))
+		}
+	}
+	return p, true, nil
+}
+
+// UpstreamJobs counts a job with a finished run as finished, even if a later
+// attempt failed, and a failed job without one as failed.
+func (m mysqlJobStore) UpstreamJobs(ctx context.Context, jobName, jobDate string) (UpstreamJobs, error) {
+	var u UpstreamJobs
+	err := m.s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(finished), 0), COALESCE(SUM(job_status = 'failed' AND NOT finished), 0)
+		FROM (
+			SELECT c.job_status, EXISTS (
+				SELECT 1 FROM job_runs r
+				WHERE r.job_id = c.job_id AND r.run_status = 'finished'
+			) AS finished
+			FROM cron_jobs c
+			WHERE c.job_name = ? AND c.job_date = ?
+		) upstream
+	`, jobName, jobDate).Scan(&u.Total, &u.Finished, &u.Failed)
+	return u, err
+}
+
+// DeferJob leaves rows that already carry note untouched, so a job held for
+// a whole window is not rewritten on every tick.
+func (m mysqlJobStore) DeferJob(ctx context.Context, jobID int64, note string) error {
+	_, err := m.s.db.ExecContext(ctx, `
+		UPDATE cron_jobs SET message = ?
+		WHERE job_id = ? AND NOT (message <=> ?)
+	`, note, jobID, note)
+	return err
+}
+
+func (m mysqlJobStore) SkipJob(ctx context.Context, jobID int64, message string) error {
+	_, err := m.s.db.ExecContext(ctx, `
+		UPDATE cron_jobs SET job_status = 'skipped', message = ?, finished_at = NOW()
+		WHERE job_id = ? AND job_status IN ('pending', 'retrying')
+	`, message, jobID)
+	return err
+}
+
+func (m mysqlJobStore) DisabledFlags(ctx context.Context) (map[string]bool, error) {
+	rows, err := m.s.db.QueryContext(ctx, "SELECT kind, name FROM enable_flags WHERE NOT enabled")
+	if err != nil {
+		return nil, fmt.Errorf("querying enable_flags: %w", err)
+	}
+	defer rows.Close()
+
+	disabled := map[string]bool{}
+	for rows.Next() {
+		var kind, name string
+		if err := rows.Scan(&kind, &name); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		disabled[flagKey(kind, name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return disabled, nil
+}
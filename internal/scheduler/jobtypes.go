@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"sort"
+	"strings"
+)
+
+// HandlerFunc executes a single job with its decoded params and returns the
+// message stored on the job record.
+type HandlerFunc[P any] func(ctx context.Context, job CronJob, params P) (string, error)
+
+// paramsValidator is implemented by params structs that need checks beyond
+// JSON decoding, such as required fields or date formats.
+type paramsValidator interface {
+	Validate() error
+}
+
+type jobType struct {
+	run      Runner
+	validate func(rawParams string) error
+	// dryRun is set for job types whose handler honors DryRun
+	dryRun bool
+	// privileged is set for job types whose params carry code, queries,
+	// procedures or URLs to run, see Privileged
+	privileged bool
+}
+
+// RegisterJobType maps jobName to the params struct P. Before the handler is
+// called, job_params is decoded into P and unknown fields are rejected.
+func RegisterJobType[P any](s *Scheduler, jobName string, handler HandlerFunc[P]) {
+	s.jobTypes[jobName] = jobType{
+		run: func(ctx context.Context, job CronJob) (string, error) {
+			var params P
+			if err := DecodeParams(job.JobParams, &params); err != nil {
+				return "", errclass.DataError(err)
+			}
+			return handler(ctx, job, params)
+		},
+		validate: func(rawParams string) error {
+			var params P
+			return DecodeParams(rawParams, &params)
+		},
+	}
+}
+
+// markPrivileged marks job types that run whatever their params say.
+func (s *Scheduler) markPrivileged(jobNames ...string) {
+	for _, name := range jobNames {
+		jt := s.jobTypes[name]
+		jt.privileged = true
+		s.jobTypes[name] = jt
+	}
+}
+
+// Privileged reports whether jobName runs commands, SQL, procedures or
+// requests given in its params, or is a plugin. Whoever may enqueue such jobs
+// can run anything the scheduler's connections allow, so the API refuses them
+// unless allowed explicitly.
+func (s *Scheduler) Privileged(jobName string) bool {
+	return s.jobTypes[jobName].privileged
+}
+
+// JobTypes returns the registered job names in sorted order.
+func (s *Scheduler) JobTypes() []string {
+	names := make([]string, 0, len(s.jobTypes))
+	for name := range s.jobTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DecodeParams strictly decodes raw job_params into v. Unknown fields and
+// trailing data are errors so that typos fail loudly instead of being ignored.
+func DecodeParams(raw string, v any) error {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("decoding job_params: %w", err)
+	}
+	if dec.More() {
+		return errors.New("decoding job_params: unexpected data after JSON object")
+	}
+
+	if validator, ok := v.(paramsValidator); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("invalid job_params: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"hotbrandon/go-cron-be/internal/lock"
+	"os"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	leaderLockName = "scheduler-leader"
+	// leaderTTL must comfortably exceed the campaign interval.
+	leaderTTL  = 30 * time.Second
+	leaderSpec = "@every 10s"
+
+	exclusiveLockTTL = time.Minute
+)
+
+// campaign acquires leadership or renews it. Only the leader creates jobs
+// from cron entries; every instance dispatches, since claiming a job is
+// atomic.
+func (s *Scheduler) campaign() {
+	ctx, cancel := context.WithTimeout(s.ctx, leaderTTL/3)
+	defer cancel()
+
+	s.leaderMu.Lock()
+	defer s.leaderMu.Unlock()
+
+	if s.leaderLock != nil {
+		err := s.leaderLock.Refresh(ctx, leaderTTL)
+		if err == nil {
+			return
+		}
+		s.logger.Warn("Lost scheduler leadership", "error", err)
+		s.leaderLock = nil
+		s.leading.Store(false)
+		if !errors.Is(err, lock.ErrLost) {
+			return
+		}
+	}
+
+	l, ok, err := s.elector.TryLock(ctx, leaderLockName, leaderTTL)
+	if err != nil {
+		s.logger.Error("Leader election failed", "error", err)
+		return
+	}
+	if ok {
+		s.leaderLock = l
+		s.leading.Store(true)
+		s.logger.Info("Became scheduler leader")
+		go s.catchUpSchedules()
+	}
+}
+
+// resign gives up leadership so another instance can take over right away.
+func (s *Scheduler) resign() {
+	s.leaderMu.Lock()
+	defer s.leaderMu.Unlock()
+
+	if s.leaderLock == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.leaderLock.Unlock(ctx); err != nil {
+		s.logger.Warn("Failed to release leadership", "error", err)
+	}
+	s.leaderLock = nil
+	s.leading.Store(false)
+}
+
+// IsLeader reports whether this instance currently creates scheduled jobs.
+func (s *Scheduler) IsLeader() bool {
+	return s.leading.Load()
+}
+
+// leaderOnly skips job on instances that are not the leader.
+func (s *Scheduler) leaderOnly(job cron.Job) cron.Job {
+	return cron.FuncJob(func() {
+		if !s.IsLeader() {
+			return
+		}
+		job.Run()
+	})
+}
+
+// clusterJob wraps cron entries that every instance runs by default, such as
+// dispatch. With a dedicated leader election only the leader runs them.
+func (s *Scheduler) clusterJob(job cron.Job) cron.Job {
+	if s.leaderOnlyCron {
+		return s.leaderOnly(job)
+	}
+	return job
+}
+
+// loadExclusiveJobs reads JOB_EXCLUSIVE, the comma-separated job types of
+// which at most one may run at a time across all instances.
+func (s *Scheduler) loadExclusiveJobs() error {
+	s.exclusive = make(map[string]bool)
+	for _, jobName := range envlist.Split(os.Getenv("JOB_EXCLUSIVE")) {
+		s.exclusive[jobName] = true
+	}
+	return nil
+}
+
+// errExclusiveLost is the cause with which a job's context is cancelled
+// when the lock of its exclusive job type is lost.
+var errExclusiveLost = errors.New("exclusive job lock lost")
+
+// acquireExclusive takes the cluster-wide lock of an exclusive job type and
+// keeps it refreshed until release is called. ok is false when another
+// instance is running that type. When the lock is lost, or refreshing it
+// fails for so long that it may have expired, lost is called so the job
+// stops before another instance starts the type.
+func (s *Scheduler) acquireExclusive(jobName string, lost context.CancelCauseFunc) (release func(), ok bool) {
+	if !s.exclusive[jobName] {
+		return func() {}, true
+	}
+	return s.holdJobLock(jobName, lost)
+}
+
+// holdJobLock takes the cluster-wide lock of job type jobName and refreshes
+// it until release is called, see acquireExclusive and the lock middleware.
+func (s *Scheduler) holdJobLock(jobName string, lost context.CancelCauseFunc) (release func(), ok bool) {
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	l, ok, err := s.locker.TryLock(ctx, "job:"+jobName, exclusiveLockTTL)
+	cancel()
+	if err != nil {
+		s.logger.Error("Failed to acquire job lock", "job_name", jobName, "error", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(exclusiveLockTTL / 3)
+		defer ticker.Stop()
+		refreshed := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), exclusiveLockTTL/3)
+				err := l.Refresh(ctx, exclusiveLockTTL)
+				cancel()
+				if err == nil {
+					refreshed = time.Now()
+					continue
+				}
+				s.logger.Error("Failed to refresh job lock", "job_name", jobName, "error", err)
+				// The next tick would come after the lock expired.
+				if errors.Is(err, lock.ErrLost) || time.Since(refreshed) >= exclusiveLockTTL-exclusiveLockTTL/3 {
+					lost(fmt.Errorf("%w: %w", errExclusiveLost, err))
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := l.Unlock(ctx); err != nil {
+			s.logger.Warn("Failed to release job lock", "job_name", jobName, "error", err)
+		}
+	}, true
+}
@@ -0,0 +1,307 @@
+package scheduler
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryJobStore is a JobStore that keeps jobs and runs in memory, so the
+// dispatcher and job types can be tested without MySQL:
+//
+//	store := scheduler.NewMemoryJobStore()
+//	s := scheduler.NewScheduler(nil, logger, scheduler.WithJobStore(store))
+//
+// It records no outbox events or webhook deliveries. Job types that read or
+// write other tables still need a database.
+type MemoryJobStore struct {
+	mu       sync.Mutex
+	jobs     []*memoryJob
+	runs     []*MemoryRun
+	policies map[string]RetryPolicy
+	disabled map[string]bool
+}
+
+type memoryJob struct {
+	job       CronJob
+	nextRunAt time.Time
+}
+
+// MemoryRun is one execution recorded by a MemoryJobStore.
+type MemoryRun struct {
+	RunID         int64
+	JobID         int64
+	RunStatus     string
+	ErrorCategory errclass.Category
+	Message       string
+	Result        json.RawMessage
+	Elapsed       time.Duration
+	Logs          []string
+}
+
+// NewMemoryJobStore returns an empty MemoryJobStore.
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{policies: make(map[string]RetryPolicy), disabled: make(map[string]bool)}
+}
+
+// SetRetryPolicy stores p for jobName, like a row in retry_policies.
+func (m *MemoryJobStore) SetRetryPolicy(jobName string, p RetryPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policies[jobName] = p
+}
+
+// SetEnabled switches the job type, site or schedule called name on or off,
+// like a row in enable_flags. kind is "job_type", "site" or "schedule".
+func (m *MemoryJobStore) SetEnabled(kind, name string, enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if enabled {
+		delete(m.disabled, flagKey(kind, name))
+	} else {
+		m.disabled[flagKey(kind, name)] = true
+	}
+}
+
+// Job returns a copy of job jobID as last recorded.
+func (m *MemoryJobStore) Job(jobID int64) (CronJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(jobID)
+	if j == nil {
+		return CronJob{}, false
+	}
+	return j.job, true
+}
+
+// Runs returns copies of the runs of job jobID, oldest first.
+func (m *MemoryJobStore) Runs(jobID int64) []MemoryRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []MemoryRun
+	for _, r := range m.runs {
+		if r.JobID == jobID {
+			runs = append(runs, *r)
+		}
+	}
+	return runs
+}
+
+func (m *MemoryJobStore) find(jobID int64) *memoryJob {
+	if jobID < 1 || jobID > int64(len(m.jobs)) {
+		return nil
+	}
+	return m.jobs[jobID-1]
+}
+
+func (m *MemoryJobStore) run(runID int64) *MemoryRun {
+	if runID < 1 || runID > int64(len(m.runs)) {
+		return nil
+	}
+	return m.runs[runID-1]
+}
+
+func pendingStatus(status string) bool {
+	return status == "pending" || status == "retrying"
+}
+
+func (m *MemoryJobStore) PendingJobs(ctx context.Context, jobNames []string) ([]CronJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var jobs []CronJob
+	for _, j := range m.jobs {
+		if pendingStatus(j.job.JobStatus) && !j.nextRunAt.After(now) && slices.Contains(jobNames, j.job.JobName) {
+			jobs = append(jobs, j.job)
+		}
+	}
+	slices.SortStableFunc(jobs, func(a, b CronJob) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+	return jobs, nil
+}
+
+func (m *MemoryJobStore) PendingJob(ctx context.Context, jobID int64) (CronJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(jobID)
+	if j == nil || !pendingStatus(j.job.JobStatus) {
+		return CronJob{}, sql.ErrNoRows
+	}
+	return j.job, nil
+}
+
+func (m *MemoryJobStore) EnqueueJob(ctx context.Context, job CronJob) (int64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.job.JobName == job.JobName && j.job.JobDate == job.JobDate && j.job.JobParams == job.JobParams {
+			return 0, false, nil
+		}
+	}
+	job.JobID = int64(len(m.jobs) + 1)
+	job.JobStatus = "pending"
+	job.Attempts = 0
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	m.jobs = append(m.jobs, &memoryJob{job: job})
+	return job.JobID, true, nil
+}
+
+func (m *MemoryJobStore) ClaimJob(ctx context.Context, jobID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(jobID)
+	if j == nil || !pendingStatus(j.job.JobStatus) {
+		return false, nil
+	}
+	j.job.JobStatus = "running"
+	j.job.Attempts++
+	j.job.UpdatedAt = time.Now()
+	return true, nil
+}
+
+func (m *MemoryJobStore) Heartbeat(ctx context.Context, jobID int64) error {
+	return nil
+}
+
+func (m *MemoryJobStore) StartRun(ctx context.Context, job CronJob) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &MemoryRun{RunID: int64(len(m.runs) + 1), JobID: job.JobID, RunStatus: "running"}
+	m.runs = append(m.runs, r)
+	return r.RunID, nil
+}
+
+func (m *MemoryJobStore) FinishJob(ctx context.Context, job CronJob, runID int64, o RunOutcome) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(job.JobID)
+	if j == nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	j.job.JobStatus = o.Status
+	j.job.Message = o.Message
+	j.job.Result = job.Result
+	j.job.ExecutionTimeMs = o.Elapsed.Milliseconds()
+	j.job.UpdatedAt = now
+	j.job.FinishedAt = &now
+	return nil
+}
+
+func (m *MemoryJobStore) RetryJob(ctx context.Context, job CronJob, o RunOutcome, delay time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(job.JobID)
+	if j == nil {
+		return sql.ErrNoRows
+	}
+	j.job.JobStatus = "retrying"
+	j.job.Message = o.Message
+	j.job.Result = job.Result
+	j.job.ExecutionTimeMs = o.Elapsed.Milliseconds()
+	j.job.UpdatedAt = time.Now()
+	j.nextRunAt = j.job.UpdatedAt.Add(delay)
+	return nil
+}
+
+func (m *MemoryJobStore) FinishRun(ctx context.Context, job CronJob, runID int64, o RunOutcome) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.run(runID)
+	if r == nil {
+		return sql.ErrNoRows
+	}
+	r.RunStatus = o.Status
+	r.ErrorCategory = o.Category
+	r.Message = o.Message
+	r.Result = job.Result
+	r.Elapsed = o.Elapsed
+	return nil
+}
+
+func (m *MemoryJobStore) RecordRunTimes(ctx context.Context, runID int64, oracle, mysql, app time.Duration) error {
+	return nil
+}
+
+func (m *MemoryJobStore) SaveRunLogs(ctx context.Context, job CronJob, runID int64, lines []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.run(runID)
+	if r == nil {
+		return sql.ErrNoRows
+	}
+	r.Logs = append([]string(nil), lines...)
+	return nil
+}
+
+func (m *MemoryJobStore) StoredRetryPolicy(ctx context.Context, jobName string) (RetryPolicy, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.policies[jobName]
+	return p, ok, nil
+}
+
+func (m *MemoryJobStore) UpstreamJobs(ctx context.Context, jobName, jobDate string) (UpstreamJobs, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var u UpstreamJobs
+	for _, j := range m.jobs {
+		if j.job.JobName != jobName || j.job.JobDate != jobDate {
+			continue
+		}
+		u.Total++
+		finished := slices.ContainsFunc(m.runs, func(r *MemoryRun) bool {
+			return r.JobID == j.job.JobID && r.RunStatus == "finished"
+		})
+		switch {
+		case finished:
+			u.Finished++
+		case j.job.JobStatus == "failed":
+			u.Failed++
+		}
+	}
+	return u, nil
+}
+
+func (m *MemoryJobStore) DeferJob(ctx context.Context, jobID int64, note string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(jobID)
+	if j == nil {
+		return sql.ErrNoRows
+	}
+	if j.job.Message != note {
+		j.job.Message = note
+		j.job.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (m *MemoryJobStore) SkipJob(ctx context.Context, jobID int64, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(jobID)
+	if j == nil || !pendingStatus(j.job.JobStatus) {
+		return nil
+	}
+	now := time.Now()
+	j.job.JobStatus = "skipped"
+	j.job.Message = message
+	j.job.UpdatedAt = now
+	j.job.FinishedAt = &now
+	return nil
+}
+
+func (m *MemoryJobStore) DisabledFlags(ctx context.Context) (map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.disabled), nil
+}
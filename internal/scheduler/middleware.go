@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/metrics"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+const defaultMiddleware = "recover"
+
+// Runner executes one run of a job and returns the message stored on the
+// job record, like a registered handler.
+type Runner func(ctx context.Context, job CronJob) (string, error)
+
+// Middleware wraps a Runner with behavior shared by every job type, the way
+// HTTP middleware wraps a handler.
+type Middleware func(next Runner) Runner
+
+// loadMiddleware reads JOB_MIDDLEWARE, the built-in middleware wrapped around
+// every handler, outermost first (comma-separated: recover, log, timeout,
+// metrics, lock, or none; default "recover"), and JOB_TIMEOUT, the deadline
+// the timeout middleware puts on each run.
+func (s *Scheduler) loadMiddleware() error {
+	raw := os.Getenv("JOB_MIDDLEWARE")
+	if raw == "" {
+		raw = defaultMiddleware
+	}
+
+	var timeout time.Duration
+	if rawTimeout := os.Getenv("JOB_TIMEOUT"); rawTimeout != "" {
+		d, err := time.ParseDuration(rawTimeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("JOB_TIMEOUT must be a positive duration, got %q", rawTimeout)
+		}
+		timeout = d
+	}
+
+	s.middleware = nil
+	for _, name := range envlist.Split(raw) {
+		switch name {
+		case "recover":
+			s.middleware = append(s.middleware, recoverMiddleware)
+		case "log":
+			s.middleware = append(s.middleware, logMiddleware)
+		case "timeout":
+			if timeout == 0 {
+				return fmt.Errorf("JOB_MIDDLEWARE includes timeout but JOB_TIMEOUT is not set")
+			}
+			s.middleware = append(s.middleware, timeoutMiddleware(timeout))
+		case "metrics":
+			s.middleware = append(s.middleware, s.metricsMiddleware)
+		case "lock":
+			s.middleware = append(s.middleware, s.lockMiddleware)
+		case "none":
+		default:
+			return fmt.Errorf("unknown middleware %q in JOB_MIDDLEWARE", name)
+		}
+	}
+	return nil
+}
+
+// Use adds middleware inside the configured JOB_MIDDLEWARE, in order, so the
+// first one added is the outermost of them. Call it before Start.
+func (s *Scheduler) Use(mw ...Middleware) {
+	s.customMiddleware = append(s.customMiddleware, mw...)
+}
+
+// wrap applies the middleware chain to run.
+func (s *Scheduler) wrap(run Runner) Runner {
+	chain := append(append([]Middleware(nil), s.middleware...), s.customMiddleware...)
+	for i := len(chain) - 1; i >= 0; i-- {
+		run = chain[i](run)
+	}
+	return run
+}
+
+// recoverMiddleware turns a panicking handler into a failed run instead of a
+// crashed worker, logging the stack.
+func recoverMiddleware(next Runner) Runner {
+	return func(ctx context.Context, job CronJob) (message string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				Logger(ctx).Error("Job handler panicked", "panic", r, "stack", string(debug.Stack()))
+				err = fmt.Errorf("handler panicked: %v", r)
+			}
+		}()
+		return next(ctx, job)
+	}
+}
+
+// logMiddleware logs when each run starts, with its params.
+func logMiddleware(next Runner) Runner {
+	return func(ctx context.Context, job CronJob) (string, error) {
+		Logger(ctx).Info("Job started", "job_date", job.JobDate, "params", job.JobParams)
+		return next(ctx, job)
+	}
+}
+
+// timeoutMiddleware cancels runs that take longer than d. Unlike
+// JOB_MAX_RUNTIME it applies to every job type and does not alert.
+func timeoutMiddleware(d time.Duration) Middleware {
+	return func(next Runner) Runner {
+		return func(ctx context.Context, job CronJob) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, job)
+		}
+	}
+}
+
+// metricsMiddleware records how long the rest of the chain and the handler
+// take, by job type and outcome. Unlike job_run_duration, which covers the
+// whole run, it leaves out the middleware outside it, e.g. waiting for a lock.
+func (s *Scheduler) metricsMiddleware(next Runner) Runner {
+	return func(ctx context.Context, job CronJob) (string, error) {
+		start := time.Now()
+		message, err := next(ctx, job)
+		outcome := "ok"
+		if err != nil {
+			outcome = string(errclass.Classify(err))
+		}
+		tags := []metrics.Tag{metrics.T("job_name", job.JobName), metrics.T("outcome", outcome), metrics.T("tenant", job.Tenant)}
+		s.metrics.Timing("job_handler_duration", time.Since(start), tags...)
+		return message, err
+	}
+}
+
+// lockMiddleware runs at most one job of each type at a time across all
+// instances, like listing every job type in JOB_EXCLUSIVE. The job has
+// already been claimed, so a type running elsewhere fails the attempt as a
+// transient error for the retry policy to run again later. JOB_EXCLUSIVE
+// types hold their lock already and pass straight through.
+func (s *Scheduler) lockMiddleware(next Runner) Runner {
+	return func(ctx context.Context, job CronJob) (string, error) {
+		if s.exclusive[job.JobName] {
+			return next(ctx, job)
+		}
+		ctx, loseLock := context.WithCancelCause(ctx)
+		defer loseLock(nil)
+		release, ok := s.holdJobLock(job.JobName, loseLock)
+		if !ok {
+			return "", errclass.TransientError(fmt.Errorf("job type %q is running on another instance", job.JobName))
+		}
+		defer release()
+
+		message, err := next(ctx, job)
+		if cause := context.Cause(ctx); err != nil && errors.Is(cause, errExclusiveLost) {
+			err = errclass.TransientError(fmt.Errorf("%w: %w", cause, err))
+		}
+		return message, err
+	}
+}
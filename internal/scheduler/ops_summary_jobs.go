@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/notify"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+// OpsSummaryParams are the job_params of an "ops_summary" job. ReportDate may
+// use templates such as "{{yesterday}}"; empty Recipients falls back to
+// OPS_SUMMARY_RECIPIENTS.
+type OpsSummaryParams struct {
+	ReportDate string   `json:"report_date"`
+	Sites      []string `json:"sites"`
+	Recipients []string `json:"recipients"`
+}
+
+func (p OpsSummaryParams) Validate() error {
+	if p.ReportDate == "" {
+		return errors.New("report_date is required")
+	}
+	return nil
+}
+
+type siteSummary struct {
+	Site    string
+	Summary ReservationSummary
+	Err     string
+}
+
+type opsSummary struct {
+	ReportDate    string
+	Sites         []siteSummary
+	InvoiceCount  int
+	InvoiceAmount int64
+}
+
+var opsSummaryTemplate = template.Must(template.New("ops_summary").Parse(`<html><body>
+<h2>每日營運摘要 {{.ReportDate}}</h2>
+<h3>高爾夫預約</h3>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>球場</th><th>當日</th><th>當月</th><th>當年</th></tr>
+{{range .Sites}}{{if .Err}}<tr><td>{{.Site}}</td><td colspan="3">錯誤: {{.Err}}</td></tr>
+{{else}}<tr><td>{{.Site}}</td><td>{{.Summary.AmtD}}</td><td>{{.Summary.AmtM}}</td><td>{{.Summary.AmtY}}</td></tr>
+{{end}}{{end}}</table>
+<h3>殯葬發票</h3>
+<p>筆數: {{.InvoiceCount}}，含稅額(除以10)合計: {{.InvoiceAmount}}</p>
+</body></html>`))
+
+// runOpsSummaryJob emails the golf reservation totals per site and the
+// funeral invoice totals for the report date. A site that cannot be reached
+// is reported in the email rather than failing the whole summary.
+func (s *Scheduler) runOpsSummaryJob(ctx context.Context, job CronJob, params OpsSummaryParams) (string, error) {
+	reportDate, err := expandTemplate(params.ReportDate, job)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("report_date: %w", err))
+	}
+	date, err := time.ParseInLocation(dateLayout, reportDate, time.Local)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("report_date must be YYYY-MM-DD: %w", err))
+	}
+
+	recipients := params.Recipients
+	if len(recipients) == 0 {
+		recipients = envlist.Split(os.Getenv("OPS_SUMMARY_RECIPIENTS"))
+	}
+	if len(recipients) == 0 {
+		return "", errclass.ConfigError(errors.New("no recipients: set recipients or OPS_SUMMARY_RECIPIENTS"))
+	}
+	if !notify.Delivers(s.notifier, "ops_summary") {
+		return "", errclass.ConfigError(errors.New("no notification channel delivers ops_summary: add email to NOTIFY_CHANNELS"))
+	}
+
+	sites := params.Sites
+	if len(sites) == 0 {
+		sites = golfSites
+	}
+
+	summary := opsSummary{ReportDate: reportDate}
+	for _, site := range sites {
+		ss := siteSummary{Site: site}
+		if ss.Summary, err = s.golf.ReservationSummary(ctx, site, date); err != nil {
+			ss.Err = err.Error()
+		}
+		summary.Sites = append(summary.Sites, ss)
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(total_amount_dividint10), 0)
+		FROM funeral_invoices
+		WHERE invoice_date = ?
+	`, reportDate).Scan(&summary.InvoiceCount, &summary.InvoiceAmount)
+	if err != nil {
+		return "", fmt.Errorf("summing funeral_invoices: %w", err)
+	}
+
+	var html bytes.Buffer
+	if err := opsSummaryTemplate.Execute(&html, summary); err != nil {
+		return "", fmt.Errorf("rendering summary: %w", err)
+	}
+
+	err = s.notifier.Notify(ctx, notify.Message{
+		Event:      "ops_summary",
+		Subject:    fmt.Sprintf("每日營運摘要 %s", reportDate),
+		Body:       fmt.Sprintf("Daily operations summary for %s: %d invoices, amount %d.", reportDate, summary.InvoiceCount, summary.InvoiceAmount),
+		HTML:       html.String(),
+		Severity:   notify.SeverityInfo,
+		JobName:    job.JobName,
+		JobID:      job.JobID,
+		Recipients: recipients,
+	})
+	if err != nil {
+		return "", errclass.TransientError(fmt.Errorf("sending summary: %w", err))
+	}
+
+	return fmt.Sprintf("sent summary for %s to %s", reportDate, strings.Join(recipients, ", ")), nil
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"database/sql"
+	"hotbrandon/go-cron-be/internal/clock"
+	"hotbrandon/go-cron-be/internal/events"
+	"hotbrandon/go-cron-be/internal/lock"
+	"hotbrandon/go-cron-be/internal/metrics"
+	"hotbrandon/go-cron-be/internal/notify"
+	"hotbrandon/go-cron-be/internal/storage"
+)
+
+// Option customizes a Scheduler created by NewScheduler.
+type Option func(*Scheduler)
+
+// WithNotifier sets where alerts such as watchdog timeouts are delivered.
+// By default they are only logged.
+func WithNotifier(n notify.Notifier) Option {
+	return func(s *Scheduler) {
+		s.notifier = n
+	}
+}
+
+// WithPublishers sets where job lifecycle events from the outbox are
+// delivered. By default they are forwarded to the notifier.
+func WithPublishers(p ...events.Publisher) Option {
+	return func(s *Scheduler) {
+		s.publishers = p
+	}
+}
+
+// WithMetrics sends run counts, durations and scheduler gauges to m. By
+// default they are discarded.
+func WithMetrics(m metrics.Sink) Option {
+	return func(s *Scheduler) {
+		s.metrics = m
+	}
+}
+
+// WithStorage enables archival of exports and old job rows to c.
+func WithStorage(c *storage.Client) Option {
+	return func(s *Scheduler) {
+		s.storage = c
+	}
+}
+
+// WithLocker sets the lock provider for leader election and exclusive job
+// types. The default stores locks in MySQL.
+func WithLocker(l lock.Locker) Option {
+	return func(s *Scheduler) {
+		s.locker = l
+	}
+}
+
+// WithLeaderElection elects the leader through l instead of the lock
+// provider and makes the election decide everything: only the leader creates,
+// dispatches and relays jobs, while every instance still serves the API.
+func WithLeaderElection(l lock.Locker) Option {
+	return func(s *Scheduler) {
+		s.elector = l
+	}
+}
+
+// WithReadReplica serves job listings, audit entries and stats from db, a
+// MySQL read replica, so they do not load the primary. Those reads may lag
+// behind the primary by the replication delay.
+func WithReadReplica(db *sql.DB) Option {
+	return func(s *Scheduler) {
+		s.readDB = db
+	}
+}
+
+// WithClock sets the clock for date logic: the job_date and date templates
+// schedules and triggers enqueue, blackout windows, deadlines and quiet
+// hours. Cron itself still fires on the wall clock; see Simulate for
+// fast-forwarding through schedules. The default is the wall clock.
+func WithClock(c clock.Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = c
+	}
+}
+
+// WithGolfRepository reads golf reservation data from r instead of the
+// sites' Oracle databases.
+func WithGolfRepository(r GolfRepository) Option {
+	return func(s *Scheduler) {
+		s.golf = r
+	}
+}
+
+// WithErpRepository reads the funeral invoice extract from r instead of the
+// ERP's Oracle database.
+func WithErpRepository(r ErpRepository) Option {
+	return func(s *Scheduler) {
+		s.erp = r
+	}
+}
+
+// WithJobStore keeps the job queue in store instead of the cron_jobs and
+// job_runs tables. Outbox events and webhook deliveries are only written by
+// the default store.
+func WithJobStore(store JobStore) Option {
+	return func(s *Scheduler) {
+		s.store = store
+	}
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+	"hotbrandon/go-cron-be/internal/notify"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+)
+
+// loadOracleBreaker reads ORACLE_BREAKER_THRESHOLD and ORACLE_BREAKER_COOLDOWN
+// (a Go duration such as "1m"), configures the per-upstream circuit breakers
+// with them and alerts when one opens or closes.
+func (s *Scheduler) loadOracleBreaker() error {
+	threshold := defaultBreakerThreshold
+	if raw := os.Getenv("ORACLE_BREAKER_THRESHOLD"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return fmt.Errorf("ORACLE_BREAKER_THRESHOLD must be a non-negative integer, got %q", raw)
+		}
+		threshold = n
+	}
+
+	cooldown := defaultBreakerCooldown
+	if raw := os.Getenv("ORACLE_BREAKER_COOLDOWN"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("ORACLE_BREAKER_COOLDOWN must be a positive duration, got %q", raw)
+		}
+		cooldown = d
+	}
+
+	database.ConfigureBreaker(threshold, cooldown)
+	database.OnBreakerChange(func(name string, open bool, err error) {
+		s.breakerChanged(name, open, err, cooldown)
+	})
+	return nil
+}
+
+func (s *Scheduler) breakerChanged(name string, open bool, err error, cooldown time.Duration) {
+	if !open {
+		s.logger.Info("Oracle circuit breaker closed", "upstream", name)
+		s.notify(notify.Message{
+			Event:    "upstream_recovered",
+			Subject:  fmt.Sprintf("%s is reachable again", name),
+			Body:     fmt.Sprintf("Connections to %s succeed again; jobs using it run normally.", name),
+			Severity: notify.SeverityInfo,
+		})
+		return
+	}
+	s.logger.Error("Oracle circuit breaker opened", "upstream", name, "error", err)
+	s.notify(notify.Message{
+		Event:   "upstream_down",
+		Subject: fmt.Sprintf("%s is unreachable", name),
+		Body: fmt.Sprintf("Connecting to %s failed repeatedly, last with: %v\n"+
+			"Jobs using it fail fast and are retried later; a connection is tried again in %s.",
+			name, err, cooldown),
+		Severity: notify.SeverityCritical,
+	})
+}
@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultProcTimeout = 30 * time.Minute
+	// procOutputTimeout bounds reading DBMS_OUTPUT after a call.
+	procOutputTimeout = 30 * time.Second
+)
+
+// procNamePattern accepts [schema.][package.]procedure identifiers. The name
+// is interpolated into the PL/SQL block, so nothing else is allowed.
+var procNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$#]*(\.[A-Za-z][A-Za-z0-9_$#]*){0,2}$`)
+
+// ProcBind is one positional bind parameter of an "oracle_proc" job. Value may
+// use Go templates such as {{.JobDate}}; Type is "string" (default), "number"
+// or "date" (YYYY-MM-DD).
+type ProcBind struct {
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// OracleProcParams are the job_params of an "oracle_proc" job. Connection is
+// "erp" or a golf site id such as "GC".
+type OracleProcParams struct {
+	Connection     string     `json:"connection"`
+	Procedure      string     `json:"procedure"`
+	Binds          []ProcBind `json:"binds"`
+	TimeoutSeconds int        `json:"timeout_seconds"`
+}
+
+func (p OracleProcParams) Validate() error {
+	if p.Connection == "" || strings.EqualFold(p.Connection, "mysql") {
+		return errors.New("connection must be erp or a golf site id")
+	}
+	if !procNamePattern.MatchString(p.Procedure) {
+		return fmt.Errorf("invalid procedure name %q", p.Procedure)
+	}
+	for i, bind := range p.Binds {
+		switch bind.Type {
+		case "", "string", "number", "date":
+		default:
+			return fmt.Errorf("bind %d: unsupported type %q", i+1, bind.Type)
+		}
+	}
+	if p.TimeoutSeconds < 0 {
+		return errors.New("timeout_seconds must not be negative")
+	}
+	return nil
+}
+
+func (s *Scheduler) runOracleProcJob(ctx context.Context, job CronJob, params OracleProcParams) (string, error) {
+	args := make([]any, len(params.Binds))
+	for i, bind := range params.Binds {
+		arg, err := bindValue(bind, job)
+		if err != nil {
+			return "", errclass.DataError(fmt.Errorf("bind %d: %w", i+1, err))
+		}
+		args[i] = arg
+	}
+
+	db, err := s.connection(params.Connection)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := defaultProcTimeout
+	if params.TimeoutSeconds > 0 {
+		timeout = time.Duration(params.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("connecting to %s: %w", params.Connection, err)
+	}
+	defer conn.Close()
+
+	if DryRun(ctx) {
+		// The connection is still checked, but the procedure may write.
+		Logger(ctx).Info("Dry run: not calling procedure", "procedure", params.Procedure, "connection", params.Connection, "binds", args)
+		return fmt.Sprintf("would call %s with %d binds on %s", params.Procedure, len(args), params.Connection), nil
+	}
+
+	if err := callProcedureLogged(ctx, conn, params.Procedure, args...); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("called %s with %d binds on %s", params.Procedure, len(args), params.Connection), nil
+}
+
+// bindValue expands and converts a bind into the Go type the driver expects.
+func bindValue(bind ProcBind, job CronJob) (any, error) {
+	value, err := expandTemplate(bind.Value, job)
+	if err != nil {
+		return nil, err
+	}
+
+	switch bind.Type {
+	case "number":
+		var n float64
+		if _, err := fmt.Sscan(value, &n); err != nil {
+			return nil, fmt.Errorf("invalid number %q", value)
+		}
+		return n, nil
+	case "date":
+		// The driver converts time.Time to Oracle's DATE type.
+		t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: %w", value, err)
+		}
+		return t, nil
+	default:
+		return value, nil
+	}
+}
+
+// callProcedure runs "BEGIN <name>(:1, :2, ...); END;". name must already be
+// validated against procNamePattern.
+func callProcedure(ctx context.Context, db execer, name string, args ...any) error {
+	placeholders := make([]string, len(args))
+	for i := range args {
+		placeholders[i] = fmt.Sprintf(":%d", i+1)
+	}
+	block := fmt.Sprintf("BEGIN %s(%s); END;", name, strings.Join(placeholders, ", "))
+
+	if _, err := db.ExecContext(ctx, block, args...); err != nil {
+		return fmt.Errorf("calling %s: %w", name, err)
+	}
+	return nil
+}
+
+// callProcedureLogged calls the procedure on conn with DBMS_OUTPUT enabled
+// and adds what it printed to the run log, whether or not the call succeeds,
+// since that output is often the only clue to why a procedure failed or
+// produced nothing.
+func callProcedureLogged(ctx context.Context, conn *sql.Conn, name string, args ...any) error {
+	if err := database.EnableOutput(ctx, conn); err != nil {
+		return err
+	}
+	callErr := callProcedure(ctx, conn, name, args...)
+
+	// Read the output even when the call timed out or was cancelled.
+	readCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), procOutputTimeout)
+	defer cancel()
+	lines, err := database.ReadOutput(readCtx, conn)
+	if len(lines) > 0 {
+		LogRun(ctx, "DBMS_OUTPUT of "+name+":")
+		LogRun(ctx, lines...)
+	}
+	if callErr != nil {
+		return callErr
+	}
+	return err
+}
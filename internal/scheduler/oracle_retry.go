@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultOracleRetryAttempts = 3
+	maxOracleRetryAttempts     = 10
+	defaultOracleRetryBackoff  = time.Second
+)
+
+// loadOracleRetry reads ORACLE_RETRY_ATTEMPTS and ORACLE_RETRY_BACKOFF (a Go
+// duration such as "1s") and configures database.Retry with them.
+func (s *Scheduler) loadOracleRetry() error {
+	attempts := defaultOracleRetryAttempts
+	if raw := os.Getenv("ORACLE_RETRY_ATTEMPTS"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxOracleRetryAttempts {
+			return fmt.Errorf("ORACLE_RETRY_ATTEMPTS must be an integer between 0 and %d, got %q", maxOracleRetryAttempts, raw)
+		}
+		attempts = n
+	}
+
+	backoff := defaultOracleRetryBackoff
+	if raw := os.Getenv("ORACLE_RETRY_BACKOFF"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("ORACLE_RETRY_BACKOFF must be a positive duration, got %q", raw)
+		}
+		backoff = d
+	}
+
+	database.ConfigureRetry(attempts, backoff)
+	return nil
+}
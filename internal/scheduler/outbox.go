@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/events"
+	"hotbrandon/go-cron-be/internal/requestid"
+	"time"
+)
+
+const (
+	relaySpec      = "@every 10s"
+	relayBatchSize = 100
+	relayTimeout   = time.Minute
+)
+
+// execer is satisfied by *sql.DB, *sql.Tx and *sql.Conn.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// EnqueueJob creates a pending job and its job_created outbox event in one
+// transaction. It returns created=false without error when an identical job
+// (same job_name, job_date and params) already exists. The request ID in ctx,
+// if any, is stored on the job.
+func (s *Scheduler) EnqueueJob(ctx context.Context, jobName, jobDate string, params any, priority int) (jobID int64, created bool, err error) {
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return 0, false, fmt.Errorf("encoding job_params: %w", err)
+	}
+
+	return s.store.EnqueueJob(ctx, CronJob{
+		JobName:   jobName,
+		Tenant:    s.TenantOf(jobName),
+		JobDate:   jobDate,
+		JobParams: string(paramsJSON),
+		Priority:  priority,
+		RequestID: requestid.FromContext(ctx),
+	})
+}
+
+// insertEvent writes an event to the job_events outbox using ex, which should
+// be the transaction that made the change the event describes.
+func insertEvent(ctx context.Context, ex execer, eventType events.Type, jobID int64, jobName, jobDate string, payload []byte) error {
+	var payloadArg any
+	if len(payload) > 0 {
+		payloadArg = string(payload)
+	}
+
+	_, err := ex.ExecContext(ctx, `
+		INSERT INTO job_events (event_type, job_id, job_name, job_date, payload)
+		VALUES (?, ?, ?, ?, ?)
+	`, string(eventType), jobID, jobName, jobDate, payloadArg)
+	if err != nil {
+		return fmt.Errorf("inserting %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// RelayEvents delivers undelivered outbox events to every publisher in order.
+// Delivery stops at the first failure so events are never reordered; the
+// failed event is retried on the next run. Rows are locked with SKIP LOCKED so
+// several instances can relay concurrently without delivering twice.
+func (s *Scheduler) RelayEvents() {
+	ctx, cancel := context.WithTimeout(s.ctx, relayTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.logger.Error("Failed to begin event relay", "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	pending, err := undeliveredEvents(ctx, tx)
+	if err != nil {
+		s.logger.Error("Failed to load outbox events", "error", err)
+		return
+	}
+
+	delivered := 0
+	for _, e := range pending {
+		if err := s.publish(ctx, e); err != nil {
+			s.logger.Warn("Failed to deliver outbox event", "event_id", e.ID, "event_type", e.Type, "error", err)
+			if _, err := tx.ExecContext(ctx, "UPDATE job_events SET attempts = attempts + 1, last_error = ? WHERE event_id = ?", err.Error(), e.ID); err != nil {
+				s.logger.Error("Failed to record event delivery failure", "event_id", e.ID, "error", err)
+			}
+			break
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE job_events SET attempts = attempts + 1, delivered_at = NOW() WHERE event_id = ?", e.ID); err != nil {
+			s.logger.Error("Failed to mark event delivered", "event_id", e.ID, "error", err)
+			break
+		}
+		delivered++
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("Failed to commit event relay", "error", err)
+		return
+	}
+	if delivered > 0 {
+		s.logger.Debug("Relayed outbox events", "count", delivered)
+	}
+}
+
+func undeliveredEvents(ctx context.Context, tx *sql.Tx) ([]events.Event, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT event_id, event_type, job_id, job_name, job_date, payload, created_at
+		FROM job_events
+		WHERE delivered_at IS NULL
+		ORDER BY event_id
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED
+	`, relayBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []events.Event
+	for rows.Next() {
+		var e events.Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.JobID, &e.JobName, &e.JobDate, &payload, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		e.Payload = payload
+		pending = append(pending, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return pending, nil
+}
+
+// publish delivers e to every publisher and joins their errors.
+func (s *Scheduler) publish(ctx context.Context, e events.Event) error {
+	var errs []error
+	for _, p := range s.publishers {
+		if err := p.Publish(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultOutputLimit keeps messages inside cron_jobs.message, a TEXT column
+// of at most 65535 bytes.
+const defaultOutputLimit = 60000
+
+func (s *Scheduler) loadOutputLimit() error {
+	s.outputLimit = defaultOutputLimit
+	raw := os.Getenv("JOB_OUTPUT_LIMIT")
+	if raw == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1024 || n > defaultOutputLimit {
+		return fmt.Errorf("JOB_OUTPUT_LIMIT must be a number of bytes between 1024 and %d, got %q", defaultOutputLimit, raw)
+	}
+	s.outputLimit = n
+	return nil
+}
+
+// capOutput enforces the output limit on a finished run's message and
+// result. Oversized values are uploaded to outputs/ in the storage bucket
+// and replaced by a pointer to the object: the message keeps its head
+// followed by the object URL, and the result becomes
+// {"overflow": "<url>", "bytes": n}. Without storage they are truncated.
+func (s *Scheduler) capOutput(job CronJob, runID int64, message string, result json.RawMessage) (string, json.RawMessage) {
+	if s.outputLimit == 0 || (len(message) <= s.outputLimit && len(result) <= s.outputLimit) {
+		return message, result
+	}
+
+	// The run may have been cancelled by shutdown; still try to keep the output.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if len(message) > s.outputLimit {
+		note := fmt.Sprintf("\n... [truncated, %d bytes]", len(message))
+		if url := s.spill(ctx, job, runID, "message.txt", []byte(message), "text/plain; charset=utf-8"); url != "" {
+			note = fmt.Sprintf("\n... [truncated, %d bytes; full message at %s]", len(message), url)
+		}
+		message = truncateUTF8(message, s.outputLimit-len(note)) + note
+	}
+	if len(result) > s.outputLimit {
+		pointer := map[string]any{"bytes": len(result)}
+		if url := s.spill(ctx, job, runID, "result.json", result, "application/json"); url != "" {
+			pointer["overflow"] = url
+		} else {
+			pointer["truncated"] = true
+		}
+		result, _ = json.Marshal(pointer)
+	}
+	return message, result
+}
+
+// spill uploads an oversized output and returns its URL, or "" when there is
+// no storage or the upload failed.
+func (s *Scheduler) spill(ctx context.Context, job CronJob, runID int64, name string, data []byte, contentType string) string {
+	if s.storage == nil {
+		return ""
+	}
+	key := s.storage.Key("outputs", job.JobDate, fmt.Sprintf("job_%d_run_%d_%s", job.JobID, runID, name))
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		s.logger.Warn("Failed to store oversized job output", "job_id", job.JobID, "key", key, "error", err)
+		return ""
+	}
+	return s.storage.URL(key)
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPluginTimeout = 30 * time.Minute
+	// pluginTempFail is the exit code (EX_TEMPFAIL) a plugin uses to report
+	// a transient failure worth retrying.
+	pluginTempFail = 75
+	// maxPluginLogLine is the longest stderr line a plugin can log; past it
+	// the rest of stderr is discarded.
+	maxPluginLogLine = 1024 * 1024
+)
+
+// pluginRequest is written as JSON to a plugin's stdin.
+type pluginRequest struct {
+	JobID   int64           `json:"job_id"`
+	JobName string          `json:"job_name"`
+	JobDate string          `json:"job_date"`
+	Attempt int             `json:"attempt"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// pluginResponse is what a plugin may print on stdout instead of a plain
+// message, to also report a structured result.
+type pluginResponse struct {
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// loadPlugins registers a job type for every executable in PLUGINS_DIR,
+// named after the file without its extension, so teams can ship their own
+// jobs without changing the scheduler. See runPlugin for the protocol.
+func (s *Scheduler) loadPlugins() error {
+	dir := os.Getenv("PLUGINS_DIR")
+	if dir == "" {
+		return nil
+	}
+
+	timeout := defaultPluginTimeout
+	if raw := os.Getenv("PLUGIN_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("PLUGIN_TIMEOUT must be a positive duration, got %q", raw)
+		}
+		timeout = d
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading PLUGINS_DIR: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !isPluginExecutable(info) {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if _, exists := s.jobTypes[name]; exists {
+			return fmt.Errorf("plugin %s: job type %q is already registered", entry.Name(), name)
+		}
+		path := filepath.Join(dir, entry.Name())
+		RegisterJobType(s, name, func(ctx context.Context, job CronJob, params json.RawMessage) (string, error) {
+			return runPlugin(ctx, path, timeout, job, params)
+		})
+		names = append(names, name)
+	}
+	s.markPrivileged(names...)
+	s.logger.Info("Plugins loaded", "dir", dir, "job_types", names)
+	return nil
+}
+
+// isPluginExecutable skips directories, dotfiles and, outside Windows, files
+// without an execute bit.
+func isPluginExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return slices.Contains([]string{".exe", ".bat", ".cmd"}, strings.ToLower(filepath.Ext(info.Name())))
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+// runPlugin runs the plugin at path as "<path> run" with a pluginRequest on
+// stdin. Each line the plugin writes to stderr goes to the job's log. Its
+// stdout is the job message, or a pluginResponse when it is a JSON object
+// with a message or result. A non-zero exit fails the run, as a transient
+// failure for exit code 75.
+func runPlugin(ctx context.Context, path string, timeout time.Duration, job CronJob, params json.RawMessage) (string, error) {
+	request, err := json.Marshal(pluginRequest{
+		JobID: job.JobID, JobName: job.JobName, JobDate: job.JobDate, Attempt: job.Attempts, Params: params,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: maxShellOutput}
+	cmd := exec.CommandContext(ctx, path, "run")
+	cmd.Stdin = strings.NewReader(string(request))
+	cmd.Stdout = stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", errclass.ConfigError(fmt.Errorf("starting plugin %s: %w", filepath.Base(path), err))
+	}
+
+	logger := Logger(ctx).With("plugin", filepath.Base(path))
+	lines := bufio.NewScanner(stderr)
+	lines.Buffer(make([]byte, 64*1024), maxPluginLogLine)
+	var lastLine string
+	for lines.Scan() {
+		lastLine = lines.Text()
+		logger.Info(lastLine)
+	}
+	if err := lines.Err(); err != nil {
+		// Keep reading, or a plugin blocked writing stderr would never exit.
+		logger.Warn("Discarding the rest of the plugin's stderr", "error", err)
+		io.Copy(io.Discard, stderr)
+	}
+	err = cmd.Wait()
+
+	message := stdout.String()
+	var response pluginResponse
+	if json.Unmarshal([]byte(message), &response) == nil && (response.Message != "" || len(response.Result) > 0) {
+		message = response.Message
+		if len(response.Result) > 0 {
+			if err := SetResult(ctx, response.Result); err != nil {
+				return message, err
+			}
+		}
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return message, fmt.Errorf("plugin %s timed out after %s", filepath.Base(path), timeout)
+	}
+	if err != nil {
+		if lastLine != "" {
+			err = fmt.Errorf("%w: %s", err, lastLine)
+		}
+		err = fmt.Errorf("plugin %s: %w", filepath.Base(path), err)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == pluginTempFail {
+			return message, errclass.TransientError(err)
+		}
+		return message, err
+	}
+	return message, nil
+}
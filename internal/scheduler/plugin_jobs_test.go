@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// A plugin that writes a stderr line longer than maxPluginLogLine must not
+// block on a full pipe until its timeout.
+func TestRunPluginLongStderrLine(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "noisy")
+	script := "#!/bin/sh\nhead -c 3000000 /dev/zero | tr '\\0' x >&2\necho >&2\necho done\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	message, err := runPlugin(context.Background(), path, 10*time.Second, CronJob{JobName: "noisy"}, nil)
+	if err != nil || message != "done\n" {
+		t.Fatalf("runPlugin = %q, %v", message, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runPlugin took %s", elapsed)
+	}
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const preflightTimeout = 15 * time.Second
+
+// loadPreflight reads ORACLE_PREFLIGHT: "off" (the default) skips the Oracle
+// checks at startup, "strict" refuses to start while any configured Oracle
+// database is unreachable, and "lazy" reports unreachable ones and leaves
+// them to be connected on first use.
+func (s *Scheduler) loadPreflight() error {
+	mode := strings.ToLower(os.Getenv("ORACLE_PREFLIGHT"))
+	switch mode {
+	case "":
+		mode = "off"
+	case "off", "strict", "lazy":
+	default:
+		return fmt.Errorf("ORACLE_PREFLIGHT must be off, strict or lazy, got %q", mode)
+	}
+	s.preflight = mode
+	return nil
+}
+
+// checkOracle pings the ERP and every golf site with a DSN in parallel and
+// logs one readiness summary.
+func (s *Scheduler) checkOracle() error {
+	if s.preflight == "off" {
+		return nil
+	}
+
+	upstreams := map[string]func() (*sql.DB, error){"ERP": database.GetErpConnection}
+	for _, site := range golfSites {
+		if os.Getenv("ORACLE_DSN_"+site) != "" {
+			upstreams[site] = func() (*sql.DB, error) { return database.GetGolfConnection(site) }
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, preflightTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	status := make(map[string]string, len(upstreams))
+	var down []string
+	for name, open := range upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := pingOracle(ctx, open)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				status[name] = "unreachable: " + err.Error()
+				down = append(down, name)
+				return
+			}
+			status[name] = fmt.Sprintf("ok in %s", time.Since(start).Round(time.Millisecond))
+		}()
+	}
+	wg.Wait()
+
+	args := make([]any, 0, 2*len(status)+2)
+	args = append(args, "mode", s.preflight)
+	for _, name := range slices.Sorted(maps.Keys(status)) {
+		args = append(args, name, status[name])
+	}
+	if len(down) == 0 {
+		s.logger.Info("Oracle preflight passed", args...)
+		return nil
+	}
+	if s.preflight == "strict" {
+		s.logger.Error("Oracle preflight failed", args...)
+		slices.Sort(down)
+		return fmt.Errorf("oracle preflight: %s unreachable", strings.Join(down, ", "))
+	}
+	s.logger.Warn("Oracle preflight: unreachable databases deferred to first use", args...)
+	return nil
+}
+
+func pingOracle(ctx context.Context, open func() (*sql.DB, error)) error {
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
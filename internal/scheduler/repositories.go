@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// GolfRepository reads a golf site's reservation data. The default reads
+// the site's Oracle database; tests and alternative sources provide their
+// own with WithGolfRepository.
+type GolfRepository interface {
+	ReservationSummary(ctx context.Context, siteID string, resvDate time.Time) (ReservationSummary, error)
+}
+
+// ErpRepository reads the funeral invoice extract of the ERP, see
+// EachFuneralInvoice and EachFuneralInvoicePage for the contract. The
+// default reads the ERP's Oracle database; replace it with
+// WithErpRepository.
+type ErpRepository interface {
+	EachFuneralInvoice(ctx context.Context, invoiceDate time.Time, fn func(FuneralInvoiceRow) error) error
+	EachFuneralInvoicePage(ctx context.Context, invoiceDate time.Time, skip, pageSize int, fn func(page []FuneralInvoiceRow, offset int) error) error
+}
+
+// oracleGolf is the GolfRepository over the sites' Oracle databases.
+type oracleGolf struct{}
+
+func (oracleGolf) ReservationSummary(ctx context.Context, siteID string, resvDate time.Time) (ReservationSummary, error) {
+	return GetReservationSummary(ctx, siteID, resvDate)
+}
+
+// oracleErp is the ErpRepository over the ERP's Oracle database.
+type oracleErp struct{}
+
+func (oracleErp) EachFuneralInvoice(ctx context.Context, invoiceDate time.Time, fn func(FuneralInvoiceRow) error) error {
+	return EachFuneralInvoice(ctx, invoiceDate, fn)
+}
+
+func (oracleErp) EachFuneralInvoicePage(ctx context.Context, invoiceDate time.Time, skip, pageSize int, fn func(page []FuneralInvoiceRow, offset int) error) error {
+	return EachFuneralInvoicePage(ctx, invoiceDate, skip, pageSize, fn)
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+type resultKey struct{}
+
+// jobResult collects the structured result a handler reports with SetResult.
+type jobResult struct {
+	mu    sync.Mutex
+	value json.RawMessage
+}
+
+func withJobResult(ctx context.Context) (context.Context, *jobResult) {
+	r := &jobResult{}
+	return context.WithValue(ctx, resultKey{}, r), r
+}
+
+func (r *jobResult) get() json.RawMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.value
+}
+
+// SetResult records v as the running job's structured result. It is stored
+// as JSON in cron_jobs.job_result and on the job_runs record when the job
+// ends, whether it succeeds or fails, so results can be queried instead of
+// parsed out of the message. A later call replaces an earlier one; outside a
+// job it does nothing.
+func SetResult(ctx context.Context, v any) error {
+	r, ok := ctx.Value(resultKey{}).(*jobResult)
+	if !ok {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding job result: %w", err)
+	}
+	r.mu.Lock()
+	r.value = raw
+	r.mu.Unlock()
+	return nil
+}
+
+// resultArg binds a result as SQL NULL when the handler reported none.
+func resultArg(result json.RawMessage) any {
+	if len(result) == 0 {
+		return nil
+	}
+	return string(result)
+}
@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how a job type is retried after a failed attempt.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt; 1 disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter randomizes each delay by up to ±Jitter (0–1) of its value.
+	Jitter  float64
+	RetryOn []errclass.Category
+}
+
+// defaultRetryPolicy applies to job types without their own policy.
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Minute,
+	MaxDelay:    30 * time.Minute,
+	Jitter:      0.1,
+	RetryOn:     []errclass.Category{errclass.Transient},
+}
+
+// ShouldRetry reports whether a failure of category on the given attempt
+// (1-based) should be retried.
+func (p RetryPolicy) ShouldRetry(category errclass.Category, attempt int) bool {
+	return attempt < p.MaxAttempts && slices.Contains(p.RetryOn, category)
+}
+
+// Delay doubles BaseDelay with every attempt, caps it at MaxDelay and then
+// applies jitter.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	return max(delay, 0)
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return errors.New("max_attempts must be at least 1")
+	}
+	if p.BaseDelay < 0 || p.MaxDelay < p.BaseDelay {
+		return errors.New("delays must satisfy 0 <= base_delay <= max_delay")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return errors.New("jitter must be between 0 and 1")
+	}
+	for _, c := range p.RetryOn {
+		switch c {
+		case errclass.Transient, errclass.Data, errclass.Config, errclass.Unknown:
+		default:
+			return fmt.Errorf("unknown retry_on category %q", c)
+		}
+	}
+	return nil
+}
+
+// retryPolicyConfig is the JSON form of a RetryPolicy. Omitted fields fall
+// back to defaultRetryPolicy.
+type retryPolicyConfig struct {
+	MaxAttempts *int                `json:"max_attempts"`
+	BaseDelay   string              `json:"base_delay"`
+	MaxDelay    string              `json:"max_delay"`
+	Jitter      *float64            `json:"jitter"`
+	RetryOn     []errclass.Category `json:"retry_on"`
+}
+
+func (c retryPolicyConfig) policy() (RetryPolicy, error) {
+	p := defaultRetryPolicy
+	if c.MaxAttempts != nil {
+		p.MaxAttempts = *c.MaxAttempts
+	}
+	if c.BaseDelay != "" {
+		d, err := time.ParseDuration(c.BaseDelay)
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("base_delay: %w", err)
+		}
+		p.BaseDelay = d
+	}
+	if c.MaxDelay != "" {
+		d, err := time.ParseDuration(c.MaxDelay)
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("max_delay: %w", err)
+		}
+		p.MaxDelay = d
+	}
+	if c.Jitter != nil {
+		p.Jitter = *c.Jitter
+	}
+	if c.RetryOn != nil {
+		p.RetryOn = c.RetryOn
+	}
+	return p, p.validate()
+}
+
+// loadRetryPolicies reads JOB_RETRY_POLICIES, a JSON object keyed by
+// job_name, e.g. {"golf": {"max_attempts": 5, "retry_on": ["transient", "unknown"]}}.
+func (s *Scheduler) loadRetryPolicies() error {
+	s.retryPolicies = make(map[string]RetryPolicy)
+	raw := os.Getenv("JOB_RETRY_POLICIES")
+	if raw == "" {
+		return nil
+	}
+
+	var configs map[string]retryPolicyConfig
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&configs); err != nil {
+		return fmt.Errorf("parsing JOB_RETRY_POLICIES: %w", err)
+	}
+	for jobName, c := range configs {
+		p, err := c.policy()
+		if err != nil {
+			return fmt.Errorf("parsing JOB_RETRY_POLICIES: %s: %w", jobName, err)
+		}
+		s.retryPolicies[jobName] = p
+	}
+	return nil
+}
+
+// retryPolicy returns the policy for jobName. A row in retry_policies takes
+// precedence over JOB_RETRY_POLICIES so operators can tune retries without a
+// restart; it is looked up on every failure.
+func (s *Scheduler) retryPolicy(jobName string) RetryPolicy {
+	p, ok, err := s.store.StoredRetryPolicy(context.Background(), jobName)
+	switch {
+	case err != nil:
+		s.logger.Warn("Failed to load retry policy, using configured policy", "job_name", jobName, "error", err)
+	case ok:
+		if err := p.validate(); err != nil {
+			s.logger.Warn("Ignoring invalid retry_policies row", "job_name", jobName, "error", err)
+			break
+		}
+		return p
+	}
+
+	if p, ok := s.retryPolicies[jobName]; ok {
+		return p
+	}
+	return defaultRetryPolicy
+}
@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// RunRef identifies one side of a RunDiff.
+type RunRef struct {
+	RunID     int64  `json:"run_id"`
+	JobID     int64  `json:"job_id"`
+	RunStatus string `json:"run_status"`
+}
+
+// ResultChange is one value that differs between two run results. Path is a
+// JSON path such as $.amt_d or $.rows[2].amount. Before is absent for added
+// values and After for removed ones; Delta is set when both are numbers.
+type ResultChange struct {
+	Path   string          `json:"path"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+	Delta  *float64        `json:"delta,omitempty"`
+}
+
+// RunDiff compares the results of two runs of the same job name and date.
+type RunDiff struct {
+	JobName string         `json:"job_name"`
+	JobDate string         `json:"job_date"`
+	From    RunRef         `json:"from"`
+	To      RunRef         `json:"to"`
+	Changes []ResultChange `json:"changes"`
+}
+
+type storedRun struct {
+	RunRef
+	jobName string
+	jobDate string
+	result  []byte
+}
+
+// DiffRuns compares the stored results of runs from and to, for example a
+// rerun after a data correction against the original. It returns
+// sql.ErrNoRows if either run does not exist, and a data error if they
+// belong to different job names or dates or either has no result.
+func (s *Scheduler) DiffRuns(ctx context.Context, from, to int64) (RunDiff, error) {
+	a, err := s.storedRun(ctx, from)
+	if err != nil {
+		return RunDiff{}, err
+	}
+	b, err := s.storedRun(ctx, to)
+	if err != nil {
+		return RunDiff{}, err
+	}
+	if a.jobName != b.jobName || a.jobDate != b.jobDate {
+		return RunDiff{}, errclass.DataError(fmt.Errorf("run %d is %s %s but run %d is %s %s",
+			from, a.jobName, a.jobDate, to, b.jobName, b.jobDate))
+	}
+	for _, r := range []storedRun{a, b} {
+		if len(r.result) == 0 {
+			return RunDiff{}, errclass.DataError(fmt.Errorf("run %d has no stored result", r.RunID))
+		}
+	}
+
+	before, err := decodeResult(a.result)
+	if err != nil {
+		return RunDiff{}, fmt.Errorf("decoding result of run %d: %w", from, err)
+	}
+	after, err := decodeResult(b.result)
+	if err != nil {
+		return RunDiff{}, fmt.Errorf("decoding result of run %d: %w", to, err)
+	}
+
+	diff := RunDiff{JobName: a.jobName, JobDate: a.jobDate, From: a.RunRef, To: b.RunRef, Changes: []ResultChange{}}
+	diffValues("$", before, after, &diff.Changes)
+	return diff, nil
+}
+
+// storedRun loads a run together with its job's name and date.
+func (s *Scheduler) storedRun(ctx context.Context, runID int64) (storedRun, error) {
+	var r storedRun
+	err := s.db.QueryRowContext(ctx, `
+		SELECT r.run_id, r.job_id, r.run_status, j.job_name, j.job_date, r.job_result
+		FROM job_runs r JOIN cron_jobs j ON j.job_id = r.job_id
+		WHERE r.run_id = ?
+	`, runID).Scan(&r.RunID, &r.JobID, &r.RunStatus, &r.jobName, &r.jobDate, &r.result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, err
+	}
+	if err != nil {
+		return r, fmt.Errorf("querying run %d: %w", runID, err)
+	}
+	return r, nil
+}
+
+// decodeResult keeps numbers as json.Number so they compare exactly.
+func decodeResult(raw []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// diffValues appends the differences between a and b under path. Objects
+// are compared key by key and arrays element by element; anything else that
+// differs is reported whole.
+func diffValues(path string, a, b any, changes *[]ResultChange) {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := slices.Collect(maps.Keys(a))
+			for key := range b {
+				if _, ok := a[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				av, inA := a[key]
+				bv, inB := b[key]
+				switch {
+				case !inA:
+					*changes = append(*changes, ResultChange{Path: path + "." + key, After: encodeValue(bv)})
+				case !inB:
+					*changes = append(*changes, ResultChange{Path: path + "." + key, Before: encodeValue(av)})
+				default:
+					diffValues(path+"."+key, av, bv, changes)
+				}
+			}
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			for i := range max(len(a), len(b)) {
+				elemPath := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(a):
+					*changes = append(*changes, ResultChange{Path: elemPath, After: encodeValue(b[i])})
+				case i >= len(b):
+					*changes = append(*changes, ResultChange{Path: elemPath, Before: encodeValue(a[i])})
+				default:
+					diffValues(elemPath, a[i], b[i], changes)
+				}
+			}
+			return
+		}
+	case json.Number:
+		if b, ok := b.(json.Number); ok {
+			af, errA := a.Float64()
+			bf, errB := b.Float64()
+			if errA == nil && errB == nil {
+				if af != bf {
+					delta := bf - af
+					*changes = append(*changes, ResultChange{Path: path, Before: encodeValue(a), After: encodeValue(b), Delta: &delta})
+				}
+				return
+			}
+		}
+	}
+
+	if !equalValues(a, b) {
+		*changes = append(*changes, ResultChange{Path: path, Before: encodeValue(a), After: encodeValue(b)})
+	}
+}
+
+func equalValues(a, b any) bool {
+	return bytes.Equal(encodeValue(a), encodeValue(b))
+}
+
+func encodeValue(v any) json.RawMessage {
+	raw, _ := json.Marshal(v)
+	return raw
+}
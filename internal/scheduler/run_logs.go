@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// maxRunLogLines caps the lines kept per run, so a handler logging in a loop
+// cannot fill job_run_logs.
+const maxRunLogLines = 5000
+
+// runLogCapture keeps the lines a job logs through Logger(ctx), formatted
+// by a slog.TextHandler, for job_run_logs.
+type runLogCapture struct {
+	mu      sync.Mutex
+	lines   []string
+	dropped int
+}
+
+// Write receives one formatted record per call from the text handler.
+func (c *runLogCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.lines) >= maxRunLogLines {
+		c.dropped++
+		return len(p), nil
+	}
+	c.lines = append(c.lines, strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+func (c *runLogCapture) snapshot() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lines := slices.Clone(c.lines)
+	if c.dropped > 0 {
+		lines = append(lines, fmt.Sprintf("... %d more lines not kept", c.dropped))
+	}
+	return lines
+}
+
+// captureRunLog returns logger with every record, at any level, also
+// written to the returned capture.
+func captureRunLog(logger *slog.Logger) (*slog.Logger, *runLogCapture) {
+	c := &runLogCapture{}
+	capture := slog.NewTextHandler(c, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(teeHandler{primary: logger.Handler(), capture: capture}), c
+}
+
+// teeHandler sends records to primary as usual and to capture regardless of
+// primary's level.
+type teeHandler struct {
+	primary slog.Handler
+	capture slog.Handler
+}
+
+func (h teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.primary.Enabled(ctx, level) || h.capture.Enabled(ctx, level)
+}
+
+func (h teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	if h.primary.Enabled(ctx, r.Level) {
+		err = h.primary.Handle(ctx, r.Clone())
+	}
+	if h.capture.Enabled(ctx, r.Level) {
+		h.capture.Handle(ctx, r)
+	}
+	return err
+}
+
+func (h teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return teeHandler{primary: h.primary.WithAttrs(attrs), capture: h.capture.WithAttrs(attrs)}
+}
+
+func (h teeHandler) WithGroup(name string) slog.Handler {
+	return teeHandler{primary: h.primary.WithGroup(name), capture: h.capture.WithGroup(name)}
+}
+
+// saveRunLogs stores the lines captured during one run. Failing to store
+// them only loses diagnostics, so it is logged and otherwise ignored.
+func (s *Scheduler) saveRunLogs(job CronJob, runID int64, c *runLogCapture) {
+	lines := c.snapshot()
+	if runID == 0 || len(lines) == 0 {
+		return
+	}
+	if err := s.store.SaveRunLogs(context.Background(), job, runID, lines); err != nil {
+		s.logger.Error("Failed to save run logs", "job_id", job.JobID, "run_id", runID, "error", err)
+	}
+}
+
+// RunJobName returns the name of the job runID belongs to, or sql.ErrNoRows
+// if jobID has no such run.
+func (s *Scheduler) RunJobName(ctx context.Context, jobID, runID int64) (string, error) {
+	var jobName string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT j.job_name FROM job_runs r JOIN cron_jobs j ON j.job_id = r.job_id
+		WHERE r.job_id = ? AND r.run_id = ?
+	`, jobID, runID).Scan(&jobName)
+	return jobName, err
+}
+
+// EachRunLogLine calls fn with the captured log lines of runID in the order
+// they were logged. Lines are stored when the run ends, so a running job has
+// none yet.
+func (s *Scheduler) EachRunLogLine(ctx context.Context, runID int64, fn func(line string) error) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT line FROM job_run_logs WHERE run_id = ? ORDER BY seq", runID)
+	if err != nil {
+		return fmt.Errorf("querying job_run_logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("scanning row: %w", err)
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
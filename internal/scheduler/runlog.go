@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+type runLogKey struct{}
+
+// runLog collects diagnostic lines a handler reports with LogRun.
+type runLog struct {
+	mu        sync.Mutex
+	lines     []string
+	endpoints map[string]string
+}
+
+func withRunLog(ctx context.Context) (context.Context, *runLog) {
+	l := &runLog{}
+	return context.WithValue(ctx, runLogKey{}, l), l
+}
+
+// appendTo adds the collected lines after message.
+func (l *runLog) appendTo(message string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.lines) == 0 {
+		return message
+	}
+	logged := strings.Join(l.lines, "\n")
+	if message == "" {
+		return logged
+	}
+	return message + "\n" + logged
+}
+
+// LogRun adds lines to the running job's run log. They are appended to the
+// message stored on the job and its job_runs record whether the job succeeds
+// or fails, for output that helps diagnose a run but is not its outcome, such
+// as what a stored procedure printed. Outside a job it does nothing.
+func LogRun(ctx context.Context, lines ...string) {
+	l, ok := ctx.Value(runLogKey{}).(*runLog)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	l.lines = append(l.lines, lines...)
+	l.mu.Unlock()
+}
+
+// endpointReporter notes in the run log which endpoint, primary or standby,
+// served each upstream the job connected to, once per change.
+func (l *runLog) endpointReporter() func(upstream, endpoint string) {
+	return func(upstream, endpoint string) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.endpoints[upstream] == endpoint {
+			return
+		}
+		if l.endpoints == nil {
+			l.endpoints = map[string]string{}
+		}
+		l.endpoints[upstream] = endpoint
+		l.lines = append(l.lines, upstream+" served by "+endpoint)
+	}
+}
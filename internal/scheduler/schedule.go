@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maxPreviewRuns caps how many fire times a preview may request.
+const maxPreviewRuns = 100
+
+type SchedulePreview struct {
+	Spec     string      `json:"spec"`
+	Timezone string      `json:"timezone"`
+	NextRuns []time.Time `json:"next_runs"`
+	Warnings []string    `json:"warnings,omitempty"`
+}
+
+// PreviewSchedule parses a standard 5-field cron expression (or a descriptor
+// such as "@daily") and returns its next n fire times after from, in loc.
+func PreviewSchedule(spec string, from time.Time, n int, loc *time.Location) (SchedulePreview, error) {
+	if n <= 0 || n > maxPreviewRuns {
+		return SchedulePreview{}, fmt.Errorf("number of runs must be between 1 and %d", maxPreviewRuns)
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return SchedulePreview{}, fmt.Errorf("parsing cron expression %q: %w", spec, err)
+	}
+
+	preview := SchedulePreview{
+		Spec:     spec,
+		Timezone: timezoneName(loc),
+		Warnings: scheduleWarnings(spec),
+	}
+
+	next := from.In(loc)
+	for i := 0; i < n; i++ {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			// The schedule can never fire again (e.g. "0 0 30 2 *").
+			break
+		}
+		preview.NextRuns = append(preview.NextRuns, next)
+	}
+	if len(preview.NextRuns) == 0 {
+		return SchedulePreview{}, fmt.Errorf("cron expression %q never fires", spec)
+	}
+
+	return preview, nil
+}
+
+// PreviewSchedule previews spec, an expression or SCHEDULE_ALIASES name, in
+// the scheduler's configured timezone.
+func (s *Scheduler) PreviewSchedule(spec string, n int) (SchedulePreview, error) {
+	return PreviewSchedule(s.resolveSchedule(spec), s.now(), n, s.c.Location())
+}
+
+// scheduleWarnings flags expressions that are valid but rarely what the
+// operator meant, such as "* 12 * * *" firing every minute of the noon hour.
+func scheduleWarnings(spec string) []string {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil
+	}
+
+	var warnings []string
+	if fields[0] == "*" && fields[1] != "*" {
+		warnings = append(warnings, fmt.Sprintf("minute field is '*': fires every minute of hour(s) %s", fields[1]))
+	}
+	return warnings
+}
+
+// timezoneName reports the TZ name behind time.Local, which otherwise
+// stringifies as "Local".
+func timezoneName(loc *time.Location) string {
+	if tz := os.Getenv("TZ"); loc == time.Local && tz != "" {
+		return tz
+	}
+	return loc.String()
+}
+
+// now is the scheduler clock's time in the cron timezone.
+func (s *Scheduler) now() time.Time {
+	return s.clock.Now().In(s.c.Location())
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+const defaultGolfSchedule = "* 12 * * *"
+
+// loadScheduleAliases reads SCHEDULE_ALIASES, a JSON object of named cron
+// expressions such as {"daily-noon": "0 12 * * *"}. Any schedule setting may
+// use a name instead of an expression, so a company-wide batch window is
+// changed in one place.
+func (s *Scheduler) loadScheduleAliases() error {
+	s.scheduleAliases = nil
+	raw := os.Getenv("SCHEDULE_ALIASES")
+	if raw == "" {
+		return nil
+	}
+
+	var aliases map[string]string
+	if err := json.Unmarshal([]byte(raw), &aliases); err != nil {
+		return fmt.Errorf("parsing SCHEDULE_ALIASES: %w", err)
+	}
+	for name, spec := range aliases {
+		if name == "" || strings.ContainsAny(name, " \t@") {
+			return fmt.Errorf("parsing SCHEDULE_ALIASES: invalid name %q", name)
+		}
+		if _, err := cron.ParseStandard(spec); err != nil {
+			return fmt.Errorf("parsing SCHEDULE_ALIASES: %s: %w", name, err)
+		}
+	}
+	s.scheduleAliases = aliases
+	return nil
+}
+
+// resolveSchedule returns the expression spec names if it is an alias, and
+// spec itself otherwise.
+func (s *Scheduler) resolveSchedule(spec string) string {
+	if expr, ok := s.scheduleAliases[strings.TrimSpace(spec)]; ok {
+		return expr
+	}
+	return spec
+}
+
+// golfSchedule is GOLF_SCHEDULE, when golf jobs are created, or
+// defaultGolfSchedule.
+func (s *Scheduler) golfSchedule() string {
+	if spec := os.Getenv("GOLF_SCHEDULE"); spec != "" {
+		return s.resolveSchedule(spec)
+	}
+	return defaultGolfSchedule
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ScheduleInfo describes a registered schedule for GET /schedules.
+type ScheduleInfo struct {
+	Name    string `json:"name"`
+	JobName string `json:"job_name"`
+	Spec    string `json:"spec"`
+	// Stored is true for schedules added through the API, which can be
+	// removed again
+	Stored  bool `json:"stored"`
+	Paused  bool `json:"paused"`
+	Enabled bool `json:"enabled"`
+	// Prev is zero until the entry has fired on this instance
+	Next       time.Time       `json:"next"`
+	Prev       time.Time       `json:"prev"`
+	LastResult *ScheduleResult `json:"last_result"`
+}
+
+// ScheduleResult is the latest job of a schedule's job type.
+type ScheduleResult struct {
+	JobID      int64      `json:"job_id"`
+	JobDate    string     `json:"job_date"`
+	Status     string     `json:"status"`
+	Message    string     `json:"message"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// Schedules lists the schedules that create jobs with their next and
+// previous fire times and the latest job of their job type.
+func (s *Scheduler) Schedules(ctx context.Context) ([]ScheduleInfo, error) {
+	s.scheduleMu.Lock()
+	entries := append([]scheduleEntry(nil), s.scheduleEntries...)
+	s.scheduleMu.Unlock()
+
+	paused, err := s.pausedSchedules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ScheduleInfo, 0, len(entries))
+	for _, e := range entries {
+		cronEntry := s.c.Entry(e.ID)
+		info := ScheduleInfo{
+			Name:    e.Name,
+			JobName: e.JobName,
+			Spec:    e.Spec,
+			Stored:  e.Stored,
+			Paused:  paused[e.Name],
+			Enabled: s.enabled(flagSchedule, e.Name),
+			Next:    cronEntry.Next,
+			Prev:    cronEntry.Prev,
+		}
+		if info.LastResult, err = s.lastScheduleResult(ctx, e.JobName); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *Scheduler) pausedSchedules(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.readDB.QueryContext(ctx, "SELECT name FROM schedule_pauses")
+	if err != nil {
+		return nil, fmt.Errorf("querying schedule_pauses: %w", err)
+	}
+	defer rows.Close()
+
+	paused := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		paused[name] = true
+	}
+	return paused, rows.Err()
+}
+
+// lastScheduleResult returns the latest job named jobName, or nil if there
+// is none yet.
+func (s *Scheduler) lastScheduleResult(ctx context.Context, jobName string) (*ScheduleResult, error) {
+	var result ScheduleResult
+	var message sql.NullString
+	err := s.readDB.QueryRowContext(ctx, `
+		SELECT job_id, job_date, job_status, message, finished_at FROM cron_jobs
+		WHERE job_name = ?
+		ORDER BY job_id DESC
+		LIMIT 1
+	`, jobName).Scan(&result.JobID, &result.JobDate, &result.Status, &message, &result.FinishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying latest %s job: %w", jobName, err)
+	}
+	result.Message = message.String
+	return &result, nil
+}
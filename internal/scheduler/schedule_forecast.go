@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"time"
+)
+
+// ScheduleForecast lists the next fire times of one schedule.
+type ScheduleForecast struct {
+	Name    string        `json:"name"`
+	JobName string        `json:"job_name"`
+	Spec    string        `json:"spec"`
+	Paused  bool          `json:"paused"`
+	Enabled bool          `json:"enabled"`
+	Runs    []ForecastRun `json:"runs"`
+}
+
+// ForecastRun is one expected firing. Blackout names the window that would
+// hold the created job at that time; CatchUp reports whether it runs once
+// the window closes rather than being skipped.
+type ForecastRun struct {
+	At       time.Time `json:"at"`
+	Blackout string    `json:"blackout,omitempty"`
+	CatchUp  bool      `json:"catch_up,omitempty"`
+}
+
+// Forecast is the batch calendar returned by ForecastSchedules.
+type Forecast struct {
+	Timezone string `json:"timezone"`
+	// Jitter is SCHEDULE_JITTER, the most each firing may be delayed by
+	Jitter    string             `json:"jitter,omitempty"`
+	Schedules []ScheduleForecast `json:"schedules"`
+}
+
+// ForecastSchedules returns the next n firings after from of every schedule
+// that creates jobs, in the scheduler's timezone, marking those that fall in
+// a blackout window of their job type. Paused and switched-off schedules are
+// listed with their fire times so the calendar shows what resuming them
+// would do. There is no holiday calendar: a schedule that must skip
+// holidays needs a blackout window for them.
+func (s *Scheduler) ForecastSchedules(ctx context.Context, from time.Time, n int) (Forecast, error) {
+	if n <= 0 || n > maxPreviewRuns {
+		return Forecast{}, errclass.DataError(fmt.Errorf("number of runs must be between 1 and %d", maxPreviewRuns))
+	}
+
+	s.scheduleMu.Lock()
+	entries := append([]scheduleEntry(nil), s.scheduleEntries...)
+	s.scheduleMu.Unlock()
+
+	paused, err := s.pausedSchedules(ctx)
+	if err != nil {
+		return Forecast{}, err
+	}
+
+	loc := s.c.Location()
+	forecast := Forecast{Timezone: timezoneName(loc), Schedules: make([]ScheduleForecast, 0, len(entries))}
+	if s.jitter > 0 {
+		forecast.Jitter = s.jitter.String()
+	}
+	for _, e := range entries {
+		f := ScheduleForecast{
+			Name:    e.Name,
+			JobName: e.JobName,
+			Spec:    e.Spec,
+			Paused:  paused[e.Name],
+			Enabled: s.enabled(flagSchedule, e.Name),
+			Runs:    []ForecastRun{},
+		}
+		schedule := s.c.Entry(e.ID).Schedule
+		if schedule == nil {
+			// removed since the snapshot
+			continue
+		}
+		next := from.In(loc)
+		for range n {
+			next = schedule.Next(next)
+			if next.IsZero() {
+				break
+			}
+			run := ForecastRun{At: next}
+			if w := s.activeBlackout(e.JobName, next); w != nil {
+				run.Blackout, run.CatchUp = w.Name, w.CatchUp
+			}
+			f.Runs = append(f.Runs, run)
+		}
+		forecast.Schedules = append(forecast.Schedules, f)
+	}
+	return forecast, nil
+}
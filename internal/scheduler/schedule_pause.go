@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ErrUnknownSchedule is returned for a schedule name that is not registered.
+var ErrUnknownSchedule = errors.New("unknown schedule")
+
+// scheduleEntry is a registered schedule that creates jobs, such as golf job
+// creation or an entry of JOB_SCHEDULES.
+type scheduleEntry struct {
+	Name    string
+	JobName string
+	Spec    string
+	ID      cron.EntryID
+	// Stored is set for schedules added through AddSchedule
+	Stored bool
+}
+
+// addSchedule registers create to run on spec under name. The entry is
+// skipped while paused or switched off in enable_flags and, like other job
+// creation, runs on the leader only.
+func (s *Scheduler) addSchedule(name, jobName, spec string, create func()) error {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	return s.addScheduleLocked(scheduleEntry{Name: name, JobName: jobName, Spec: spec}, create)
+}
+
+// addScheduleLocked is addSchedule with scheduleMu held.
+func (s *Scheduler) addScheduleLocked(entry scheduleEntry, create func()) error {
+	name := entry.Name
+	for _, e := range s.scheduleEntries {
+		if e.Name == name {
+			return fmt.Errorf("schedule %q is registered twice", name)
+		}
+	}
+
+	pausable := cron.FuncJob(func() {
+		s.recordFire(name)
+		if !s.enabled(flagSchedule, name) {
+			s.logger.Debug("Schedule switched off, skipping", "schedule", name)
+			return
+		}
+		paused, err := s.schedulePaused(name)
+		if err != nil {
+			// Creating jobs is idempotent; better an unwanted job than a missed one.
+			s.logger.Error("Failed to read schedule pause state", "schedule", name, "error", err)
+		}
+		if paused {
+			s.logger.Debug("Schedule paused, skipping", "schedule", name)
+			return
+		}
+		create()
+	})
+	id, err := s.c.AddJob(s.resolveSchedule(entry.Spec), s.leaderOnly(s.withJitter(pausable)))
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+	s.scheduleEntries = append(s.scheduleEntries, entry)
+	return nil
+}
+
+// scheduleEntry returns the registered schedule called name.
+func (s *Scheduler) scheduleEntry(name string) (scheduleEntry, bool) {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	for _, e := range s.scheduleEntries {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return scheduleEntry{}, false
+}
+
+// ScheduleJobName returns the job type the schedule called name creates, or
+// ErrUnknownSchedule.
+func (s *Scheduler) ScheduleJobName(name string) (string, error) {
+	e, ok := s.scheduleEntry(name)
+	if !ok {
+		return "", ErrUnknownSchedule
+	}
+	return e.JobName, nil
+}
+
+// schedulePaused reports whether name is paused. Pauses are stored in MySQL
+// so they apply to whichever instance leads and survive restarts.
+func (s *Scheduler) schedulePaused(name string) (bool, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM schedule_pauses WHERE name = ?", name).Scan(&n)
+	return n > 0, err
+}
+
+// PauseSchedule stops the schedule called name from creating jobs until it
+// is resumed, without affecting other schedules or jobs already queued.
+func (s *Scheduler) PauseSchedule(ctx context.Context, name, actor string) error {
+	if _, ok := s.scheduleEntry(name); !ok {
+		return ErrUnknownSchedule
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedule_pauses (name, paused_by) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE name = name
+	`, name, actor)
+	if err != nil {
+		return fmt.Errorf("pausing schedule %s: %w", name, err)
+	}
+	s.logger.Info("Schedule paused", "schedule", name, "by", actor)
+	return nil
+}
+
+// ResumeSchedule lets a paused schedule create jobs again from its next
+// fire time; occurrences missed while paused are not made up.
+func (s *Scheduler) ResumeSchedule(ctx context.Context, name string) error {
+	if _, ok := s.scheduleEntry(name); !ok {
+		return ErrUnknownSchedule
+	}
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM schedule_pauses WHERE name = ?", name); err != nil {
+		return fmt.Errorf("resuming schedule %s: %w", name, err)
+	}
+	s.logger.Info("Schedule resumed", "schedule", name)
+	return nil
+}
@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// scheduleSyncSpec is how often each instance picks up schedules added or
+// removed through another instance.
+const scheduleSyncSpec = "@every 30s"
+
+var (
+	// ErrScheduleExists is returned by AddSchedule for a name already in use.
+	ErrScheduleExists = errors.New("schedule already exists")
+	// ErrStaticSchedule is returned by RemoveSchedule for a schedule that
+	// comes from the environment rather than AddSchedule.
+	ErrStaticSchedule = errors.New("schedule is configured in the environment")
+)
+
+// AddSchedule stores job in the schedules table and registers it on the
+// running cron at once. Other instances pick it up within scheduleSyncSpec,
+// and it is registered again on restart.
+func (s *Scheduler) AddSchedule(ctx context.Context, job ScheduledJob, actor string) (ScheduledJob, error) {
+	if job.Name == "" {
+		job.Name = job.JobName
+	}
+	if len(job.Name) > 64 {
+		return job, errclass.DataError(errors.New("name must be at most 64 characters"))
+	}
+	if err := s.validateScheduledJob(job); err != nil {
+		return job, errclass.DataError(err)
+	}
+	if !s.storedJobAllowed(job.JobName) {
+		return job, errclass.DataError(fmt.Errorf("job type %q cannot be scheduled through the API, see API_TRIGGER_PRIVILEGED_JOBS", job.JobName))
+	}
+	if _, ok := s.scheduleEntry(job.Name); ok {
+		return job, ErrScheduleExists
+	}
+
+	var params any
+	if len(job.Params) > 0 {
+		params = string(job.Params)
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedules (name, schedule, job_name, job_date, params, priority, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, job.Name, job.Schedule, job.JobName, job.JobDate, params, job.Priority, actor)
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+		return job, ErrScheduleExists
+	}
+	if err != nil {
+		return job, fmt.Errorf("inserting schedule %s: %w", job.Name, err)
+	}
+	s.logger.Info("Schedule added", "schedule", job.Name, "job_name", job.JobName, "spec", job.Schedule, "by", actor)
+
+	if err := s.syncSchedules(ctx); err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+// RemoveSchedule deletes a schedule added with AddSchedule, along with its
+// pause, and removes it from the running cron. Jobs it already created are
+// left alone.
+func (s *Scheduler) RemoveSchedule(ctx context.Context, name string) error {
+	entry, ok := s.scheduleEntry(name)
+	if !ok {
+		return ErrUnknownSchedule
+	}
+	if !entry.Stored {
+		return ErrStaticSchedule
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schedules WHERE name = ?", name); err != nil {
+		return fmt.Errorf("deleting schedule %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schedule_pauses WHERE name = ?", name); err != nil {
+		return fmt.Errorf("deleting schedule %s pause: %w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.logger.Info("Schedule removed", "schedule", name)
+
+	return s.syncSchedules(ctx)
+}
+
+// loadStoredPrivileged reads API_TRIGGER_PRIVILEGED_JOBS, the privileged
+// job types that schedules added through the API may create.
+func (s *Scheduler) loadStoredPrivileged() error {
+	s.storedPrivileged = envlist.Split(os.Getenv("API_TRIGGER_PRIVILEGED_JOBS"))
+	return nil
+}
+
+// storedJobAllowed reports whether a stored schedule may create jobName
+// jobs. Privileged job types, see Privileged, must be listed in
+// API_TRIGGER_PRIVILEGED_JOBS, as for POST /jobs.
+func (s *Scheduler) storedJobAllowed(jobName string) bool {
+	return !s.Privileged(jobName) || slices.Contains(s.storedPrivileged, jobName)
+}
+
+// SyncSchedules brings the cron entries of stored schedules in line with
+// the schedules table.
+func (s *Scheduler) SyncSchedules() {
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+	if err := s.syncSchedules(ctx); err != nil {
+		s.logger.Error("Failed to sync schedules", "error", err)
+	}
+}
+
+func (s *Scheduler) syncSchedules(ctx context.Context) error {
+	stored, err := s.storedSchedules(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+
+	registered := map[string]bool{}
+	kept := s.scheduleEntries[:0]
+	for _, e := range s.scheduleEntries {
+		if _, ok := stored[e.Name]; e.Stored && !ok {
+			s.c.Remove(e.ID)
+			s.logger.Info("Schedule unregistered", "schedule", e.Name)
+			continue
+		}
+		registered[e.Name] = true
+		kept = append(kept, e)
+	}
+	s.scheduleEntries = kept
+
+	for name, job := range stored {
+		if registered[name] {
+			continue
+		}
+		// A job type may have gone away since the schedule was stored; the
+		// row is kept so the schedule comes back with it.
+		if err := s.validateScheduledJob(job); err != nil {
+			s.logger.Warn("Skipping invalid stored schedule", "schedule", name, "error", err)
+			continue
+		}
+		// Rows may predate the check or be written to the table directly.
+		if !s.storedJobAllowed(job.JobName) {
+			s.logger.Warn("Skipping stored schedule of a privileged job type, see API_TRIGGER_PRIVILEGED_JOBS", "schedule", name, "job_name", job.JobName)
+			continue
+		}
+		entry := scheduleEntry{Name: name, JobName: job.JobName, Spec: job.Schedule, Stored: true}
+		if err := s.addScheduleLocked(entry, func() { s.enqueueScheduled(job) }); err != nil {
+			return fmt.Errorf("registering schedule %s: %w", name, err)
+		}
+		s.logger.Info("Schedule registered", "schedule", name, "job_name", job.JobName, "spec", job.Schedule)
+	}
+	return nil
+}
+
+// storedSchedules reads the schedules table by name.
+func (s *Scheduler) storedSchedules(ctx context.Context) (map[string]ScheduledJob, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, schedule, job_name, job_date, params, priority FROM schedules")
+	if err != nil {
+		return nil, fmt.Errorf("querying schedules: %w", err)
+	}
+	defer rows.Close()
+
+	stored := map[string]ScheduledJob{}
+	for rows.Next() {
+		var job ScheduledJob
+		var params sql.NullString
+		if err := rows.Scan(&job.Name, &job.Schedule, &job.JobName, &job.JobDate, &params, &job.Priority); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		if params.Valid {
+			job.Params = json.RawMessage(params.String)
+		}
+		stored[job.Name] = job
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return stored, nil
+}
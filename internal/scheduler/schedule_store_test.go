@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"strings"
+	"testing"
+)
+
+func TestStoredScheduleRefusesPrivilegedJobTypes(t *testing.T) {
+	s, _ := newMemoryScheduler(t)
+	t.Setenv("API_TRIGGER_PRIVILEGED_JOBS", "http")
+	if err := s.loadStoredPrivileged(); err != nil {
+		t.Fatal(err)
+	}
+	for jobName, want := range map[string]bool{"golf": true, "http": true, "sql": false, "shell": false} {
+		if got := s.storedJobAllowed(jobName); got != want {
+			t.Errorf("storedJobAllowed(%q) = %v, want %v", jobName, got, want)
+		}
+	}
+
+	job := ScheduledJob{Name: "wipe", Schedule: "@daily", JobName: "sql", Params: json.RawMessage(`{"connection": "mysql", "statements": [{"sql": "DELETE FROM cron_jobs"}]}`)}
+	_, err := s.AddSchedule(context.Background(), job, "test")
+	if errclass.Classify(err) != errclass.Data || !strings.Contains(err.Error(), "API_TRIGGER_PRIVILEGED_JOBS") {
+		t.Errorf("AddSchedule of a sql job = %v, want a data error", err)
+	}
+}
@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduledJob enqueues a job every time Schedule, a cron expression or a
+// SCHEDULE_ALIASES name, fires. Name identifies the schedule, for example to
+// pause it, and defaults to JobName. JobDate and string
+// values in Params may use the templates of EnqueueDates, expanded when the
+// job is enqueued; JobDate defaults to "{{.Today}}". Params may also be an
+// array of objects, to enqueue one job per element, such as one per site;
+// elements whose db_id is a switched-off site are skipped.
+type ScheduledJob struct {
+	Name     string          `json:"name"`
+	Schedule string          `json:"schedule"`
+	JobName  string          `json:"job_name"`
+	JobDate  string          `json:"job_date"`
+	Params   json.RawMessage `json:"params"`
+	Priority int             `json:"priority"`
+}
+
+// loadSchedules reads JOB_SCHEDULES, a JSON array of ScheduledJob, e.g.
+// [{"schedule": "0 7 * * 1", "job_name": "ops_summary", "params":
+// {"report_date": "{{.Yesterday}}"}}], and adds the golf jobs of every site
+// on GOLF_SCHEDULE and the jobs configured by OPS_SUMMARY_SCHEDULE,
+// FUNERAL_IMPORT_SCHEDULE and STATS_ROLLUP_SCHEDULE.
+func (s *Scheduler) loadSchedules() error {
+	golf := make([]GolfParams, len(golfSites))
+	for i, dbID := range golfSites {
+		golf[i] = GolfParams{DbID: dbID, JobDate: "{{.Today}}"}
+	}
+	golfParams, err := json.Marshal(golf)
+	if err != nil {
+		return err
+	}
+	s.schedules = []ScheduledJob{{Name: "golf", Schedule: s.golfSchedule(), JobName: "golf", Params: golfParams}}
+
+	for _, builtin := range []struct {
+		env string
+		job ScheduledJob
+	}{
+		{"OPS_SUMMARY_SCHEDULE", ScheduledJob{JobName: "ops_summary", Params: json.RawMessage(`{"report_date": "{{.Yesterday}}"}`)}},
+		{"FUNERAL_IMPORT_SCHEDULE", ScheduledJob{JobName: "funeral_invoice_import", Params: json.RawMessage(`{"invoice_date": "{{.Yesterday}}"}`)}},
+		{"STATS_ROLLUP_SCHEDULE", ScheduledJob{JobName: "stats_rollup", Params: json.RawMessage(`{"stats_date": "{{.Yesterday}}"}`)}},
+	} {
+		if spec := os.Getenv(builtin.env); spec != "" {
+			builtin.job.Schedule = spec
+			s.schedules = append(s.schedules, builtin.job)
+		}
+	}
+
+	if raw := os.Getenv("JOB_SCHEDULES"); raw != "" {
+		// A misspelled key such as "shedule" would otherwise be dropped
+		// silently.
+		var jobs []ScheduledJob
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&jobs); err != nil {
+			return fmt.Errorf("parsing JOB_SCHEDULES: %w", err)
+		}
+		if dec.More() {
+			return errors.New("parsing JOB_SCHEDULES: unexpected data after the array")
+		}
+		s.schedules = append(s.schedules, jobs...)
+	}
+
+	for i := range s.schedules {
+		job := &s.schedules[i]
+		if job.Name == "" {
+			job.Name = job.JobName
+		}
+		if err := s.validateScheduledJob(*job); err != nil {
+			return fmt.Errorf("schedule %d (%s): %w", i+1, job.JobName, err)
+		}
+	}
+	return nil
+}
+
+// validateScheduledJob checks the schedule and that the params, expanded for
+// today, decode for the job type.
+func (s *Scheduler) validateScheduledJob(job ScheduledJob) error {
+	jt, ok := s.jobTypes[job.JobName]
+	if !ok {
+		return fmt.Errorf("unknown job_name %q", job.JobName)
+	}
+	if _, err := cron.ParseStandard(s.resolveSchedule(job.Schedule)); err != nil {
+		return fmt.Errorf("parsing schedule %q: %w", job.Schedule, err)
+	}
+	jobDate, params, err := job.expand(enqueueDates(s.now()))
+	if err != nil {
+		return err
+	}
+	if _, err := time.Parse(dateLayout, jobDate); err != nil {
+		return fmt.Errorf("job_date must expand to YYYY-MM-DD, got %q", jobDate)
+	}
+	for i, p := range params {
+		if err := jt.validate(string(p)); err != nil {
+			if len(params) > 1 {
+				return fmt.Errorf("params %d: %w", i+1, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// expand renders the job's date and params for dates, one params object per
+// job to enqueue.
+func (job ScheduledJob) expand(dates EnqueueDates) (string, []json.RawMessage, error) {
+	dateTemplate := job.JobDate
+	if dateTemplate == "" {
+		dateTemplate = "{{.Today}}"
+	}
+	jobDate, err := expandEnqueueTemplate(dateTemplate, dates)
+	if err != nil {
+		return "", nil, fmt.Errorf("job_date: %w", err)
+	}
+
+	params := []json.RawMessage{json.RawMessage("{}")}
+	if raw := bytes.TrimSpace(job.Params); len(raw) > 0 && raw[0] == '[' {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return "", nil, fmt.Errorf("params: %w", err)
+		}
+		if len(params) == 0 {
+			return "", nil, errors.New("params: array is empty")
+		}
+	} else if len(raw) > 0 {
+		params[0] = job.Params
+	}
+	for i, p := range params {
+		if params[i], err = expandParams(p, dates); err != nil {
+			return "", nil, fmt.Errorf("params: %w", err)
+		}
+	}
+	return jobDate, params, nil
+}
+
+// scheduledParams is job.expand without the params of switched-off sites.
+func (s *Scheduler) scheduledParams(job ScheduledJob, dates EnqueueDates) (string, []json.RawMessage, error) {
+	jobDate, params, err := job.expand(dates)
+	if err != nil {
+		return "", nil, err
+	}
+	enabled := params[:0]
+	for _, p := range params {
+		if site := jobSite(CronJob{JobParams: string(p)}); site != "" && !s.enabled(flagSite, site) {
+			s.logger.Debug("Site switched off, skipping", "schedule", job.Name, "db_id", site)
+			continue
+		}
+		enabled = append(enabled, p)
+	}
+	return jobDate, enabled, nil
+}
+
+// enqueueCounts tallies the jobs one firing of a schedule created, found
+// already existing, and failed to create.
+type enqueueCounts struct {
+	created, skipped, failed int
+}
+
+// enqueueScheduled enqueues one occurrence of job. Like the other scheduled
+// creators it is idempotent: a job that already exists for the expanded date
+// and params is left alone, and a failure for one params object does not
+// prevent the others from being enqueued. One summary line per firing
+// reports the counts.
+func (s *Scheduler) enqueueScheduled(job ScheduledJob) enqueueCounts {
+	var counts enqueueCounts
+	jobDate, params, err := s.scheduledParams(job, enqueueDates(s.now()))
+	if err != nil {
+		s.logger.Error("failed expanding scheduled job", "schedule", job.Name, "job_name", job.JobName, "error", err)
+		return counts
+	}
+	for _, p := range params {
+		jobID, created, err := s.EnqueueJob(s.ctx, job.JobName, jobDate, p, job.Priority)
+		switch {
+		case err != nil:
+			counts.failed++
+			s.logger.Error("failed creating scheduled job", "job_name", job.JobName, "job_date", jobDate, "params", string(p), "error", err)
+		case !created:
+			counts.skipped++
+			s.logger.Debug("scheduled job already exists", "job_name", job.JobName, "job_date", jobDate, "params", string(p))
+		default:
+			counts.created++
+			s.logger.Info("scheduled job created", "job_id", jobID, "job_name", job.JobName, "job_date", jobDate)
+		}
+	}
+	s.logger.Info("scheduled jobs enqueued", "schedule", job.Name, "job_name", job.JobName, "job_date", jobDate,
+		"created", counts.created, "skipped", counts.skipped, "failed", counts.failed)
+	return counts
+}
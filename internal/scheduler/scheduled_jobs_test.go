@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// failingStore is a MemoryJobStore that cannot enqueue jobs whose params
+// contain fail.
+type failingStore struct {
+	*MemoryJobStore
+	fail string
+}
+
+func (f failingStore) EnqueueJob(ctx context.Context, job CronJob) (int64, bool, error) {
+	if strings.Contains(job.JobParams, f.fail) {
+		return 0, false, errors.New("connection refused")
+	}
+	return f.MemoryJobStore.EnqueueJob(ctx, job)
+}
+
+func TestEnqueueScheduledCounts(t *testing.T) {
+	var logs bytes.Buffer
+	store := failingStore{MemoryJobStore: NewMemoryJobStore(), fail: "broken"}
+	s := NewScheduler(nil, slog.New(slog.NewTextHandler(&logs, nil)), WithJobStore(store))
+	RegisterJobType(s, "echo", func(ctx context.Context, job CronJob, p echoParams) (string, error) {
+		return p.Text, nil
+	})
+	job := ScheduledJob{
+		Name:    "echoes",
+		JobName: "echo",
+		Params:  json.RawMessage(`[{"text": "a"}, {"text": "b"}, {"text": "broken"}]`),
+	}
+
+	if got, want := s.enqueueScheduled(job), (enqueueCounts{created: 2, failed: 1}); got != want {
+		t.Errorf("first firing = %+v, want %+v", got, want)
+	}
+	if got, want := s.enqueueScheduled(job), (enqueueCounts{skipped: 2, failed: 1}); got != want {
+		t.Errorf("second firing = %+v, want %+v", got, want)
+	}
+	if n := strings.Count(logs.String(), `msg="scheduled jobs enqueued" schedule=echoes`); n != 2 {
+		t.Errorf("%d summary lines, want one per firing:\n%s", n, logs.String())
+	}
+	if !strings.Contains(logs.String(), "created=0 skipped=2 failed=1") {
+		t.Errorf("summary of the second firing missing:\n%s", logs.String())
+	}
+}
+
+func TestLoadSchedulesRejectsUnknownFields(t *testing.T) {
+	s, _ := newMemoryScheduler(t)
+	t.Setenv("JOB_SCHEDULES", `[{"shedule": "0 7 * * *", "job_name": "ops_summary"}]`)
+	if err := s.loadSchedules(); err == nil || !strings.Contains(err.Error(), `unknown field "shedule"`) {
+		t.Errorf("loadSchedules = %v, want the misspelled key reported", err)
+	}
+
+	t.Setenv("JOB_SCHEDULES", `[{"schedule": "0 7 * * *", "job_name": "ops_summary", "params": {"report_date": "{{.Yesterday}}"}}]`)
+	if err := s.loadSchedules(); err != nil {
+		t.Errorf("loadSchedules: %v", err)
+	}
+}
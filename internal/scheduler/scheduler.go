@@ -1,10 +1,19 @@
 package scheduler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"hotbrandon/go-cron-be/internal/clock"
+	"hotbrandon/go-cron-be/internal/events"
+	"hotbrandon/go-cron-be/internal/lock"
+	"hotbrandon/go-cron-be/internal/metrics"
+	"hotbrandon/go-cron-be/internal/notify"
+	"hotbrandon/go-cron-be/internal/storage"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -12,9 +21,125 @@ import (
 )
 
 type Scheduler struct {
-	db     *sql.DB
-	logger *slog.Logger
-	c      *cron.Cron
+	db *sql.DB
+	// readDB serves heavy reads such as job listings and stats; it is db
+	// unless a read replica is configured
+	readDB   *sql.DB
+	logger   *slog.Logger
+	c        *cron.Cron
+	jobTypes map[string]jobType
+	// dependencies maps a job_name to the job_names that must finish first
+	dependencies map[string][]string
+	// slots limits concurrent executions per job_name
+	slots map[string]chan struct{}
+	// tenants maps a job_name to the business unit that owns it, and
+	// tenantSlots limits concurrent executions per tenant
+	tenants     map[string]string
+	tenantSlots map[string]chan struct{}
+	// maxRuntimes is the watchdog limit per job_name
+	maxRuntimes    map[string]time.Duration
+	cancelOverruns bool
+	retryPolicies  map[string]RetryPolicy
+	notifier       notify.Notifier
+	// jitter is the maximum random delay added to scheduled fire times
+	jitter    time.Duration
+	blackouts []BlackoutWindow
+	// metrics receives run counts and durations and the gauges of
+	// ReportGauges
+	metrics metrics.Sink
+	// publishers receive job lifecycle events relayed from the outbox
+	publishers []events.Publisher
+	// storage archives exports and old jobs; nil when not configured
+	storage *storage.Client
+	// locker provides leader election and cluster-wide exclusive job types
+	locker lock.Locker
+	// elector decides leadership; when set by WithLeaderElection only the
+	// leader runs cron entries
+	elector    lock.Locker
+	leaderMu   sync.Mutex
+	leaderLock lock.Lock
+	leading    atomic.Bool
+	exclusive  map[string]bool
+	// leaderOnlyCron gates dispatch and housekeeping on leadership too
+	leaderOnlyCron bool
+	// catchUpWindow is SCHEDULE_CATCHUP_WINDOW, see catchUpSchedules;
+	// handoffSeen is the last leader handoff WatchHandoff acted on
+	catchUpWindow time.Duration
+	handoffSeen   time.Time
+
+	// escalations are the ESCALATION_POLICIES by job_name
+	escalations map[string][]EscalationStep
+
+	// lastTick is when the cron loop last ran, in unix nanoseconds
+	lastTick atomic.Int64
+
+	workerCount int
+	staleAfter  time.Duration
+	queue       chan CronJob
+	workers     sync.WaitGroup
+	// busy counts workers running a job
+	busy atomic.Int64
+	// outputLimit caps stored messages and results, see capOutput
+	outputLimit int
+	// webhookHosts is WEBHOOK_ALLOWED_HOSTS, see webhookHostAllowed
+	webhookHosts []string
+	// storedPrivileged is API_TRIGGER_PRIVILEGED_JOBS, see storedJobAllowed
+	storedPrivileged []string
+	// deadlines is the time of day by which each job type must be finished;
+	// deadlineAlerted holds the job_date last checked per type
+	deadlines       map[string]time.Duration
+	deadlineAlerted map[string]string
+	// anomalyThreshold is the GOLF_ANOMALY_THRESHOLD percentage; zero
+	// disables anomaly detection
+	anomalyThreshold float64
+	anomalyWeeks     int
+	// golfSites holds per-site alerting settings from GOLF_SITES
+	golfSites map[string]GolfSite
+	// invoiceRules validate funeral invoice rows before they are imported;
+	// overwriteConflicts replaces stored amounts that changed in the ERP
+	invoiceRules       []invoiceRule
+	overwriteConflicts bool
+	invoiceRecipients  []string
+	// insertBatchSize is the rows per multi-row INSERT, see insertBatched
+	insertBatchSize int
+	// preflight is the ORACLE_PREFLIGHT mode checked by Start
+	preflight string
+	// connHeldAlert is how long an Oracle session may be checked out before
+	// CheckConnLeaks reports it; connLeakAlerted holds the ones reported
+	connHeldAlert   time.Duration
+	connLeakAlerted map[int64]bool
+	// schedules are the jobs enqueued on a cron schedule, see loadSchedules
+	schedules []ScheduledJob
+	// scheduleAliases are the named cron expressions of SCHEDULE_ALIASES
+	scheduleAliases map[string]string
+	// scheduleEntries are the cron entries that create jobs, by name;
+	// scheduleMu guards them since AddSchedule changes them at runtime
+	scheduleMu      sync.Mutex
+	scheduleEntries []scheduleEntry
+	// clock tells the time for date logic such as the job_date a schedule
+	// enqueues, see WithClock
+	clock clock.Clock
+	// golf and erp read the Oracle data golf and ERP jobs work on
+	golf GolfRepository
+	erp  ErpRepository
+	// store holds the job queue, see JobStore
+	store JobStore
+	// dryRun is DRY_RUN: every job runs as a dry run
+	dryRun bool
+	// flags are the switched-off job types, sites and schedules of
+	// enable_flags, see RefreshFlags
+	flags enableFlags
+	// hooks are the lifecycle subscribers added with AddHook
+	hooks map[HookPoint][]Hook
+	// middleware wraps every handler: the JOB_MIDDLEWARE chain, then the
+	// middleware added with Use
+	middleware       []Middleware
+	customMiddleware []Middleware
+	// stopping is closed when Stop begins so dispatch stops handing out jobs
+	stopping chan struct{}
+	// ctx is passed to running jobs and cancelled when they must abort
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 type CronJob struct {
@@ -23,30 +148,100 @@ type CronJob struct {
 	JobDate         string     `json:"job_date"`
 	JobParams       string     `json:"job_params"`
 	JobStatus       string     `json:"job_status"`
+	Priority        int        `json:"priority"`
+	Attempts        int        `json:"attempts"`
 	Message         string     `json:"message"`
 	ExecutionTimeMs int64      `json:"execution_time_ms"`
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
 	FinishedAt      *time.Time `json:"finished_at"`
+	// Tenant is the business unit that owns the job, see TenantOf.
+	Tenant string `json:"tenant"`
+	// RequestID is the X-Request-ID of the API call that created the job.
+	RequestID string `json:"request_id,omitempty"`
+	// Result is what the handler reported with SetResult.
+	Result json.RawMessage `json:"job_result,omitempty"`
+	// DryRun is set while running a job triggered as a dry run or under
+	// DRY_RUN, see DryRun.
+	DryRun bool `json:"-"`
 }
 
-type JobParams struct {
-	DbID    string `json:"db_id"`
-	JobDate string `json:"job_date"`
-}
+// dispatchSpec controls how often pending jobs are picked up and executed.
+const dispatchSpec = "@every 1m"
+
+// golfSites are the golf course databases, see database.GetGolfConnection.
+var golfSites = []string{"GC", "TH", "OS"}
 
-func NewScheduler(db *sql.DB, logger *slog.Logger) *Scheduler {
+func NewScheduler(db *sql.DB, logger *slog.Logger, opts ...Option) *Scheduler {
 	c := cron.New()
-	return &Scheduler{
-		c:      c,
-		db:     db,
-		logger: logger,
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		c:        c,
+		db:       db,
+		logger:   logger,
+		jobTypes: make(map[string]jobType),
+		queue:    make(chan CronJob),
+		stopping: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		notifier: notify.NewLogNotifier(logger),
+		metrics:  metrics.Nop{},
+		clock:    clock.Real{},
+		golf:     oracleGolf{},
+		erp:      oracleErp{},
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	if s.readDB == nil {
+		s.readDB = db
+	}
+	if s.store == nil {
+		s.store = mysqlJobStore{s: s}
+	}
+	if s.locker == nil {
+		s.locker = lock.NewMySQLLocker(db)
+	}
+	s.leaderOnlyCron = s.elector != nil
+	if s.elector == nil {
+		s.elector = s.locker
+	}
+	if s.publishers == nil {
+		s.publishers = []events.Publisher{events.NewNotifierPublisher(s.notifier)}
+	}
+	s.registerJobTypes()
+	s.registerHooks()
+	return s
 }
 
+// registerJobTypes maps every job_name to its params struct and handler.
+func (s *Scheduler) registerJobTypes() {
+	RegisterJobType(s, "golf", s.runGolfJob)
+	RegisterJobType(s, "shell", s.runShellJob)
+	RegisterJobType(s, "http", s.runHTTPJob)
+	RegisterJobType(s, "sql", s.runSQLJob)
+	RegisterJobType(s, "oracle_proc", s.runOracleProcJob)
+	RegisterJobType(s, "einvoice_upload", s.runEInvoiceJob)
+	RegisterJobType(s, "ops_summary", s.runOpsSummaryJob)
+	RegisterJobType(s, "funeral_invoice_xlsx", s.runFuneralXLSXJob)
+	RegisterJobType(s, "csv_export", s.runCSVExportJob)
+	RegisterJobType(s, "archive_jobs", s.runArchiveJob)
+	RegisterJobType(s, "stats_rollup", s.runStatsRollupJob)
+	RegisterJobType(s, "funeral_invoice_import", s.runFuneralImportJob)
+	RegisterJobType(s, "script", s.runScriptJob)
+	s.supportDryRun("funeral_invoice_import", "einvoice_upload", "oracle_proc", "script")
+	s.markPrivileged("shell", "http", "sql", "oracle_proc", "csv_export")
+}
+
+// Stop stops scheduling new work and waits for running jobs to finish.
 func (s *Scheduler) Stop() {
+	close(s.stopping)
+	// Wait for running cron funcs, including an in-progress dispatch.
+	<-s.c.Stop().Done()
+	// Let a standby take over before draining, which may take shutdownGrace.
+	s.handOff()
+	s.stopWorkers()
 	s.logger.Info("Scheduler stopped")
-	s.c.Stop()
 }
 
 // initializeTables creates the required database tables if they don't exist
@@ -61,6 +256,29 @@ func (s *Scheduler) initializeTables() error {
 		UNIQUE(invoice_date, c_idno2)
 	);`
 
+	funeralInvoiceQuarantineTable := `
+	CREATE TABLE IF NOT EXISTS funeral_invoice_quarantine (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		job_id INT NOT NULL,
+		invoice_date VARCHAR(32) NOT NULL,
+		c_idno2 VARCHAR(50) NOT NULL,
+		total_amount_dividint10 INT NOT NULL,
+		violations TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	funeralInvoiceDiscrepanciesTable := `
+	CREATE TABLE IF NOT EXISTS funeral_invoice_discrepancies (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		job_id INT NOT NULL,
+		invoice_date VARCHAR(10) NOT NULL,
+		c_idno2 VARCHAR(50) NOT NULL,
+		old_amount INT NOT NULL,
+		new_amount INT NOT NULL,
+		action VARCHAR(16) NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
 	CronJobsTable := `
 	CREATE TABLE IF NOT EXISTS cron_jobs (
 		job_id INT PRIMARY KEY AUTO_INCREMENT,
@@ -69,7 +287,12 @@ func (s *Scheduler) initializeTables() error {
 		job_params JSON,
 		job_params_hash VARCHAR(64) AS (SHA2(job_params, 256)) STORED,
 		job_status VARCHAR(10) NOT NULL DEFAULT 'pending',
+		priority INT NOT NULL DEFAULT 0,
+		attempts INT NOT NULL DEFAULT 0,
+		next_run_at DATETIME,
+		heartbeat_at DATETIME,
 		message TEXT,
+		job_result JSON,
 		execution_time_ms BIGINT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
@@ -77,19 +300,330 @@ func (s *Scheduler) initializeTables() error {
 		UNIQUE KEY unique_job (job_name, job_date, job_params_hash)
 	);`
 
+	JobRunsTable := `
+	CREATE TABLE IF NOT EXISTS job_runs (
+		run_id INT PRIMARY KEY AUTO_INCREMENT,
+		job_id INT NOT NULL,
+		run_status VARCHAR(10) NOT NULL DEFAULT 'running',
+		error_category VARCHAR(16),
+		message MEDIUMTEXT,
+		job_result JSON,
+		execution_time_ms BIGINT,
+		oracle_ms BIGINT,
+		mysql_ms BIGINT,
+		app_ms BIGINT,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		finished_at DATETIME
+	);`
+
+	// columns added after the initial release, for tables created before them
+	columns := []string{
+		"ALTER TABLE cron_jobs ADD COLUMN priority INT NOT NULL DEFAULT 0 AFTER job_status;",
+		"ALTER TABLE cron_jobs ADD COLUMN heartbeat_at DATETIME AFTER priority;",
+		"ALTER TABLE cron_jobs ADD COLUMN attempts INT NOT NULL DEFAULT 0 AFTER priority;",
+		"ALTER TABLE cron_jobs ADD COLUMN next_run_at DATETIME AFTER attempts;",
+		"ALTER TABLE job_runs ADD COLUMN error_category VARCHAR(16) AFTER run_status;",
+		"ALTER TABLE cron_jobs ADD COLUMN request_id VARCHAR(64) AFTER next_run_at;",
+		"ALTER TABLE cron_jobs ADD COLUMN tenant VARCHAR(64) NOT NULL DEFAULT 'default' AFTER job_name;",
+		"ALTER TABLE cron_jobs ADD COLUMN job_result JSON AFTER message;",
+		"ALTER TABLE job_runs ADD COLUMN oracle_ms BIGINT AFTER execution_time_ms;",
+		"ALTER TABLE job_runs ADD COLUMN mysql_ms BIGINT AFTER oracle_ms;",
+		"ALTER TABLE job_runs ADD COLUMN app_ms BIGINT AFTER mysql_ms;",
+		"ALTER TABLE job_runs ADD COLUMN job_result JSON AFTER message;",
+		"ALTER TABLE einvoice_uploads ADD COLUMN invoice_number VARCHAR(10) AFTER c_idno2;",
+	}
+
+	JobEventsTable := `
+	CREATE TABLE IF NOT EXISTS job_events (
+		event_id BIGINT PRIMARY KEY AUTO_INCREMENT,
+		event_type VARCHAR(32) NOT NULL,
+		job_id INT NOT NULL,
+		job_name VARCHAR(255) NOT NULL,
+		job_date VARCHAR(10) NOT NULL,
+		payload JSON,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delivered_at DATETIME
+	);`
+
+	RetryPoliciesTable := `
+	CREATE TABLE IF NOT EXISTS retry_policies (
+		job_name VARCHAR(255) PRIMARY KEY,
+		max_attempts INT NOT NULL DEFAULT 3,
+		base_delay_ms BIGINT NOT NULL DEFAULT 60000,
+		max_delay_ms BIGINT NOT NULL DEFAULT 1800000,
+		jitter DOUBLE NOT NULL DEFAULT 0.1,
+		retry_on VARCHAR(255) NOT NULL DEFAULT 'transient',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	);`
+
+	EInvoiceUploadsTable := `
+	CREATE TABLE IF NOT EXISTS einvoice_uploads (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		invoice_date VARCHAR(10) NOT NULL,
+		c_idno2 VARCHAR(50) NOT NULL,
+		invoice_number VARCHAR(10),
+		upload_status VARCHAR(10) NOT NULL,
+		message TEXT,
+		attempts INT NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		UNIQUE(invoice_date, c_idno2)
+	);`
+
+	WebhooksTable := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		webhook_id INT PRIMARY KEY AUTO_INCREMENT,
+		job_name VARCHAR(255) NOT NULL,
+		url VARCHAR(2048) NOT NULL,
+		secret VARCHAR(255) NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	WebhookDeliveriesTable := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		delivery_id BIGINT PRIMARY KEY AUTO_INCREMENT,
+		webhook_id INT NOT NULL,
+		payload JSON NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delivered_at DATETIME
+	);`
+
+	SchedulerLocksTable := `
+	CREATE TABLE IF NOT EXISTS scheduler_locks (
+		lock_name VARCHAR(191) PRIMARY KEY,
+		owner VARCHAR(64) NOT NULL,
+		expires_at DATETIME(3) NOT NULL
+	);`
+
+	AuditLogTable := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		audit_id BIGINT PRIMARY KEY AUTO_INCREMENT,
+		actor VARCHAR(255) NOT NULL,
+		action VARCHAR(64) NOT NULL,
+		target VARCHAR(255) NOT NULL,
+		payload JSON,
+		request_id VARCHAR(64),
+		remote_addr VARCHAR(64),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	JobStatsDailyTable := `
+	CREATE TABLE IF NOT EXISTS job_stats_daily (
+		stats_date DATE NOT NULL,
+		job_name VARCHAR(255) NOT NULL,
+		runs INT NOT NULL,
+		succeeded INT NOT NULL,
+		failed INT NOT NULL,
+		success_rate DECIMAL(5,4) NOT NULL,
+		avg_ms BIGINT NOT NULL,
+		p95_ms BIGINT NOT NULL,
+		max_ms BIGINT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (stats_date, job_name)
+	);`
+
+	JobCheckpointsTable := `
+	CREATE TABLE IF NOT EXISTS job_checkpoints (
+		job_id INT PRIMARY KEY,
+		checkpoint JSON NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	);`
+
+	JobRunLogsTable := `
+	CREATE TABLE IF NOT EXISTS job_run_logs (
+		run_id INT NOT NULL,
+		seq INT NOT NULL,
+		job_id INT NOT NULL,
+		line TEXT NOT NULL,
+		PRIMARY KEY (run_id, seq)
+	);`
+
+	SchedulePausesTable := `
+	CREATE TABLE IF NOT EXISTS schedule_pauses (
+		name VARCHAR(64) PRIMARY KEY,
+		paused_by VARCHAR(255),
+		paused_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	SchedulesTable := `
+	CREATE TABLE IF NOT EXISTS schedules (
+		name VARCHAR(64) PRIMARY KEY,
+		schedule VARCHAR(255) NOT NULL,
+		job_name VARCHAR(255) NOT NULL,
+		job_date VARCHAR(255) NOT NULL DEFAULT '',
+		params JSON,
+		priority INT NOT NULL DEFAULT 0,
+		created_by VARCHAR(255),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	EnableFlagsTable := `
+	CREATE TABLE IF NOT EXISTS enable_flags (
+		kind VARCHAR(16) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		note VARCHAR(255),
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (kind, name)
+	);`
+
+	ScheduleFiresTable := `
+	CREATE TABLE IF NOT EXISTS schedule_fires (
+		name VARCHAR(64) PRIMARY KEY,
+		fired_at DATETIME(3) NOT NULL
+	);`
+
+	LeaderHandoffsTable := `
+	CREATE TABLE IF NOT EXISTS leader_handoffs (
+		lock_name VARCHAR(64) PRIMARY KEY,
+		handed_off_at DATETIME(3) NOT NULL
+	);`
+
+	AlertsTable := `
+	CREATE TABLE IF NOT EXISTS alerts (
+		alert_id BIGINT PRIMARY KEY AUTO_INCREMENT,
+		job_id INT NOT NULL,
+		job_name VARCHAR(255) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		body TEXT NOT NULL,
+		step INT NOT NULL DEFAULT 0,
+		next_escalation_at DATETIME NULL,
+		acknowledged_at DATETIME NULL,
+		acknowledged_by VARCHAR(255) NULL,
+		resolved_at DATETIME NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	ScriptsTable := `
+	CREATE TABLE IF NOT EXISTS scripts (
+		name VARCHAR(64) PRIMARY KEY,
+		source MEDIUMTEXT NOT NULL,
+		updated_by VARCHAR(255),
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	);`
+
 	indexes := []string{
 		"CREATE INDEX idx_cron_jobs_status ON cron_jobs(job_status);",
+		"CREATE INDEX idx_cron_jobs_status_priority ON cron_jobs(job_status, priority);",
 		"CREATE INDEX idx_cron_jobs_job_name_date ON cron_jobs(job_name, job_date);",
+		"CREATE INDEX idx_job_runs_job_id ON job_runs(job_id);",
+		"CREATE INDEX idx_job_events_undelivered ON job_events(delivered_at, event_id);",
+		"CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(delivered_at, next_attempt_at);",
+		"CREATE INDEX idx_audit_log_created ON audit_log(created_at);",
+		"CREATE INDEX idx_cron_jobs_tenant_status ON cron_jobs(tenant, job_status);",
+		"CREATE INDEX idx_cron_jobs_job_date ON cron_jobs(job_date);",
+		"CREATE INDEX idx_job_runs_started ON job_runs(started_at);",
+		"CREATE INDEX idx_job_run_logs_job_id ON job_run_logs(job_id);",
+		"CREATE INDEX idx_alerts_next_escalation ON alerts(next_escalation_at);",
+		"CREATE INDEX idx_alerts_job_id ON alerts(job_id);",
+		"CREATE UNIQUE INDEX idx_einvoice_uploads_number ON einvoice_uploads(invoice_number);",
 	}
 
 	if _, err := s.db.Exec(funeralInvoicesTable); err != nil {
 		return fmt.Errorf("creating funeral_invoices table: %w", err)
 	}
 
+	if _, err := s.db.Exec(funeralInvoiceQuarantineTable); err != nil {
+		return fmt.Errorf("creating funeral_invoice_quarantine table: %w", err)
+	}
+
+	if _, err := s.db.Exec(funeralInvoiceDiscrepanciesTable); err != nil {
+		return fmt.Errorf("creating funeral_invoice_discrepancies table: %w", err)
+	}
+
 	if _, err := s.db.Exec(CronJobsTable); err != nil {
 		return fmt.Errorf("creating cron_jobs table: %w", err)
 	}
 
+	if _, err := s.db.Exec(JobRunsTable); err != nil {
+		return fmt.Errorf("creating job_runs table: %w", err)
+	}
+
+	if _, err := s.db.Exec(JobEventsTable); err != nil {
+		return fmt.Errorf("creating job_events table: %w", err)
+	}
+
+	if _, err := s.db.Exec(RetryPoliciesTable); err != nil {
+		return fmt.Errorf("creating retry_policies table: %w", err)
+	}
+
+	if _, err := s.db.Exec(EInvoiceUploadsTable); err != nil {
+		return fmt.Errorf("creating einvoice_uploads table: %w", err)
+	}
+
+	if _, err := s.db.Exec(WebhooksTable); err != nil {
+		return fmt.Errorf("creating webhooks table: %w", err)
+	}
+
+	if _, err := s.db.Exec(WebhookDeliveriesTable); err != nil {
+		return fmt.Errorf("creating webhook_deliveries table: %w", err)
+	}
+
+	if _, err := s.db.Exec(SchedulerLocksTable); err != nil {
+		return fmt.Errorf("creating scheduler_locks table: %w", err)
+	}
+
+	if _, err := s.db.Exec(AuditLogTable); err != nil {
+		return fmt.Errorf("creating audit_log table: %w", err)
+	}
+
+	if _, err := s.db.Exec(JobStatsDailyTable); err != nil {
+		return fmt.Errorf("creating job_stats_daily table: %w", err)
+	}
+
+	if _, err := s.db.Exec(JobCheckpointsTable); err != nil {
+		return fmt.Errorf("creating job_checkpoints table: %w", err)
+	}
+
+	if _, err := s.db.Exec(JobRunLogsTable); err != nil {
+		return fmt.Errorf("creating job_run_logs table: %w", err)
+	}
+
+	if _, err := s.db.Exec(SchedulePausesTable); err != nil {
+		return fmt.Errorf("creating schedule_pauses table: %w", err)
+	}
+
+	if _, err := s.db.Exec(SchedulesTable); err != nil {
+		return fmt.Errorf("creating schedules table: %w", err)
+	}
+
+	if _, err := s.db.Exec(EnableFlagsTable); err != nil {
+		return fmt.Errorf("creating enable_flags table: %w", err)
+	}
+
+	if _, err := s.db.Exec(ScheduleFiresTable); err != nil {
+		return fmt.Errorf("creating schedule_fires table: %w", err)
+	}
+
+	if _, err := s.db.Exec(LeaderHandoffsTable); err != nil {
+		return fmt.Errorf("creating leader_handoffs table: %w", err)
+	}
+
+	if _, err := s.db.Exec(AlertsTable); err != nil {
+		return fmt.Errorf("creating alerts table: %w", err)
+	}
+
+	if _, err := s.db.Exec(ScriptsTable); err != nil {
+		return fmt.Errorf("creating scripts table: %w", err)
+	}
+
+	for _, col := range columns {
+		if _, err := s.db.Exec(col); err != nil {
+			// MySQL reports an existing column as "duplicate column name" (code 1060)
+			if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1060 {
+				s.logger.Debug("Column already exists, skipping creation.", "query", col)
+			} else {
+				return fmt.Errorf("adding column: %w", err)
+			}
+		}
+	}
+
 	for _, idx := range indexes {
 		if _, err := s.db.Exec(idx); err != nil {
 			// Check if the error is a MySQL-specific "duplicate key name" error (code 1061)
@@ -113,100 +647,158 @@ func (s *Scheduler) RegisterJobs() error {
 		return fmt.Errorf("initializing database tables: %w", err)
 	}
 
-	_, err := s.c.AddFunc("* 12 * * *", func() {
-		s.CreateGolfJob()
-	})
-	if err != nil {
-		return fmt.Errorf("error registering golf jobs: %w", err)
+	for _, load := range s.configLoaders() {
+		if err := load(); err != nil {
+			return err
+		}
 	}
 
-	s.logger.Info("Jobs registered successfully")
-	return nil
-}
+	if err := s.assignTenants(); err != nil {
+		return err
+	}
 
-// Start initializes and starts the scheduler
-func (s *Scheduler) Start() error {
-	// Register jobs before starting
-	if err := s.RegisterJobs(); err != nil {
-		return fmt.Errorf("registering jobs: %w", err)
+	if err := s.refreshFlags(s.ctx); err != nil {
+		return err
 	}
 
-	s.logger.Info("Scheduler started")
-	s.c.Start()
-	return nil
-}
+	flags := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(s.RefreshFlags))
+	if _, err := s.c.AddJob(flagRefreshSpec, flags); err != nil {
+		return fmt.Errorf("error registering enable flag refresh: %w", err)
+	}
+
+	if _, err := s.c.AddFunc(livenessSpec, s.tick); err != nil {
+		return fmt.Errorf("error registering liveness tick: %w", err)
+	}
 
-func (s *Scheduler) CreateGolfJob() {
-
-	jobDate := time.Now().Format("2006-01-02")
-	for _, db_id := range []string{"GC", "TH", "OS"} {
-		paramsJSON, _ := json.Marshal(JobParams{DbID: db_id, JobDate: jobDate})
-
-		query := `
-			INSERT INTO cron_jobs (job_name, job_date, job_params)
-			VALUES (?, ?, ?)
-		`
-		result, err := s.db.Exec(query, "golf", jobDate, string(paramsJSON))
-		if err != nil {
-			s.logger.Error("failed creating golf jobs", "error", err)
-			return
-		} else {
-			insertedId, _ := result.LastInsertId()
-			s.logger.Info("golf job created", "job_id", insertedId)
+	if _, err := s.c.AddFunc(leaderSpec, s.campaign); err != nil {
+		return fmt.Errorf("error registering leader election: %w", err)
+	}
+
+	if _, ok := s.metrics.(metrics.Nop); !ok {
+		gauges := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(s.ReportGauges))
+		if _, err := s.c.AddJob(metricsSpec, gauges); err != nil {
+			return fmt.Errorf("error registering metrics gauges: %w", err)
 		}
 	}
-}
 
-func (s *Scheduler) RunGolfJob() {
-	var job CronJob
-	var jobs []CronJob
-	query := `
-		SELECT 
-			job_id, job_name, job_date, job_params
-		FROM cron_jobs
-		WHERE job_name = 'golf' AND job_status <> 'finished'
-	`
-	rows, err := s.db.Query(query)
-	if err != nil {
-		s.logger.Error("querying cron_jobs:", "error", err)
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		if err := rows.Scan(&job.JobID, &job.JobName, &job.JobDate, &job.JobParams); err != nil {
-			s.logger.Error("scanning row:", "error", err)
-			return
+	handoffs := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(s.WatchHandoff))
+	if _, err := s.c.AddJob(handoffWatchSpec, handoffs); err != nil {
+		return fmt.Errorf("error registering leader handoff watch: %w", err)
+	}
+
+	for _, job := range s.schedules {
+		if err := s.addSchedule(job.Name, job.JobName, job.Schedule, func() { s.enqueueScheduled(job) }); err != nil {
+			return fmt.Errorf("error registering scheduled %s job: %w", job.Name, err)
 		}
-		jobs = append(jobs, job)
 	}
-	if err := rows.Err(); err != nil {
-		s.logger.Error("rows error:", "error", err)
-		return
+
+	if err := s.syncSchedules(s.ctx); err != nil {
+		return fmt.Errorf("error registering stored schedules: %w", err)
+	}
+
+	syncSchedules := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(s.SyncSchedules))
+	if _, err := s.c.AddJob(scheduleSyncSpec, syncSchedules); err != nil {
+		return fmt.Errorf("error registering schedule sync: %w", err)
+	}
+
+	// Overlapping dispatches are skipped; claiming a job is atomic anyway.
+	dispatch := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(s.clusterJob(cron.FuncJob(s.RunPendingJobs)))
+	if _, err := s.c.AddJob(dispatchSpec, dispatch); err != nil {
+		return fmt.Errorf("error registering job dispatcher: %w", err)
+	}
+
+	if _, err := s.c.AddJob(reaperSpec, s.clusterJob(cron.FuncJob(s.ReapStaleJobs))); err != nil {
+		return fmt.Errorf("error registering stale job reaper: %w", err)
+	}
+
+	relay := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(s.clusterJob(cron.FuncJob(s.RelayEvents)))
+	if _, err := s.c.AddJob(relaySpec, relay); err != nil {
+		return fmt.Errorf("error registering outbox relay: %w", err)
+	}
+
+	webhooks := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(s.clusterJob(cron.FuncJob(s.DeliverWebhooks)))
+	if _, err := s.c.AddJob(webhookSpec, webhooks); err != nil {
+		return fmt.Errorf("error registering webhook delivery: %w", err)
 	}
 
-	var jobParam JobParams
-	for _, job := range jobs {
-		if err := json.Unmarshal([]byte(job.JobParams), &jobParam); err != nil {
-			s.logger.Error("failed to unmarshal job_params:", "error", err)
-			return
+	if len(s.deadlines) > 0 {
+		deadlines := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(s.leaderOnly(cron.FuncJob(s.CheckDeadlines)))
+		if _, err := s.c.AddJob(deadlineSpec, deadlines); err != nil {
+			return fmt.Errorf("error registering deadline monitor: %w", err)
 		}
+	}
 
-		// The layout must match the format used when creating the date string.
-		const layout = "2006-01-02"
-		jobDate, err := time.Parse(layout, jobParam.JobDate)
-		if err != nil {
-			// If parsing fails, log the error and continue to the next job.
-			s.logger.Error("Failed to parse job_date for job", "job_id", job.JobID, "date_string", jobParam.JobDate, "error", err)
-			continue
+	if len(s.escalations) > 0 {
+		escalations := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(s.leaderOnly(cron.FuncJob(s.EscalateAlerts)))
+		if _, err := s.c.AddJob(escalationSpec, escalations); err != nil {
+			return fmt.Errorf("error registering alert escalation: %w", err)
 		}
+	}
 
-		summary, err := GetReservationSummary(jobParam.DbID, jobDate)
-		if err != nil {
-			// If the job execution fails, log the error and continue to the next job.
-			s.logger.Error("Failed to get reservation summary for job", "job_id", job.JobID, "db_id", jobParam.DbID, "error", err)
-			continue
+	if s.connHeldAlert > 0 {
+		leaks := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(s.CheckConnLeaks))
+		if _, err := s.c.AddJob(connLeakSpec, leaks); err != nil {
+			return fmt.Errorf("error registering connection leak detector: %w", err)
 		}
-		s.logger.Info("Successfully ran golf job", "job_id", job.JobID, "db_id", jobParam.DbID, "summary", summary)
 	}
+
+	s.logger.Info("Jobs registered successfully")
+	return nil
+}
+
+// configLoaders returns the functions that read the scheduler's settings
+// from the environment, in the order RegisterJobs applies them: plugins and
+// aliases come first since later settings refer to their job types and
+// schedule names.
+func (s *Scheduler) configLoaders() []func() error {
+	return []func() error{
+		s.loadPlugins,
+		s.loadScheduleAliases,
+		s.loadDependencies,
+		s.loadConcurrencyLimits,
+		s.loadTenants,
+		s.loadWorkerCount,
+		s.loadStaleAfter,
+		s.loadCatchUpWindow,
+		s.loadMaxRuntimes,
+		s.loadRetryPolicies,
+		s.loadJitter,
+		s.loadBlackoutWindows,
+		s.loadExclusiveJobs,
+		s.loadOutputLimit,
+		s.loadWebhookHosts,
+		s.loadStoredPrivileged,
+		s.loadDeadlines,
+		s.loadEscalationPolicies,
+		s.loadAnomalyDetection,
+		s.loadGolfSites,
+		s.loadInvoiceRules,
+		s.loadInsertBatchSize,
+		s.loadOracleRetry,
+		s.loadOracleBreaker,
+		s.loadPreflight,
+		s.loadConnLeakThreshold,
+		s.loadMiddleware,
+		s.loadDryRun,
+		s.loadSchedules,
+	}
+}
+
+// Start initializes and starts the scheduler
+func (s *Scheduler) Start() error {
+	// Register jobs before starting
+	if err := s.RegisterJobs(); err != nil {
+		return fmt.Errorf("registering jobs: %w", err)
+	}
+
+	if err := s.checkOracle(); err != nil {
+		return err
+	}
+
+	s.tick()
+	s.campaign()
+	s.startWorkers()
+	s.logger.Info("Scheduler started")
+	s.c.Start()
+	return nil
 }
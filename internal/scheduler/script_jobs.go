@@ -0,0 +1,315 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/expr"
+	"hotbrandon/go-cron-be/internal/lua"
+	"hotbrandon/go-cron-be/internal/notify"
+	"strings"
+	"text/template"
+)
+
+// ScriptInput selects the job whose result a script checks: the latest
+// finished job named JobName for JobDate (a template, default the script
+// job's own date) whose job_params match Params, e.g. {"db_id": "TH"}.
+type ScriptInput struct {
+	JobName string            `json:"job_name"`
+	JobDate string            `json:"job_date"`
+	Params  map[string]string `json:"params"`
+}
+
+// ScriptCheck raises Alert when If, an expression over the input's result
+// fields such as "amt_d < 10", is true. Alert is a template over the same
+// fields, e.g. "Only {{.amt_d}} reservations"; Severity defaults to warning.
+type ScriptCheck struct {
+	If       string `json:"if"`
+	Alert    string `json:"alert"`
+	Severity string `json:"severity"`
+}
+
+// ScriptParams are the job_params of a "script" job, which runs logic kept
+// in the database rather than in Go, so simple checks and transformations
+// need no deploy. A job either lists Checks, or runs a Lua script: the
+// stored script named Script, or the inline Source.
+//
+// A Lua script sees these globals:
+//
+//	input     the input job's result as a table (nil without Input)
+//	args      the job's Args as a table
+//	job_date  the date the input was looked up for
+//	alert     alert(message[, severity]) notifies like a fired check
+//
+// What it returns is stored as the job's result. print writes to the run
+// log. Recipients overrides where alerts go; FailOnAlert fails the job when
+// any alert fires.
+type ScriptParams struct {
+	Input       ScriptInput    `json:"input"`
+	Checks      []ScriptCheck  `json:"checks"`
+	Script      string         `json:"script"`
+	Source      string         `json:"source"`
+	Args        map[string]any `json:"args"`
+	Recipients  []string       `json:"recipients"`
+	FailOnAlert bool           `json:"fail_on_alert"`
+}
+
+func (p ScriptParams) Validate() error {
+	if p.Input.JobName != "" {
+		if err := (JobFilter{Params: p.Input.Params}).Validate(); err != nil {
+			return fmt.Errorf("input.params: %w", err)
+		}
+	}
+
+	switch {
+	case p.Script != "" && p.Source != "":
+		return errors.New("script and source are mutually exclusive")
+	case p.runsLua() && len(p.Checks) > 0:
+		return errors.New("checks cannot be combined with a script")
+	case p.Script != "":
+		return validScriptName(p.Script)
+	case p.Source != "":
+		_, err := lua.Compile("source", p.Source)
+		return err
+	}
+
+	if p.Input.JobName == "" {
+		return errors.New("input.job_name is required")
+	}
+	if len(p.Checks) == 0 {
+		return errors.New("at least one check, a script or a source is required")
+	}
+	for i, check := range p.Checks {
+		if _, err := expr.Parse(check.If); err != nil {
+			return fmt.Errorf("check %d: %w", i+1, err)
+		}
+		if check.Alert == "" {
+			return fmt.Errorf("check %d: alert is required", i+1)
+		}
+		if _, err := alertTemplate(check.Alert); err != nil {
+			return fmt.Errorf("check %d: alert: %w", i+1, err)
+		}
+		if err := validSeverity(check.Severity); err != nil {
+			return fmt.Errorf("check %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// runsLua reports whether the job runs a Lua script rather than checks.
+func (p ScriptParams) runsLua() bool {
+	return p.Script != "" || p.Source != ""
+}
+
+func validSeverity(severity string) error {
+	switch notify.Severity(severity) {
+	case "", notify.SeverityInfo, notify.SeverityWarning, notify.SeverityCritical:
+		return nil
+	}
+	return errors.New("severity must be info, warning or critical")
+}
+
+// scriptOutcome is the result of a script job. Result is what a Lua script
+// returned.
+type scriptOutcome struct {
+	InputJobID int64    `json:"input_job_id,omitempty"`
+	Fired      []string `json:"fired"`
+	Result     any      `json:"result,omitempty"`
+}
+
+// runScriptJob evaluates each check against the input job's result, or runs
+// the job's Lua script, and notifies for the alerts that fire; a dry run
+// only logs them.
+func (s *Scheduler) runScriptJob(ctx context.Context, job CronJob, params ScriptParams) (string, error) {
+	date := job.JobDate
+	if params.Input.JobDate != "" {
+		expanded, err := expandTemplate(params.Input.JobDate, job)
+		if err != nil {
+			return "", errclass.DataError(fmt.Errorf("input.job_date: %w", err))
+		}
+		date = expanded
+	}
+	if params.runsLua() {
+		return s.runLuaScript(ctx, job, params, date)
+	}
+
+	inputID, vars, err := s.scriptInput(ctx, params.Input, date)
+	if err != nil {
+		return "", err
+	}
+	vars["job_date"] = date
+
+	outcome := scriptOutcome{InputJobID: inputID, Fired: []string{}}
+	for i, check := range params.Checks {
+		// Validate already parsed the expression and template.
+		e, _ := expr.Parse(check.If)
+		fired, err := e.EvalBool(vars)
+		if err != nil {
+			return "", errclass.DataError(fmt.Errorf("check %d: %w", i+1, err))
+		}
+		if !fired {
+			continue
+		}
+
+		tmpl, _ := alertTemplate(check.Alert)
+		var alert bytes.Buffer
+		if err := tmpl.Execute(&alert, vars); err != nil {
+			return "", errclass.DataError(fmt.Errorf("check %d: alert: %w", i+1, err))
+		}
+		outcome.Fired = append(outcome.Fired, alert.String())
+		Logger(ctx).Warn("Script check fired", "check", check.If, "alert", alert.String())
+		s.scriptAlert(ctx, job, params, date, alert.String(), check.Severity)
+	}
+	if err := SetResult(ctx, outcome); err != nil {
+		return "", err
+	}
+
+	message := fmt.Sprintf("%d of %d checks fired on job %d", len(outcome.Fired), len(params.Checks), inputID)
+	return scriptMessage(message, outcome, params)
+}
+
+// runLuaScript runs the job's Lua script with the globals documented on
+// ScriptParams. Script errors, including an exhausted step budget, are data
+// errors: running the script again will not fix them.
+func (s *Scheduler) runLuaScript(ctx context.Context, job CronJob, params ScriptParams, date string) (string, error) {
+	chunk, err := s.loadScript(ctx, job, params)
+	if err != nil {
+		return "", err
+	}
+
+	outcome := scriptOutcome{Fired: []string{}}
+	state := lua.NewState()
+	state.Print = func(line string) { Logger(ctx).Info("Script output", "line", line) }
+	if params.Input.JobName != "" {
+		inputID, vars, err := s.scriptInput(ctx, params.Input, date)
+		if err != nil {
+			return "", err
+		}
+		outcome.InputJobID = inputID
+		state.SetGlobal("input", lua.FromJSON(vars))
+	}
+	state.SetGlobal("args", lua.FromJSON(params.Args))
+	state.SetGlobal("job_date", date)
+	state.SetGlobal("alert", &lua.Function{Name: "alert", Fn: func(_ *lua.State, a []lua.Value) ([]lua.Value, error) {
+		text, ok := argAt(a, 0).(string)
+		if !ok {
+			return nil, lua.Errorf("bad argument #1 to 'alert' (string expected, got %s)", lua.TypeName(argAt(a, 0)))
+		}
+		severity, _ := argAt(a, 1).(string)
+		if err := validSeverity(severity); err != nil {
+			return nil, lua.Errorf("alert: %v", err)
+		}
+		outcome.Fired = append(outcome.Fired, text)
+		Logger(ctx).Warn("Script alert fired", "alert", text)
+		s.scriptAlert(ctx, job, params, date, text, severity)
+		return nil, nil
+	}})
+
+	rets, err := state.Run(ctx, chunk)
+	var luaErr *lua.Error
+	switch {
+	case errors.As(err, &luaErr), errors.Is(err, lua.ErrStepLimit):
+		return "", errclass.DataError(err)
+	case err != nil:
+		return "", err
+	}
+	if len(rets) > 0 {
+		if outcome.Result, err = lua.ToJSON(rets[0]); err != nil {
+			return "", errclass.DataError(fmt.Errorf("script result: %w", err))
+		}
+	}
+	if err := SetResult(ctx, outcome); err != nil {
+		return "", err
+	}
+
+	name := params.Script
+	if name == "" {
+		name = "inline script"
+	}
+	message := fmt.Sprintf("%s fired %d alerts", name, len(outcome.Fired))
+	return scriptMessage(message, outcome, params)
+}
+
+func argAt(args []lua.Value, i int) lua.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// scriptAlert notifies for an alert a check or script fired, unless the run
+// is a dry run.
+func (s *Scheduler) scriptAlert(ctx context.Context, job CronJob, params ScriptParams, date, text, severity string) {
+	if DryRun(ctx) {
+		return
+	}
+	if severity == "" {
+		severity = string(notify.SeverityWarning)
+	}
+	subject := params.Input.JobName
+	if subject == "" {
+		subject = job.JobName
+	}
+	s.notify(notify.Message{
+		Event:      "script_check",
+		Subject:    fmt.Sprintf("%s check for %s", subject, date),
+		Body:       text,
+		Severity:   notify.Severity(severity),
+		JobName:    job.JobName,
+		JobID:      job.JobID,
+		Recipients: params.Recipients,
+	})
+}
+
+// scriptMessage appends the fired alerts to message and fails the job for
+// them when FailOnAlert is set.
+func scriptMessage(message string, outcome scriptOutcome, params ScriptParams) (string, error) {
+	if len(outcome.Fired) == 0 {
+		return message, nil
+	}
+	message += "\n" + strings.Join(outcome.Fired, "\n")
+	if params.FailOnAlert {
+		return message, errclass.DataError(fmt.Errorf("%d alerts fired", len(outcome.Fired)))
+	}
+	return message, nil
+}
+
+// alertTemplate parses a check's alert. Misspelled fields are errors rather
+// than "<no value>".
+func alertTemplate(text string) (*template.Template, error) {
+	return template.New("alert").Option("missingkey=error").Parse(text)
+}
+
+// scriptInput loads the result of the job a script checks as variables.
+func (s *Scheduler) scriptInput(ctx context.Context, in ScriptInput, date string) (int64, map[string]any, error) {
+	f := JobFilter{JobName: in.JobName, Statuses: []string{"finished"}, DateFrom: date, DateTo: date, Params: in.Params}
+	if err := f.Validate(); err != nil {
+		return 0, nil, err
+	}
+	where, args := f.where()
+
+	var jobID int64
+	var result []byte
+	err := s.db.QueryRowContext(ctx, `
+		SELECT job_id, job_result FROM cron_jobs
+		WHERE `+where+` AND job_result IS NOT NULL
+		ORDER BY job_id DESC
+		LIMIT 1
+	`, args...).Scan(&jobID, &result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil, errclass.DataError(fmt.Errorf("no finished %s job with a result for %s", in.JobName, date))
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("querying input job: %w", err)
+	}
+
+	vars := map[string]any{}
+	if err := json.Unmarshal(result, &vars); err != nil {
+		return 0, nil, fmt.Errorf("input job %d: result is not a JSON object: %w", jobID, err)
+	}
+	return jobID, vars, nil
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"strings"
+	"testing"
+)
+
+func TestScriptJobRunsLua(t *testing.T) {
+	s, store := newMemoryScheduler(t)
+	ctx := context.Background()
+
+	source := `
+		local low = {}
+		for _, site in ipairs(args.sites) do
+			if site.amt_d < args.min then
+				table.insert(low, site.name)
+				alert(site.name .. " has only " .. site.amt_d .. " reservations", "critical")
+			end
+		end
+		return {date = job_date, low = low}
+	`
+	params := ScriptParams{Source: source, Args: map[string]any{
+		"min":   10.0,
+		"sites": []any{map[string]any{"name": "TH", "amt_d": 8.0}, map[string]any{"name": "KS", "amt_d": 12.0}},
+	}}
+	jobID, _, err := s.EnqueueJob(ctx, "script", "2025-07-15", params, 0)
+	if err != nil {
+		t.Fatalf("EnqueueJob: %v", err)
+	}
+	if err := s.RunJob(ctx, jobID); err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+
+	job, _ := store.Job(jobID)
+	if job.JobStatus != "finished" || !strings.Contains(job.Message, "TH has only 8 reservations") {
+		t.Errorf("job = %s %q, want finished with the TH alert", job.JobStatus, job.Message)
+	}
+	var outcome scriptOutcome
+	if err := json.Unmarshal(job.Result, &outcome); err != nil {
+		t.Fatalf("job_result %s: %v", job.Result, err)
+	}
+	result, _ := json.Marshal(outcome.Result)
+	if len(outcome.Fired) != 1 || string(result) != `{"date":"2025-07-15","low":["TH"]}` {
+		t.Errorf("outcome = %v, result %s", outcome.Fired, result)
+	}
+}
+
+func TestScriptJobErrorsAreDataErrors(t *testing.T) {
+	s, store := newMemoryScheduler(t)
+	ctx := context.Background()
+
+	for _, source := range []string{
+		"return args.missing.field",
+		"while true do end",
+		"alert('low') return 1",
+	} {
+		params := ScriptParams{Source: source, FailOnAlert: true}
+		jobID, _, err := s.EnqueueJob(ctx, "script", "2025-07-15", params, 0)
+		if err != nil {
+			t.Fatalf("EnqueueJob %q: %v", source, err)
+		}
+		if err := s.RunJob(ctx, jobID); err != nil {
+			t.Fatalf("RunJob %q: %v", source, err)
+		}
+		job, _ := store.Job(jobID)
+		runs := store.Runs(jobID)
+		if job.JobStatus != "failed" || len(runs) != 1 || runs[0].ErrorCategory != errclass.Data {
+			t.Errorf("%q: job %s with runs %+v, want failed once as a data error", source, job.JobStatus, runs)
+		}
+	}
+}
+
+func TestScriptParamsValidate(t *testing.T) {
+	tests := []struct {
+		params ScriptParams
+		want   string
+	}{
+		{ScriptParams{Source: "return 1"}, ""},
+		{ScriptParams{Script: "daily_check"}, ""},
+		{ScriptParams{Source: "if then"}, "source:1:"},
+		{ScriptParams{Script: "a b"}, "must be 1-64 letters"},
+		{ScriptParams{Script: "x", Source: "return 1"}, "mutually exclusive"},
+		{ScriptParams{Source: "return 1", Checks: []ScriptCheck{{If: "true", Alert: "x"}}}, "cannot be combined"},
+		{ScriptParams{}, "input.job_name is required"},
+	}
+	for _, tt := range tests {
+		err := tt.params.Validate()
+		if (tt.want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.params, err, tt.want)
+		}
+	}
+}
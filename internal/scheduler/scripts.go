@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"hotbrandon/go-cron-be/internal/lua"
+	"regexp"
+	"time"
+)
+
+// scriptNamePattern is what SaveScript accepts as a script name.
+var scriptNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Script is a Lua script kept in the scripts table, which "script" jobs
+// run by name, so a script shared by several jobs is changed in one place.
+type Script struct {
+	Name      string    `json:"name"`
+	Source    string    `json:"source"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// validScriptName rejects names the scripts table or a URL path would
+// mangle.
+func validScriptName(name string) error {
+	if !scriptNamePattern.MatchString(name) {
+		return fmt.Errorf("script name %q must be 1-64 letters, digits, '_', '.' or '-'", name)
+	}
+	return nil
+}
+
+// SaveScript creates or replaces the script name. The source is compiled
+// first, so a syntax error is reported here rather than by the next run.
+func (s *Scheduler) SaveScript(ctx context.Context, name, source, actor string) (Script, error) {
+	if err := validScriptName(name); err != nil {
+		return Script{}, errclass.DataError(err)
+	}
+	if _, err := lua.Compile(name, source); err != nil {
+		return Script{}, errclass.DataError(err)
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scripts (name, source, updated_by) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE source = VALUES(source), updated_by = VALUES(updated_by)
+	`, name, source, actor)
+	if err != nil {
+		return Script{}, fmt.Errorf("saving script %s: %w", name, err)
+	}
+	s.logger.Info("Script saved", "script", name, "by", actor)
+	return s.Script(ctx, name)
+}
+
+// Script returns the stored script name, or sql.ErrNoRows.
+func (s *Scheduler) Script(ctx context.Context, name string) (Script, error) {
+	var sc Script
+	var updatedBy sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT name, source, updated_by, updated_at FROM scripts WHERE name = ?
+	`, name).Scan(&sc.Name, &sc.Source, &updatedBy, &sc.UpdatedAt)
+	if err != nil {
+		return Script{}, err
+	}
+	sc.UpdatedBy = updatedBy.String
+	return sc, nil
+}
+
+// Scripts returns the stored scripts by name, without their source.
+func (s *Scheduler) Scripts(ctx context.Context) ([]Script, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, updated_by, updated_at FROM scripts ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("querying scripts: %w", err)
+	}
+	defer rows.Close()
+
+	scripts := []Script{}
+	for rows.Next() {
+		var sc Script
+		var updatedBy sql.NullString
+		if err := rows.Scan(&sc.Name, &updatedBy, &sc.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		sc.UpdatedBy = updatedBy.String
+		scripts = append(scripts, sc)
+	}
+	return scripts, rows.Err()
+}
+
+// DeleteScript removes the script name, or returns sql.ErrNoRows. Jobs that
+// still run it fail with a data error.
+func (s *Scheduler) DeleteScript(ctx context.Context, name string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM scripts WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("deleting script %s: %w", name, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	s.logger.Info("Script deleted", "script", name)
+	return nil
+}
+
+// loadScript compiles the script a job runs: its inline source, or the
+// stored script it names.
+func (s *Scheduler) loadScript(ctx context.Context, job CronJob, params ScriptParams) (*lua.Chunk, error) {
+	if params.Source != "" {
+		return lua.Compile(job.JobName, params.Source)
+	}
+	sc, err := s.Script(ctx, params.Script)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errclass.DataError(fmt.Errorf("script %s does not exist", params.Script))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading script %s: %w", params.Script, err)
+	}
+	chunk, err := lua.Compile(sc.Name, sc.Source)
+	if err != nil {
+		return nil, errclass.DataError(err)
+	}
+	return chunk, nil
+}
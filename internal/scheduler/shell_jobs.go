@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultShellTimeout = 5 * time.Minute
+	// maxShellOutput caps how much of stdout and stderr each is kept.
+	maxShellOutput = 16 * 1024
+)
+
+// ShellParams are the job_params of a "shell" job. Args may use Go templates
+// such as {{.JobDate}} or {{.JobID}}.
+type ShellParams struct {
+	Command        string   `json:"command"`
+	Args           []string `json:"args"`
+	Dir            string   `json:"dir"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+}
+
+func (p ShellParams) Validate() error {
+	if p.Command == "" {
+		return errors.New("command is required")
+	}
+	if p.TimeoutSeconds < 0 {
+		return errors.New("timeout_seconds must not be negative")
+	}
+	return nil
+}
+
+// shellCommandAllowed reports whether command is listed in SHELL_JOB_COMMANDS
+// (comma-separated). Shell jobs are refused entirely when it is unset.
+func shellCommandAllowed(command string) bool {
+	allowed := strings.Split(os.Getenv("SHELL_JOB_COMMANDS"), ",")
+	for i := range allowed {
+		allowed[i] = strings.TrimSpace(allowed[i])
+	}
+	return command != "" && slices.Contains(allowed, command)
+}
+
+// runShellJob executes the configured command without a shell, so templated
+// arguments can never be interpreted as shell syntax.
+func (s *Scheduler) runShellJob(ctx context.Context, job CronJob, params ShellParams) (string, error) {
+	if !shellCommandAllowed(params.Command) {
+		return "", errclass.ConfigError(fmt.Errorf("command %q is not listed in SHELL_JOB_COMMANDS", params.Command))
+	}
+
+	args, err := expandArgs(params.Args, job)
+	if err != nil {
+		return "", errclass.DataError(err)
+	}
+
+	timeout := defaultShellTimeout
+	if params.TimeoutSeconds > 0 {
+		timeout = time.Duration(params.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: maxShellOutput}
+	stderr := &limitedBuffer{limit: maxShellOutput}
+	cmd := exec.CommandContext(ctx, params.Command, args...)
+	cmd.Dir = params.Dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	output := fmt.Sprintf("stdout:\n%s\nstderr:\n%s", stdout, stderr)
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, fmt.Errorf("command %q timed out after %s", params.Command, timeout)
+	}
+	if err != nil {
+		return output, fmt.Errorf("running %q: %w", params.Command, err)
+	}
+
+	return output, nil
+}
+
+// expandArgs renders each argument as a template against the job record.
+func expandArgs(args []string, job CronJob) ([]string, error) {
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		value, err := expandTemplate(arg, job)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		expanded[i] = value
+	}
+	return expanded, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a chatty script cannot fill the job record.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.buf.Write(p[:max(remaining, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
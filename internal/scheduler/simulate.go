@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/clock"
+	"slices"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maxSimulatedFirings bounds a simulation, e.g. against "* * * * *" over a
+// year.
+const maxSimulatedFirings = 100000
+
+// Outcomes of a SimulatedJob.
+const (
+	SimCreated      = "created"
+	SimDeduplicated = "deduplicated"
+	SimHeld         = "held"
+	SimSkipped      = "skipped"
+)
+
+// SimulatedJob is a job a schedule would enqueue at At. Outcome tells what
+// would happen to it: created and dispatched, absorbed by unique_job as a
+// duplicate of an earlier firing, held by Blackout until RunAt, or skipped
+// by Blackout.
+type SimulatedJob struct {
+	At       time.Time       `json:"at"`
+	Schedule string          `json:"schedule"`
+	JobName  string          `json:"job_name"`
+	JobDate  string          `json:"job_date"`
+	Params   json.RawMessage `json:"params"`
+	Outcome  string          `json:"outcome"`
+	Blackout string          `json:"blackout,omitempty"`
+	RunAt    *time.Time      `json:"run_at,omitempty"`
+}
+
+// SimulationSummary counts a schedule's firings and job outcomes.
+type SimulationSummary struct {
+	Schedule     string `json:"schedule"`
+	Firings      int    `json:"firings"`
+	Created      int    `json:"created"`
+	Deduplicated int    `json:"deduplicated"`
+	Held         int    `json:"held"`
+	Skipped      int    `json:"skipped"`
+}
+
+// Simulation is the result of Simulate.
+type Simulation struct {
+	Timezone  string              `json:"timezone"`
+	Jobs      []SimulatedJob      `json:"jobs"`
+	Summaries []SimulationSummary `json:"summaries"`
+}
+
+// simulatedSchedule is a schedule and the jobs it enqueues at the time of
+// the scheduler's clock.
+type simulatedSchedule struct {
+	name     string
+	schedule cron.Schedule
+	jobs     func() ([]PlannedJob, error)
+}
+
+// PlannedJob is a job a schedule enqueues when it fires.
+type PlannedJob struct {
+	JobName string
+	JobDate string
+	Params  json.RawMessage
+}
+
+// Simulate fast-forwards a fake clock through every firing of the golf
+// schedule and the schedules of the environment in [from, to), without a
+// database or cron, and reports the jobs each firing would enqueue: which
+// are new, which unique_job would absorb as duplicates, and which blackout
+// windows would hold or skip. It loads the configuration itself, so it must
+// be called on a Scheduler that has not been started. Schedules added
+// through the API live in MySQL and are not simulated.
+func (s *Scheduler) Simulate(from, to time.Time) (Simulation, error) {
+	if !from.Before(to) {
+		return Simulation{}, errors.New("simulation range is empty")
+	}
+	for _, load := range []func() error{s.loadPlugins, s.loadScheduleAliases, s.loadBlackoutWindows, s.loadSchedules} {
+		if err := load(); err != nil {
+			return Simulation{}, err
+		}
+	}
+	schedules, err := s.simulatedSchedules()
+	if err != nil {
+		return Simulation{}, err
+	}
+
+	fake := clock.NewFake(from)
+	s.clock = fake
+	loc := s.c.Location()
+
+	// Collect the firings of all schedules in time order, so dedup matches
+	// what the first firing of a key would create.
+	type firing struct {
+		at    time.Time
+		index int
+	}
+	var firings []firing
+	for i, sched := range schedules {
+		for t := sched.schedule.Next(from.In(loc).Add(-time.Second)); !t.IsZero() && t.Before(to); t = sched.schedule.Next(t) {
+			if len(firings) == maxSimulatedFirings {
+				return Simulation{}, fmt.Errorf("more than %d firings; simulate a shorter range", maxSimulatedFirings)
+			}
+			firings = append(firings, firing{at: t, index: i})
+		}
+	}
+	slices.SortFunc(firings, func(a, b firing) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return a.index - b.index
+	})
+
+	sim := Simulation{Timezone: timezoneName(loc), Jobs: []SimulatedJob{}, Summaries: make([]SimulationSummary, len(schedules))}
+	for i, sched := range schedules {
+		sim.Summaries[i].Schedule = sched.name
+	}
+	seen := map[string]bool{}
+	for _, f := range firings {
+		fake.Set(f.at)
+		sched := schedules[f.index]
+		summary := &sim.Summaries[f.index]
+		summary.Firings++
+
+		jobs, err := sched.jobs()
+		if err != nil {
+			return Simulation{}, fmt.Errorf("schedule %s at %s: %w", sched.name, f.at.Format(time.RFC3339), err)
+		}
+		for _, job := range jobs {
+			simJob := SimulatedJob{At: f.at, Schedule: sched.name, JobName: job.JobName, JobDate: job.JobDate, Params: job.Params}
+			var params bytes.Buffer
+			if err := json.Compact(&params, job.Params); err != nil {
+				return Simulation{}, fmt.Errorf("schedule %s: params: %w", sched.name, err)
+			}
+			key := job.JobName + "\x00" + job.JobDate + "\x00" + params.String()
+			switch w := s.activeBlackout(job.JobName, s.now()); {
+			case seen[key]:
+				simJob.Outcome = SimDeduplicated
+				summary.Deduplicated++
+			case w != nil && w.CatchUp:
+				opened := w.schedule.Next(s.now().Add(-w.duration))
+				runAt := opened.Add(w.duration)
+				simJob.Outcome, simJob.Blackout, simJob.RunAt = SimHeld, w.Name, &runAt
+				summary.Held++
+			case w != nil:
+				simJob.Outcome, simJob.Blackout = SimSkipped, w.Name
+				summary.Skipped++
+			default:
+				simJob.Outcome = SimCreated
+				summary.Created++
+			}
+			seen[key] = true
+			sim.Jobs = append(sim.Jobs, simJob)
+		}
+	}
+	return sim, nil
+}
+
+// simulatedSchedules returns the schedules of the environment, enqueueing
+// through the same code as their cron entries.
+func (s *Scheduler) simulatedSchedules() ([]simulatedSchedule, error) {
+	var schedules []simulatedSchedule
+	for _, job := range s.schedules {
+		schedule, err := cron.ParseStandard(s.resolveSchedule(job.Schedule))
+		if err != nil {
+			return nil, fmt.Errorf("parsing schedule %s: %w", job.Name, err)
+		}
+		schedules = append(schedules, simulatedSchedule{
+			name:     job.Name,
+			schedule: schedule,
+			jobs: func() ([]PlannedJob, error) {
+				jobDate, params, err := s.scheduledParams(job, enqueueDates(s.now()))
+				if err != nil {
+					return nil, err
+				}
+				jobs := make([]PlannedJob, len(params))
+				for i, p := range params {
+					jobs[i] = PlannedJob{JobName: job.JobName, JobDate: jobDate, Params: p}
+				}
+				return jobs, nil
+			},
+		})
+	}
+	return schedules, nil
+}
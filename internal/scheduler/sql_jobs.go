@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/database"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSQLTimeout = 10 * time.Minute
+	// maxSQLResultRows caps how many rows of a SELECT are recorded.
+	maxSQLResultRows = 100
+)
+
+// SQLStatement is one statement of a "sql" job. String args may use Go
+// templates such as {{.JobDate}}.
+type SQLStatement struct {
+	SQL  string `json:"sql"`
+	Args []any  `json:"args"`
+}
+
+// SQLParams are the job_params of a "sql" job. Connection is "mysql", "erp"
+// or a golf site id such as "GC"; statements run in order in one transaction.
+type SQLParams struct {
+	Connection     string         `json:"connection"`
+	Statements     []SQLStatement `json:"statements"`
+	TimeoutSeconds int            `json:"timeout_seconds"`
+}
+
+func (p SQLParams) Validate() error {
+	if p.Connection == "" {
+		return errors.New("connection is required")
+	}
+	if len(p.Statements) == 0 {
+		return errors.New("at least one statement is required")
+	}
+	for i, stmt := range p.Statements {
+		if strings.TrimSpace(stmt.SQL) == "" {
+			return fmt.Errorf("statement %d: sql is required", i+1)
+		}
+	}
+	if p.TimeoutSeconds < 0 {
+		return errors.New("timeout_seconds must not be negative")
+	}
+	return nil
+}
+
+// connection resolves a named connection to its shared pool, which must not
+// be closed.
+func (s *Scheduler) connection(name string) (*sql.DB, error) {
+	switch strings.ToLower(name) {
+	case "mysql":
+		return s.db, nil
+	case "erp":
+		return database.GetErpConnection()
+	default:
+		return database.GetGolfConnection(name)
+	}
+}
+
+func (s *Scheduler) runSQLJob(ctx context.Context, job CronJob, params SQLParams) (string, error) {
+	db, err := s.connection(params.Connection)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := defaultSQLTimeout
+	if params.TimeoutSeconds > 0 {
+		timeout = time.Duration(params.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("beginning transaction on %s: %w", params.Connection, err)
+	}
+	defer tx.Rollback()
+
+	var output strings.Builder
+	for i, stmt := range params.Statements {
+		args, err := expandSQLArgs(stmt.Args, job)
+		if err != nil {
+			return output.String(), errclass.DataError(fmt.Errorf("statement %d: %w", i+1, err))
+		}
+
+		if isQuery(stmt.SQL) {
+			rows, err := queryRows(ctx, tx, stmt.SQL, args)
+			if err != nil {
+				return output.String(), fmt.Errorf("statement %d: %w", i+1, err)
+			}
+			encoded, _ := json.Marshal(rows)
+			fmt.Fprintf(&output, "statement %d: %d rows\n%s\n", i+1, len(rows), encoded)
+			continue
+		}
+
+		result, err := tx.ExecContext(ctx, stmt.SQL, args...)
+		if err != nil {
+			return output.String(), fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		affected, _ := result.RowsAffected()
+		fmt.Fprintf(&output, "statement %d: %d rows affected\n", i+1, affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return output.String(), fmt.Errorf("committing transaction on %s: %w", params.Connection, err)
+	}
+
+	return output.String(), nil
+}
+
+// expandSQLArgs renders string bind args as templates; other JSON values are
+// passed through unchanged.
+func expandSQLArgs(args []any, job CronJob) ([]any, error) {
+	expanded := make([]any, len(args))
+	for i, arg := range args {
+		str, ok := arg.(string)
+		if !ok {
+			expanded[i] = arg
+			continue
+		}
+		value, err := expandTemplate(str, job)
+		if err != nil {
+			return nil, fmt.Errorf("arg %d: %w", i+1, err)
+		}
+		expanded[i] = value
+	}
+	return expanded, nil
+}
+
+func isQuery(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	keyword := strings.ToUpper(fields[0])
+	return keyword == "SELECT" || keyword == "WITH"
+}
+
+// queryRows returns up to maxSQLResultRows rows as column-name maps.
+func queryRows(ctx context.Context, tx *sql.Tx, query string, args []any) ([]map[string]any, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]any
+	for rows.Next() && len(result) < maxSQLResultRows {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return result, nil
+}
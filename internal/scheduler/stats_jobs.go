@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"math"
+	"slices"
+	"time"
+)
+
+// StatsRollupParams are the job_params of a "stats_rollup" job. StatsDate
+// may use templates such as "{{yesterday}}".
+type StatsRollupParams struct {
+	StatsDate string `json:"stats_date"`
+}
+
+func (p StatsRollupParams) Validate() error {
+	if p.StatsDate == "" {
+		return errors.New("stats_date is required")
+	}
+	return nil
+}
+
+// DailyStats is one job_stats_daily row: the completed runs of one job_name
+// that started on StatsDate.
+type DailyStats struct {
+	StatsDate   string  `json:"stats_date"`
+	JobName     string  `json:"job_name"`
+	Runs        int     `json:"runs"`
+	Succeeded   int     `json:"succeeded"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"success_rate"`
+	AvgMs       int64   `json:"avg_ms"`
+	P95Ms       int64   `json:"p95_ms"`
+	MaxMs       int64   `json:"max_ms"`
+}
+
+// runStatsRollupJob aggregates the date's job_runs into job_stats_daily,
+// replacing any earlier rollup of that date so reruns are safe.
+func (s *Scheduler) runStatsRollupJob(ctx context.Context, job CronJob, params StatsRollupParams) (string, error) {
+	statsDate, err := expandTemplate(params.StatsDate, job)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("stats_date: %w", err))
+	}
+	day, err := time.ParseInLocation(dateLayout, statsDate, time.Local)
+	if err != nil {
+		return "", errclass.DataError(fmt.Errorf("stats_date must be YYYY-MM-DD: %w", err))
+	}
+
+	rows, err := s.readDB.QueryContext(ctx, `
+		SELECT j.job_name, r.run_status, COALESCE(r.execution_time_ms, 0)
+		FROM job_runs r
+		JOIN cron_jobs j ON j.job_id = r.job_id
+		WHERE r.started_at >= ? AND r.started_at < ? AND r.run_status <> 'running'
+	`, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return "", fmt.Errorf("querying job_runs: %w", err)
+	}
+	defer rows.Close()
+
+	durations := make(map[string][]int64)
+	stats := make(map[string]*DailyStats)
+	for rows.Next() {
+		var jobName, status string
+		var ms int64
+		if err := rows.Scan(&jobName, &status, &ms); err != nil {
+			return "", fmt.Errorf("scanning row: %w", err)
+		}
+		st, ok := stats[jobName]
+		if !ok {
+			st = &DailyStats{StatsDate: statsDate, JobName: jobName}
+			stats[jobName] = st
+		}
+		st.Runs++
+		if status == "finished" {
+			st.Succeeded++
+		} else {
+			st.Failed++
+		}
+		durations[jobName] = append(durations[jobName], ms)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("rows error: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM job_stats_daily WHERE stats_date = ?", statsDate); err != nil {
+		return "", fmt.Errorf("clearing job_stats_daily: %w", err)
+	}
+	var values [][]any
+	for jobName, st := range stats {
+		ms := durations[jobName]
+		slices.Sort(ms)
+		var total int64
+		for _, d := range ms {
+			total += d
+		}
+		st.SuccessRate = float64(st.Succeeded) / float64(st.Runs)
+		st.AvgMs = total / int64(len(ms))
+		st.P95Ms = percentile(ms, 0.95)
+		st.MaxMs = ms[len(ms)-1]
+		values = append(values, []any{st.StatsDate, st.JobName, st.Runs, st.Succeeded, st.Failed, st.SuccessRate, st.AvgMs, st.P95Ms, st.MaxMs})
+	}
+	err = s.insertBatched(ctx, tx,
+		"INSERT INTO job_stats_daily (stats_date, job_name, runs, succeeded, failed, success_rate, avg_ms, p95_ms, max_ms)", "", values)
+	if err != nil {
+		return "", fmt.Errorf("inserting stats: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("committing stats: %w", err)
+	}
+
+	return fmt.Sprintf("rolled up %s: %d job types", statsDate, len(stats)), nil
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+// ListDailyStats returns the rollups between from and to (inclusive,
+// YYYY-MM-DD), oldest first, optionally for one job name.
+func (s *Scheduler) ListDailyStats(ctx context.Context, from, to, jobName string) ([]DailyStats, error) {
+	for _, date := range []string{from, to} {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return nil, errclass.DataError(fmt.Errorf("dates must be YYYY-MM-DD, got %q", date))
+		}
+	}
+
+	query := `
+		SELECT DATE_FORMAT(stats_date, '%Y-%m-%d'), job_name, runs, succeeded, failed, success_rate, avg_ms, p95_ms, max_ms
+		FROM job_stats_daily
+		WHERE stats_date BETWEEN ? AND ?`
+	args := []any{from, to}
+	if jobName != "" {
+		query += " AND job_name = ?"
+		args = append(args, jobName)
+	}
+	query += " ORDER BY stats_date, job_name"
+
+	rows, err := s.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying job_stats_daily: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []DailyStats{}
+	for rows.Next() {
+		var st DailyStats
+		if err := rows.Scan(&st.StatsDate, &st.JobName, &st.Runs, &st.Succeeded, &st.Failed, &st.SuccessRate,
+			&st.AvgMs, &st.P95Ms, &st.MaxMs); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
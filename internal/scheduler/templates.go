@@ -0,0 +1,231 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/dateutil"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// templateFuncs returns the date helpers available in job param templates.
+// Relative dates are computed from the job's job_date (today if it cannot be
+// parsed), so reruns of an old job produce the same values:
+//
+//	{{today}}, {{yesterday}}, {{add_days -7}}  -> "2025-07-15" style dates
+//	{{roc_date}}, {{roc_yesterday}}            -> "1140715" style ROC dates
+//	{{roc_date_sep "/"}}                       -> "114/07/15"
+//	{{roc (add_days -1)}}                      -> any YYYY-MM-DD date as ROC
+//
+// In params of scheduled and triggered jobs most templates are expanded at
+// enqueue time instead; see EnqueueDates.
+func templateFuncs(job CronJob) template.FuncMap {
+	base, err := time.ParseInLocation(dateLayout, job.JobDate, time.Local)
+	if err != nil {
+		now := time.Now()
+		base = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	}
+
+	return template.FuncMap{
+		"today":     func() string { return base.Format(dateLayout) },
+		"yesterday": func() string { return base.AddDate(0, 0, -1).Format(dateLayout) },
+		"add_days":  func(n int) string { return base.AddDate(0, 0, n).Format(dateLayout) },
+		"roc_date":  func() string { return dateutil.FormatROC(base) },
+		"roc_yesterday": func() string {
+			return dateutil.FormatROC(base.AddDate(0, 0, -1))
+		},
+		"roc_date_sep": func(sep string) string { return dateutil.FormatROCSep(base, sep) },
+		"roc": func(date string) (string, error) {
+			t, err := time.ParseInLocation(dateLayout, date, time.Local)
+			if err != nil {
+				return "", fmt.Errorf("roc: %w", err)
+			}
+			return dateutil.FormatROC(t), nil
+		},
+	}
+}
+
+// expandTemplate renders text as a Go template against the job record, e.g.
+// "{{.JobDate}}" or "{{roc_date}}". Unknown fields are errors rather than
+// "<no value>".
+func expandTemplate(text string, job CronJob) (string, error) {
+	tmpl, err := template.New("param").Funcs(templateFuncs(job)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, job); err != nil {
+		return "", fmt.Errorf("expanding template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// EnqueueDates are the fields of templates in job params that are expanded
+// when the job is enqueued rather than when it runs, computed from the
+// enqueue time:
+//
+//	{{.Today}}, {{.Yesterday}}, {{.Tomorrow}}  -> "2025-07-15" style dates
+//	{{.MonthStart}}, {{.MonthEnd}}             -> "2025-07-01", "2025-07-31"
+//	{{.YearStart}}                             -> "2025-01-01"
+//	{{.ROCDate}}, {{.ROCYesterday}}            -> "1140715" style ROC dates
+//
+// The date functions of templateFuncs are available too, relative to Today.
+// Which time a template is relative to depends on the fields it uses, not on
+// the dialect: a template without fields of the job record, whether
+// "{{.Yesterday}}" or "{{yesterday}}", is expanded at enqueue time, so both
+// give the day before the enqueue date. A template that uses a job field,
+// such as "{{yesterday}}-{{.JobID}}", is stored as is and expanded by the
+// handler, where the functions are relative to the job's job_date. The two
+// kinds of field cannot be mixed in one template.
+type EnqueueDates struct {
+	Today        string
+	Yesterday    string
+	Tomorrow     string
+	MonthStart   string
+	MonthEnd     string
+	YearStart    string
+	ROCDate      string
+	ROCYesterday string
+}
+
+func enqueueDates(now time.Time) EnqueueDates {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := today.AddDate(0, 0, 1-today.Day())
+	return EnqueueDates{
+		Today:        today.Format(dateLayout),
+		Yesterday:    today.AddDate(0, 0, -1).Format(dateLayout),
+		Tomorrow:     today.AddDate(0, 0, 1).Format(dateLayout),
+		MonthStart:   monthStart.Format(dateLayout),
+		MonthEnd:     monthStart.AddDate(0, 1, -1).Format(dateLayout),
+		YearStart:    time.Date(today.Year(), 1, 1, 0, 0, 0, 0, today.Location()).Format(dateLayout),
+		ROCDate:      dateutil.FormatROC(today),
+		ROCYesterday: dateutil.FormatROC(today.AddDate(0, 0, -1)),
+	}
+}
+
+// expandEnqueueTemplate renders text against dates. Templates that refer to
+// fields of the job record, such as {{.JobID}}, are returned unchanged for
+// the handler to expand when the job runs. Unknown fields, and templates
+// that use both kinds of field, are errors: the handler could not expand
+// the EnqueueDates fields.
+func expandEnqueueTemplate(text string, dates EnqueueDates) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("param").Funcs(templateFuncs(CronJob{JobDate: dates.Today})).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template %q: %w", text, err)
+	}
+	var enqueueField, jobField string
+	for _, field := range templateFields(tmpl.Tree.Root) {
+		if _, ok := reflect.TypeFor[EnqueueDates]().FieldByName(field); ok {
+			enqueueField = field
+			continue
+		}
+		if _, ok := reflect.TypeFor[CronJob]().FieldByName(field); ok {
+			jobField = field
+			continue
+		}
+		return "", fmt.Errorf("template %q: unknown field %s", text, field)
+	}
+	if jobField != "" && enqueueField != "" {
+		return "", fmt.Errorf("template %q: .%s is expanded when the job is enqueued but .%s only when it runs; use date functions such as {{yesterday}} with job fields", text, enqueueField, jobField)
+	}
+	if jobField != "" {
+		return text, nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, dates); err != nil {
+		return "", fmt.Errorf("expanding template %q: %w", text, err)
+	}
+	return buf.String(), nil
+}
+
+// templateFields lists the top-level fields a template refers to, e.g.
+// "Today" for {{.Today}}.
+func templateFields(node parse.Node) []string {
+	var fields []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			fields = append(fields, templateFields(child)...)
+		}
+	case *parse.ActionNode:
+		fields = templateFields(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			fields = append(fields, templateFields(cmd)...)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			fields = append(fields, templateFields(arg)...)
+		}
+	case *parse.FieldNode:
+		fields = append(fields, n.Ident[0])
+	case *parse.ChainNode:
+		fields = templateFields(n.Node)
+	case *parse.IfNode:
+		fields = append(append(templateFields(n.Pipe), templateFields(n.List)...), templateFields(n.ElseList)...)
+	case *parse.RangeNode:
+		fields = append(append(templateFields(n.Pipe), templateFields(n.List)...), templateFields(n.ElseList)...)
+	case *parse.WithNode:
+		fields = append(append(templateFields(n.Pipe), templateFields(n.List)...), templateFields(n.ElseList)...)
+	}
+	return fields
+}
+
+// expandParams expands the enqueue templates in every string of raw, a
+// job_params object. raw is returned as is when it holds no templates, so
+// the job_params_hash of plain params does not change.
+func expandParams(raw json.RawMessage, dates EnqueueDates) (json.RawMessage, error) {
+	if !bytes.Contains(raw, []byte("{{")) {
+		return raw, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decoding job_params: %w", err)
+	}
+	expanded, err := expandValue(v, dates)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(expanded)
+}
+
+func expandValue(v any, dates EnqueueDates) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return expandEnqueueTemplate(v, dates)
+	case map[string]any:
+		for key, value := range v {
+			expanded, err := expandValue(value, dates)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v[key] = expanded
+		}
+	case []any:
+		for i, value := range v {
+			expanded, err := expandValue(value, dates)
+			if err != nil {
+				return nil, fmt.Errorf("%d: %w", i, err)
+			}
+			v[i] = expanded
+		}
+	}
+	return v, nil
+}
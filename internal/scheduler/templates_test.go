@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandEnqueueTemplate(t *testing.T) {
+	dates := enqueueDates(time.Date(2025, 7, 15, 9, 30, 0, 0, time.Local))
+	tests := []struct {
+		text, want, err string
+	}{
+		{"plain", "plain", ""},
+		{"{{.Yesterday}}", "2025-07-14", ""},
+		{"{{yesterday}}", "2025-07-14", ""},
+		{"{{.MonthStart}}..{{.MonthEnd}}", "2025-07-01..2025-07-31", ""},
+		{"{{.ROCDate}}", "1140715", ""},
+		{"{{yesterday}}-{{.JobID}}", "{{yesterday}}-{{.JobID}}", ""},
+		{"{{.Today}}-{{.JobID}}", "", "use date functions"},
+		{"{{.Tomorow}}", "", "unknown field Tomorow"},
+	}
+	for _, tt := range tests {
+		got, err := expandEnqueueTemplate(tt.text, dates)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expandEnqueueTemplate(%q) = %q, %v, want error %q", tt.text, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("expandEnqueueTemplate(%q) = %q, %v, want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestScheduledJobExpandArray(t *testing.T) {
+	job := ScheduledJob{JobName: "golf", Params: json.RawMessage(`[{"db_id": "GC", "job_date": "{{.Today}}"}, {"db_id": "TH", "job_date": "{{.Today}}"}]`)}
+	jobDate, params, err := job.expand(enqueueDates(time.Date(2025, 7, 15, 12, 0, 0, 0, time.Local)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobDate != "2025-07-15" || len(params) != 2 ||
+		string(params[0]) != `{"db_id":"GC","job_date":"2025-07-15"}` || string(params[1]) != `{"db_id":"TH","job_date":"2025-07-15"}` {
+		t.Errorf("expand = %s, %s", jobDate, params)
+	}
+
+	job.Params = json.RawMessage(`[]`)
+	if _, _, err := job.expand(enqueueDates(time.Now())); err == nil {
+		t.Error("expand of an empty params array succeeded")
+	}
+}
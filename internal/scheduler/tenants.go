@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// DefaultTenant owns job types that JOB_TENANTS does not assign.
+const DefaultTenant = "default"
+
+// loadTenants reads JOB_TENANTS, which assigns job types to business units
+// ("golf=golf,einvoice_upload=funeral"), and TENANT_CONCURRENCY, the most
+// jobs of one tenant that may run at once ("golf=2,funeral=1").
+func (s *Scheduler) loadTenants() error {
+	tenants, err := parseKeyValues(os.Getenv("JOB_TENANTS"))
+	if err != nil {
+		return fmt.Errorf("parsing JOB_TENANTS: %w", err)
+	}
+	s.tenants = tenants
+
+	limits, err := parseKeyValues(os.Getenv("TENANT_CONCURRENCY"))
+	if err != nil {
+		return fmt.Errorf("parsing TENANT_CONCURRENCY: %w", err)
+	}
+	s.tenantSlots = make(map[string]chan struct{}, len(limits))
+	for tenant, value := range limits {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("parsing TENANT_CONCURRENCY: invalid limit for %q, must be a positive integer", tenant)
+		}
+		s.tenantSlots[tenant] = make(chan struct{}, n)
+	}
+	return nil
+}
+
+// assignTenants moves the existing jobs of each JOB_TENANTS entry from the
+// default tenant to their own.
+func (s *Scheduler) assignTenants() error {
+	for jobName, tenant := range s.tenants {
+		_, err := s.db.Exec("UPDATE cron_jobs SET tenant = ? WHERE job_name = ? AND tenant = ?", tenant, jobName, DefaultTenant)
+		if err != nil {
+			return fmt.Errorf("assigning %s jobs to tenant %s: %w", jobName, tenant, err)
+		}
+	}
+	return nil
+}
+
+// TenantOf returns the tenant that owns jobName.
+func (s *Scheduler) TenantOf(jobName string) string {
+	if tenant, ok := s.tenants[jobName]; ok {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// Tenants returns every configured tenant, including the default one.
+func (s *Scheduler) Tenants() []string {
+	seen := map[string]bool{DefaultTenant: true}
+	for _, tenant := range s.tenants {
+		seen[tenant] = true
+	}
+	names := make([]string, 0, len(seen))
+	for tenant := range seen {
+		names = append(names, tenant)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// acquireTenantSlot is acquireSlot for the tenant quota.
+func (s *Scheduler) acquireTenantSlot(tenant string) (release func(), ok bool) {
+	slot, limited := s.tenantSlots[tenant]
+	if !limited {
+		return func() {}, true
+	}
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, true
+	default:
+		return nil, false
+	}
+}
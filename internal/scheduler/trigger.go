@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"time"
+)
+
+// TriggerJob enqueues a job on behalf of an API caller. Unknown job names and
+// params that do not decode for the job type are rejected as data errors
+// instead of failing later in the worker. An empty jobDate means today.
+// Templates in params such as {{.Yesterday}} are expanded now, see
+// EnqueueDates. A dry run is a job of its own, so it does not stand in for
+// the real one.
+func (s *Scheduler) TriggerJob(ctx context.Context, jobName, jobDate string, params json.RawMessage, priority int, dryRun bool) (int64, bool, error) {
+	jt, ok := s.jobTypes[jobName]
+	if !ok {
+		return 0, false, errclass.DataError(fmt.Errorf("unknown job_name %q", jobName))
+	}
+	if dryRun && !jt.dryRun {
+		return 0, false, errclass.DataError(fmt.Errorf("job type %q does not support dry runs", jobName))
+	}
+
+	if jobDate == "" {
+		jobDate = s.now().Format(dateLayout)
+	} else if _, err := time.Parse(dateLayout, jobDate); err != nil {
+		return 0, false, errclass.DataError(fmt.Errorf("job_date must be YYYY-MM-DD, got %q", jobDate))
+	}
+
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	params, err := expandParams(params, enqueueDates(s.now()))
+	if err != nil {
+		return 0, false, errclass.DataError(err)
+	}
+	if err := jt.validate(string(params)); err != nil {
+		return 0, false, errclass.DataError(err)
+	}
+	if dryRun {
+		if params, err = markDryRun(params); err != nil {
+			return 0, false, errclass.DataError(err)
+		}
+	}
+
+	return s.EnqueueJob(ctx, jobName, jobDate, params, priority)
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/notify"
+	"os"
+	"time"
+)
+
+const notifyTimeout = 30 * time.Second
+
+// loadMaxRuntimes reads JOB_MAX_RUNTIME, e.g. "golf=5m,oracle_proc=30m", and
+// JOB_MAX_RUNTIME_ACTION, which is "cancel" (default) or "flag".
+func (s *Scheduler) loadMaxRuntimes() error {
+	values, err := parseKeyValues(os.Getenv("JOB_MAX_RUNTIME"))
+	if err != nil {
+		return fmt.Errorf("parsing JOB_MAX_RUNTIME: %w", err)
+	}
+
+	s.maxRuntimes = make(map[string]time.Duration, len(values))
+	for jobName, value := range values {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("parsing JOB_MAX_RUNTIME: invalid duration for %q: %q", jobName, value)
+		}
+		s.maxRuntimes[jobName] = d
+	}
+
+	switch action := os.Getenv("JOB_MAX_RUNTIME_ACTION"); action {
+	case "", "cancel":
+		s.cancelOverruns = true
+	case "flag":
+		s.cancelOverruns = false
+	default:
+		return fmt.Errorf("JOB_MAX_RUNTIME_ACTION must be cancel or flag, got %q", action)
+	}
+	return nil
+}
+
+// watchRuntime arms a watchdog for a run of job. When the job type's max
+// runtime elapses it raises a notification and, in cancel mode, cancels the
+// run through cancelRun. The returned stop function disarms the watchdog and
+// reports whether it fired.
+func (s *Scheduler) watchRuntime(job CronJob, cancelRun context.CancelFunc) (stop func() bool) {
+	limit, ok := s.maxRuntimes[job.JobName]
+	if !ok {
+		return func() bool { return false }
+	}
+
+	fired := make(chan struct{})
+	timer := time.AfterFunc(limit, func() {
+		close(fired)
+		action := "flagged"
+		if s.cancelOverruns {
+			action = "cancelled"
+			cancelRun()
+		}
+		s.logger.Warn("Job exceeded max runtime", "job_id", job.JobID, "job_name", job.JobName, "max_runtime", limit, "action", action)
+		s.notify(notify.Message{
+			Event:    "max_runtime_exceeded",
+			Subject:  fmt.Sprintf("Job %s #%d exceeded max runtime", job.JobName, job.JobID),
+			Body:     fmt.Sprintf("Job %d (%s, job_date %s) has been running for more than %s and was %s.", job.JobID, job.JobName, job.JobDate, limit, action),
+			Severity: notify.SeverityWarning,
+			JobName:  job.JobName,
+			JobID:    job.JobID,
+		})
+	})
+
+	return func() bool {
+		if timer.Stop() {
+			return false
+		}
+		<-fired
+		return true
+	}
+}
+
+// notify delivers msg through the configured notifier, logging failures.
+func (s *Scheduler) notify(msg notify.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := s.notifier.Notify(ctx, msg); err != nil {
+		s.logger.Error("Failed to send notification", "subject", msg.Subject, "error", err)
+	}
+}
@@ -0,0 +1,367 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/envlist"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	webhookSpec        = "@every 10s"
+	webhookBatchSize   = 50
+	webhookTimeout     = 10 * time.Second
+	webhookMaxAttempts = 10
+	// webhookLease is how long a claimed delivery is hidden from other
+	// relays; one that is neither delivered nor failed by then, because its
+	// relay died, is retried.
+	webhookLease = 2 * relayTimeout
+	// webhookAllJobs registers a webhook for every job type.
+	webhookAllJobs = "*"
+)
+
+// Webhook is a URL that receives a signed JSON payload whenever a job of
+// JobName finishes or fails.
+//
+// Each request carries X-Webhook-Id (the delivery id, stable across
+// retries), X-Webhook-Timestamp (unix seconds) and X-Webhook-Signature,
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed
+// with the webhook's secret.
+type Webhook struct {
+	WebhookID int64     `json:"webhook_id"`
+	JobName   string    `json:"job_name"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks.
+type WebhookPayload struct {
+	Event           string    `json:"event"`
+	JobID           int64     `json:"job_id"`
+	JobName         string    `json:"job_name"`
+	JobDate         string    `json:"job_date"`
+	RunID           int64     `json:"run_id"`
+	Status          string    `json:"status"`
+	ErrorCategory   string    `json:"error_category,omitempty"`
+	Message         string    `json:"message"`
+	ExecutionTimeMs int64     `json:"execution_time_ms"`
+	FinishedAt      time.Time `json:"finished_at"`
+}
+
+// ListWebhooks returns all registered webhooks without their secrets.
+func (s *Scheduler) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT webhook_id, job_name, url, enabled, created_at
+		FROM webhooks ORDER BY webhook_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("querying webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var w Webhook
+		if err := rows.Scan(&w.WebhookID, &w.JobName, &w.URL, &w.Enabled, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// CreateWebhook registers rawURL for jobName ("*" for every job type). When
+// secret is empty a random one is generated; the returned Webhook is the only
+// place it is ever shown.
+func (s *Scheduler) CreateWebhook(ctx context.Context, jobName, rawURL, secret string) (Webhook, error) {
+	if jobName != webhookAllJobs {
+		if _, ok := s.jobTypes[jobName]; !ok {
+			return Webhook{}, errclass.DataError(fmt.Errorf("unknown job_name %q", jobName))
+		}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, errclass.DataError(fmt.Errorf("url %q must be an absolute http(s) URL", rawURL))
+	}
+	if !s.webhookHostAllowed(u.Hostname()) {
+		return Webhook{}, errclass.DataError(fmt.Errorf("host %q is not in WEBHOOK_ALLOWED_HOSTS", u.Hostname()))
+	}
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return Webhook{}, fmt.Errorf("generating secret: %w", err)
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	result, err := s.db.ExecContext(ctx, "INSERT INTO webhooks (job_name, url, secret) VALUES (?, ?, ?)", jobName, rawURL, secret)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("inserting webhook: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Webhook{}, fmt.Errorf("reading webhook id: %w", err)
+	}
+
+	return Webhook{WebhookID: id, JobName: jobName, URL: rawURL, Secret: secret, Enabled: true, CreatedAt: time.Now()}, nil
+}
+
+// DeleteWebhook removes a webhook and its undelivered payloads. It returns
+// sql.ErrNoRows if the webhook does not exist.
+func (s *Scheduler) DeleteWebhook(ctx context.Context, webhookID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE webhook_id = ?", webhookID)
+	if err != nil {
+		return fmt.Errorf("deleting webhook: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = ? AND delivered_at IS NULL", webhookID); err != nil {
+		return fmt.Errorf("deleting pending deliveries: %w", err)
+	}
+	return tx.Commit()
+}
+
+// enqueueWebhooks queues the payload for every enabled webhook of the job's
+// type. It runs in the transaction that records the job result so a
+// delivery is queued if and only if the result is stored.
+func enqueueWebhooks(tx *sql.Tx, job CronJob, runID int64, status string, category errclass.Category, message string, elapsed time.Duration) error {
+	event := "job_finished"
+	if status == "failed" {
+		event = "job_failed"
+	}
+	payload, err := json.Marshal(WebhookPayload{
+		Event:           event,
+		JobID:           job.JobID,
+		JobName:         job.JobName,
+		JobDate:         job.JobDate,
+		RunID:           runID,
+		Status:          status,
+		ErrorCategory:   string(category),
+		Message:         message,
+		ExecutionTimeMs: elapsed.Milliseconds(),
+		FinishedAt:      time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding webhook payload: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, payload)
+		SELECT webhook_id, ? FROM webhooks
+		WHERE enabled AND job_name IN (?, ?)
+	`, string(payload), job.JobName, webhookAllJobs)
+	if err != nil {
+		return fmt.Errorf("queueing webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+type webhookDelivery struct {
+	id       int64
+	attempts int
+	url      string
+	secret   string
+	payload  []byte
+}
+
+// loadWebhookHosts reads WEBHOOK_ALLOWED_HOSTS, the comma-separated hosts
+// webhooks may be sent to: "hooks.example.com" for that host,
+// ".example.com" for its subdomains, or "*" for any host. Webhooks are
+// registered through the API, so without it none can be created and
+// existing ones are not delivered.
+func (s *Scheduler) loadWebhookHosts() error {
+	s.webhookHosts = nil
+	for _, host := range envlist.Split(os.Getenv("WEBHOOK_ALLOWED_HOSTS")) {
+		if strings.ContainsAny(host, "/:") {
+			return fmt.Errorf("WEBHOOK_ALLOWED_HOSTS: %q must be a host name without scheme or port", host)
+		}
+		s.webhookHosts = append(s.webhookHosts, strings.ToLower(host))
+	}
+	return nil
+}
+
+func (s *Scheduler) webhookHostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range s.webhookHosts {
+		switch {
+		case allowed == "*", allowed == host:
+			return true
+		case strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed):
+			return true
+		}
+	}
+	return false
+}
+
+// webhookClient is http.DefaultClient, except that redirects are only
+// followed to allowed hosts.
+func (s *Scheduler) webhookClient() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if !s.webhookHostAllowed(req.URL.Hostname()) {
+				return fmt.Errorf("redirect to %s is not in WEBHOOK_ALLOWED_HOSTS", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+}
+
+// DeliverWebhooks POSTs due webhook payloads. Unlike the outbox relay, each
+// delivery is retried independently with exponential backoff (30s doubling
+// up to 1h) so one unreachable endpoint does not hold up the others. After
+// webhookMaxAttempts the delivery is left undelivered with its last error.
+//
+// Deliveries are claimed first, by counting the attempt and leasing them for
+// webhookLease, so no rows stay locked while the requests are made.
+func (s *Scheduler) DeliverWebhooks() {
+	ctx, cancel := context.WithTimeout(s.ctx, relayTimeout)
+	defer cancel()
+
+	due, err := s.claimWebhookDeliveries(ctx)
+	if err != nil {
+		s.logger.Error("Failed to claim webhook deliveries", "error", err)
+		return
+	}
+
+	client := s.webhookClient()
+	for _, d := range due {
+		var err error
+		if u, perr := url.Parse(d.url); perr != nil || !s.webhookHostAllowed(u.Hostname()) {
+			err = errors.New("host is not in WEBHOOK_ALLOWED_HOSTS")
+		} else {
+			err = postWebhook(ctx, client, d)
+		}
+		if err != nil {
+			s.logger.Warn("Webhook delivery failed", "delivery_id", d.id, "url", d.url, "attempt", d.attempts+1, "error", err)
+			backoff := min(30<<d.attempts, 3600)
+			if _, err := s.db.ExecContext(ctx, `
+				UPDATE webhook_deliveries
+				SET last_error = ?, next_attempt_at = NOW() + INTERVAL ? SECOND
+				WHERE delivery_id = ?
+			`, err.Error(), backoff, d.id); err != nil {
+				s.logger.Error("Failed to record webhook failure", "delivery_id", d.id, "error", err)
+			}
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, "UPDATE webhook_deliveries SET delivered_at = NOW(), last_error = NULL WHERE delivery_id = ?", d.id); err != nil {
+			s.logger.Error("Failed to mark webhook delivered", "delivery_id", d.id, "error", err)
+		}
+	}
+}
+
+// claimWebhookDeliveries counts an attempt on each due delivery and hides it
+// from other relays for webhookLease, in a transaction committed before any
+// delivery is made. The attempts returned are those before the claim.
+func (s *Scheduler) claimWebhookDeliveries(ctx context.Context) ([]webhookDelivery, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT d.delivery_id, d.attempts, w.url, w.secret, d.payload
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.webhook_id = d.webhook_id
+		WHERE d.delivered_at IS NULL AND d.next_attempt_at <= NOW() AND d.attempts < ?
+		ORDER BY d.delivery_id
+		LIMIT ?
+		FOR UPDATE OF d SKIP LOCKED
+	`, webhookMaxAttempts, webhookBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("loading webhook deliveries: %w", err)
+	}
+	var due []webhookDelivery
+	for rows.Next() {
+		var d webhookDelivery
+		if err := rows.Scan(&d.id, &d.attempts, &d.url, &d.secret, &d.payload); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning webhook delivery: %w", err)
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("loading webhook deliveries: %w", err)
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]any, len(due))
+	for i, d := range due {
+		ids[i] = d.id
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = NOW() + INTERVAL ? SECOND
+		WHERE delivery_id IN (`+placeholders(len(ids))+`)
+	`, append([]any{int(webhookLease.Seconds())}, ids...)...)
+	if err != nil {
+		return nil, fmt.Errorf("claiming webhook deliveries: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// postWebhook sends one signed delivery. Any non-2xx status is a failure.
+func postWebhook(ctx context.Context, client *http.Client, d webhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(d.secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(d.payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-cron-be-webhook")
+	req.Header.Set("X-Webhook-Id", strconv.FormatInt(d.id, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookHostAllowed(t *testing.T) {
+	s, _ := newMemoryScheduler(t)
+	t.Setenv("WEBHOOK_ALLOWED_HOSTS", "hooks.example.com, .partner.test")
+	if err := s.loadWebhookHosts(); err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]bool{
+		"hooks.example.com":  true,
+		"HOOKS.example.com.": true,
+		"api.partner.test":   true,
+		"partner.test":       false,
+		"example.com":        false,
+		"169.254.169.254":    false,
+		"localhost":          false,
+	} {
+		if got := s.webhookHostAllowed(host); got != want {
+			t.Errorf("webhookHostAllowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	t.Setenv("WEBHOOK_ALLOWED_HOSTS", "https://hooks.example.com")
+	if err := s.loadWebhookHosts(); err == nil {
+		t.Error("a URL in WEBHOOK_ALLOWED_HOSTS was accepted")
+	}
+}
+
+func TestPostWebhookRefusesRedirectToOtherHost(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect to a host outside the allowlist was followed")
+	}))
+	defer internal.Close()
+	// Reached as 127.0.0.1, redirecting to the same server as localhost.
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(internal.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	s, _ := newMemoryScheduler(t)
+	t.Setenv("WEBHOOK_ALLOWED_HOSTS", "127.0.0.1")
+	if err := s.loadWebhookHosts(); err != nil {
+		t.Fatal(err)
+	}
+	err := postWebhook(context.Background(), s.webhookClient(), webhookDelivery{id: 1, url: redirector.URL, payload: []byte("{}")})
+	if err == nil || !strings.Contains(err.Error(), "not in WEBHOOK_ALLOWED_HOSTS") {
+		t.Errorf("postWebhook = %v, want the redirect refused", err)
+	}
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWorkerCount = 4
+	// shutdownGrace is how long Stop waits for running jobs before cancelling them.
+	shutdownGrace = 30 * time.Second
+)
+
+// loadWorkerCount reads WORKER_COUNT, defaulting to defaultWorkerCount.
+func (s *Scheduler) loadWorkerCount() error {
+	s.workerCount = defaultWorkerCount
+	raw := os.Getenv("WORKER_COUNT")
+	if raw == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return fmt.Errorf("WORKER_COUNT must be a positive integer, got %q", raw)
+	}
+	s.workerCount = n
+	return nil
+}
+
+// startWorkers launches the goroutines that execute jobs handed out by
+// RunPendingJobs.
+func (s *Scheduler) startWorkers() {
+	for i := 0; i < s.workerCount; i++ {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			for job := range s.queue {
+				s.busy.Add(1)
+				s.runJob(s.ctx, job)
+				s.busy.Add(-1)
+			}
+		}()
+	}
+	s.logger.Info("Workers started", "count", s.workerCount)
+}
+
+// enqueue hands job to the next free worker. It returns false once the
+// scheduler is stopping.
+func (s *Scheduler) enqueue(job CronJob) bool {
+	select {
+	case s.queue <- job:
+		return true
+	case <-s.stopping:
+		return false
+	}
+}
+
+// stopWorkers lets in-flight jobs finish, cancelling them if they are still
+// running after shutdownGrace. No dispatch may be running when it is called.
+func (s *Scheduler) stopWorkers() {
+	close(s.queue)
+
+	finished := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(shutdownGrace):
+		s.logger.Warn("Jobs still running after shutdown grace period, cancelling them", "grace", shutdownGrace)
+		s.cancel()
+		<-finished
+	}
+	s.cancel()
+}
@@ -0,0 +1,128 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// EncryptedPrefix marks a config value sealed with Encrypt, e.g.
+// MYSQL_DSN=enc:v1:3q2+7w...
+const EncryptedPrefix = "enc:v1:"
+
+// KeyFromEnv returns the AES-256 key for encrypted config values from
+// CONFIG_KEY (base64) or the file named by CONFIG_KEY_FILE. It returns nil,
+// nil when neither is set.
+func KeyFromEnv() ([]byte, error) {
+	raw := os.Getenv("CONFIG_KEY")
+	if path := os.Getenv("CONFIG_KEY_FILE"); raw == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading CONFIG_KEY_FILE: %w", err)
+		}
+		raw = string(data)
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	return ParseKey(raw)
+}
+
+// ParseKey decodes a base64 AES-256 key, as printed by "openssl rand -base64 32".
+func ParseKey(raw string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("config key is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("config key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with AES-256-GCM. The variable name is bound as
+// additional data, so a value cannot be moved to another variable.
+func Encrypt(key []byte, name, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(name))
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt for the same name.
+func Decrypt(key []byte, name, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, EncryptedPrefix)
+	if !ok {
+		return "", fmt.Errorf("%s is not an encrypted value", name)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%s: invalid base64: %w", name, err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("%s: encrypted value is truncated", name)
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("%s: decryption failed (wrong key or value?)", name)
+	}
+	return string(plaintext), nil
+}
+
+// EncryptedEnv lists the environment variables holding encrypted values.
+func EncryptedEnv() []string {
+	var names []string
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(value, EncryptedPrefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DecryptEnv replaces every encrypted environment variable with its
+// plaintext and returns the names it decrypted.
+func DecryptEnv(key []byte) ([]string, error) {
+	names := EncryptedEnv()
+	var errs []error
+	for _, name := range names {
+		plaintext, err := Decrypt(key, name, os.Getenv(name))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Setenv(name, plaintext); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("config key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,110 @@
+// Package sftp delivers files to the SFTP drop that downstream systems such
+// as the accounting ledger ingest from.
+//
+// Uploads go through the OpenSSH sftp client in batch mode, so the host key
+// must already be trusted (SFTP_KNOWN_HOSTS) and authentication is by private
+// key only.
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+type Client struct {
+	host       string
+	port       string
+	user       string
+	keyFile    string
+	knownHosts string
+	remoteDir  string
+}
+
+// NewClientFromEnv configures a client from SFTP_HOST, SFTP_PORT (default
+// 22), SFTP_USER, SFTP_KEY_FILE, SFTP_KNOWN_HOSTS and SFTP_REMOTE_DIR.
+func NewClientFromEnv() (*Client, error) {
+	c := &Client{
+		host:       os.Getenv("SFTP_HOST"),
+		port:       os.Getenv("SFTP_PORT"),
+		user:       os.Getenv("SFTP_USER"),
+		keyFile:    os.Getenv("SFTP_KEY_FILE"),
+		knownHosts: os.Getenv("SFTP_KNOWN_HOSTS"),
+		remoteDir:  os.Getenv("SFTP_REMOTE_DIR"),
+	}
+	if c.port == "" {
+		c.port = "22"
+	}
+	if c.host == "" || c.user == "" || c.keyFile == "" {
+		return nil, errclass.ConfigError(errors.New("SFTP_HOST, SFTP_USER and SFTP_KEY_FILE must be set"))
+	}
+	if _, err := exec.LookPath("sftp"); err != nil {
+		return nil, errclass.ConfigError(fmt.Errorf("sftp client not installed: %w", err))
+	}
+	return c, nil
+}
+
+// Destination returns where Upload puts remoteName, for log messages.
+func (c *Client) Destination(remoteDir, remoteName string) string {
+	return fmt.Sprintf("%s@%s:%s", c.user, c.host, c.remotePath(remoteDir, remoteName))
+}
+
+// Upload copies localPath to remoteName in remoteDir (SFTP_REMOTE_DIR if
+// empty). The file is uploaded under a temporary name and renamed at the end
+// so the downstream system never picks up a partial file; an existing file
+// with the same name is replaced.
+func (c *Client) Upload(ctx context.Context, localPath, remoteDir, remoteName string) error {
+	if strings.ContainsAny(localPath, "\"\n") || strings.ContainsAny(remoteDir+remoteName, "\"\n") {
+		return errclass.DataError(fmt.Errorf("unsupported characters in upload path %q", remoteName))
+	}
+
+	final := c.remotePath(remoteDir, remoteName)
+	tmp := path.Join(path.Dir(final), "."+path.Base(final)+".part")
+
+	// A leading "-" tells sftp to carry on if the command fails.
+	var batch strings.Builder
+	fmt.Fprintf(&batch, "-rm \"%s\"\n", tmp)
+	fmt.Fprintf(&batch, "put \"%s\" \"%s\"\n", localPath, tmp)
+	fmt.Fprintf(&batch, "-rm \"%s\"\n", final)
+	fmt.Fprintf(&batch, "rename \"%s\" \"%s\"\n", tmp, final)
+
+	args := []string{
+		"-b", "-",
+		"-P", c.port,
+		"-i", c.keyFile,
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=yes",
+	}
+	if c.knownHosts != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+c.knownHosts)
+	}
+	args = append(args, c.user+"@"+c.host)
+
+	cmd := exec.CommandContext(ctx, "sftp", args...)
+	cmd.Stdin = strings.NewReader(batch.String())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Connection drops and a busy server are the usual causes; the retry
+		// policy decides whether to try again.
+		return errclass.TransientError(fmt.Errorf("uploading %s to %s: %w: %s", localPath, c.Destination(remoteDir, remoteName), err, strings.TrimSpace(stderr.String())))
+	}
+	return nil
+}
+
+func (c *Client) remotePath(remoteDir, remoteName string) string {
+	if remoteDir == "" {
+		remoteDir = c.remoteDir
+	}
+	if remoteDir == "" {
+		return remoteName
+	}
+	return path.Join(remoteDir, remoteName)
+}
@@ -0,0 +1,369 @@
+// Package storage archives exports, run logs and old job rows to an
+// S3-compatible bucket (AWS S3 or MinIO).
+//
+// Requests are signed with AWS Signature Version 4. Only the handful of
+// object operations the scheduler needs are implemented, so no SDK is
+// required.
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/errclass"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsignedPayload lets uploads stream without hashing the body first.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	prefix    string
+	pathStyle bool
+	retention time.Duration
+	http      *http.Client
+}
+
+// NewClientFromEnv configures a client from STORAGE_ENDPOINT, STORAGE_REGION
+// (default us-east-1), STORAGE_BUCKET, STORAGE_ACCESS_KEY,
+// STORAGE_SECRET_KEY, STORAGE_PREFIX, STORAGE_PATH_STYLE (default true, as
+// MinIO expects) and STORAGE_RETENTION. It returns nil, nil when
+// STORAGE_BUCKET is not set so archival stays optional.
+func NewClientFromEnv() (*Client, error) {
+	bucket := os.Getenv("STORAGE_BUCKET")
+	if bucket == "" {
+		return nil, nil
+	}
+
+	endpoint, err := url.Parse(os.Getenv("STORAGE_ENDPOINT"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, errclass.ConfigError(fmt.Errorf("STORAGE_ENDPOINT must be an http(s) URL"))
+	}
+
+	c := &Client{
+		endpoint:  endpoint,
+		region:    os.Getenv("STORAGE_REGION"),
+		bucket:    bucket,
+		accessKey: os.Getenv("STORAGE_ACCESS_KEY"),
+		secretKey: os.Getenv("STORAGE_SECRET_KEY"),
+		prefix:    strings.Trim(os.Getenv("STORAGE_PREFIX"), "/"),
+		pathStyle: true,
+		http:      &http.Client{Timeout: 10 * time.Minute},
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, errclass.ConfigError(errors.New("STORAGE_ACCESS_KEY and STORAGE_SECRET_KEY must be set"))
+	}
+	if raw := os.Getenv("STORAGE_PATH_STYLE"); raw != "" {
+		if c.pathStyle, err = strconv.ParseBool(raw); err != nil {
+			return nil, errclass.ConfigError(fmt.Errorf("invalid STORAGE_PATH_STYLE %q: %w", raw, err))
+		}
+	}
+	if raw := os.Getenv("STORAGE_RETENTION"); raw != "" {
+		if c.retention, err = ParseRetention(raw); err != nil {
+			return nil, errclass.ConfigError(fmt.Errorf("invalid STORAGE_RETENTION: %w", err))
+		}
+	}
+	return c, nil
+}
+
+// ParseRetention accepts a Go duration ("720h") or a number of days ("90d").
+func ParseRetention(raw string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days %q", raw)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("retention %q must not be negative", raw)
+	}
+	return d, nil
+}
+
+// Key joins parts below STORAGE_PREFIX, e.g. Key("exports", "a.csv") ->
+// "cron/exports/a.csv".
+func (c *Client) Key(parts ...string) string {
+	return path.Join(append([]string{c.prefix}, parts...)...)
+}
+
+// URL returns the s3:// address of key, for log messages.
+func (c *Client) URL(key string) string {
+	return "s3://" + c.bucket + "/" + key
+}
+
+// Retention is how long archived objects are kept; zero keeps them forever.
+func (c *Client) Retention() time.Duration {
+	return c.retention
+}
+
+// Put uploads body as key. size must be the exact body length.
+func (c *Client) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := c.newRequest(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return c.do(req, nil)
+}
+
+// PutFile uploads the file at localPath as key.
+func (c *Client) PutFile(ctx context.Context, key, localPath, contentType string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return c.Put(ctx, key, f, info.Size(), contentType)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, nil)
+}
+
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns every object whose key starts with prefix.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := c.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listResult
+		if err := c.do(req, &result); err != nil {
+			return nil, err
+		}
+		for _, obj := range result.Contents {
+			objects = append(objects, Object{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Prune deletes objects below prefix that are older than the configured
+// retention and returns how many were removed. It does nothing when no
+// retention is set.
+func (c *Client) Prune(ctx context.Context, prefix string, now time.Time) (int, error) {
+	if c.retention <= 0 {
+		return 0, nil
+	}
+	objects, err := c.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := now.Add(-c.retention)
+	deleted := 0
+	for _, obj := range objects {
+		if !obj.LastModified.Before(cutoff) {
+			continue
+		}
+		if err := c.Delete(ctx, obj.Key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *c.endpoint
+	objectPath := "/" + key
+	if c.pathStyle {
+		objectPath = "/" + c.bucket + objectPath
+	} else {
+		u.Host = c.bucket + "." + u.Host
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + objectPath
+	// The signed path must match the one sent byte for byte.
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("building storage request: %w", err)
+	}
+	c.sign(req, time.Now().UTC())
+	return req, nil
+}
+
+// do sends req and decodes an XML response into out if it is not nil.
+// Network errors, 5xx and 503 SlowDown are transient.
+func (c *Client) do(req *http.Request, out any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return errclass.TransientError(fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var s3Err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		detail := strings.TrimSpace(string(body))
+		if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+			detail = s3Err.Code + ": " + s3Err.Message
+		}
+		err := fmt.Errorf("%s %s returned %s: %s", req.Method, req.URL.Path, resp.Status, detail)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return errclass.TransientError(err)
+		}
+		return err
+	}
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding storage response: %w", err)
+	}
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header.
+func (c *Client) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + unsignedPayload + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), day)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query sorted by key with SigV4's escaping rules,
+// which the request URL must use as well.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything except unreserved characters, and
+// "/" unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
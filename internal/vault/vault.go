@@ -0,0 +1,324 @@
+// Package vault loads the database DSNs from a HashiCorp Vault KV secret
+// instead of plaintext environment variables.
+//
+// Each field of the secret is exported into the process environment under
+// its own name (MYSQL_DSN, ERP_DSN, ORACLE_DSN_GC, ...), so the rest of the
+// code keeps reading os.Getenv and picks up rotated values on its next
+// connection.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRefreshInterval = 5 * time.Minute
+
+var envName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Client reads one secret with either a static token (VAULT_TOKEN) or an
+// AppRole login, and keeps the token alive.
+type Client struct {
+	addr      string
+	namespace string
+	path      string
+	roleID    string
+	secretID  string
+	mount     string
+	transit   string
+	refresh   time.Duration
+	http      *http.Client
+	logger    *slog.Logger
+
+	mu        sync.Mutex
+	token     string
+	ttl       time.Duration
+	renewable bool
+	lookedUp  bool
+}
+
+// NewClientFromEnv configures the client from VAULT_ADDR, VAULT_SECRET_PATH
+// (e.g. secret/data/go-cron-be for KV v2) and either VAULT_TOKEN or
+// VAULT_ROLE_ID plus VAULT_SECRET_ID. Optional: VAULT_NAMESPACE,
+// VAULT_APPROLE_MOUNT (default approle), VAULT_TRANSIT_KEY (default
+// transit/keys/go-cron-be), VAULT_CACERT and VAULT_REFRESH_INTERVAL
+// (default 5m). VAULT_SECRET_PATH may be empty when Vault is only used
+// through TransitDecrypt. It returns nil, nil when VAULT_ADDR is not set.
+func NewClientFromEnv(logger *slog.Logger) (*Client, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return nil, nil
+	}
+
+	c := &Client{
+		addr:      addr,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		path:      strings.Trim(os.Getenv("VAULT_SECRET_PATH"), "/"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		roleID:    os.Getenv("VAULT_ROLE_ID"),
+		secretID:  os.Getenv("VAULT_SECRET_ID"),
+		mount:     os.Getenv("VAULT_APPROLE_MOUNT"),
+		transit:   os.Getenv("VAULT_TRANSIT_KEY"),
+		refresh:   defaultRefreshInterval,
+		logger:    logger.WithGroup("VAULT"),
+	}
+	if c.token == "" && (c.roleID == "" || c.secretID == "") {
+		return nil, errors.New("vault needs VAULT_TOKEN or VAULT_ROLE_ID and VAULT_SECRET_ID")
+	}
+	if c.mount == "" {
+		c.mount = "approle"
+	}
+	if c.transit == "" {
+		c.transit = "transit/keys/go-cron-be"
+	}
+	if raw := os.Getenv("VAULT_REFRESH_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 10*time.Second {
+			return nil, fmt.Errorf("VAULT_REFRESH_INTERVAL must be a duration of at least 10s, got %q", raw)
+		}
+		c.refresh = d
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile := os.Getenv("VAULT_CACERT"); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading VAULT_CACERT: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("VAULT_CACERT %s contains no certificates", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	c.http = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	return c, nil
+}
+
+// ReadsSecrets reports whether VAULT_SECRET_PATH is configured.
+func (c *Client) ReadsSecrets() bool {
+	return c.path != ""
+}
+
+// Load authenticates if needed, reads the secret and exports its fields into
+// the environment. It returns the names that were set, never the values.
+func (c *Client) Load(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.path, nil, &resp); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", c.path, err)
+	}
+
+	// KV v2 nests the fields under data.data next to data.metadata.
+	fields := resp.Data
+	if nested, ok := resp.Data["data"].(map[string]any); ok {
+		if _, v2 := resp.Data["metadata"]; v2 {
+			fields = nested
+		}
+	}
+
+	var keys []string
+	for key, value := range fields {
+		s, ok := value.(string)
+		if !ok || !envName.MatchString(key) {
+			continue
+		}
+		if err := os.Setenv(key, s); err != nil {
+			return nil, fmt.Errorf("setting %s: %w", key, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("secret %s has no string fields named like environment variables", c.path)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// TransitDecrypt unwraps ciphertext ("vault:v1:...") with the transit key
+// and returns the base64 plaintext, e.g. a data key from transit/datakey.
+func (c *Client) TransitDecrypt(ctx context.Context, ciphertext string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.authenticate(ctx); err != nil {
+		return "", err
+	}
+
+	// VAULT_TRANSIT_KEY names the key as <mount>/keys/<name>.
+	mount, name, ok := strings.Cut(c.transit, "/keys/")
+	if !ok || mount == "" || name == "" {
+		return "", fmt.Errorf("VAULT_TRANSIT_KEY must look like transit/keys/<name>, got %q", c.transit)
+	}
+	body, _ := json.Marshal(map[string]string{"ciphertext": ciphertext})
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodPost, mount+"/decrypt/"+name, body, &resp); err != nil {
+		return "", fmt.Errorf("transit decrypt with %s: %w", c.transit, err)
+	}
+	return resp.Data.Plaintext, nil
+}
+
+// Run renews the token and re-reads the secret until ctx is done, so
+// rotated credentials reach new connections without a restart.
+func (c *Client) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.nextRefresh()):
+		}
+
+		if err := c.renew(ctx); err != nil {
+			c.logger.Warn("Failed to renew Vault token", "error", err)
+		}
+		if _, err := c.Load(ctx); err != nil {
+			c.logger.Error("Failed to refresh secrets from Vault", "error", err)
+		}
+	}
+}
+
+// nextRefresh is the refresh interval, shortened to half the token TTL so a
+// short-lived token is renewed before it expires.
+func (c *Client) nextRefresh() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl > 0 && c.ttl/2 < c.refresh {
+		return max(c.ttl/2, 5*time.Second)
+	}
+	return c.refresh
+}
+
+// authenticate logs in with AppRole when there is no token yet, or looks up
+// a static token's TTL the first time it is used.
+func (c *Client) authenticate(ctx context.Context) error {
+	if c.token == "" {
+		return c.login(ctx)
+	}
+	if c.lookedUp {
+		return nil
+	}
+
+	var resp struct {
+		Data struct {
+			TTL       int  `json:"ttl"`
+			Renewable bool `json:"renewable"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "auth/token/lookup-self", nil, &resp); err != nil {
+		return fmt.Errorf("looking up token: %w", err)
+	}
+	c.ttl = time.Duration(resp.Data.TTL) * time.Second
+	c.renewable = resp.Data.Renewable
+	c.lookedUp = true
+	return nil
+}
+
+func (c *Client) login(ctx context.Context) error {
+	body, _ := json.Marshal(map[string]string{"role_id": c.roleID, "secret_id": c.secretID})
+	c.token = ""
+	var resp authResponse
+	if err := c.do(ctx, http.MethodPost, "auth/"+c.mount+"/login", body, &resp); err != nil {
+		return fmt.Errorf("approle login: %w", err)
+	}
+	c.setAuth(resp)
+	return nil
+}
+
+// renew extends the token's lease. A token that cannot be renewed any more
+// is replaced by a fresh AppRole login when one is configured.
+func (c *Client) renew(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.renewable {
+		var resp authResponse
+		err := c.do(ctx, http.MethodPost, "auth/token/renew-self", []byte("{}"), &resp)
+		if err == nil {
+			c.setAuth(resp)
+			return nil
+		}
+		if c.roleID == "" {
+			return err
+		}
+		c.logger.Info("Token renewal failed, logging in again", "error", err)
+	}
+	if c.roleID == "" {
+		return nil
+	}
+	return c.login(ctx)
+}
+
+type authResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+func (c *Client) setAuth(resp authResponse) {
+	c.token = resp.Auth.ClientToken
+	c.ttl = time.Duration(resp.Auth.LeaseDuration) * time.Second
+	c.renewable = resp.Auth.Renewable
+	c.lookedUp = true
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var apiErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(raw, &apiErr) == nil && len(apiErr.Errors) > 0 {
+			return fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(apiErr.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
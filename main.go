@@ -3,15 +3,32 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"hotbrandon/go-cron-be/internal/api"
+	"hotbrandon/go-cron-be/internal/database"
+	"hotbrandon/go-cron-be/internal/events"
+	"hotbrandon/go-cron-be/internal/lock"
+	"hotbrandon/go-cron-be/internal/metrics"
+	"hotbrandon/go-cron-be/internal/notify"
+	"hotbrandon/go-cron-be/internal/pidfile"
 	"hotbrandon/go-cron-be/internal/scheduler"
+	"hotbrandon/go-cron-be/internal/secret"
+	"hotbrandon/go-cron-be/internal/storage"
+	"hotbrandon/go-cron-be/internal/systemd"
+	"hotbrandon/go-cron-be/internal/vault"
+	"io"
 	"log"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 )
 
@@ -20,8 +37,9 @@ func showEnvironments(logger *slog.Logger) {
 
 	logger.Info("Environment variables",
 		"TZ", os.Getenv("TZ"),
-		"ERP_DSN", os.Getenv("ERP_DSN"),
-		"MYSQL_DSN", os.Getenv("MYSQL_DSN"),
+		"ERP_DSN", secret.DSN(os.Getenv("ERP_DSN")),
+		"MYSQL_DSN", secret.DSN(os.Getenv("MYSQL_DSN")),
+		"MYSQL_READ_DSN", secret.DSN(os.Getenv("MYSQL_READ_DSN")),
 	)
 }
 
@@ -32,6 +50,28 @@ func main() {
 		log.Println("Warning: .env not loaded:", err)
 	}
 
+	logger := newLogger(os.Stdout)
+	slog.SetDefault(logger)
+
+	// CLI subcommands run standalone and do not need the databases
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// graceful shutdown on signals
+	stop := make(chan struct{})
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCh
+		close(stop)
+	}()
+
+	os.Exit(serve(logger, stop, func() {}))
+}
+
+// newLogger builds the process logger writing to w at LOG_LEVEL.
+func newLogger(w io.Writer) *slog.Logger {
 	var logLevel slog.Level
 	switch os.Getenv("LOG_LEVEL") {
 	case "DEBUG":
@@ -48,33 +88,80 @@ func main() {
 		// Set the minimum log level. Anything below this level will be discarded.
 		Level: logLevel,
 	}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, handlerOpts))
-	slog.SetDefault(logger)
+	return slog.New(slog.NewTextHandler(w, handlerOpts))
+}
+
+// serve runs the scheduler and API server until stop is closed and returns
+// the process exit code. ready is called once the scheduler is running.
+func serve(logger *slog.Logger, stop <-chan struct{}, ready func()) int {
+	// refuse to double-create jobs when a second copy is started on this host
+	if path := os.Getenv("PID_FILE"); path != "" {
+		pid, err := pidfile.Acquire(path)
+		if err != nil {
+			logger.Error("Refusing to start", "error", err)
+			return 1
+		}
+		defer pid.Release()
+	}
+
+	// DSNs may come from Vault instead of plaintext env vars
+	vaultClient, err := vault.NewClientFromEnv(logger)
+	if err != nil {
+		logger.Error("Invalid Vault configuration", "error", err)
+		return 1
+	}
 
-	mysqlDsn := os.Getenv("MYSQL_DSN")
-	if mysqlDsn == "" {
-		slog.Error("MYSQL_DSN environment variable is not set")
-		os.Exit(1)
+	// sites that forbid plaintext passwords on disk store enc:v1: values
+	decrypted, err := decryptConfig(vaultClient)
+	if err != nil {
+		logger.Error("Failed to decrypt configuration", "error", err)
+		return 1
+	}
+	if len(decrypted) > 0 {
+		logger.Info("Decrypted configuration values", "keys", decrypted)
 	}
 
-	erpDsn := os.Getenv("ERP_DSN")
-	if erpDsn == "" {
-		slog.Error("ERP_DSN environment variable is not set")
-		os.Exit(1)
+	if vaultClient != nil && vaultClient.ReadsSecrets() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		keys, err := vaultClient.Load(ctx)
+		cancel()
+		if err != nil {
+			logger.Error("Failed to load secrets from Vault", "error", err)
+			return 1
+		}
+		logger.Info("Loaded secrets from Vault", "keys", keys)
+
+		vaultCtx, stopVault := context.WithCancel(context.Background())
+		defer stopVault()
+		go vaultClient.Run(vaultCtx)
+	}
+
+	// report every configuration mistake at once rather than the first, or
+	// the one a job trips over hours later
+	if errs := validateConfig(logger); len(errs) > 0 {
+		printConfigErrors(os.Stderr, errs)
+		return 1
 	}
 
 	showEnvironments(logger)
 
-	// Connect to the MySQL database
-	db, err := sql.Open("mysql", mysqlDsn)
+	if err := database.SlowQueryThresholdFromEnv(); err != nil {
+		slog.Error("Invalid slow query configuration", "error", err)
+		return 1
+	}
+
+	db, err := openMySQL("MYSQL_DSN")
 	if err != nil {
 		slog.Error("Error opening database", "error", err)
-		os.Exit(1)
+		return 1
 	}
 
-	db.SetMaxOpenConns(2)
-	db.SetMaxIdleConns(2)
-	db.SetConnMaxLifetime(time.Minute * 60)
+	pool, err := database.ParsePoolSettings(os.Getenv("MYSQL_POOL"), database.DefaultMySQLPool)
+	if err != nil {
+		slog.Error("Invalid MYSQL_POOL", "error", err)
+		return 1
+	}
+	pool.Apply(db)
 
 	// verify DB is reachable
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -82,7 +169,7 @@ func main() {
 	if err := db.PingContext(ctx); err != nil {
 		logger.Error("Error pinging DB", "error", err)
 		_ = db.Close()
-		os.Exit(1)
+		return 1
 	}
 
 	// Ensure DB closed on exit
@@ -92,22 +179,355 @@ func main() {
 		}
 	}()
 
-	sched := scheduler.NewScheduler(db, logger)
+	var readDB *sql.DB
+	if os.Getenv("MYSQL_READ_DSN") != "" {
+		readDB, err = openMySQL("MYSQL_READ_DSN")
+		if err != nil {
+			slog.Error("Error opening read replica", "error", err)
+			return 1
+		}
+		pool.Apply(readDB)
+		defer readDB.Close()
+		// A replica that is down at startup is not fatal; reads report errors until it is back
+		if err := readDB.PingContext(ctx); err != nil {
+			logger.Warn("Error pinging read replica", "error", err)
+		}
+	}
+
+	notifier, err := notify.FromEnv(logger)
+	if err != nil {
+		logger.Error("Invalid notification configuration", "error", err)
+		return 1
+	}
+
+	publishers, err := events.FromEnv(notifier)
+	if err != nil {
+		logger.Error("Invalid event publisher configuration", "error", err)
+		return 1
+	}
+
+	sink, prom, err := metrics.FromEnv()
+	if err != nil {
+		logger.Error("Invalid metrics configuration", "error", err)
+		return 1
+	}
+
+	opts := []scheduler.Option{scheduler.WithNotifier(notifier), scheduler.WithPublishers(publishers...), scheduler.WithMetrics(sink)}
+	if readDB != nil {
+		opts = append(opts, scheduler.WithReadReplica(readDB))
+	}
+
+	locker, err := lock.FromEnv(db)
+	if err != nil {
+		logger.Error("Invalid lock configuration", "error", err)
+		return 1
+	}
+	opts = append(opts, scheduler.WithLocker(locker))
+
+	switch mode := os.Getenv("LEADER_ELECTION"); mode {
+	case "":
+	case "kubernetes":
+		elector, err := lock.NewKubernetesLockerFromEnv()
+		if err != nil {
+			logger.Error("Invalid leader election configuration", "error", err)
+			return 1
+		}
+		opts = append(opts, scheduler.WithLeaderElection(elector))
+	default:
+		logger.Error("Unknown LEADER_ELECTION mode", "mode", mode)
+		return 1
+	}
+
+	store, err := storage.NewClientFromEnv()
+	if err != nil {
+		logger.Error("Invalid object storage configuration", "error", err)
+		return 1
+	}
+	if store != nil {
+		opts = append(opts, scheduler.WithStorage(store))
+	}
+
+	tlsConfig, err := api.TLSConfigFromEnv()
+	if err != nil {
+		logger.Error("Invalid API TLS configuration", "error", err)
+		return 1
+	}
+	cors, err := api.CORSFromEnv()
+	if err != nil {
+		logger.Error("Invalid API CORS configuration", "error", err)
+		return 1
+	}
+	rateLimit, err := api.RateLimitFromEnv()
+	if err != nil {
+		logger.Error("Invalid API rate limit configuration", "error", err)
+		return 1
+	}
+	tenantAccess, err := api.TenantAccessFromEnv(tlsConfig)
+	if err != nil {
+		logger.Error("Invalid API tenant access configuration", "error", err)
+		return 1
+	}
+	auth, err := api.AuthFromEnv(tlsConfig)
+	if err != nil {
+		logger.Error("Invalid API authentication configuration", "error", err)
+		return 1
+	}
+	if auth == nil {
+		logger.Warn("API changes are disabled: set API_TLS_CLIENT_CA or API_TRIGGER_TOKEN to trigger jobs or manage schedules, scripts and webhooks")
+	}
+
+	sched := scheduler.NewScheduler(db, logger, opts...)
 
 	// Start the scheduler (this will register jobs and start the cron)
 	if err := sched.Start(); err != nil {
 		slog.Error("Failed to start scheduler", "error", err)
-		os.Exit(1)
+		return 1
 	}
+	// Deferred first, so held-back notifications go out once the scheduler
+	// and event relay have stopped adding to them.
+	defer notify.Close(notifier)
+	defer events.Close(publishers)
 	defer sched.Stop()
 
-	// Optional: Show scheduled entries for debugging
-	// sched.ShowEntries()
+	// GET /debug/vars shows it next to the runtime's memstats and cmdline
+	expvar.Publish("scheduler", expvar.Func(sched.Vars))
 
-	// graceful shutdown on signals
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	// MySQL answered the ping and the scheduler is running
+	if _, err := systemd.Notify("READY=1"); err != nil {
+		logger.Warn("Failed to notify systemd", "error", err)
+	}
+	if interval, ok := systemd.WatchdogInterval(); ok {
+		go watchdog(sched, interval, logger)
+	}
+	ready()
+
+	apiAddr := apiAddr()
+	apiServer := api.NewServer(sched, logger)
+	apiServer.EnableWrites(auth)
+	if prom != nil {
+		apiServer.Handle("GET /metrics", prom)
+	}
+	handler := apiServer.Handler()
+	if rateLimit != nil {
+		handler = rateLimit(handler)
+	}
+	if tenantAccess != nil {
+		handler = tenantAccess(handler)
+	}
+	if tlsConfig != nil && tlsConfig.ClientCAs != nil {
+		handler = api.RequireClientCert(handler)
+	}
+	handler = api.RequestID(handler)
+	if cors != nil {
+		// outermost, so preflights are answered before any other check
+		handler = cors(handler)
+	}
+	srv := &http.Server{
+		Addr:              apiAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+	go func() {
+		logger.Info("API server listening", "addr", apiAddr, "tls", tlsConfig != nil, "mtls", tlsConfig != nil && tlsConfig.ClientCAs != nil)
+		var err error
+		if tlsConfig != nil {
+			// certificates come from TLSConfig.GetCertificate
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("API server failed", "error", err)
+		}
+	}()
+
+	<-stop
 
 	logger.Info("Shutdown signal received, exiting")
+	systemd.Notify("STOPPING=1")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("Failed to shut down API server", "error", err)
+	}
+	return 0
+}
+
+// decryptConfig replaces the enc:v1: values in the environment with their
+// plaintext, see the encrypt-config command.
+func decryptConfig(vaultClient *vault.Client) ([]string, error) {
+	if len(secret.EncryptedEnv()) == 0 {
+		return nil, nil
+	}
+	key, err := configKey(vaultClient)
+	if err != nil {
+		return nil, err
+	}
+	return secret.DecryptEnv(key)
+}
+
+// validateConfig checks the whole configuration without connecting to
+// anything: DSNs, TZ, pool settings, notification channels, event
+// publishers, object storage, the API middleware and everything the
+// scheduler reads, including its cron expressions and Oracle DSNs.
+func validateConfig(logger *slog.Logger) []error {
+	var errs []error
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if os.Getenv("MYSQL_DSN") == "" {
+		add(errors.New("MYSQL_DSN is not set"))
+	} else if _, err := mysql.ParseDSN(os.Getenv("MYSQL_DSN")); err != nil {
+		add(fmt.Errorf("invalid MYSQL_DSN: %w", err))
+	}
+	if dsn := os.Getenv("MYSQL_READ_DSN"); dsn != "" {
+		if _, err := mysql.ParseDSN(dsn); err != nil {
+			add(fmt.Errorf("invalid MYSQL_READ_DSN: %w", err))
+		}
+	}
+	// an unknown TZ silently falls back to UTC
+	if tz := os.Getenv("TZ"); tz != "" {
+		if _, err := time.LoadLocation(strings.TrimPrefix(tz, ":")); err != nil {
+			add(fmt.Errorf("invalid TZ: %w", err))
+		}
+	}
+	if _, err := database.ParsePoolSettings(os.Getenv("MYSQL_POOL"), database.DefaultMySQLPool); err != nil {
+		add(fmt.Errorf("invalid MYSQL_POOL: %w", err))
+	}
+	add(database.SlowQueryThresholdFromEnv())
+
+	notifier, err := notify.FromEnv(logger)
+	add(err)
+	if err == nil {
+		_, err = events.FromEnv(notifier)
+		add(err)
+	}
+	_, _, err = metrics.FromEnv()
+	add(err)
+	_, err = metrics.PushgatewayFromEnv()
+	add(err)
+	_, err = lock.FromEnv(nil)
+	add(err)
+	switch mode := os.Getenv("LEADER_ELECTION"); mode {
+	case "":
+	case "kubernetes":
+		_, err = lock.NewKubernetesLockerFromEnv()
+		add(err)
+	default:
+		add(fmt.Errorf("unknown LEADER_ELECTION mode %q", mode))
+	}
+	_, err = storage.NewClientFromEnv()
+	add(err)
+
+	tlsConfig, err := api.TLSConfigFromEnv()
+	add(err)
+	_, err = api.AuthFromEnv(tlsConfig)
+	add(err)
+	_, err = api.CORSFromEnv()
+	add(err)
+	_, err = api.RateLimitFromEnv()
+	add(err)
+	_, err = api.TenantAccessFromEnv(tlsConfig)
+	add(err)
+
+	return append(errs, scheduler.NewScheduler(nil, logger).ValidateConfig()...)
+}
+
+// printConfigErrors writes the report of validateConfig to w.
+func printConfigErrors(w io.Writer, errs []error) {
+	fmt.Fprintf(w, "Invalid configuration (%d problems):\n", len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(w, "  - %v\n", err)
+	}
+}
+
+// configKey returns the AES key for encrypted config values: CONFIG_KEY or
+// CONFIG_KEY_FILE, or CONFIG_KEY_WRAPPED unwrapped by Vault transit so the
+// key itself never sits on disk in plaintext.
+func configKey(vaultClient *vault.Client) ([]byte, error) {
+	if wrapped := os.Getenv("CONFIG_KEY_WRAPPED"); wrapped != "" {
+		if vaultClient == nil {
+			return nil, errors.New("CONFIG_KEY_WRAPPED needs VAULT_ADDR to unwrap it")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		plaintext, err := vaultClient.TransitDecrypt(ctx, wrapped)
+		if err != nil {
+			return nil, err
+		}
+		return secret.ParseKey(plaintext)
+	}
+
+	key, err := secret.KeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("encrypted values need CONFIG_KEY, CONFIG_KEY_FILE or CONFIG_KEY_WRAPPED")
+	}
+	return key, nil
+}
+
+// openMySQL opens the MySQL DSN in environment variable key. DATETIME
+// columns are scanned into time.Time, and new connections take the current
+// credentials from key, which Vault may rotate.
+func openMySQL(key string) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(os.Getenv(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	if err := cfg.Apply(mysql.BeforeConnect(refreshMySQLCredentials(key))); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(database.WithSlowLog(connector, database.KindMySQL, strings.ToLower(strings.TrimSuffix(key, "_DSN")))), nil
+}
+
+// refreshMySQLCredentials copies the user and password of the current DSN in
+// key into cfg before each new connection.
+func refreshMySQLCredentials(key string) func(ctx context.Context, cfg *mysql.Config) error {
+	return func(ctx context.Context, cfg *mysql.Config) error {
+		current, err := mysql.ParseDSN(os.Getenv(key))
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", key, err)
+		}
+		cfg.User, cfg.Passwd = current.User, current.Passwd
+		return nil
+	}
+}
+
+// apiAddr is the admin API listen address from API_ADDR.
+func apiAddr() string {
+	if addr := os.Getenv("API_ADDR"); addr != "" {
+		return addr
+	}
+	return ":8005"
+}
+
+// watchdog pings the systemd watchdog while the scheduler's cron loop keeps
+// ticking. If the loop stalls the pings stop and systemd restarts the service.
+func watchdog(sched *scheduler.Scheduler, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !sched.Alive(time.Minute) {
+			logger.Error("Scheduler loop stalled, withholding watchdog ping")
+			continue
+		}
+		if _, err := systemd.Notify("WATCHDOG=1"); err != nil {
+			logger.Warn("Failed to ping systemd watchdog", "error", err)
+		}
+	}
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const serviceUsage = "usage: go-cron-be service install|uninstall|run"
+
+// serviceName is the Windows service name. SERVICE_NAME overrides it so a
+// test and a production instance can be installed on the same host.
+func serviceName() string {
+	if name := os.Getenv("SERVICE_NAME"); name != "" {
+		return name
+	}
+	return "go-cron-be"
+}
+
+// serviceCommand installs, removes or runs the scheduler as a Windows
+// service. "run" is what the service control manager invokes; it is not
+// meant to be started from a console.
+func serviceCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, serviceUsage)
+		return 2
+	}
+
+	name := serviceName()
+	var err error
+	switch args[0] {
+	case "install":
+		err = installService(name)
+	case "uninstall":
+		err = uninstallService(name)
+	case "run":
+		err = runService(name)
+	default:
+		fmt.Fprintln(os.Stderr, serviceUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
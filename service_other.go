@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+var errServiceUnsupported = errors.New("service commands are only supported on Windows; use systemd or the container image elsewhere")
+
+func installService(name string) error { return errServiceUnsupported }
+
+func uninstallService(name string) error { return errServiceUnsupported }
+
+func runService(name string) error { return errServiceUnsupported }
@@ -0,0 +1,210 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/joho/godotenv"
+)
+
+// The service control manager API is called through advapi32 directly; it
+// is small enough that golang.org/x/sys/windows/svc is not worth the
+// dependency.
+var (
+	advapi32                          = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+)
+
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped      = 1
+	serviceStartPending = 2
+	serviceStopPending  = 3
+	serviceRunning      = 4
+
+	serviceAcceptStop     = 0x1
+	serviceAcceptShutdown = 0x4
+
+	serviceControlStop     = 1
+	serviceControlShutdown = 5
+
+	errorServiceSpecificError = 1066
+
+	// serviceWaitHint is how long the SCM should wait on a pending start or
+	// stop before assuming the service hung. Stop waits for running jobs.
+	serviceWaitHint = 60_000
+)
+
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// serviceStatus mirrors SERVICE_STATUS.
+type serviceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+// installService registers the current executable with "service run" as an
+// automatically started service that restarts after a crash.
+func installService(name string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+	binPath := fmt.Sprintf(`"%s" service run`, exe)
+
+	if err := sc("create", name, "binPath=", binPath, "start=", "delayed-auto", "DisplayName=", "Go Cron Scheduler ("+name+")"); err != nil {
+		return err
+	}
+	if err := sc("description", name, "Runs the go-cron-be job scheduler and API"); err != nil {
+		return err
+	}
+	// Restart after 1 minute on the first three failures; reset the count daily.
+	if err := sc("failure", name, "reset=", "86400", "actions=", "restart/60000/restart/60000/restart/60000"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Installed service %s (%s)\n", name, binPath)
+	fmt.Printf("The .env file and logs are read from and written to %s\n", filepath.Dir(exe))
+	fmt.Printf("Start it with: sc.exe start %s\n", name)
+	return nil
+}
+
+// uninstallService stops the service if it is running and removes it.
+func uninstallService(name string) error {
+	// Stopping fails when the service is not running, which is fine.
+	_ = sc("stop", name)
+	if err := sc("delete", name); err != nil {
+		return err
+	}
+	fmt.Printf("Removed service %s\n", name)
+	return nil
+}
+
+func sc(args ...string) error {
+	out, err := exec.Command("sc.exe", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sc.exe %s %s: %w: %s", args[0], args[1], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// windowsService holds the state shared between the SCM callbacks, which
+// Windows invokes on its own threads.
+type windowsService struct {
+	name   *uint16
+	logger *slog.Logger
+	handle uintptr
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	exitCode int
+}
+
+// runService hands the process to the service control manager, which calls
+// back into main and control until the service stops.
+func runService(name string) error {
+	// The SCM starts services in %WINDIR%\System32; keep .env, exports and
+	// the log next to the binary instead.
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+	if err := os.Chdir(filepath.Dir(exe)); err != nil {
+		return fmt.Errorf("changing to %s: %w", filepath.Dir(exe), err)
+	}
+	_ = godotenv.Load(".env")
+
+	// A service has no console, so log to LOG_FILE (default <name>.log).
+	logPath := os.Getenv("LOG_FILE")
+	if logPath == "" {
+		logPath = name + ".log"
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	defer logFile.Close()
+	logger := newLogger(logFile)
+	slog.SetDefault(logger)
+
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	s := &windowsService{name: namePtr, logger: logger, stop: make(chan struct{})}
+
+	table := []serviceTableEntry{{name: namePtr, proc: syscall.NewCallback(s.main)}, {}}
+	if r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		return fmt.Errorf("connecting to the service control manager (service run is only for the SCM): %w", err)
+	}
+	if s.exitCode != 0 {
+		return fmt.Errorf("service exited with code %d", s.exitCode)
+	}
+	return nil
+}
+
+// main is the ServiceMain callback. It runs the scheduler until a stop or
+// shutdown control arrives.
+func (s *windowsService) main(argc, argv uintptr) uintptr {
+	handle, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(s.name)), syscall.NewCallback(s.control), 0)
+	if handle == 0 {
+		s.logger.Error("Failed to register service control handler", "error", err)
+		s.exitCode = 1
+		return 0
+	}
+	s.handle = handle
+
+	s.setStatus(serviceStartPending, 0)
+	s.logger.Info("Windows service starting")
+	s.exitCode = serve(s.logger, s.stop, func() { s.setStatus(serviceRunning, 0) })
+	s.setStatus(serviceStopped, s.exitCode)
+	return 0
+}
+
+// control is the HandlerEx callback. It must return quickly, so it only
+// signals serve to shut down.
+func (s *windowsService) control(ctrl, eventType, eventData, context uintptr) uintptr {
+	switch ctrl {
+	case serviceControlStop, serviceControlShutdown:
+		s.setStatus(serviceStopPending, 0)
+		s.stopOnce.Do(func() { close(s.stop) })
+	}
+	return 0 // NO_ERROR, which is also the answer to SERVICE_CONTROL_INTERROGATE
+}
+
+func (s *windowsService) setStatus(state uint32, exitCode int) {
+	status := serviceStatus{serviceType: serviceWin32OwnProcess, currentState: state}
+	switch state {
+	case serviceRunning:
+		status.controlsAccepted = serviceAcceptStop | serviceAcceptShutdown
+	case serviceStartPending, serviceStopPending:
+		status.waitHint = serviceWaitHint
+	}
+	if exitCode != 0 {
+		status.win32ExitCode = errorServiceSpecificError
+		status.serviceSpecificExitCode = uint32(exitCode)
+	}
+	if r, _, err := procSetServiceStatus.Call(s.handle, uintptr(unsafe.Pointer(&status))); r == 0 {
+		s.logger.Warn("Failed to report service status", "state", state, "error", err)
+	}
+}